@@ -0,0 +1,59 @@
+// mp-emulator levanta un doble local de la API de MercadoPago para probar el checkout, el webhook
+// y los reembolsos sin salir a internet. La app se apunta con MP_BASE_URL y los webhooks se firman
+// con MP_WEBHOOK_SECRET, igual que en producción.
+//
+// Uso:
+//
+//	go run ./cmd/mp-emulator -addr :8090
+//	MP_BASE_URL=http://localhost:8090 MP_ACCESS_TOKEN=TEST-local go run ./cmd/tienda3d
+//
+// El checkout (init_point) muestra botones para aprobar, rechazar o dejar pendiente el pago. Para
+// scripts: POST /emulator/payments {"external_reference":"<orderID>","status":"approved"} y
+// POST /emulator/payments/{id}/status {"status":"approved"}; GET /emulator/webhooks lista los
+// webhooks enviados.
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog"
+	zlog "github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/adapters/payments/mercadopago/mpemu"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	zerolog.TimeFieldFormat = time.RFC3339
+	zlog.Logger = zlog.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.Kitchen})
+
+	addr := flag.String("addr", envOr("MP_EMULATOR_ADDR", ":8090"), "dirección donde escucha el emulador")
+	public := flag.String("public-url", os.Getenv("MP_EMULATOR_PUBLIC_URL"), "URL del emulador que ve el navegador (default: la del request)")
+	notify := flag.String("notify-url", os.Getenv("MP_EMULATOR_NOTIFY_URL"), "reemplaza la notification_url de las preferencias (ej. http://app:8080/webhooks/mp)")
+	secret := flag.String("secret", os.Getenv("MP_WEBHOOK_SECRET"), "clave para firmar los webhooks (x-signature)")
+	flag.Parse()
+
+	emu := mpemu.New(*secret)
+	emu.PublicURL = *public
+	emu.NotifyURL = *notify
+	if *secret == "" {
+		zlog.Warn().Msg("sin MP_WEBHOOK_SECRET: los webhooks salen sin x-signature")
+	}
+	srv := &http.Server{Addr: *addr, Handler: emu, ReadHeaderTimeout: 10 * time.Second}
+	zlog.Info().Str("addr", *addr).Msg("emulador de MercadoPago escuchando")
+	if err := srv.ListenAndServe(); err != nil {
+		zlog.Fatal().Err(err).Msg("emulador")
+	}
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
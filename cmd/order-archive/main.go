@@ -0,0 +1,128 @@
+// order-archive lista y restaura los archivos de órdenes que se guardan antes de borrar un
+// período desde /admin/orders (JSONL comprimido con gzip en el storage privado).
+//
+// Uso:
+//
+//	go run ./cmd/order-archive -list
+//	go run ./cmd/order-archive -restore <id del archivo>
+//	go run ./cmd/order-archive -file ordenes_2025-01-01_2025-01-31.jsonl.gz -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog"
+	zlog "github.com/rs/zerolog/log"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/phenrril/tienda3d/internal/app"
+	"github.com/phenrril/tienda3d/internal/domain"
+	"github.com/phenrril/tienda3d/internal/usecase"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	zerolog.TimeFieldFormat = time.RFC3339
+	zlog.Logger = zlog.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.Kitchen})
+
+	list := flag.Bool("list", false, "lista los archivos guardados")
+	restore := flag.String("restore", "", "id del archivo a restaurar")
+	file := flag.String("file", "", "restaura desde un .jsonl.gz descargado en vez del storage")
+	dry := flag.Bool("dry-run", false, "con -file: sólo lee el archivo e informa")
+	actor := flag.String("actor", "cli", "quién restaura (queda registrado en el archivo)")
+	flag.Parse()
+
+	if !*list && *restore == "" && *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// -file -dry-run no necesita la DB
+	var entries []domain.OrderArchiveEntry
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			zlog.Fatal().Err(err).Msg("abriendo archivo")
+		}
+		err = usecase.ReadOrderArchive(f, func(e *domain.OrderArchiveEntry) error {
+			entries = append(entries, *e)
+			return nil
+		})
+		f.Close()
+		if err != nil {
+			zlog.Fatal().Err(err).Str("file", *file).Msg("leyendo archivo")
+		}
+		if *dry {
+			for _, e := range entries {
+				fmt.Printf("%s\t%s\t%s\t%s\t%.2f\t%d items\n", e.Order.Code(), e.Order.CreatedAt.Format("2006-01-02 15:04"), e.Order.Email, e.Order.Status, e.Order.Total, len(e.Order.Items))
+			}
+			zlog.Info().Int("ordenes", len(entries)).Msg("dry-run: no se restauró nada")
+			return
+		}
+	}
+
+	db, err := gorm.Open(postgres.Open(dsnFromEnv()), &gorm.Config{})
+	if err != nil {
+		zlog.Fatal().Err(err).Msg("abriendo DB")
+	}
+	application, err := app.NewApp(db)
+	if err != nil {
+		zlog.Fatal().Err(err).Msg("init app")
+	}
+	uc := application.OrderArchiveUC
+	ctx := context.Background()
+
+	switch {
+	case *list:
+		archives, err := uc.List(ctx)
+		if err != nil {
+			zlog.Fatal().Err(err).Msg("listando archivos")
+		}
+		for _, a := range archives {
+			restored := "-"
+			if a.RestoredAt != nil {
+				restored = a.RestoredAt.Format("2006-01-02 15:04")
+			}
+			fmt.Printf("%s\t%s..%s\t%d órdenes\t%.2f\tarchivado %s\trestaurado %s\t%s\n", a.ID, a.PeriodFrom.Format("2006-01-02"), a.PeriodTo.Format("2006-01-02"), a.Orders, a.Total, a.CreatedAt.Format("2006-01-02 15:04"), restored, a.Path)
+		}
+	case *restore != "":
+		id, err := uuid.Parse(*restore)
+		if err != nil {
+			zlog.Fatal().Err(err).Msg("id inválido")
+		}
+		a, n, err := uc.Restore(ctx, id, *actor)
+		if err != nil {
+			zlog.Fatal().Err(err).Msg("restaurando")
+		}
+		zlog.Info().Str("archivo", a.ID.String()).Int("restauradas", n).Int("en_archivo", a.Orders).Msg("listo")
+	default:
+		n, err := uc.RestoreEntries(ctx, entries)
+		if err != nil {
+			zlog.Fatal().Err(err).Msg("restaurando")
+		}
+		zlog.Info().Str("file", *file).Int("restauradas", n).Int("en_archivo", len(entries)).Msg("listo")
+	}
+}
+
+func dsnFromEnv() string {
+	if dsn := os.Getenv("DB_DSN"); dsn != "" {
+		return dsn
+	}
+	get := func(k, def string) string {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+		return def
+	}
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
+		get("DB_HOST", "localhost"), get("DB_USER", "postgres"), get("DB_PASSWORD", "postgres"),
+		get("DB_NAME", "tienda3d"), get("DB_PORT", "5432"))
+}
@@ -0,0 +1,194 @@
+// storage-migrate copia los archivos subidos del disco local (STORAGE_DIR) a un bucket S3
+// y normaliza las rutas guardadas en la DB (images.url, uploaded_models.path) al formato
+// "/uploads/<sub>/<archivo>" que sirven ambos backends.
+//
+// Uso:
+//
+//	go run ./cmd/storage-migrate -dry-run
+//	go run ./cmd/storage-migrate -src uploads
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog"
+	zlog "github.com/rs/zerolog/log"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/phenrril/tienda3d/internal/adapters/storage/localfs"
+	"github.com/phenrril/tienda3d/internal/adapters/storage/s3"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	zerolog.TimeFieldFormat = time.RFC3339
+	zlog.Logger = zlog.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.Kitchen})
+
+	defSrc := os.Getenv("STORAGE_DIR")
+	if defSrc == "" {
+		defSrc = "uploads"
+	}
+	src := flag.String("src", defSrc, "directorio local de uploads")
+	dry := flag.Bool("dry-run", false, "no sube ni modifica la DB, sólo informa")
+	skipDB := flag.Bool("skip-db", false, "no normaliza rutas en la DB")
+	flag.Parse()
+
+	dst, err := s3.New(s3.ConfigFromEnv())
+	if err != nil {
+		zlog.Fatal().Err(err).Msg("config s3")
+	}
+	local := localfs.New(*src)
+	ctx := context.Background()
+
+	uploaded, skipped, failed := 0, 0, 0
+	for _, sub := range []string{"images", "models", "attachments", "config"} {
+		root := filepath.Join(*src, sub)
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			key, ok := local.Key(p)
+			if !ok {
+				return nil
+			}
+			if exists, err := dst.Exists(ctx, key); err == nil && exists {
+				skipped++
+				return nil
+			}
+			if *dry {
+				zlog.Info().Str("key", key).Msg("subiría")
+				uploaded++
+				return nil
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				zlog.Error().Err(err).Str("file", p).Msg("leyendo archivo")
+				failed++
+				return nil
+			}
+			if err := dst.Put(ctx, key, data, ""); err != nil {
+				zlog.Error().Err(err).Str("key", key).Msg("subiendo archivo")
+				failed++
+				return nil
+			}
+			uploaded++
+			return nil
+		})
+		if err != nil {
+			zlog.Fatal().Err(err).Str("dir", root).Msg("recorriendo uploads")
+		}
+	}
+	zlog.Info().Int("subidos", uploaded).Int("existentes", skipped).Int("errores", failed).Bool("dry_run", *dry).Msg("archivos")
+
+	if *skipDB {
+		return
+	}
+	db, err := gorm.Open(postgres.Open(dsnFromEnv()), &gorm.Config{})
+	if err != nil {
+		zlog.Fatal().Err(err).Msg("abriendo DB")
+	}
+	imgs, err := normalizeImages(db, local, *dry)
+	if err != nil {
+		zlog.Fatal().Err(err).Msg("normalizando images")
+	}
+	models, err := normalizeModels(db, local, *dry)
+	if err != nil {
+		zlog.Fatal().Err(err).Msg("normalizando uploaded_models")
+	}
+	zlog.Info().Int("images", imgs).Int("models", models).Bool("dry_run", *dry).Msg("rutas normalizadas")
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// canonicalPath devuelve "/uploads/<clave>" para rutas locales; las URLs externas no se tocan.
+func canonicalPath(local *localfs.Storage, p string) (string, bool) {
+	low := strings.ToLower(strings.TrimSpace(p))
+	if low == "" || strings.HasPrefix(low, "http://") || strings.HasPrefix(low, "https://") {
+		return "", false
+	}
+	key, ok := local.Key(p)
+	if !ok {
+		return "", false
+	}
+	c := "/uploads/" + key
+	return c, c != p
+}
+
+func normalizeImages(db *gorm.DB, local *localfs.Storage, dry bool) (int, error) {
+	var list []domain.Image
+	if err := db.Select("id", "url").Find(&list).Error; err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, im := range list {
+		c, changed := canonicalPath(local, im.URL)
+		if !changed {
+			continue
+		}
+		n++
+		if dry {
+			fmt.Printf("image %s: %s -> %s\n", im.ID, im.URL, c)
+			continue
+		}
+		if err := db.Model(&domain.Image{}).Where("id = ?", im.ID).Update("url", c).Error; err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func normalizeModels(db *gorm.DB, local *localfs.Storage, dry bool) (int, error) {
+	var list []domain.UploadedModel
+	if err := db.Select("id", "path").Find(&list).Error; err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, m := range list {
+		c, changed := canonicalPath(local, m.Path)
+		if !changed {
+			continue
+		}
+		n++
+		if dry {
+			fmt.Printf("model %s: %s -> %s\n", m.ID, m.Path, c)
+			continue
+		}
+		if err := db.Model(&domain.UploadedModel{}).Where("id = ?", m.ID).Update("path", c).Error; err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func dsnFromEnv() string {
+	if dsn := os.Getenv("DB_DSN"); dsn != "" {
+		return dsn
+	}
+	get := func(k, def string) string {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+		return def
+	}
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
+		get("DB_HOST", "localhost"), get("DB_USER", "postgres"), get("DB_PASSWORD", "postgres"),
+		get("DB_NAME", "tienda3d"), get("DB_PORT", "5432"))
+}
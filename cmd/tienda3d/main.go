@@ -83,6 +83,11 @@ func main() {
 	digestCtx, digestCancel := context.WithCancel(context.Background())
 	defer digestCancel()
 	application.RunWorkshopDigestLoop(digestCtx)
+	application.RunStorageGCLoop(digestCtx)
+	application.RunOrderExpiryLoop(digestCtx)
+	application.RunShipmentTrackingLoop(digestCtx)
+	application.RunPaymentReconcileLoop(digestCtx)
+	application.RunWebhookInboxLoop(digestCtx)
 
 	// Iniciar scheduler de backup
 	go func() {
@@ -7,6 +7,7 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/robfig/cron/v3 v3.0.1
 	github.com/rs/zerolog v1.32.0
+	golang.org/x/image v0.36.0
 	golang.org/x/oauth2 v0.36.0
 	google.golang.org/api v0.271.0
 	gopkg.in/gomail.v2 v2.0.0-20160411212932-81ebce5c23df
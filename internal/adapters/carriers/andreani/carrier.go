@@ -0,0 +1,233 @@
+// Package andreani cotiza y da de alta envíos con la API de Andreani (apis.andreani.com).
+// Necesita usuario/contraseña de la API, número de cliente y contrato.
+package andreani
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+const defaultBaseURL = "https://apis.andreani.com"
+
+type Config struct {
+	BaseURL  string
+	User     string
+	Password string
+	Client   string
+	Contract string
+}
+
+type Carrier struct {
+	cfg        Config
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	token    string
+	tokenExp time.Time
+}
+
+func New(cfg Config) *Carrier {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultBaseURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &Carrier{cfg: cfg, httpClient: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (c *Carrier) Code() string { return "andreani" }
+func (c *Carrier) Name() string { return "Andreani" }
+
+// login obtiene el token (x-authorization-token) con basic auth; dura 24 h, lo renovamos antes.
+func (c *Carrier) login(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.tokenExp) {
+		return c.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.BaseURL+"/login", nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(c.cfg.User, c.cfg.Password)
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		b, _ := io.ReadAll(res.Body)
+		return "", fmt.Errorf("andreani login status %d: %s", res.StatusCode, string(b))
+	}
+	tok := res.Header.Get("x-authorization-token")
+	if tok == "" {
+		return "", errors.New("andreani login sin token")
+	}
+	c.token, c.tokenExp = tok, time.Now().Add(20*time.Hour)
+	return tok, nil
+}
+
+func (c *Carrier) do(ctx context.Context, method, path string, body any, out any) ([]byte, error) {
+	tok, err := c.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rd io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rd = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.cfg.BaseURL+path, rd)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-authorization-token", tok)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(res.Body, 10<<20))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("andreani %s %s status %d: %s", method, path, res.StatusCode, string(b))
+	}
+	if out != nil {
+		if err := json.Unmarshal(b, out); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+type tarifaResp struct {
+	TarifaConIva struct {
+		Total string `json:"total"`
+	} `json:"tarifaConIva"`
+}
+
+func (c *Carrier) Quote(ctx context.Context, req domain.CarrierQuoteRequest) ([]domain.CarrierRate, error) {
+	l, w, h := req.Package.Box()
+	q := url.Values{}
+	q.Set("cpDestino", req.DestPostal)
+	q.Set("contrato", c.cfg.Contract)
+	q.Set("cliente", c.cfg.Client)
+	q.Set("bultos[0][valorDeclarado]", strconv.FormatFloat(req.DeclaredValue, 'f', 2, 64))
+	q.Set("bultos[0][volumen]", strconv.FormatFloat(l*w*h/1000, 'f', 0, 64)) // cm³
+	q.Set("bultos[0][kilos]", strconv.FormatFloat(req.Package.Grams/1000, 'f', 3, 64))
+	var tr tarifaResp
+	if _, err := c.do(ctx, http.MethodGet, "/v1/tarifas?"+q.Encode(), nil, &tr); err != nil {
+		return nil, err
+	}
+	price, err := strconv.ParseFloat(tr.TarifaConIva.Total, 64)
+	if err != nil || price <= 0 {
+		return nil, fmt.Errorf("andreani tarifa inválida %q", tr.TarifaConIva.Total)
+	}
+	return []domain.CarrierRate{{Carrier: c.Code(), CarrierName: c.Name(), Service: "estandar", ServiceName: "Envío a domicilio", Price: price, DaysMin: 3, DaysMax: 7}}, nil
+}
+
+type ordenResp struct {
+	Bultos []struct {
+		NumeroDeEnvio string `json:"numeroDeEnvio"`
+	} `json:"bultos"`
+}
+
+func (c *Carrier) CreateShipment(ctx context.Context, req domain.CarrierShipmentRequest) (*domain.CarrierShipment, error) {
+	o := req.Order
+	if o == nil {
+		return nil, errors.New("orden nil")
+	}
+	l, w, h := req.Package.Box()
+	body := map[string]any{
+		"contrato": c.cfg.Contract,
+		"origen":   map[string]any{"postal": map[string]any{"codigoPostal": req.OriginPostal}},
+		"destino": map[string]any{"postal": map[string]any{
+			"codigoPostal": o.PostalCode,
+			"calle":        o.Address,
+			"region":       o.Province,
+			"pais":         "Argentina",
+		}},
+		"destinatario": []map[string]any{{
+			"nombreCompleto":  o.Name,
+			"email":           o.Email,
+			"documentoTipo":   "DNI",
+			"documentoNumero": o.DNI,
+			"telefonos":       []map[string]any{{"tipo": 1, "numero": o.Phone}},
+		}},
+		"bultos": []map[string]any{{
+			"kilos":                      req.Package.Grams / 1000,
+			"largoCm":                    l / 10,
+			"anchoCm":                    w / 10,
+			"altoCm":                     h / 10,
+			"volumenCm":                  l * w * h / 1000,
+			"valorDeclaradoConImpuestos": o.Total - o.ShippingCost,
+			"referencias":                []map[string]any{{"meta": "idCliente", "contenido": o.ID.String()}},
+		}},
+	}
+	var or ordenResp
+	if _, err := c.do(ctx, http.MethodPost, "/v2/ordenes-de-envio", body, &or); err != nil {
+		return nil, err
+	}
+	if len(or.Bultos) == 0 || or.Bultos[0].NumeroDeEnvio == "" {
+		return nil, errors.New("andreani: respuesta sin número de envío")
+	}
+	tracking := or.Bultos[0].NumeroDeEnvio
+	label, err := c.do(ctx, http.MethodGet, "/v2/ordenes-de-envio/"+url.PathEscape(tracking)+"/etiquetas", nil, nil)
+	if err != nil {
+		// el envío ya quedó dado de alta: devolvemos el seguimiento aunque falle la etiqueta
+		return &domain.CarrierShipment{TrackingNumber: tracking}, nil
+	}
+	return &domain.CarrierShipment{TrackingNumber: tracking, LabelPDF: label}, nil
+}
+
+type trazasResp struct {
+	Eventos []struct {
+		Fecha      string `json:"Fecha"`
+		Estado     string `json:"Estado"`
+		Traduccion string `json:"Traduccion"`
+		Sucursal   string `json:"Sucursal"`
+	} `json:"eventos"`
+}
+
+func (c *Carrier) Track(ctx context.Context, trackingNumber string) ([]domain.TrackingEvent, error) {
+	var tr trazasResp
+	if _, err := c.do(ctx, http.MethodGet, "/v2/envios/"+url.PathEscape(trackingNumber)+"/trazas", nil, &tr); err != nil {
+		return nil, err
+	}
+	out := make([]domain.TrackingEvent, 0, len(tr.Eventos))
+	for _, ev := range tr.Eventos {
+		at, err := time.Parse("2006-01-02T15:04:05", strings.TrimSuffix(ev.Fecha, "Z"))
+		if err != nil {
+			continue
+		}
+		desc := ev.Traduccion
+		if desc == "" {
+			desc = ev.Estado
+		}
+		out = append(out, domain.TrackingEvent{Stage: domain.ShipmentStageFromText(ev.Estado), Description: desc, Location: ev.Sucursal, OccurredAt: at})
+	}
+	return out, nil
+}
+
+func (c *Carrier) TrackingURL(trackingNumber string) string {
+	return "https://www.andreani.com/envio/" + url.PathEscape(trackingNumber)
+}
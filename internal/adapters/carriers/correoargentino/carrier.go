@@ -0,0 +1,241 @@
+// Package correoargentino cotiza y da de alta envíos con la API MiCorreo de Correo Argentino.
+// El alta importa el envío en MiCorreo; la etiqueta se imprime desde su panel (la API no la devuelve).
+package correoargentino
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+const defaultBaseURL = "https://api.correoargentino.com.ar/micorreo/v1"
+
+type Config struct {
+	BaseURL    string
+	User       string
+	Password   string
+	CustomerID string
+}
+
+type Carrier struct {
+	cfg        Config
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	token    string
+	tokenExp time.Time
+}
+
+func New(cfg Config) *Carrier {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultBaseURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &Carrier{cfg: cfg, httpClient: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (c *Carrier) Code() string { return "correoargentino" }
+func (c *Carrier) Name() string { return "Correo Argentino" }
+
+type tokenResp struct {
+	Token   string `json:"token"`
+	Expires string `json:"expires"`
+}
+
+func (c *Carrier) auth(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.tokenExp) {
+		return c.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.BaseURL+"/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(c.cfg.User, c.cfg.Password)
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		b, _ := io.ReadAll(res.Body)
+		return "", fmt.Errorf("correo argentino token status %d: %s", res.StatusCode, string(b))
+	}
+	var tr tokenResp
+	if err := json.NewDecoder(res.Body).Decode(&tr); err != nil {
+		return "", err
+	}
+	if tr.Token == "" {
+		return "", errors.New("correo argentino: token vacío")
+	}
+	exp := time.Now().Add(50 * time.Minute)
+	if t, err := time.Parse("2006-01-02 15:04:05", tr.Expires); err == nil && t.Before(exp) {
+		exp = t.Add(-time.Minute)
+	}
+	c.token, c.tokenExp = tr.Token, exp
+	return tr.Token, nil
+}
+
+func (c *Carrier) post(ctx context.Context, path string, body, out any) error {
+	tok, err := c.auth(ctx)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.BaseURL+path, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+tok)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("correo argentino %s status %d: %s", path, res.StatusCode, string(b))
+	}
+	if out != nil && len(b) > 0 {
+		return json.Unmarshal(b, out)
+	}
+	return nil
+}
+
+type dimensions struct {
+	Weight int `json:"weight"` // gramos
+	Height int `json:"height"` // cm
+	Width  int `json:"width"`
+	Length int `json:"length"`
+}
+
+func packageDimensions(p domain.ShippingPackage) dimensions {
+	l, w, h := p.Box()
+	cm := func(mm float64) int { return int(math.Max(1, math.Ceil(mm/10))) }
+	return dimensions{Weight: int(math.Max(1, math.Ceil(p.Grams))), Height: cm(h), Width: cm(w), Length: cm(l)}
+}
+
+type ratesResp struct {
+	Rates []struct {
+		DeliveredType   string  `json:"deliveredType"`
+		ProductType     string  `json:"productType"`
+		ProductName     string  `json:"productName"`
+		Price           float64 `json:"price"`
+		DeliveryTimeMin string  `json:"deliveryTimeMin"`
+		DeliveryTimeMax string  `json:"deliveryTimeMax"`
+	} `json:"rates"`
+}
+
+func (c *Carrier) Quote(ctx context.Context, req domain.CarrierQuoteRequest) ([]domain.CarrierRate, error) {
+	body := map[string]any{
+		"customerId":            c.cfg.CustomerID,
+		"postalCodeOrigin":      req.OriginPostal,
+		"postalCodeDestination": req.DestPostal,
+		"deliveredType":         "D", // a domicilio
+		"dimensions":            packageDimensions(req.Package),
+	}
+	var rr ratesResp
+	if err := c.post(ctx, "/rates", body, &rr); err != nil {
+		return nil, err
+	}
+	out := make([]domain.CarrierRate, 0, len(rr.Rates))
+	for _, r := range rr.Rates {
+		if r.Price <= 0 || r.ProductType == "" {
+			continue
+		}
+		dmin, _ := strconv.Atoi(r.DeliveryTimeMin)
+		dmax, _ := strconv.Atoi(r.DeliveryTimeMax)
+		name := r.ProductName
+		if name == "" {
+			name = r.ProductType
+		}
+		out = append(out, domain.CarrierRate{Carrier: c.Code(), CarrierName: c.Name(), Service: strings.ToLower(r.ProductType), ServiceName: name, Price: r.Price, DaysMin: dmin, DaysMax: dmax})
+	}
+	return out, nil
+}
+
+func (c *Carrier) CreateShipment(ctx context.Context, req domain.CarrierShipmentRequest) (*domain.CarrierShipment, error) {
+	o := req.Order
+	if o == nil {
+		return nil, errors.New("orden nil")
+	}
+	product := strings.ToUpper(req.Service)
+	if product == "" {
+		product = "CP"
+	}
+	// usamos el id de la orden como extOrderId: es el número con el que se sigue en MiCorreo
+	ext := o.ID.String()
+	body := map[string]any{
+		"customerId":  c.cfg.CustomerID,
+		"extOrderId":  ext,
+		"orderNumber": o.Code(),
+		"sender":      map[string]any{"postalCode": req.OriginPostal},
+		"recipient":   map[string]any{"name": o.Name, "phone": o.Phone, "email": o.Email},
+		"shipping": map[string]any{
+			"deliveryType":  "D",
+			"productType":   product,
+			"declaredValue": o.Total - o.ShippingCost,
+			"address":       map[string]any{"streetName": o.Address, "postalCode": o.PostalCode, "province": o.Province},
+			"dimensions":    packageDimensions(req.Package),
+		},
+	}
+	var resp struct {
+		TrackingNumber string `json:"trackingNumber"`
+	}
+	if err := c.post(ctx, "/shipping/import", body, &resp); err != nil {
+		return nil, err
+	}
+	tracking := resp.TrackingNumber
+	if tracking == "" {
+		tracking = ext
+	}
+	return &domain.CarrierShipment{TrackingNumber: tracking}, nil
+}
+
+type trackingResp []struct {
+	Events []struct {
+		Date   string `json:"date"`
+		Event  string `json:"event"`
+		Branch string `json:"branch"`
+		Status string `json:"status"`
+	} `json:"events"`
+}
+
+func (c *Carrier) Track(ctx context.Context, trackingNumber string) ([]domain.TrackingEvent, error) {
+	var tr trackingResp
+	if err := c.post(ctx, "/shipping/tracking", map[string]any{"shippingId": trackingNumber}, &tr); err != nil {
+		return nil, err
+	}
+	var out []domain.TrackingEvent
+	for _, sh := range tr {
+		for _, ev := range sh.Events {
+			at, err := time.ParseInLocation("02-01-2006 15:04", ev.Date, time.Local)
+			if err != nil {
+				continue
+			}
+			out = append(out, domain.TrackingEvent{Stage: domain.ShipmentStageFromText(ev.Event + " " + ev.Status), Description: ev.Event, Location: ev.Branch, OccurredAt: at})
+		}
+	}
+	return out, nil
+}
+
+func (c *Carrier) TrackingURL(trackingNumber string) string {
+	return "https://www.correoargentino.com.ar/formularios/e-commerce?id=" + url.QueryEscape(trackingNumber)
+}
@@ -0,0 +1,128 @@
+// Package fake es un correo de mentira para desarrollo: cotiza con una fórmula fija según
+// distancia entre códigos postales y peso, y genera números de seguimiento y etiquetas PDF
+// sin salir a la red. El seguimiento avanza solo: en camino al consultarlo por primera vez,
+// en distribución después de Step y entregado después de 2×Step. Se habilita con CARRIER_FAKE=true.
+package fake
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phenrril/tienda3d/internal/adapters/pdf"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type Carrier struct {
+	// Step es cuánto tarda el envío en pasar a la etapa siguiente.
+	Step time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func New() *Carrier { return &Carrier{Step: 30 * time.Minute, seen: map[string]time.Time{}} }
+
+func (c *Carrier) Code() string { return "fake" }
+func (c *Carrier) Name() string { return "Correo de prueba" }
+
+func postal(pc string) int {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, pc)
+	if len(digits) > 4 {
+		digits = digits[:4]
+	}
+	n, _ := strconv.Atoi(digits)
+	return n
+}
+
+func (c *Carrier) Quote(ctx context.Context, req domain.CarrierQuoteRequest) ([]domain.CarrierRate, error) {
+	dest := postal(req.DestPostal)
+	if dest == 0 {
+		return nil, errors.New("código postal de destino inválido")
+	}
+	dist := math.Abs(float64(dest - postal(req.OriginPostal)))
+	kg := math.Max(req.Package.Grams, req.Package.VolumeMM/5000) / 1000
+	base := math.Round(3500 + dist*0.8 + math.Ceil(kg)*900)
+	days := 2 + int(dist/2500)
+	return []domain.CarrierRate{
+		{Carrier: c.Code(), CarrierName: c.Name(), Service: "estandar", ServiceName: "Estándar", Price: base, DaysMin: days, DaysMax: days + 3},
+		{Carrier: c.Code(), CarrierName: c.Name(), Service: "express", ServiceName: "Express", Price: math.Round(base * 1.6), DaysMin: 1, DaysMax: 2},
+	}, nil
+}
+
+func (c *Carrier) CreateShipment(ctx context.Context, req domain.CarrierShipmentRequest) (*domain.CarrierShipment, error) {
+	if req.Order == nil {
+		return nil, errors.New("orden nil")
+	}
+	sum := sha1.Sum([]byte(req.Order.ID.String() + req.Service))
+	tracking := "FAKE" + strings.ToUpper(hex.EncodeToString(sum[:])[:12])
+	o := req.Order
+	lines := []string{
+		"CORREO DE PRUEBA - " + strings.ToUpper(req.Service),
+		"Seguimiento: " + tracking,
+		"",
+		"Destinatario: " + o.Name,
+		"Dirección: " + o.Address,
+		"CP " + o.PostalCode + " - " + o.Province,
+		"Tel: " + o.Phone,
+		"",
+		"Origen CP " + req.OriginPostal,
+		fmt.Sprintf("Peso: %.0f g", req.Package.Grams),
+		"Orden: " + o.Code(),
+	}
+	return &domain.CarrierShipment{TrackingNumber: tracking, LabelPDF: labelPDF(lines)}, nil
+}
+
+func (c *Carrier) Track(ctx context.Context, trackingNumber string) ([]domain.TrackingEvent, error) {
+	if !strings.HasPrefix(trackingNumber, "FAKE") {
+		return nil, fmt.Errorf("número de seguimiento desconocido %q", trackingNumber)
+	}
+	c.mu.Lock()
+	start, ok := c.seen[trackingNumber]
+	if !ok {
+		start = time.Now()
+		c.seen[trackingNumber] = start
+	}
+	c.mu.Unlock()
+	steps := []domain.TrackingEvent{
+		{Stage: domain.ShipmentStageShipped, Description: "Ingresado al correo", Location: "Rosario"},
+		{Stage: domain.ShipmentStageOutForDelivery, Description: "En distribución", Location: "Sucursal destino"},
+		{Stage: domain.ShipmentStageDelivered, Description: "Entregado", Location: "Domicilio"},
+	}
+	var out []domain.TrackingEvent
+	for i, ev := range steps {
+		at := start.Add(time.Duration(i) * c.Step)
+		if at.After(time.Now()) {
+			break
+		}
+		ev.OccurredAt = at
+		out = append(out, ev)
+	}
+	return out, nil
+}
+
+// TrackingURL: el correo de prueba no tiene página pública de seguimiento.
+func (c *Carrier) TrackingURL(trackingNumber string) string { return "" }
+
+// labelPDF arma una etiqueta A6 con las líneas de texto.
+func labelPDF(lines []string) []byte {
+	d := pdf.New(pdf.A6Width, pdf.A6Height)
+	y := 36.0
+	for _, l := range lines {
+		d.Text(24, y, 11, false, l)
+		y += 14
+	}
+	return d.Bytes()
+}
@@ -0,0 +1,313 @@
+// Package documents genera los PDFs imprimibles de las órdenes (el comprobante para el cliente y
+// la hoja de armado para producción y despacho) y los tickets para la impresora térmica.
+package documents
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/phenrril/tienda3d/internal/adapters/pdf"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+const (
+	margin  = 40.0
+	bottom  = pdf.A4Height - 70
+	colQty  = 370.0
+	colUnit = 460.0
+	colSub  = pdf.A4Width - margin - 8
+)
+
+type Generator struct {
+	Business domain.BusinessInfo
+	// Location: zona horaria de las fechas impresas (nil = la del servidor).
+	Location *time.Location
+	// TicketWidth: caracteres por línea de la impresora térmica (0 = papel de 80 mm).
+	TicketWidth int
+}
+
+func New(b domain.BusinessInfo) *Generator { return &Generator{Business: b} }
+
+func (g *Generator) date(t time.Time) string {
+	if g.Location != nil {
+		t = t.In(g.Location)
+	}
+	return t.Format("02/01/2006 15:04")
+}
+
+// money formatea como en la tienda: $ 12.345 o $ 12.345,50.
+func money(v float64) string {
+	neg := v < 0
+	if neg {
+		v = -v
+	}
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	intPart, dec, _ := strings.Cut(s, ".")
+	var b strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(r)
+	}
+	out := "$ " + b.String()
+	if dec != "00" {
+		out += "," + dec
+	}
+	if neg {
+		out = "-" + out
+	}
+	return out
+}
+
+func paymentLabel(method string) string {
+	switch strings.ToLower(method) {
+	case "mercadopago":
+		return "MercadoPago"
+	case "efectivo":
+		return "Efectivo"
+	case "transferencia", "transfer":
+		return "Transferencia bancaria"
+	case "":
+		return "No especificado"
+	}
+	return method
+}
+
+func paid(o *domain.Order) bool {
+	switch o.Status {
+	case domain.OrderStatusFinished, domain.OrderStatusInPrint, domain.OrderStatusShipped:
+		return true
+	}
+	return false
+}
+
+func destination(o *domain.Order) string {
+	addr := o.Address
+	if o.PostalCode != "" || o.Province != "" {
+		addr += " (" + strings.Trim(o.PostalCode+" – "+o.Province, " –") + ")"
+	}
+	return addr
+}
+
+// itemDetails son las líneas chicas debajo de cada artículo: color y personalización.
+func itemDetails(it domain.OrderItem) []string {
+	var out []string
+	if it.Color != "" {
+		out = append(out, "Color: "+it.Color)
+	}
+	for _, v := range it.PersonalizationValues() {
+		out = append(out, v.Label+": "+v.Display())
+	}
+	return out
+}
+
+// header imprime los datos del negocio y el título; devuelve dónde sigue el contenido.
+func (g *Generator) header(d *pdf.Doc, title string, o *domain.Order) float64 {
+	right := d.Width() - margin
+	b := g.Business
+	d.Text(margin, 58, 20, true, b.Name)
+	y := 74.0
+	for _, l := range []string{
+		b.LegalName,
+		strings.Trim(joinNonEmpty(" · ", prefixed("CUIT ", b.TaxID), b.TaxCondition), " "),
+		b.Address,
+		joinNonEmpty(" · ", b.Email, b.Phone),
+		b.Website,
+	} {
+		if l == "" {
+			continue
+		}
+		d.Text(margin, y, 9, false, l)
+		y += 12
+	}
+	d.TextRight(right, 50, 12, true, title)
+	d.TextRight(right, 66, 10, false, "Pedido #"+o.Code())
+	d.TextRight(right, 79, 9, false, "Fecha: "+g.date(o.CreatedAt))
+	if y < 96 {
+		y = 96
+	}
+	d.Line(margin, y, right, y, 0.8)
+	return y + 20
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var out []string
+	for _, p := range parts {
+		if strings.TrimSpace(p) != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, sep)
+}
+
+func prefixed(prefix, v string) string {
+	if v == "" {
+		return ""
+	}
+	return prefix + v
+}
+
+// Receipt arma el comprobante de la orden para el cliente.
+func (g *Generator) Receipt(o *domain.Order) ([]byte, error) {
+	d := pdf.New(pdf.A4Width, pdf.A4Height)
+	right := d.Width() - margin
+	y := g.header(d, "COMPROBANTE DE COMPRA", o)
+
+	d.Text(margin, y, 10, true, "Cliente")
+	y += 14
+	for _, l := range []string{
+		o.Name,
+		joinNonEmpty(" · ", prefixed("DNI ", o.DNI), o.Email, o.Phone),
+		"Entrega: " + domain.ShippingMethodLabel(o.ShippingMethod),
+	} {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		d.Text(margin, y, 9, false, l)
+		y += 12
+	}
+	if o.ShippingMethod == domain.ShippingCourier || o.ShippingMethod == domain.ShippingCadete {
+		d.Text(margin, y, 9, false, "Dirección: "+destination(o))
+		y += 12
+	}
+	y += 10
+
+	tableHeader := func(y float64) float64 {
+		d.Rect(margin, y, right-margin, 18, 0.92)
+		d.Text(margin+8, y+12, 9, true, "Descripción")
+		d.TextRight(colQty, y+12, 9, true, "Cant.")
+		d.TextRight(colUnit, y+12, 9, true, "P. unitario")
+		d.TextRight(colSub, y+12, 9, true, "Subtotal")
+		return y + 32
+	}
+	y = tableHeader(y)
+	itemsTotal := 0.0
+	for _, it := range o.Items {
+		title := pdf.Wrap(it.Title, 9, false, colQty-margin-60)
+		details := itemDetails(it)
+		need := float64(len(title))*12 + float64(len(details))*10 + 8
+		if y+need > bottom {
+			d.AddPage()
+			y = tableHeader(margin)
+		}
+		sub := it.UnitPrice * float64(it.Qty)
+		itemsTotal += sub
+		d.TextRight(colQty, y, 9, false, strconv.Itoa(it.Qty))
+		d.TextRight(colUnit, y, 9, false, money(it.UnitPrice))
+		d.TextRight(colSub, y, 9, false, money(sub))
+		for _, l := range title {
+			d.Text(margin+8, y, 9, false, l)
+			y += 12
+		}
+		for _, l := range details {
+			d.Text(margin+16, y-2, 7.5, false, l)
+			y += 10
+		}
+		y += 4
+		d.Line(margin, y-8, right, y-8, 0.3)
+	}
+
+	if y+110 > bottom {
+		d.AddPage()
+		y = margin
+	}
+	y += 6
+	row := func(label, value string, bold bool) {
+		size := 9.0
+		if bold {
+			size = 11
+		}
+		d.TextRight(colUnit, y, size, bold, label)
+		d.TextRight(colSub, y, size, bold, value)
+		y += size + 6
+	}
+	row("Subtotal", money(itemsTotal), false)
+	if o.ShippingCost > 0 || o.ShippingMethod == domain.ShippingCourier || o.ShippingMethod == domain.ShippingCadete {
+		row("Envío", money(o.ShippingCost), false)
+	}
+	if o.DiscountAmount > 0 {
+		label := "Descuento"
+		if o.CouponCode != "" {
+			label += " (cupón " + o.CouponCode + ")"
+		}
+		row(label, money(-o.DiscountAmount), false)
+	}
+	if o.PaymentAdjustment != 0 {
+		row(paymentLabel(o.PaymentMethod)+" ("+o.PaymentAdjustmentLabel()+")", money(o.PaymentAdjustment), false)
+	}
+	d.Line(colUnit-120, y-4, right, y-4, 0.6)
+	y += 6
+	row("TOTAL", money(o.Total), true)
+
+	y += 10
+	status := "Pendiente de pago"
+	if paid(o) {
+		status = "Pagado"
+	} else if o.Status == domain.OrderStatusCancelled {
+		status = "Cancelado"
+	}
+	d.Text(margin, y, 9, true, "Forma de pago:")
+	d.Text(margin+pdf.TextWidth("Forma de pago: ", 9, true), y, 9, false, paymentLabel(o.PaymentMethod)+" · "+status)
+
+	d.Text(margin, d.Height()-48, 8, false, "Documento no válido como factura.")
+	d.TextRight(right, d.Height()-48, 8, false, "¡Gracias por tu compra!")
+	return d.Bytes(), nil
+}
+
+// PackingSlip arma la hoja de armado: qué imprimir/empacar y a dónde mandarlo, sin precios.
+func (g *Generator) PackingSlip(o *domain.Order) ([]byte, error) {
+	d := pdf.New(pdf.A4Width, pdf.A4Height)
+	right := d.Width() - margin
+	y := g.header(d, "HOJA DE ARMADO", o)
+
+	boxTop := y - 14
+	d.Box(margin, boxTop, right-margin, 92, 1)
+	d.Text(margin+10, y+2, 10, true, "Destinatario · "+domain.ShippingMethodLabel(o.ShippingMethod))
+	y += 20
+	d.Text(margin+10, y, 14, true, o.Name)
+	y += 16
+	for _, l := range []string{
+		joinNonEmpty(" · ", prefixed("Tel. ", o.Phone), prefixed("DNI ", o.DNI)),
+		destination(o),
+		joinNonEmpty(" · ", o.Carrier, prefixed("Seguimiento ", o.TrackingNumber)),
+	} {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		d.Text(margin+10, y, 10, false, l)
+		y += 13
+	}
+	y = boxTop + 92 + 26
+
+	d.Text(margin, y, 10, true, "Artículos")
+	y += 18
+	units := 0
+	for _, it := range o.Items {
+		title := pdf.Wrap(it.Title, 11, true, right-margin-80)
+		details := itemDetails(it)
+		need := float64(len(title))*14 + float64(len(details))*12 + 14
+		if y+need > bottom {
+			d.AddPage()
+			y = margin + 10
+		}
+		units += it.Qty
+		d.Box(margin, y-10, 11, 11, 0.8)
+		d.Text(margin+22, y, 12, true, strconv.Itoa(it.Qty)+" ×")
+		for _, l := range title {
+			d.Text(margin+60, y, 11, true, l)
+			y += 14
+		}
+		for _, l := range details {
+			d.Text(margin+60, y, 9, false, l)
+			y += 12
+		}
+		y += 8
+		d.Line(margin, y-10, right, y-10, 0.3)
+	}
+	d.Text(margin, y+6, 9, false, "Total de unidades: "+strconv.Itoa(units))
+	d.Text(margin, d.Height()-48, 8, false, "Estado: "+domain.OrderStatusLabel(o.Status)+" · "+paymentLabel(o.PaymentMethod))
+	d.TextRight(right, d.Height()-48, 8, false, "Armó: ____________   Controló: ____________")
+	return d.Bytes(), nil
+}
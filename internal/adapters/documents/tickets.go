@@ -0,0 +1,141 @@
+package documents
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/phenrril/tienda3d/internal/adapters/escpos"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+var workshopStatusLabels = map[domain.WorkshopOrderStatus]string{
+	domain.WorkshopPendiente:    "Pendiente",
+	domain.WorkshopDisenado:     "Diseñado",
+	domain.WorkshopEnImpresion:  "En impresión",
+	domain.WorkshopListoEntrega: "Listo para entregar",
+	domain.WorkshopEntregado:    "Entregado",
+}
+
+// ticketHeader imprime el nombre y los datos del negocio centrados.
+func (g *Generator) ticketHeader(t *escpos.Ticket) {
+	b := g.Business
+	t.Align(escpos.AlignCenter).Bold(true).Double(true).Line(b.Name).Double(false).Bold(false)
+	for _, l := range []string{
+		b.Address,
+		joinNonEmpty(" · ", prefixed("CUIT ", b.TaxID), b.TaxCondition),
+		joinNonEmpty(" · ", b.Phone, b.Website),
+	} {
+		if strings.TrimSpace(l) != "" {
+			t.Line(l)
+		}
+	}
+	t.Align(escpos.AlignLeft).Rule()
+}
+
+func ticketFooter(t *escpos.Ticket, lines ...string) []byte {
+	t.Align(escpos.AlignCenter)
+	for _, l := range lines {
+		t.Line(l)
+	}
+	return t.Feed(2).Cut().Bytes()
+}
+
+// OrderTicket arma el ticket de mostrador de una orden de la tienda (ventas y retiros).
+func (g *Generator) OrderTicket(o *domain.Order) ([]byte, error) {
+	t := escpos.NewTicket(g.TicketWidth)
+	g.ticketHeader(t)
+	t.Bold(true).Line("Pedido #" + o.Code()).Bold(false)
+	t.Line("Fecha: " + g.date(o.CreatedAt))
+	t.Line("Cliente: " + o.Name)
+	if l := joinNonEmpty(" · ", o.Phone, o.Email); l != "" {
+		t.Line(l)
+	}
+	t.Line("Entrega: " + domain.ShippingMethodLabel(o.ShippingMethod))
+	if o.ShippingMethod == domain.ShippingCourier || o.ShippingMethod == domain.ShippingCadete {
+		t.Line("Dirección: " + destination(o))
+	}
+	t.Rule()
+
+	itemsTotal := 0.0
+	for _, it := range o.Items {
+		sub := it.UnitPrice * float64(it.Qty)
+		itemsTotal += sub
+		t.Row(strconv.Itoa(it.Qty)+" x "+it.Title, money(sub))
+		for _, l := range itemDetails(it) {
+			t.Line("  " + l)
+		}
+	}
+	t.Rule()
+	t.Row("Subtotal", money(itemsTotal))
+	if o.ShippingCost > 0 || o.ShippingMethod == domain.ShippingCourier || o.ShippingMethod == domain.ShippingCadete {
+		t.Row("Envío", money(o.ShippingCost))
+	}
+	if o.DiscountAmount > 0 {
+		t.Row(joinNonEmpty(" ", "Descuento", o.CouponCode), money(-o.DiscountAmount))
+	}
+	if o.PaymentAdjustment != 0 {
+		t.Row(paymentLabel(o.PaymentMethod)+" "+o.PaymentAdjustmentLabel(), money(o.PaymentAdjustment))
+	}
+	t.Bold(true).Double(true).Row("TOTAL", money(o.Total)).Double(false).Bold(false)
+
+	status := "Pendiente de pago"
+	if paid(o) {
+		status = "Pagado"
+	} else if o.Status == domain.OrderStatusCancelled {
+		status = "Cancelado"
+	}
+	t.Line("Pago: " + paymentLabel(o.PaymentMethod) + " · " + status)
+	if o.ShippingMethod == domain.ShippingPickup || o.ShippingMethod == "" {
+		t.Feed(1).Line("Retiró: ____________________").Feed(1).Line("Firma: ____________________")
+	}
+	t.Feed(1)
+	return ticketFooter(t, "Documento no válido como factura", "¡Gracias por tu compra!"), nil
+}
+
+// WorkshopTicket arma el ticket de un pedido del taller con las señas y el saldo a cobrar.
+func (g *Generator) WorkshopTicket(o *domain.WorkshopOrder) ([]byte, error) {
+	t := escpos.NewTicket(g.TicketWidth)
+	g.ticketHeader(t)
+	t.Bold(true).Line("Pedido taller #" + o.ID.String()[:8]).Bold(false)
+	t.Line("Cliente: " + o.ClientSlug)
+	t.Line("Solicitado: " + o.RequestedAt.Format("02/01/2006"))
+	t.Line("Entrega: " + o.DeliveryDate.Format("02/01/2006"))
+	if l, ok := workshopStatusLabels[o.Status]; ok {
+		t.Line("Estado: " + l)
+	}
+	t.Rule()
+	if strings.TrimSpace(o.Detail) != "" {
+		t.Line(o.Detail)
+	}
+	for _, f := range o.Filaments {
+		t.Line("  Filamento " + f.ColorSlug + ": " + strconv.Itoa(f.Grams) + " g")
+	}
+	t.Rule()
+
+	if o.IsBarter {
+		t.Bold(true).Row("TOTAL", "CANJE").Bold(false)
+		return ticketFooter(t, "¡Gracias!"), nil
+	}
+	deposits := 0.0
+	for _, d := range o.Deposits {
+		deposits += d.Amount
+		t.Row("Seña "+d.PaidAt.Format("02/01/2006"), money(d.Amount))
+	}
+	if o.TotalAmount == nil {
+		if deposits > 0 {
+			t.Row("Total señas", money(deposits))
+		}
+		t.Bold(true).Row("TOTAL", "a confirmar").Bold(false)
+		return ticketFooter(t, "¡Gracias!"), nil
+	}
+	t.Row("Total", money(*o.TotalAmount))
+	if deposits > 0 {
+		t.Row("Señas", money(-deposits))
+	}
+	balance := *o.TotalAmount - deposits
+	if balance < 0 {
+		balance = 0
+	}
+	t.Bold(true).Double(true).Row("SALDO", money(balance)).Double(false).Bold(false)
+	return ticketFooter(t, "¡Gracias!"), nil
+}
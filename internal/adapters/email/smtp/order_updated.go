@@ -0,0 +1,182 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+
+	"gopkg.in/gomail.v2"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// NotifyOrderUpdated le avisa al cliente que modificamos su pedido, con los cambios y cómo
+// quedó el detalle y el total.
+func (s *SMTPService) NotifyOrderUpdated(ctx context.Context, order *domain.Order, changes []string) error {
+	if order.Email == "" {
+		return nil
+	}
+	if s.user == "" || s.password == "" {
+		fmt.Printf("⚠️  SMTP no configurado - no se envió aviso de pedido modificado para orden %s\n", order.ID)
+		return nil
+	}
+
+	htmlBody, err := s.generateOrderUpdatedHTML(order, changes)
+	if err != nil {
+		return fmt.Errorf("error generando HTML del aviso de pedido modificado: %w", err)
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.from)
+	m.SetHeader("To", order.Email)
+	m.SetHeader("Subject", fmt.Sprintf("✏️ Actualizamos tu pedido #%s", order.Code()))
+	m.SetBody("text/html", htmlBody)
+
+	d := gomail.NewDialer(s.host, s.port, s.user, s.password)
+	if err := d.DialAndSend(m); err != nil {
+		return fmt.Errorf("error enviando email: %w", err)
+	}
+
+	fmt.Printf("📧 Aviso de pedido modificado enviado a %s para orden %s\n", order.Email, order.ID)
+	return nil
+}
+
+func (s *SMTPService) generateOrderUpdatedHTML(order *domain.Order, changes []string) (string, error) {
+	tmplStr := `
+<!DOCTYPE html>
+<html lang="es">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Actualizamos tu pedido</title>
+</head>
+<body style="margin: 0; padding: 0; font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif; background-color: #f3f4f6;">
+    <table role="presentation" style="width: 100%; border-collapse: collapse;">
+        <tr>
+            <td style="padding: 40px 20px; text-align: center;">
+                <table role="presentation" style="max-width: 600px; margin: 0 auto; background-color: #ffffff; border-radius: 8px; overflow: hidden; box-shadow: 0 4px 6px rgba(0, 0, 0, 0.1);">
+                    <tr>
+                        <td style="background: linear-gradient(135deg, #667eea 0%, #764ba2 100%); padding: 32px 30px; text-align: center;">
+                            <h1 style="margin: 0; color: #ffffff; font-size: 26px; font-weight: bold;">Actualizamos tu pedido</h1>
+                            <p style="margin: 8px 0 0 0; color: #e0e7ff; font-size: 15px;">Pedido #{{.OrderNumber}}</p>
+                        </td>
+                    </tr>
+                    <tr>
+                        <td style="padding: 32px 30px; text-align: left;">
+                            <p style="margin: 0 0 16px 0; color: #374151; font-size: 16px; line-height: 1.6;">
+                                Hola <strong>{{.Name}}</strong>, hicimos estos cambios en tu pedido:
+                            </p>
+                            <ul style="margin: 0 0 24px 0; padding: 12px 16px 12px 32px; background-color: #eef2ff; border-left: 4px solid #667eea; color: #312e81; font-size: 15px; line-height: 1.6;">
+                                {{range .Changes}}<li>{{.}}</li>{{end}}
+                            </ul>
+
+                            <h2 style="margin: 0 0 12px 0; color: #111827; font-size: 18px; font-weight: bold;">Así quedó tu pedido</h2>
+                            <table role="presentation" style="width: 100%; border-collapse: collapse; margin-bottom: 20px;">
+                                {{range .Items}}
+                                <tr style="border-bottom: 1px solid #f3f4f6;">
+                                    <td style="padding: 10px 0; color: #111827; font-size: 15px;">{{.Title}}{{if .Color}}<br/><span style="color: #6b7280; font-size: 13px;">Color: {{.Color}}</span>{{end}}</td>
+                                    <td style="padding: 10px 12px; text-align: center; color: #374151; font-size: 15px;">{{.Qty}}</td>
+                                    <td style="padding: 10px 0; text-align: right; color: #111827; font-size: 15px;">${{printf "%.2f" .Subtotal}}</td>
+                                </tr>
+                                {{end}}
+                            </table>
+                            <table role="presentation" style="width: 100%; border-collapse: collapse; margin-bottom: 24px;">
+                                <tr>
+                                    <td style="padding: 6px 0; text-align: right; color: #6b7280; font-size: 15px;">{{.Shipping}}:</td>
+                                    <td style="padding: 6px 0 6px 20px; text-align: right; color: #111827; font-size: 15px; width: 100px;">${{printf "%.2f" .ShippingCost}}</td>
+                                </tr>
+                                {{if .DiscountAmount}}
+                                <tr>
+                                    <td style="padding: 6px 0; text-align: right; color: #059669; font-size: 15px;">Descuento:</td>
+                                    <td style="padding: 6px 0 6px 20px; text-align: right; color: #059669; font-size: 15px; width: 100px;">-${{printf "%.2f" .DiscountAmount}}</td>
+                                </tr>
+                                {{end}}
+                                {{if .PaymentAdjustment}}
+                                <tr>
+                                    <td style="padding: 6px 0; text-align: right; color: #6b7280; font-size: 15px;">Medio de pago ({{.AdjustmentLabel}}):</td>
+                                    <td style="padding: 6px 0 6px 20px; text-align: right; color: #111827; font-size: 15px; width: 100px;">{{if lt .PaymentAdjustment 0.0}}-${{printf "%.2f" (neg .PaymentAdjustment)}}{{else}}${{printf "%.2f" .PaymentAdjustment}}{{end}}</td>
+                                </tr>
+                                {{end}}
+                                <tr style="border-top: 2px solid #e5e7eb;">
+                                    <td style="padding: 12px 0; text-align: right; color: #111827; font-size: 18px; font-weight: bold;">Total:</td>
+                                    <td style="padding: 12px 0 12px 20px; text-align: right; color: #667eea; font-size: 20px; font-weight: bold; width: 100px;">${{printf "%.2f" .Total}}</td>
+                                </tr>
+                            </table>
+                            <p style="margin: 0 0 16px 0; text-align: center;">
+                                <a href="{{.OrderURL}}" style="display: inline-block; padding: 12px 24px; background-color: #667eea; color: #ffffff; text-decoration: none; border-radius: 6px; font-weight: bold;">Ver mi pedido</a>
+                            </p>
+                            <p style="margin: 0; color: #6b7280; font-size: 14px; line-height: 1.6;">
+                                Si algo no coincide con lo que hablamos, escribinos por WhatsApp y lo revisamos.
+                            </p>
+                        </td>
+                    </tr>
+                    <tr>
+                        <td style="background-color: #f9fafb; padding: 24px; text-align: center; border-top: 1px solid #e5e7eb;">
+                            <p style="margin: 0; color: #9ca3af; font-size: 12px;">
+                                Este es un email automático, por favor no responder.
+                            </p>
+                        </td>
+                    </tr>
+                </table>
+            </td>
+        </tr>
+    </table>
+</body>
+</html>
+`
+
+	baseURL := strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	type ItemData struct {
+		Title    string
+		Color    string
+		Qty      int
+		Subtotal float64
+	}
+	items := make([]ItemData, len(order.Items))
+	for i, item := range order.Items {
+		items[i] = ItemData{Title: item.Title, Color: item.Color, Qty: item.Qty, Subtotal: item.UnitPrice * float64(item.Qty)}
+	}
+	data := struct {
+		Name              string
+		OrderNumber       string
+		Changes           []string
+		Items             []ItemData
+		Shipping          string
+		ShippingCost      float64
+		DiscountAmount    float64
+		PaymentAdjustment float64
+		AdjustmentLabel   string
+		Total             float64
+		OrderURL          string
+	}{
+		Name:              order.Name,
+		OrderNumber:       order.Code(),
+		Changes:           changes,
+		Items:             items,
+		Shipping:          domain.ShippingMethodLabel(order.ShippingMethod),
+		ShippingCost:      order.ShippingCost,
+		DiscountAmount:    order.DiscountAmount,
+		PaymentAdjustment: order.PaymentAdjustment,
+		AdjustmentLabel:   order.PaymentAdjustmentLabel(),
+		Total:             order.Total,
+		OrderURL:          baseURL + "/pay/" + order.ID.String(),
+	}
+
+	tmpl, err := template.New("order_updated").Funcs(template.FuncMap{"neg": func(v float64) float64 { return -v }}).Parse(tmplStr)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
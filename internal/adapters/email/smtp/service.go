@@ -5,8 +5,10 @@ import (
 	"context"
 	"fmt"
 	"html/template"
+	"io"
 	"os"
 	"strings"
+	"time"
 
 	"gopkg.in/gomail.v2"
 
@@ -19,6 +21,9 @@ type SMTPService struct {
 	user     string
 	password string
 	from     string
+
+	// Documents: si está, el comprobante PDF va adjunto al email de confirmación.
+	Documents domain.OrderDocuments
 }
 
 func NewSMTPService() *SMTPService {
@@ -66,8 +71,18 @@ func (s *SMTPService) SendOrderConfirmation(ctx context.Context, order *domain.O
 	m := gomail.NewMessage()
 	m.SetHeader("From", s.from)
 	m.SetHeader("To", order.Email)
-	m.SetHeader("Subject", fmt.Sprintf("✅ Confirmación de tu pedido #%s", order.ID.String()[:8]))
+	m.SetHeader("Subject", fmt.Sprintf("✅ Confirmación de tu pedido #%s", order.Code()))
 	m.SetBody("text/html", htmlBody)
+	if s.Documents != nil {
+		if pdf, err := s.Documents.Receipt(order); err != nil {
+			fmt.Printf("⚠️  No se pudo generar el comprobante de la orden %s: %v\n", order.ID, err)
+		} else {
+			m.Attach("comprobante-"+order.Code()+".pdf", gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(pdf)
+				return err
+			}))
+		}
+	}
 
 	// Enviar
 	d := gomail.NewDialer(s.host, s.port, s.user, s.password)
@@ -79,6 +94,126 @@ func (s *SMTPService) SendOrderConfirmation(ctx context.Context, order *domain.O
 	return nil
 }
 
+// SendPaymentReminder avisa al cliente que su orden impaga se cancela en expiresAt.
+func (s *SMTPService) SendPaymentReminder(ctx context.Context, order *domain.Order, expiresAt time.Time) error {
+	if s.user == "" || s.password == "" {
+		fmt.Printf("⚠️  SMTP no configurado - no se envió recordatorio de pago para orden %s\n", order.ID)
+		return nil
+	}
+
+	htmlBody, err := s.generateReminderHTML(order, expiresAt)
+	if err != nil {
+		return fmt.Errorf("error generando HTML del recordatorio: %w", err)
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.from)
+	m.SetHeader("To", order.Email)
+	m.SetHeader("Subject", fmt.Sprintf("⏰ Tu pedido #%s está esperando el pago", order.Code()))
+	m.SetBody("text/html", htmlBody)
+
+	d := gomail.NewDialer(s.host, s.port, s.user, s.password)
+	if err := d.DialAndSend(m); err != nil {
+		return fmt.Errorf("error enviando email: %w", err)
+	}
+
+	fmt.Printf("📧 Recordatorio de pago enviado a %s para orden %s\n", order.Email, order.ID)
+	return nil
+}
+
+func (s *SMTPService) generateReminderHTML(order *domain.Order, expiresAt time.Time) (string, error) {
+	tmplStr := `
+<!DOCTYPE html>
+<html lang="es">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Pedido pendiente de pago</title>
+</head>
+<body style="margin: 0; padding: 0; font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif; background-color: #f3f4f6;">
+    <table role="presentation" style="width: 100%; border-collapse: collapse;">
+        <tr>
+            <td style="padding: 40px 20px; text-align: center;">
+                <table role="presentation" style="max-width: 600px; margin: 0 auto; background-color: #ffffff; border-radius: 8px; overflow: hidden; box-shadow: 0 4px 6px rgba(0, 0, 0, 0.1);">
+                    <tr>
+                        <td style="background: linear-gradient(135deg, #f59e0b 0%, #d97706 100%); padding: 32px 30px; text-align: center;">
+                            <h1 style="margin: 0; color: #ffffff; font-size: 26px; font-weight: bold;">Tu pedido sigue esperando el pago</h1>
+                        </td>
+                    </tr>
+                    <tr>
+                        <td style="padding: 32px 30px; text-align: left;">
+                            <p style="margin: 0 0 16px 0; color: #374151; font-size: 16px; line-height: 1.6;">
+                                Hola <strong>{{.Name}}</strong>,
+                            </p>
+                            <p style="margin: 0 0 16px 0; color: #374151; font-size: 16px; line-height: 1.6;">
+                                Todavía no registramos el pago de tu pedido <strong>#{{.OrderNumber}}</strong> ({{.PaymentMethod}}) por <strong>${{printf "%.2f" .Total}}</strong>.
+                            </p>
+                            <p style="margin: 0 0 16px 0; color: #374151; font-size: 16px; line-height: 1.6;">
+                                Si no se completa antes del <strong>{{.ExpiresAt}}</strong>, el pedido se cancela automáticamente{{if .CouponCode}} y el cupón <strong>{{.CouponCode}}</strong> vuelve a quedar disponible{{end}}.
+                            </p>
+                            <p style="margin: 0; color: #6b7280; font-size: 14px; line-height: 1.6;">
+                                Si ya pagaste, ignorá este mensaje. Ante cualquier duda, escribinos.
+                            </p>
+                        </td>
+                    </tr>
+                    <tr>
+                        <td style="background-color: #f9fafb; padding: 24px; text-align: center; border-top: 1px solid #e5e7eb;">
+                            <p style="margin: 0; color: #9ca3af; font-size: 12px;">
+                                Este es un email automático, por favor no responder.
+                            </p>
+                        </td>
+                    </tr>
+                </table>
+            </td>
+        </tr>
+    </table>
+</body>
+</html>
+`
+
+	data := struct {
+		Name          string
+		OrderNumber   string
+		PaymentMethod string
+		Total         float64
+		ExpiresAt     string
+		CouponCode    string
+	}{
+		Name:          order.Name,
+		OrderNumber:   order.Code(),
+		PaymentMethod: paymentMethodLabel(order.PaymentMethod),
+		Total:         order.Total,
+		ExpiresAt:     expiresAt.Format("02/01/2006 15:04"),
+		CouponCode:    order.CouponCode,
+	}
+
+	tmpl, err := template.New("reminder").Parse(tmplStr)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func paymentMethodLabel(method string) string {
+	switch strings.ToLower(method) {
+	case "mercadopago":
+		return "MercadoPago"
+	case "efectivo":
+		return "Efectivo"
+	case "transferencia", "transfer":
+		return "Transferencia Bancaria"
+	case "":
+		return "No especificado"
+	}
+	return method
+}
+
 func (s *SMTPService) generateOrderHTML(order *domain.Order) (string, error) {
 	tmplStr := `
 <!DOCTYPE html>
@@ -145,6 +280,9 @@ func (s *SMTPService) generateOrderHTML(order *domain.Order) (string, error) {
                                             {{if .Color}}
                                             <p style="margin: 4px 0 0 0; color: #6b7280; font-size: 13px;">Color: {{.Color}}</p>
                                             {{end}}
+                                            {{range .Personalization}}
+                                            <p style="margin: 4px 0 0 0; color: #6b7280; font-size: 13px;">{{.Label}}: {{.Display}}</p>
+                                            {{end}}
                                         </td>
                                         <td style="padding: 16px 12px; text-align: center; color: #374151; font-size: 15px;">{{.Qty}}</td>
                                         <td style="padding: 16px 12px; text-align: right; color: #111827; font-size: 15px; font-weight: 500;">${{printf "%.2f" .Subtotal}}</td>
@@ -167,6 +305,12 @@ func (s *SMTPService) generateOrderHTML(order *domain.Order) (string, error) {
                                     <td style="padding: 8px 0 8px 20px; text-align: right; color: #059669; font-size: 15px; font-weight: 500; width: 100px;">-${{printf "%.2f" .DiscountAmount}}</td>
                                 </tr>
                                 {{end}}
+                                {{if .PaymentAdjustment}}
+                                <tr>
+                                    <td style="padding: 8px 0; text-align: right; color: {{if lt .PaymentAdjustment 0.0}}#059669{{else}}#6b7280{{end}}; font-size: 15px;">{{.PaymentMethod}} ({{.AdjustmentLabel}}):</td>
+                                    <td style="padding: 8px 0 8px 20px; text-align: right; color: {{if lt .PaymentAdjustment 0.0}}#059669{{else}}#111827{{end}}; font-size: 15px; font-weight: 500; width: 100px;">{{if lt .PaymentAdjustment 0.0}}-${{printf "%.2f" (neg .PaymentAdjustment)}}{{else}}${{printf "%.2f" .PaymentAdjustment}}{{end}}</td>
+                                </tr>
+                                {{end}}
                                 <tr style="border-top: 2px solid #e5e7eb;">
                                     <td style="padding: 16px 0; text-align: right; color: #111827; font-size: 18px; font-weight: bold;">Total:</td>
                                     <td style="padding: 16px 0 16px 20px; text-align: right; color: #667eea; font-size: 20px; font-weight: bold; width: 100px;">${{printf "%.2f" .Total}}</td>
@@ -217,35 +361,25 @@ func (s *SMTPService) generateOrderHTML(order *domain.Order) (string, error) {
 
 	// Preparar datos para el template
 	type ItemData struct {
-		Title    string
-		Color    string
-		Qty      int
-		Subtotal float64
+		Title           string
+		Color           string
+		Qty             int
+		Subtotal        float64
+		Personalization []domain.PersonalizationValue
 	}
 
 	items := make([]ItemData, len(order.Items))
 	for i, item := range order.Items {
 		items[i] = ItemData{
-			Title:    item.Title,
-			Color:    item.Color,
-			Qty:      item.Qty,
-			Subtotal: item.UnitPrice * float64(item.Qty),
+			Title:           item.Title,
+			Color:           item.Color,
+			Qty:             item.Qty,
+			Subtotal:        item.UnitPrice * float64(item.Qty),
+			Personalization: item.PersonalizationValues(),
 		}
 	}
 
-	paymentMethodName := "No especificado"
-	switch strings.ToLower(order.PaymentMethod) {
-	case "mercadopago":
-		paymentMethodName = "MercadoPago"
-	case "efectivo":
-		paymentMethodName = "Efectivo"
-	case "transferencia", "transfer":
-		paymentMethodName = "Transferencia Bancaria"
-	default:
-		if order.PaymentMethod != "" {
-			paymentMethodName = order.PaymentMethod
-		}
-	}
+	paymentMethodName := paymentMethodLabel(order.PaymentMethod)
 
 	data := struct {
 		Name           string
@@ -254,24 +388,29 @@ func (s *SMTPService) generateOrderHTML(order *domain.Order) (string, error) {
 		Items          []ItemData
 		ShippingCost   float64
 		DiscountAmount float64
-		Total          float64
-		Address        string
-		PostalCode     string
-		Province       string
+		// PaymentAdjustment: descuento (negativo) o recargo del medio de pago.
+		PaymentAdjustment float64
+		AdjustmentLabel   string
+		Total             float64
+		Address           string
+		PostalCode        string
+		Province          string
 	}{
-		Name:           order.Name,
-		OrderNumber:    order.ID.String()[:8],
-		PaymentMethod:  paymentMethodName,
-		Items:          items,
-		ShippingCost:   order.ShippingCost,
-		DiscountAmount: order.DiscountAmount,
-		Total:          order.Total,
-		Address:        order.Address,
-		PostalCode:     order.PostalCode,
-		Province:       order.Province,
+		Name:              order.Name,
+		OrderNumber:       order.Code(),
+		PaymentMethod:     paymentMethodName,
+		Items:             items,
+		ShippingCost:      order.ShippingCost,
+		DiscountAmount:    order.DiscountAmount,
+		PaymentAdjustment: order.PaymentAdjustment,
+		AdjustmentLabel:   order.PaymentAdjustmentLabel(),
+		Total:             order.Total,
+		Address:           order.Address,
+		PostalCode:        order.PostalCode,
+		Province:          order.Province,
 	}
 
-	tmpl, err := template.New("email").Parse(tmplStr)
+	tmpl, err := template.New("email").Funcs(template.FuncMap{"neg": func(v float64) float64 { return -v }}).Parse(tmplStr)
 	if err != nil {
 		return "", err
 	}
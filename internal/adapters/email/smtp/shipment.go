@@ -0,0 +1,142 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+
+	"gopkg.in/gomail.v2"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// NotifyShipment avisa al cliente que su pedido salió, está en distribución o se entregó.
+func (s *SMTPService) NotifyShipment(ctx context.Context, order *domain.Order, stage string) error {
+	if order.Email == "" {
+		return nil
+	}
+	if s.user == "" || s.password == "" {
+		fmt.Printf("⚠️  SMTP no configurado - no se envió aviso de envío para orden %s\n", order.ID)
+		return nil
+	}
+
+	subject, htmlBody, err := s.generateShipmentHTML(order, stage)
+	if err != nil {
+		return fmt.Errorf("error generando HTML del aviso de envío: %w", err)
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.from)
+	m.SetHeader("To", order.Email)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/html", htmlBody)
+
+	d := gomail.NewDialer(s.host, s.port, s.user, s.password)
+	if err := d.DialAndSend(m); err != nil {
+		return fmt.Errorf("error enviando email: %w", err)
+	}
+
+	fmt.Printf("📧 Aviso de envío (%s) enviado a %s para orden %s\n", stage, order.Email, order.ID)
+	return nil
+}
+
+func (s *SMTPService) generateShipmentHTML(order *domain.Order, stage string) (string, string, error) {
+	number := order.Code()
+	var subject, title, message string
+	switch stage {
+	case domain.ShipmentStageOutForDelivery:
+		subject = fmt.Sprintf("🚚 Tu pedido #%s está en distribución", number)
+		title = "Tu pedido llega pronto"
+		message = "El correo ya está repartiendo tu pedido. Asegurate de que haya alguien para recibirlo."
+	case domain.ShipmentStageDelivered:
+		subject = fmt.Sprintf("✅ Tu pedido #%s fue entregado", number)
+		title = "¡Tu pedido fue entregado!"
+		message = "Esperamos que lo disfrutes. Si algo no llegó como esperabas, escribinos."
+	default:
+		subject = fmt.Sprintf("📦 Tu pedido #%s está en camino", number)
+		title = "Tu pedido está en camino"
+		message = "Despachamos tu pedido. Podés seguirlo con el número de seguimiento del correo."
+	}
+
+	tmplStr := `
+<!DOCTYPE html>
+<html lang="es">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}}</title>
+</head>
+<body style="margin: 0; padding: 0; font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif; background-color: #f3f4f6;">
+    <table role="presentation" style="width: 100%; border-collapse: collapse;">
+        <tr>
+            <td style="padding: 40px 20px; text-align: center;">
+                <table role="presentation" style="max-width: 600px; margin: 0 auto; background-color: #ffffff; border-radius: 8px; overflow: hidden; box-shadow: 0 4px 6px rgba(0, 0, 0, 0.1);">
+                    <tr>
+                        <td style="background: linear-gradient(135deg, #6366f1 0%, #4f46e5 100%); padding: 32px 30px; text-align: center;">
+                            <h1 style="margin: 0; color: #ffffff; font-size: 26px; font-weight: bold;">{{.Title}}</h1>
+                        </td>
+                    </tr>
+                    <tr>
+                        <td style="padding: 32px 30px; text-align: left;">
+                            <p style="margin: 0 0 16px 0; color: #374151; font-size: 16px; line-height: 1.6;">
+                                Hola <strong>{{.Name}}</strong>,
+                            </p>
+                            <p style="margin: 0 0 16px 0; color: #374151; font-size: 16px; line-height: 1.6;">
+                                Pedido <strong>#{{.OrderNumber}}</strong>: {{.Message}}
+                            </p>
+                            {{if .TrackingNumber}}
+                            <p style="margin: 0 0 16px 0; color: #374151; font-size: 16px; line-height: 1.6;">
+                                Número de seguimiento: <strong>{{.TrackingNumber}}</strong>
+                            </p>
+                            {{end}}
+                            {{if .TrackingURL}}
+                            <p style="margin: 0 0 16px 0; text-align: center;">
+                                <a href="{{.TrackingURL}}" style="display: inline-block; padding: 12px 24px; background-color: #4f46e5; color: #ffffff; text-decoration: none; border-radius: 6px; font-weight: bold;">Seguir mi envío</a>
+                            </p>
+                            {{end}}
+                        </td>
+                    </tr>
+                    <tr>
+                        <td style="background-color: #f9fafb; padding: 24px; text-align: center; border-top: 1px solid #e5e7eb;">
+                            <p style="margin: 0; color: #9ca3af; font-size: 12px;">
+                                Este es un email automático, por favor no responder.
+                            </p>
+                        </td>
+                    </tr>
+                </table>
+            </td>
+        </tr>
+    </table>
+</body>
+</html>
+`
+
+	data := struct {
+		Title          string
+		Message        string
+		Name           string
+		OrderNumber    string
+		TrackingNumber string
+		TrackingURL    string
+	}{
+		Title:          title,
+		Message:        message,
+		Name:           order.Name,
+		OrderNumber:    number,
+		TrackingNumber: order.TrackingNumber,
+		TrackingURL:    order.TrackingURL,
+	}
+
+	tmpl, err := template.New("shipment").Parse(tmplStr)
+	if err != nil {
+		return "", "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", "", err
+	}
+
+	return subject, buf.String(), nil
+}
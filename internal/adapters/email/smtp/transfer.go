@@ -0,0 +1,129 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+
+	"gopkg.in/gomail.v2"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// NotifyTransferRejected le avisa al cliente que su comprobante de transferencia no se pudo
+// verificar, con el motivo y el link para subir otro.
+func (s *SMTPService) NotifyTransferRejected(ctx context.Context, order *domain.Order, reason string) error {
+	if order.Email == "" {
+		return nil
+	}
+	if s.user == "" || s.password == "" {
+		fmt.Printf("⚠️  SMTP no configurado - no se envió aviso de comprobante rechazado para orden %s\n", order.ID)
+		return nil
+	}
+
+	htmlBody, err := s.generateTransferRejectedHTML(order, reason)
+	if err != nil {
+		return fmt.Errorf("error generando HTML del aviso de comprobante: %w", err)
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.from)
+	m.SetHeader("To", order.Email)
+	m.SetHeader("Subject", fmt.Sprintf("⚠️ No pudimos verificar la transferencia de tu pedido #%s", order.Code()))
+	m.SetBody("text/html", htmlBody)
+
+	d := gomail.NewDialer(s.host, s.port, s.user, s.password)
+	if err := d.DialAndSend(m); err != nil {
+		return fmt.Errorf("error enviando email: %w", err)
+	}
+
+	fmt.Printf("📧 Aviso de comprobante rechazado enviado a %s para orden %s\n", order.Email, order.ID)
+	return nil
+}
+
+func (s *SMTPService) generateTransferRejectedHTML(order *domain.Order, reason string) (string, error) {
+	tmplStr := `
+<!DOCTYPE html>
+<html lang="es">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Comprobante de transferencia rechazado</title>
+</head>
+<body style="margin: 0; padding: 0; font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif; background-color: #f3f4f6;">
+    <table role="presentation" style="width: 100%; border-collapse: collapse;">
+        <tr>
+            <td style="padding: 40px 20px; text-align: center;">
+                <table role="presentation" style="max-width: 600px; margin: 0 auto; background-color: #ffffff; border-radius: 8px; overflow: hidden; box-shadow: 0 4px 6px rgba(0, 0, 0, 0.1);">
+                    <tr>
+                        <td style="background: linear-gradient(135deg, #ef4444 0%, #dc2626 100%); padding: 32px 30px; text-align: center;">
+                            <h1 style="margin: 0; color: #ffffff; font-size: 26px; font-weight: bold;">No pudimos verificar tu transferencia</h1>
+                        </td>
+                    </tr>
+                    <tr>
+                        <td style="padding: 32px 30px; text-align: left;">
+                            <p style="margin: 0 0 16px 0; color: #374151; font-size: 16px; line-height: 1.6;">
+                                Hola <strong>{{.Name}}</strong>,
+                            </p>
+                            <p style="margin: 0 0 16px 0; color: #374151; font-size: 16px; line-height: 1.6;">
+                                Revisamos el comprobante que subiste para el pedido <strong>#{{.OrderNumber}}</strong> (${{printf "%.2f" .Total}}) y no pudimos confirmarlo:
+                            </p>
+                            <p style="margin: 0 0 16px 0; padding: 12px 16px; background-color: #fef2f2; border-left: 4px solid #dc2626; color: #7f1d1d; font-size: 15px; line-height: 1.6;">
+                                {{.Reason}}
+                            </p>
+                            <p style="margin: 0 0 16px 0; color: #374151; font-size: 16px; line-height: 1.6;">
+                                Tu pedido sigue reservado. Podés subir otro comprobante desde la página del pedido.
+                            </p>
+                            <p style="margin: 0 0 16px 0; text-align: center;">
+                                <a href="{{.PayURL}}" style="display: inline-block; padding: 12px 24px; background-color: #dc2626; color: #ffffff; text-decoration: none; border-radius: 6px; font-weight: bold;">Subir otro comprobante</a>
+                            </p>
+                        </td>
+                    </tr>
+                    <tr>
+                        <td style="background-color: #f9fafb; padding: 24px; text-align: center; border-top: 1px solid #e5e7eb;">
+                            <p style="margin: 0; color: #9ca3af; font-size: 12px;">
+                                Este es un email automático, por favor no responder.
+                            </p>
+                        </td>
+                    </tr>
+                </table>
+            </td>
+        </tr>
+    </table>
+</body>
+</html>
+`
+
+	baseURL := strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	data := struct {
+		Name        string
+		OrderNumber string
+		Total       float64
+		Reason      string
+		PayURL      string
+	}{
+		Name:        order.Name,
+		OrderNumber: order.Code(),
+		Total:       order.Total,
+		Reason:      reason,
+		PayURL:      baseURL + "/pay/" + order.ID.String(),
+	}
+
+	tmpl, err := template.New("transfer_rejected").Parse(tmplStr)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
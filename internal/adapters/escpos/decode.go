@@ -0,0 +1,166 @@
+package escpos
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DecodedLine es una línea impresa con el formato que tenía activo.
+type DecodedLine struct {
+	Text   string
+	Bold   bool
+	Double bool
+	Align  Align
+}
+
+// Decoded es lo que imprimiría la impresora: sirve para revisar tickets sin gastar papel.
+type Decoded struct {
+	Initialized bool
+	CodePage    int
+	Cut         bool
+	Lines       []DecodedLine
+}
+
+// Text devuelve las líneas como texto plano, una por renglón.
+func (d *Decoded) Text() string {
+	var b strings.Builder
+	for _, l := range d.Lines {
+		b.WriteString(l.Text)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Check verifica que el ticket esté bien formado: que arranque inicializando la impresora, que
+// termine con corte y que ninguna línea pase del ancho del papel.
+func (d *Decoded) Check(width int) error {
+	if !d.Initialized {
+		return fmt.Errorf("escpos: falta ESC @ al inicio")
+	}
+	if !d.Cut {
+		return fmt.Errorf("escpos: falta el corte de papel")
+	}
+	for i, l := range d.Lines {
+		max := width
+		if l.Double {
+			max = width / 2
+		}
+		if n := utf8.RuneCountInString(l.Text); n > max {
+			return fmt.Errorf("escpos: la línea %d tiene %d caracteres (máximo %d): %q", i+1, n, max, l.Text)
+		}
+	}
+	return nil
+}
+
+// Decode interpreta el subconjunto de comandos ESC/POS que genera Ticket. Cualquier otro comando
+// es un error, así un cambio en el renderer que la impresora no entienda no pasa desapercibido.
+func Decode(data []byte) (*Decoded, error) {
+	d := &Decoded{CodePage: -1}
+	var (
+		cur    []rune
+		bold   bool
+		double bool
+		align  Align
+	)
+	flush := func() {
+		d.Lines = append(d.Lines, DecodedLine{Text: string(cur), Bold: bold, Double: double, Align: align})
+		cur = cur[:0]
+	}
+	arg := func(i, n int) ([]byte, error) {
+		if i+n >= len(data) {
+			return nil, fmt.Errorf("escpos: comando truncado en el byte %d", i)
+		}
+		return data[i+1 : i+1+n], nil
+	}
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == esc:
+			cmd, err := arg(i, 1)
+			if err != nil {
+				return nil, err
+			}
+			i++
+			switch cmd[0] {
+			case '@':
+				if i == 1 {
+					d.Initialized = true
+				}
+				bold, double, align = false, false, AlignLeft
+				continue
+			}
+			p, err := arg(i, 1)
+			if err != nil {
+				return nil, err
+			}
+			i++
+			switch cmd[0] {
+			case 't':
+				d.CodePage = int(p[0])
+			case 'a':
+				if p[0] > 2 {
+					return nil, fmt.Errorf("escpos: alineación inválida %d", p[0])
+				}
+				align = Align(p[0])
+			case 'E':
+				bold = p[0]&1 == 1
+			case 'd':
+				for n := 0; n < int(p[0]); n++ {
+					flush()
+				}
+			default:
+				return nil, fmt.Errorf("escpos: comando ESC %q no soportado en el byte %d", cmd[0], i-2)
+			}
+		case c == gs:
+			cmd, err := arg(i, 1)
+			if err != nil {
+				return nil, err
+			}
+			i++
+			switch cmd[0] {
+			case '!':
+				p, err := arg(i, 1)
+				if err != nil {
+					return nil, err
+				}
+				i++
+				double = p[0] != 0
+			case 'V':
+				p, err := arg(i, 1)
+				if err != nil {
+					return nil, err
+				}
+				i++
+				if p[0] == 65 || p[0] == 66 {
+					if _, err := arg(i, 1); err != nil {
+						return nil, err
+					}
+					i++
+				}
+				if len(cur) > 0 {
+					flush()
+				}
+				d.Cut = true
+			default:
+				return nil, fmt.Errorf("escpos: comando GS %q no soportado en el byte %d", cmd[0], i-1)
+			}
+		case c == lf:
+			flush()
+		case c < 0x20:
+			return nil, fmt.Errorf("escpos: byte de control 0x%02x inesperado en el byte %d", c, i)
+		case c < 0x80:
+			cur = append(cur, rune(c))
+		default:
+			if r, ok := pc858Runes[c]; ok {
+				cur = append(cur, r)
+			} else {
+				cur = append(cur, '?')
+			}
+		}
+	}
+	if len(cur) > 0 {
+		flush()
+	}
+	return d, nil
+}
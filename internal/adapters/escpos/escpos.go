@@ -0,0 +1,233 @@
+// Package escpos arma tickets para impresoras térmicas ESC/POS (comandos de texto, sin gráficos)
+// y los manda a la impresora por TCP (puerto raw, normalmente 9100).
+package escpos
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	esc = 0x1b
+	gs  = 0x1d
+	lf  = 0x0a
+
+	// codePage858 es la página de códigos PC858 (PC850 + €) que usan casi todas las térmicas.
+	codePage858 = 19
+)
+
+// Anchos típicos en caracteres con la fuente A.
+const (
+	Width58mm = 32
+	Width80mm = 48
+)
+
+type Align byte
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// Ticket acumula los comandos de un ticket. Width es el ancho del papel en caracteres.
+type Ticket struct {
+	Width int
+
+	buf    bytes.Buffer
+	double bool
+}
+
+// NewTicket inicializa la impresora y selecciona la página de códigos con acentos y eñes.
+func NewTicket(width int) *Ticket {
+	if width <= 0 {
+		width = Width80mm
+	}
+	t := &Ticket{Width: width}
+	t.buf.Write([]byte{esc, '@', esc, 't', codePage858})
+	return t
+}
+
+func (t *Ticket) Align(a Align) *Ticket {
+	t.buf.Write([]byte{esc, 'a', byte(a)})
+	return t
+}
+
+func (t *Ticket) Bold(on bool) *Ticket {
+	t.buf.Write([]byte{esc, 'E', boolByte(on)})
+	return t
+}
+
+// Double activa doble ancho y doble alto (la mitad de caracteres por línea).
+func (t *Ticket) Double(on bool) *Ticket {
+	n := byte(0)
+	if on {
+		n = 0x11
+	}
+	t.double = on
+	t.buf.Write([]byte{gs, '!', n})
+	return t
+}
+
+// cols es cuántos caracteres entran en una línea con el tamaño actual.
+func (t *Ticket) cols() int {
+	if t.double {
+		return t.Width / 2
+	}
+	return t.Width
+}
+
+// Line imprime texto cortándolo en palabras para que no pase del ancho.
+func (t *Ticket) Line(s string) *Ticket {
+	for _, l := range Wrap(s, t.cols()) {
+		t.buf.Write(encode(l))
+		t.buf.WriteByte(lf)
+	}
+	return t
+}
+
+// Row imprime un texto a la izquierda y un valor alineado a la derecha en la misma línea; si no
+// entran, el texto se corta en varias líneas y el valor va en la última.
+func (t *Ticket) Row(left, right string) *Ticket {
+	w := t.cols()
+	rw := utf8.RuneCountInString(right)
+	lines := Wrap(left, w-rw-1)
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	for _, l := range lines[:len(lines)-1] {
+		t.buf.Write(encode(l))
+		t.buf.WriteByte(lf)
+	}
+	last := lines[len(lines)-1]
+	pad := w - utf8.RuneCountInString(last) - rw
+	if pad < 1 {
+		pad = 1
+	}
+	t.buf.Write(encode(last + strings.Repeat(" ", pad) + right))
+	t.buf.WriteByte(lf)
+	return t
+}
+
+// Rule imprime una línea separadora de guiones.
+func (t *Ticket) Rule() *Ticket {
+	t.buf.WriteString(strings.Repeat("-", t.cols()))
+	t.buf.WriteByte(lf)
+	return t
+}
+
+// Feed avanza n líneas en blanco.
+func (t *Ticket) Feed(n int) *Ticket {
+	if n > 255 {
+		n = 255
+	}
+	if n > 0 {
+		t.buf.Write([]byte{esc, 'd', byte(n)})
+	}
+	return t
+}
+
+// Cut avanza el papel hasta la cuchilla y hace un corte parcial.
+func (t *Ticket) Cut() *Ticket {
+	t.buf.Write([]byte{gs, 'V', 66, 3})
+	return t
+}
+
+func (t *Ticket) Bytes() []byte { return t.buf.Bytes() }
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Wrap corta s en líneas de hasta width caracteres, respetando los saltos de línea propios y
+// partiendo las palabras que no entran solas.
+func Wrap(s string, width int) []string {
+	if width < 1 {
+		width = 1
+	}
+	var out []string
+	for _, para := range strings.Split(strings.ReplaceAll(s, "\r", ""), "\n") {
+		// la sangría del párrafo se repite en todas sus líneas
+		indent := para[:len(para)-len(strings.TrimLeft(para, " "))]
+		if len(indent) >= width {
+			indent = ""
+		}
+		w := width - len(indent)
+		line := ""
+		for _, word := range strings.Fields(para) {
+			for utf8.RuneCountInString(word) > w {
+				if line != "" {
+					out = append(out, indent+line)
+					line = ""
+				}
+				r := []rune(word)
+				out = append(out, indent+string(r[:w]))
+				word = string(r[w:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= w:
+				line += " " + word
+			default:
+				out = append(out, indent+line)
+				line = word
+			}
+		}
+		if line != "" {
+			line = indent + line
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// pc858 son los caracteres no ASCII que usamos en castellano y su byte en la página PC858.
+var pc858 = map[rune]byte{
+	'Ç': 0x80, 'ü': 0x81, 'é': 0x82, 'â': 0x83, 'ä': 0x84, 'à': 0x85, 'ç': 0x87, 'ê': 0x88,
+	'ë': 0x89, 'è': 0x8a, 'ï': 0x8b, 'î': 0x8c, 'ì': 0x8d, 'Ä': 0x8e, 'É': 0x90, 'ô': 0x93,
+	'ö': 0x94, 'ò': 0x95, 'û': 0x96, 'ù': 0x97, 'Ö': 0x99, 'Ü': 0x9a, '×': 0x9e, 'á': 0xa0,
+	'í': 0xa1, 'ó': 0xa2, 'ú': 0xa3, 'ñ': 0xa4, 'Ñ': 0xa5, 'ª': 0xa6, 'º': 0xa7, '¿': 0xa8,
+	'¡': 0xad, '«': 0xae, '»': 0xaf, 'Á': 0xb5, 'Â': 0xb6, 'À': 0xb7, '©': 0xb8, '€': 0xd5,
+	'Í': 0xd6, 'Ó': 0xe0, 'Ú': 0xe9, '°': 0xf8, '·': 0xfa,
+}
+
+var pc858Runes = func() map[byte]rune {
+	m := make(map[byte]rune, len(pc858))
+	for r, b := range pc858 {
+		m[b] = r
+	}
+	return m
+}()
+
+// encode pasa el texto a PC858; lo que la impresora no tiene sale como "?" y los caracteres de
+// control se descartan para no mezclarse con los comandos.
+func encode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '–' || r == '—':
+			out = append(out, '-')
+		case r == '“' || r == '”':
+			out = append(out, '"')
+		case r == '‘' || r == '’':
+			out = append(out, '\'')
+		case r == '\t':
+			out = append(out, ' ')
+		case r < 0x20 || r == 0x7f:
+		case r < 0x80:
+			out = append(out, byte(r))
+		default:
+			if b, ok := pc858[r]; ok {
+				out = append(out, b)
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
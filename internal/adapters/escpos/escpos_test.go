@@ -0,0 +1,125 @@
+package escpos_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/adapters/documents"
+	"github.com/phenrril/tienda3d/internal/adapters/escpos"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+func testOrder() *domain.Order {
+	return &domain.Order{
+		ID:             uuid.MustParse("3f2a9c1e-0000-4000-8000-000000000001"),
+		Number:         42,
+		Status:         domain.OrderStatusFinished,
+		Name:           "Iñaki Peña",
+		Email:          "inaki@example.com",
+		ShippingMethod: domain.ShippingCourier,
+		Address:        "Av. Córdoba 1234",
+		PostalCode:     "2000",
+		Province:       "Santa Fe",
+		ShippingCost:   5000,
+		PaymentMethod:  "transferencia",
+		Total:          29000,
+		CreatedAt:      time.Date(2025, 3, 14, 10, 30, 0, 0, time.UTC),
+		Items: []domain.OrderItem{
+			{Qty: 2, UnitPrice: 12000, Title: "Maceta geométrica", Color: "Azul"},
+		},
+	}
+}
+
+func TestOrderTicketBytes(t *testing.T) {
+	g := documents.New(domain.BusinessInfo{Name: "Chroma3D", Address: "San Martín 500, Rosario"})
+	g.Location = time.UTC
+	b, err := g.OrderTicket(testOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ESC @ inicializa y ESC t 19 selecciona PC858 antes que cualquier texto
+	if want := []byte{0x1b, '@', 0x1b, 't', 19}; !bytes.HasPrefix(b, want) {
+		t.Fatalf("inicio = % x; want % x", b[:min(len(b), 8)], want)
+	}
+	// termina avanzando dos líneas (ESC d 2) y con corte parcial (GS V 66 3)
+	if want := []byte{0x1b, 'd', 2, 0x1d, 'V', 66, 3}; !bytes.HasSuffix(b, want) {
+		t.Fatalf("final = % x; want % x", b[max(0, len(b)-8):], want)
+	}
+
+	// acentos y eñes en PC858, nunca en UTF-8
+	for _, want := range [][]byte{
+		[]byte("I\xa4aki Pe\xa4a"),           // Iñaki Peña
+		[]byte("Av. C\xa2rdoba 1234"),        // Córdoba
+		[]byte("Direcci\xa2n"),               // Dirección
+		[]byte("Env\xa1o"),                   // Envío
+		[]byte("Maceta geom\x82trica"),       // geométrica
+		[]byte("\xadGracias por tu compra!"), // ¡Gracias
+	} {
+		if !bytes.Contains(b, want) {
+			t.Errorf("falta %q en el ticket", want)
+		}
+	}
+	if !utf8Free(b) {
+		t.Error("el ticket tiene secuencias UTF-8 de acentos")
+	}
+
+	d, err := escpos.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Check(escpos.Width80mm); err != nil {
+		t.Fatal(err)
+	}
+	if d.CodePage != 19 {
+		t.Fatalf("CodePage = %d", d.CodePage)
+	}
+	text := d.Text()
+	for _, want := range []string{"Pedido #" + testOrder().Code(), "Fecha: 14/03/2025 10:30", "Cliente: Iñaki Peña", "Dirección: Av. Córdoba 1234", "¡Gracias por tu compra!"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("falta %q en:\n%s", want, text)
+		}
+	}
+	var total *escpos.DecodedLine
+	for i := range d.Lines {
+		if strings.HasPrefix(d.Lines[i].Text, "TOTAL") {
+			total = &d.Lines[i]
+		}
+	}
+	if total == nil || !total.Bold || !total.Double || len([]rune(total.Text)) != escpos.Width80mm/2 {
+		t.Fatalf("línea de total = %+v", total)
+	}
+}
+
+// utf8Free indica que no quedó ninguna á/é/í/ó/ú/ñ codificada en UTF-8 (0xc3 0xa1 etc.).
+func utf8Free(b []byte) bool {
+	for _, r := range "áéíóúñÁÉÍÓÚÑ¡¿" {
+		if bytes.Contains(b, []byte(string(r))) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTicketEncodesUnsupportedRunes(t *testing.T) {
+	b := escpos.NewTicket(escpos.Width58mm).Line("Precio: 10€ – “oferta”\tya\x07 漢").Cut().Bytes()
+	want := []byte("Precio: 10\xd5 - \"oferta\" ya ?\n")
+	if !bytes.Contains(b, want) {
+		t.Fatalf("ticket = %q; want que contenga %q", b, want)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	got := escpos.Wrap("Maceta geométrica grande\n  color: azul petróleo mate", 12)
+	want := []string{"Maceta", "geométrica", "grande", "  color:", "  azul", "  petróleo", "  mate"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("Wrap = %q; want %q", got, want)
+	}
+	if got := escpos.Wrap("abcdefghij", 4); strings.Join(got, "|") != "abcd|efgh|ij" {
+		t.Fatalf("Wrap palabra larga = %q", got)
+	}
+}
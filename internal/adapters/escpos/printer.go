@@ -0,0 +1,34 @@
+package escpos
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Printer manda los tickets a una impresora de red por su puerto raw (JetDirect, normalmente 9100).
+type Printer struct {
+	Addr    string
+	Timeout time.Duration
+}
+
+func NewPrinter(addr string) *Printer { return &Printer{Addr: addr, Timeout: 5 * time.Second} }
+
+func (p *Printer) Print(ctx context.Context, data []byte) error {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", p.Addr)
+	if err != nil {
+		return fmt.Errorf("conectar impresora %s: %w", p.Addr, err)
+	}
+	defer conn.Close()
+	_ = conn.SetWriteDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("enviar ticket a %s: %w", p.Addr, err)
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+	"github.com/phenrril/tienda3d/internal/usecase"
+)
+
+const accountOrdersPageSize = 10
+
+type accountOrderView struct {
+	ID         uuid.UUID
+	Number     string
+	Status     domain.OrderStatus
+	Total      float64
+	ItemsCount int
+	CreatedAt  string
+	CanPay     bool
+}
+
+// requireCustomer devuelve el cliente logueado o lo manda a loguearse con Google y volver acá.
+func (s *Server) requireCustomer(w http.ResponseWriter, r *http.Request) *sessionUser {
+	if u := readUserSession(w, r); u != nil {
+		return u
+	}
+	next := r.URL.Path
+	if r.Method != http.MethodGet {
+		next = "/account/orders"
+	}
+	http.Redirect(w, r, "/auth/google/login?next="+url.QueryEscape(next), http.StatusFound)
+	return nil
+}
+
+// canPayOnline indica si la orden impaga puede pagarse con MercadoPago desde la cuenta.
+func canPayOnline(o *domain.Order) bool {
+	if o.Status != domain.OrderStatusAwaitingPay {
+		return false
+	}
+	switch o.PaymentMethod {
+	case "efectivo", "transferencia", "":
+		return false
+	}
+	return true
+}
+
+// accountOrderNotices son los mensajes que puede mostrar el detalle de un pedido según ?err=; el
+// texto no viaja en la URL para que nadie pueda armar un link con un mensaje propio.
+var accountOrderNotices = map[string]string{
+	"no_online":     "Este pedido no se puede pagar online",
+	"pago":          "No pudimos generar el link de pago, probá de nuevo en unos minutos",
+	"carrito":       "No pudimos armar el carrito",
+	"sin_productos": "Los productos de este pedido ya no están disponibles",
+}
+
+func redirectAccountOrder(w http.ResponseWriter, r *http.Request, id uuid.UUID, code string) {
+	http.Redirect(w, r, "/account/orders/"+id.String()+"?err="+url.QueryEscape(code), http.StatusFound)
+}
+
+func (s *Server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/account/orders", http.StatusFound)
+}
+
+func (s *Server) handleAccountOrders(w http.ResponseWriter, r *http.Request) {
+	u := s.requireCustomer(w, r)
+	if u == nil {
+		return
+	}
+	page := 1
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	list, total, err := s.orders.CustomerOrders(r.Context(), u.Email, page, accountOrdersPageSize)
+	if err != nil {
+		log.Error().Err(err).Msg("cuenta: listar órdenes")
+		http.Error(w, "error", http.StatusInternalServerError)
+		return
+	}
+	views := make([]accountOrderView, 0, len(list))
+	for i := range list {
+		o := &list[i]
+		n := 0
+		for _, it := range o.Items {
+			n += it.Qty
+		}
+		views = append(views, accountOrderView{
+			ID:         o.ID,
+			Number:     o.Code(),
+			Status:     o.Status,
+			Total:      o.Total,
+			ItemsCount: n,
+			CreatedAt:  o.CreatedAt.Format("02/01/2006"),
+			CanPay:     canPayOnline(o),
+		})
+	}
+	pages := (int(total) + accountOrdersPageSize - 1) / accountOrdersPageSize
+	s.render(w, "account_orders.html", map[string]any{
+		"User":      u,
+		"Orders":    views,
+		"Page":      page,
+		"Pages":     pages,
+		"PageTitle": "Mis pedidos — Chroma3D",
+	})
+}
+
+func (s *Server) handleAccountOrder(w http.ResponseWriter, r *http.Request) {
+	u := s.requireCustomer(w, r)
+	if u == nil {
+		return
+	}
+	id, err := uuid.Parse(strings.TrimPrefix(r.URL.Path, "/account/orders/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	o, err := s.orders.CustomerOrder(r.Context(), u.Email, id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	history, err := s.orders.History(r.Context(), []uuid.UUID{o.ID})
+	if err != nil {
+		log.Error().Err(err).Str("order_id", o.ID.String()).Msg("cuenta: historial")
+	}
+	var shippedAt string
+	for _, h := range history[o.ID] {
+		if h.To == domain.OrderStatusShipped {
+			shippedAt = h.CreatedAt.Format("02/01/2006 15:04")
+		}
+	}
+	events, err := s.tracking.OrderEvents(r.Context(), o.ID)
+	if err != nil {
+		log.Error().Err(err).Str("order_id", o.ID.String()).Msg("cuenta: seguimiento")
+	}
+	balance, _, err := s.payments.Balance(r.Context(), o)
+	if err != nil {
+		log.Error().Err(err).Str("order_id", o.ID.String()).Msg("cuenta: pagos")
+	}
+	s.render(w, "account_order.html", map[string]any{
+		"User":       u,
+		"Order":      o,
+		"Number":     o.Code(),
+		"History":    history[o.ID],
+		"CanPay":     canPayOnline(o),
+		"ShippedAt":  shippedAt,
+		"Events":     events,
+		"Balance":    balance,
+		"FlashError": accountOrderNotices[strings.TrimSpace(r.URL.Query().Get("err"))],
+		"PageTitle":  "Pedido #" + o.Code() + " — Chroma3D",
+	})
+}
+
+// handleAccountOrderPay genera una preferencia de MercadoPago nueva para una orden impaga.
+func (s *Server) handleAccountOrderPay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/account/orders", http.StatusFound)
+		return
+	}
+	u := s.requireCustomer(w, r)
+	if u == nil {
+		return
+	}
+	id, _ := uuid.Parse(r.FormValue("id"))
+	o, err := s.orders.CustomerOrder(r.Context(), u.Email, id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !canPayOnline(o) {
+		redirectAccountOrder(w, r, o.ID, "no_online")
+		return
+	}
+	redirURL, err := s.payments.CreatePreference(r.Context(), o)
+	if err != nil {
+		log.Error().Err(err).Str("order_id", o.ID.String()).Msg("cuenta: preferencia MP")
+		redirectAccountOrder(w, r, o.ID, "pago")
+		return
+	}
+	// sólo la preferencia: la orden leída pudo cancelarse o cobrarse mientras se armaba
+	if err := s.orders.Orders.SetPreference(r.Context(), o.ID, o.MPPreferenceID, o.Total); err != nil {
+		log.Error().Err(err).Str("order_id", o.ID.String()).Msg("cuenta: guardar preferencia")
+	}
+	http.Redirect(w, r, redirURL, http.StatusFound)
+}
+
+// handleAccountOrderReorder agrega al carrito los productos de una orden anterior, con precios actuales.
+func (s *Server) handleAccountOrderReorder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/account/orders", http.StatusFound)
+		return
+	}
+	u := s.requireCustomer(w, r)
+	if u == nil {
+		return
+	}
+	id, _ := uuid.Parse(r.FormValue("id"))
+	o, err := s.orders.CustomerOrder(r.Context(), u.Email, id)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	items, skipped, err := s.reorderCartItems(r, o)
+	if err != nil {
+		log.Error().Err(err).Str("order_id", o.ID.String()).Msg("cuenta: repetir pedido")
+		redirectAccountOrder(w, r, o.ID, "carrito")
+		return
+	}
+	if len(items) == 0 {
+		redirectAccountOrder(w, r, o.ID, "sin_productos")
+		return
+	}
+	cp := s.loadCart(w, r)
+	cp.Items = append(cp.Items, items...)
+	s.saveCart(w, r, cp)
+	http.Redirect(w, r, "/cart?reordered="+strconv.Itoa(len(items))+"&skipped="+strconv.Itoa(skipped), http.StatusFound)
+}
+
+// reorderCartItems arma las líneas de carrito de una orden. Saltea productos borrados y
+// personalizaciones que ya no validan contra los campos actuales del producto.
+func (s *Server) reorderCartItems(r *http.Request, o *domain.Order) ([]cartItem, int, error) {
+	ids := make([]uuid.UUID, 0, len(o.Items))
+	for _, it := range o.Items {
+		if it.ProductID != nil {
+			ids = append(ids, *it.ProductID)
+		}
+	}
+	products, err := s.products.ListByIDs(r.Context(), ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	slugByID := make(map[uuid.UUID]string, len(products))
+	for _, p := range products {
+		slugByID[p.ID] = p.Slug
+	}
+	var items []cartItem
+	skipped := 0
+	for _, it := range o.Items {
+		if it.ProductID == nil || slugByID[*it.ProductID] == "" || it.Qty <= 0 {
+			skipped++
+			continue
+		}
+		p, err := s.products.GetBySlug(r.Context(), slugByID[*it.ProductID])
+		if err != nil {
+			skipped++
+			continue
+		}
+		pz, err := reorderPersonalization(s.products, p, it.PersonalizationValues())
+		if err != nil {
+			skipped++
+			continue
+		}
+		items = append(items, cartItem{
+			Slug:        p.Slug,
+			Color:       it.Color,
+			Observation: normalizeCartObservation(it.Observation()),
+			Qty:         it.Qty,
+			Price:       p.BasePrice + domain.PersonalizationSurcharge(pz),
+			PZ:          domain.DecodePersonalization(cartPZKey(pz)),
+		})
+	}
+	return items, skipped, nil
+}
+
+func reorderPersonalization(uc *usecase.ProductUC, p *domain.Product, vals []domain.PersonalizationValue) ([]domain.PersonalizationValue, error) {
+	if len(p.Personalization) == 0 {
+		if len(vals) > 0 {
+			return nil, errors.New("el producto ya no admite personalización")
+		}
+		return nil, nil
+	}
+	input := make(map[uuid.UUID]string, len(vals))
+	for _, v := range vals {
+		input[v.FieldID] = v.Value
+	}
+	return uc.ResolvePersonalization(p, input)
+}
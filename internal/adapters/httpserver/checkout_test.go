@@ -0,0 +1,104 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/phenrril/tienda3d/internal/usecase"
+)
+
+func TestCheckoutFormValidate(t *testing.T) {
+	base := url.Values{"email": {"ana@example.com"}, "name": {"Ana"}}
+	with := func(kv ...string) url.Values {
+		v := url.Values{}
+		for k, vs := range base {
+			v[k] = vs
+		}
+		for i := 0; i+1 < len(kv); i += 2 {
+			v.Set(kv[i], kv[i+1])
+		}
+		return v
+	}
+	envio := []string{"shipping", "envio", "province", "Córdoba", "address_envio", "San Martín 123", "postal_code", "5000", "dni", "30111222", "phone", "3511234567"}
+
+	cases := []struct {
+		name string
+		form url.Values
+		want string
+	}{
+		{"retiro por defecto", with(), ""},
+		{"sin email", url.Values{"name": {"Ana"}}, "datos"},
+		{"sin nombre", url.Values{"email": {"ana@example.com"}}, "datos"},
+		{"envio completo", with(envio...), ""},
+		{"envio sin provincia", with(append(envio, "province", "")...), "envio"},
+		{"envio sin telefono", with(append(envio, "phone", "")...), "envio"},
+		{"envio usa la direccion de su bloque", with(append(envio, "address_envio", "", "address", "Otra 1")...), "envio"},
+		{"envio dni corto", with(append(envio, "dni", "123")...), "formato"},
+		{"envio dni con puntos", with(append(envio, "dni", "30.111.222")...), "formato"},
+		{"envio cp con letras", with(append(envio, "postal_code", "S2000")...), "formato"},
+		{"cadete completo", with("shipping", "cadete", "address_cadete", "Mitre 50", "phone", "341555"), ""},
+		{"cadete sin telefono", with("shipping", "cadete", "address_cadete", "Mitre 50"), "cadete"},
+		{"cadete sin direccion", with("shipping", "cadete", "address_envio", "Mitre 50", "phone", "341555"), "cadete"},
+	}
+	for _, c := range cases {
+		f := parseCheckoutForm(c.form)
+		if got := f.validate(); got != c.want {
+			t.Errorf("%s: validate() = %q; want %q", c.name, got, c.want)
+		}
+		if c.want != "" && cartErrorNotice(c.want) == "" {
+			t.Errorf("%s: el código %q no tiene mensaje en el carrito", c.name, c.want)
+		}
+	}
+}
+
+func TestParseCheckoutFormDefaults(t *testing.T) {
+	f := parseCheckoutForm(url.Values{
+		"email": {"ana@example.com"}, "name": {"Ana"},
+		"shipping": {"cadete"}, "address_cadete": {"Mitre 50"}, "phone": {"341555"},
+		"postal_code": {"5000"}, "postal_code_cadete": {" 2000 "}, "coupon_code": {" PROMO "},
+	})
+	if f.PaymentMethod != "efectivo" {
+		t.Errorf("PaymentMethod = %q", f.PaymentMethod)
+	}
+	if f.PostalCode != "2000" || f.Address != "Mitre 50" || f.CouponCode != "PROMO" {
+		t.Errorf("cadete: postal %q, address %q, coupon %q", f.PostalCode, f.Address, f.CouponCode)
+	}
+	if code := f.validate(); code != "" || f.Province != "Santa Fe" {
+		t.Errorf("cadete sin provincia: code %q, province %q", code, f.Province)
+	}
+	if f := parseCheckoutForm(url.Values{}); f.ShippingMethod != "retiro" {
+		t.Errorf("ShippingMethod por defecto = %q", f.ShippingMethod)
+	}
+}
+
+func TestCartErrorNoticeIgnoresUnknownCodes(t *testing.T) {
+	for _, code := range []string{"", "Tu cuenta fue suspendida, ingresá en http://example.com", "<script>alert(1)</script>", "msg"} {
+		if got := cartErrorNotice(code); got != "" {
+			t.Errorf("cartErrorNotice(%q) = %q; want vacío", code, got)
+		}
+	}
+}
+
+func TestCouponErrorCode(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{usecase.ErrCouponNotFound, "cupon_no_encontrado"},
+		{usecase.ErrCouponExpired, "cupon_expirado"},
+		{fmt.Errorf("%w (requerido: $5000.00)", usecase.ErrCouponMinPurchase), "cupon_minimo"},
+		{fmt.Errorf("%w (2): completá", usecase.ErrCouponPendingOrder), "cupon_pendiente"},
+		{errors.New("error al buscar cupón: timeout"), "cupon_invalido"},
+	}
+	for _, c := range cases {
+		got := couponErrorCode(c.err)
+		if got != c.want {
+			t.Errorf("couponErrorCode(%v) = %q; want %q", c.err, got, c.want)
+		}
+		if cartErrorNotice(got) == "" {
+			t.Errorf("el código %q no tiene mensaje en el carrito", got)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// loadCart devuelve el carrito del visitante: el guardado en la base si está logueado,
+// o el de la cookie firmada si no. Si la base falla se usa la cookie.
+func (s *Server) loadCart(w http.ResponseWriter, r *http.Request) cartPayload {
+	if s.carts == nil {
+		return readCart(r)
+	}
+	u := readUserSession(w, r)
+	if u == nil || u.Email == "" {
+		return readCart(r)
+	}
+	items, err := s.carts.Load(r.Context(), u.Email)
+	if err != nil {
+		log.Error().Err(err).Str("email", u.Email).Msg("cargar carrito")
+		return readCart(r)
+	}
+	return cartPayload{Items: items}
+}
+
+// saveCart guarda el carrito donde corresponde según haya sesión de cliente.
+func (s *Server) saveCart(w http.ResponseWriter, r *http.Request, cp cartPayload) {
+	if s.carts != nil {
+		if u := readUserSession(w, r); u != nil && u.Email != "" {
+			err := s.carts.Save(r.Context(), u.Email, cp.Items)
+			if err == nil {
+				return
+			}
+			log.Error().Err(err).Str("email", u.Email).Msg("guardar carrito")
+		}
+	}
+	writeCart(w, cp)
+}
+
+// mergeCartOnLogin pasa el carrito anónimo de la cookie al carrito guardado del cliente.
+func (s *Server) mergeCartOnLogin(w http.ResponseWriter, r *http.Request, email string) {
+	if s.carts == nil || email == "" {
+		return
+	}
+	anon := readCart(r)
+	if len(anon.Items) == 0 {
+		return
+	}
+	if _, err := s.carts.Merge(r.Context(), email, anon.Items); err != nil {
+		log.Error().Err(err).Str("email", email).Msg("unir carrito al loguear")
+		return
+	}
+	writeCart(w, cartPayload{})
+}
@@ -0,0 +1,256 @@
+package httpserver
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// variantWidths son los anchos que se generan; ?w= se redondea al siguiente para no
+// permitir un tamaño distinto por request.
+var variantWidths = []int{160, 240, 320, 480, 640, 800, 960, 1200, 1600}
+
+// variantAspects son las proporciones ancho:alto admitidas con ?h=; el alto pedido se lleva a la
+// más cercana para que tampoco se pueda pedir una variante distinta por request.
+var variantAspects = [][2]int{{2, 1}, {16, 9}, {3, 2}, {4, 3}, {5, 4}, {1, 1}, {4, 5}, {3, 4}, {2, 3}, {1, 2}}
+
+const maxVariantSourceBytes = 25 << 20
+
+// maxVariantSourcePixels limita las dimensiones de la original: un PNG chico en bytes puede
+// declarar dimensiones enormes y ocupar gigas al decodificarse.
+const maxVariantSourcePixels = 40_000_000
+
+func snapVariantWidth(w int) int {
+	for _, v := range variantWidths {
+		if w <= v {
+			return v
+		}
+	}
+	return variantWidths[len(variantWidths)-1]
+}
+
+// snapVariantHeight devuelve el alto para el ancho snapped con la proporción de variantAspects
+// más cercana a w:h.
+func snapVariantHeight(snapped, w, h int) int {
+	want := math.Log(float64(h) / float64(w))
+	best, bestDiff := variantAspects[0], math.Inf(1)
+	for _, a := range variantAspects {
+		if d := math.Abs(math.Log(float64(a[1])/float64(a[0])) - want); d < bestDiff {
+			best, bestDiff = a, d
+		}
+	}
+	return snapped * best[1] / best[0]
+}
+
+type variant struct {
+	key         string
+	data        []byte
+	contentType string
+}
+
+// variantCache es un LRU en memoria acotado por bytes para las variantes redimensionadas.
+type variantCache struct {
+	mu    sync.Mutex
+	max   int64
+	size  int64
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newVariantCache(maxBytes int64) *variantCache {
+	return &variantCache{max: maxBytes, ll: list.New(), items: map[string]*list.Element{}}
+}
+
+func (c *variantCache) get(key string) (*variant, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		return el.Value.(*variant), true
+	}
+	return nil, false
+}
+
+func (c *variantCache) put(v *variant) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if int64(len(v.data)) > c.max/4 {
+		return
+	}
+	if el, ok := c.items[v.key]; ok {
+		c.size -= int64(len(el.Value.(*variant).data))
+		c.ll.Remove(el)
+	}
+	c.items[v.key] = c.ll.PushFront(v)
+	c.size += int64(len(v.data))
+	for c.size > c.max && c.ll.Len() > 0 {
+		el := c.ll.Back()
+		old := el.Value.(*variant)
+		c.ll.Remove(el)
+		delete(c.items, old.key)
+		c.size -= int64(len(old.data))
+	}
+}
+
+// serveImageVariant responde /uploads/images/x.jpg?w=480[&h=480] aplicando el recorte guardado
+// de la imagen y, si se pide alto, un recorte "cover" centrado en el punto focal.
+// Devuelve false si no pudo generar la variante (el caller sirve el original).
+func (s *Server) serveImageVariant(w http.ResponseWriter, r *http.Request, rel string) bool {
+	q := r.URL.Query()
+	wd, err := strconv.Atoi(q.Get("w"))
+	if err != nil || wd <= 0 {
+		return false
+	}
+	snapped := snapVariantWidth(wd)
+	ht := 0
+	if v, err := strconv.Atoi(q.Get("h")); err == nil && v > 0 {
+		// alto pedido: recorte "cover" con la proporción admitida más cercana a w:h
+		ht = snapVariantHeight(snapped, wd, v)
+	}
+	wd = snapped
+
+	meta := domain.Image{FocalX: 0.5, FocalY: 0.5}
+	if s.products != nil {
+		for _, u := range []string{"/uploads/" + rel, "uploads/" + rel} {
+			if im, err := s.products.FindImageByURL(r.Context(), u); err == nil && im != nil {
+				meta = *im
+				break
+			}
+		}
+	}
+	key := fmt.Sprintf("%s|%d|%d|%.4f,%.4f,%.4f,%.4f|%.4f,%.4f", rel, wd, ht, meta.CropX, meta.CropY, meta.CropW, meta.CropH, meta.FocalX, meta.FocalY)
+	if v, ok := s.variants.get(key); ok {
+		writeVariant(w, r, v)
+		return true
+	}
+
+	rc, err := s.storage.Open(r.Context(), "uploads/"+rel)
+	if err != nil {
+		return false
+	}
+	raw, err := io.ReadAll(io.LimitReader(rc, maxVariantSourceBytes+1))
+	_ = rc.Close()
+	if err != nil || len(raw) > maxVariantSourceBytes {
+		return false
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxVariantSourcePixels {
+		return false
+	}
+	src, format, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return false
+	}
+	img := cropImage(src, meta, wd, ht)
+	b := img.Bounds()
+	if b.Dx() <= wd && ht == 0 && !meta.HasCrop() {
+		// no agrandar: la original ya es más chica que la variante
+		return false
+	}
+	dw := wd
+	if b.Dx() < dw {
+		dw = b.Dx()
+	}
+	dh := b.Dy() * dw / b.Dx()
+	if dh < 1 {
+		dh = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+
+	var buf bytes.Buffer
+	v := &variant{key: key}
+	if format == "png" || format == "gif" {
+		v.contentType = "image/png"
+		err = png.Encode(&buf, dst)
+	} else {
+		v.contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 82})
+	}
+	if err != nil {
+		return false
+	}
+	v.data = buf.Bytes()
+	s.variants.put(v)
+	writeVariant(w, r, v)
+	return true
+}
+
+func writeVariant(w http.ResponseWriter, r *http.Request, v *variant) {
+	w.Header().Set("Content-Type", v.contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(v.data)))
+	w.Header().Set("Cache-Control", "public, max-age=604800")
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(v.data)
+}
+
+type subImager interface {
+	SubImage(r image.Rectangle) image.Image
+}
+
+// cropImage aplica el recorte guardado y, con alto pedido, ajusta a la relación wd:ht
+// manteniendo el punto focal lo más centrado posible.
+func cropImage(src image.Image, meta domain.Image, wd, ht int) image.Image {
+	b := src.Bounds()
+	rect := b
+	if meta.HasCrop() {
+		x0 := b.Min.X + int(meta.CropX*float64(b.Dx()))
+		y0 := b.Min.Y + int(meta.CropY*float64(b.Dy()))
+		x1 := x0 + int(meta.CropW*float64(b.Dx()))
+		y1 := y0 + int(meta.CropH*float64(b.Dy()))
+		rect = image.Rect(x0, y0, x1, y1).Intersect(b)
+		if rect.Empty() {
+			rect = b
+		}
+	}
+	if ht > 0 {
+		fx := b.Min.X + int(meta.FocalX*float64(b.Dx()))
+		fy := b.Min.Y + int(meta.FocalY*float64(b.Dy()))
+		target := float64(wd) / float64(ht)
+		cw, ch := rect.Dx(), rect.Dy()
+		if float64(cw)/float64(ch) > target {
+			cw = int(float64(ch) * target)
+		} else {
+			ch = int(float64(cw) / target)
+		}
+		x0 := clampInt(fx-cw/2, rect.Min.X, rect.Max.X-cw)
+		y0 := clampInt(fy-ch/2, rect.Min.Y, rect.Max.Y-ch)
+		rect = image.Rect(x0, y0, x0+cw, y0+ch)
+	}
+	if rect == b {
+		return src
+	}
+	if si, ok := src.(subImager); ok {
+		return si.SubImage(rect)
+	}
+	return src
+}
+
+func clampInt(v, lo, hi int) int {
+	if hi < lo {
+		return lo
+	}
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
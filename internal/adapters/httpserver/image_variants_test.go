@@ -0,0 +1,94 @@
+package httpserver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/phenrril/tienda3d/internal/adapters/storage/localfs"
+)
+
+func TestSnapVariantHeight(t *testing.T) {
+	cases := []struct {
+		snapped, w, h int
+		want          int
+	}{
+		{480, 480, 480, 480},
+		{480, 400, 400, 480},
+		{480, 480, 481, 480},
+		{480, 480, 600, 600},
+		{480, 480, 640, 640},
+		{480, 480, 270, 270},
+		{480, 480, 5000, 960},
+		{480, 480, 1, 240},
+		{1600, 2000, 4000, 3200},
+	}
+	for _, c := range cases {
+		if got := snapVariantHeight(c.snapped, c.w, c.h); got != c.want {
+			t.Errorf("snapVariantHeight(%d, %d, %d) = %d; want %d", c.snapped, c.w, c.h, got, c.want)
+		}
+	}
+
+	heights := map[int]bool{}
+	for h := 1; h <= 10000; h++ {
+		heights[snapVariantHeight(480, 480, h)] = true
+	}
+	if len(heights) != len(variantAspects) {
+		t.Fatalf("altos distintos para w=480: %d; want %d", len(heights), len(variantAspects))
+	}
+}
+
+func TestServeImageVariantRejectsHugeDimensions(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) {
+		full := filepath.Join(dir, "images", name)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1000, 500))); err != nil {
+		t.Fatal(err)
+	}
+	write("chica.png", buf.Bytes())
+	write("bomba.png", withPNGSize(t, buf.Bytes(), 50000, 50000))
+
+	s := &Server{storage: localfs.New(dir), variants: newVariantCache(1 << 20)}
+	rec := httptest.NewRecorder()
+	if !s.serveImageVariant(rec, httptest.NewRequest(http.MethodGet, "/uploads/images/chica.png?w=480&h=480", nil), "images/chica.png") {
+		t.Fatal("no se generó la variante de una imagen normal")
+	}
+	cfg, err := png.DecodeConfig(rec.Body)
+	if err != nil || cfg.Width != 480 || cfg.Height != 480 {
+		t.Fatalf("variante %dx%d, %v", cfg.Width, cfg.Height, err)
+	}
+
+	rec = httptest.NewRecorder()
+	if s.serveImageVariant(rec, httptest.NewRequest(http.MethodGet, "/uploads/images/bomba.png?w=480", nil), "images/bomba.png") {
+		t.Fatal("se decodificó una imagen de 50000x50000")
+	}
+}
+
+// withPNGSize cambia las dimensiones declaradas en el IHDR de un PNG (y su CRC).
+func withPNGSize(t *testing.T, data []byte, w, h uint32) []byte {
+	t.Helper()
+	out := bytes.Clone(data)
+	// firma (8) + largo (4) + "IHDR" (4): ancho y alto son los primeros 8 bytes del chunk
+	if string(out[12:16]) != "IHDR" {
+		t.Fatal("PNG sin IHDR al principio")
+	}
+	binary.BigEndian.PutUint32(out[16:20], w)
+	binary.BigEndian.PutUint32(out[20:24], h)
+	binary.BigEndian.PutUint32(out[29:33], crc32.ChecksumIEEE(out[12:29]))
+	return out
+}
@@ -0,0 +1,144 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+func redirectOrderArchives(w http.ResponseWriter, r *http.Request, key, msg string) {
+	http.Redirect(w, r, "/admin/orders/archives?"+key+"="+url.QueryEscape(msg), http.StatusFound)
+}
+
+// handleAdminOrderArchives lista los archivos de órdenes borradas.
+func (s *Server) handleAdminOrderArchives(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	if s.orderArchives == nil {
+		redirectAdminOrders(w, r, "err", "Archivo de órdenes no disponible")
+		return
+	}
+	list, err := s.orderArchives.List(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("listar archivos de órdenes")
+		http.Error(w, "err", http.StatusInternalServerError)
+		return
+	}
+	s.render(w, "admin_order_archives.html", map[string]any{
+		"Archives":   list,
+		"Flash":      strings.TrimSpace(r.URL.Query().Get("ok")),
+		"FlashError": strings.TrimSpace(r.URL.Query().Get("err")),
+		"AdminToken": s.readAdminToken(r),
+	})
+}
+
+// handleAdminOrderArchiveView muestra las órdenes guardadas en un archivo.
+func (s *Server) handleAdminOrderArchiveView(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	a, ok := s.findOrderArchive(w, r)
+	if !ok {
+		return
+	}
+	entries, err := s.orderArchives.Entries(r.Context(), a)
+	if err != nil {
+		log.Error().Err(err).Str("archive_id", a.ID.String()).Msg("leer archivo de órdenes")
+		redirectOrderArchives(w, r, "err", "No pude leer el archivo "+filepath.Base(a.Path))
+		return
+	}
+	s.render(w, "admin_order_archive_view.html", map[string]any{
+		"Archive":    a,
+		"Entries":    entries,
+		"AdminToken": s.readAdminToken(r),
+	})
+}
+
+// handleAdminOrderArchiveDownload baja el archivo comprimido tal cual está en el storage.
+func (s *Server) handleAdminOrderArchiveDownload(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	a, ok := s.findOrderArchive(w, r)
+	if !ok {
+		return
+	}
+	rc, err := s.orderArchives.Open(r.Context(), a)
+	if err != nil {
+		log.Error().Err(err).Str("archive_id", a.ID.String()).Msg("abrir archivo de órdenes")
+		redirectOrderArchives(w, r, "err", "No encontré el archivo en el storage")
+		return
+	}
+	defer rc.Close()
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="ordenes_%s_%s.jsonl.gz"`, a.PeriodFrom.Format("2006-01-02"), a.PeriodTo.Format("2006-01-02")))
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = io.Copy(w, rc)
+}
+
+// handleAdminOrderArchiveRestore vuelve a cargar las órdenes de un archivo.
+func (s *Server) handleAdminOrderArchiveRestore(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.orderArchives == nil {
+		redirectAdminOrders(w, r, "err", "Archivo de órdenes no disponible")
+		return
+	}
+	id, err := uuid.Parse(r.FormValue("id"))
+	if err != nil {
+		redirectOrderArchives(w, r, "err", "id inválido")
+		return
+	}
+	a, n, err := s.orderArchives.Restore(r.Context(), id, s.adminEmail(r))
+	if err != nil {
+		log.Error().Err(err).Str("archive_id", id.String()).Msg("restaurar archivo de órdenes")
+		redirectOrderArchives(w, r, "err", "No se pudo restaurar: "+err.Error())
+		return
+	}
+	log.Warn().Str("archive_id", a.ID.String()).Int("restored_orders", n).Str("actor", a.RestoredBy).Msg("admin restored order archive")
+	if n == 0 {
+		redirectOrderArchives(w, r, "ok", "Las órdenes del archivo ya estaban cargadas; no se restauró nada")
+		return
+	}
+	redirectOrderArchives(w, r, "ok", fmt.Sprintf("Se restauraron %d órdenes de %d", n, a.Orders))
+}
+
+func (s *Server) findOrderArchive(w http.ResponseWriter, r *http.Request) (*domain.OrderArchive, bool) {
+	if s.orderArchives == nil {
+		redirectAdminOrders(w, r, "err", "Archivo de órdenes no disponible")
+		return nil, false
+	}
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		redirectOrderArchives(w, r, "err", "id inválido")
+		return nil, false
+	}
+	a, err := s.orderArchives.Get(r.Context(), id)
+	if err != nil {
+		if !errors.Is(err, domain.ErrNotFound) {
+			log.Error().Err(err).Str("archive_id", id.String()).Msg("buscar archivo de órdenes")
+		}
+		redirectOrderArchives(w, r, "err", "Archivo no encontrado")
+		return nil, false
+	}
+	return a, true
+}
@@ -0,0 +1,154 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+	"github.com/phenrril/tienda3d/internal/usecase"
+)
+
+// orderEditNewRows: filas vacías del formulario para agregar productos.
+const orderEditNewRows = 3
+
+func redirectOrderEdit(w http.ResponseWriter, r *http.Request, id uuid.UUID, key, msg string) {
+	http.Redirect(w, r, "/admin/orders/edit?id="+id.String()+"&"+key+"="+url.QueryEscape(msg), http.StatusFound)
+}
+
+// handleAdminOrderEdit muestra (GET) y guarda (POST) la edición de items, envío y dirección de
+// una orden.
+func (s *Server) handleAdminOrderEdit(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	if s.orderEdits == nil {
+		redirectAdminOrders(w, r, "err", "Edición de órdenes no disponible")
+		return
+	}
+	id, err := uuid.Parse(r.FormValue("id"))
+	if err != nil {
+		redirectAdminOrders(w, r, "err", "id inválido")
+		return
+	}
+	if r.Method == http.MethodPost {
+		s.saveAdminOrderEdit(w, r, id)
+		return
+	}
+	o, err := s.orders.Orders.FindByID(r.Context(), id)
+	if err != nil {
+		redirectAdminOrders(w, r, "err", "orden no encontrada")
+		return
+	}
+	edits, err := s.orderEdits.History(r.Context(), o.ID)
+	if err != nil {
+		log.Error().Err(err).Str("order_id", o.ID.String()).Msg("historial de ediciones")
+	}
+	products, _, err := s.products.List(r.Context(), domain.ProductFilter{Page: 1, PageSize: 1000})
+	if err != nil {
+		log.Error().Err(err).Msg("productos para editar orden")
+	}
+	s.render(w, "admin_order_edit.html", map[string]any{
+		"Order":      o,
+		"Editable":   usecase.OrderEditable(o),
+		"Edits":      edits,
+		"Products":   products,
+		"Provinces":  domain.Provinces,
+		"NewRows":    make([]struct{}, orderEditNewRows),
+		"Flash":      strings.TrimSpace(r.URL.Query().Get("ok")),
+		"FlashError": strings.TrimSpace(r.URL.Query().Get("err")),
+		"AdminToken": s.readAdminToken(r),
+	})
+}
+
+func (s *Server) saveAdminOrderEdit(w http.ResponseWriter, r *http.Request, id uuid.UUID) {
+	if err := r.ParseForm(); err != nil {
+		redirectOrderEdit(w, r, id, "err", "formulario inválido")
+		return
+	}
+	in, err := parseOrderEditForm(r.PostForm)
+	if err != nil {
+		redirectOrderEdit(w, r, id, "err", err.Error())
+		return
+	}
+
+	o, edit, err := s.orderEdits.Edit(r.Context(), id, in, s.adminEmail(r))
+	if err != nil {
+		if !errors.Is(err, usecase.ErrNoOrderChanges) && !errors.Is(err, usecase.ErrOrderNotEditable) {
+			log.Error().Err(err).Str("order_id", id.String()).Msg("admin editar orden")
+		}
+		redirectOrderEdit(w, r, id, "err", err.Error())
+		return
+	}
+	// la preferencia vieja cobraría el total anterior: se arma una nueva con el total editado
+	if o.MPPreferenceID != "" && edit.NewTotal != edit.PrevTotal && canPayOnline(o) {
+		if _, err := s.payments.CreatePreference(r.Context(), o); err != nil {
+			log.Error().Err(err).Str("order_id", o.ID.String()).Msg("preferencia MP de orden editada")
+		} else if err := s.orders.Orders.Save(r.Context(), o); err != nil {
+			log.Error().Err(err).Str("order_id", o.ID.String()).Msg("guardar preferencia de orden editada")
+		}
+	}
+	redirectOrderEdit(w, r, id, "ok", "Orden "+o.Code()+" actualizada: total $"+strconv.FormatFloat(o.Total, 'f', 2, 64))
+}
+
+// parseOrderEditForm lee el formulario de edición. Una cantidad vacía o inválida es un error: un
+// item sólo se quita con su casilla "quitar".
+func parseOrderEditForm(f url.Values) (usecase.OrderEditInput, error) {
+	in := usecase.OrderEditInput{
+		ShippingMethod: f.Get("shipping"),
+		Address:        f.Get("address"),
+		Province:       f.Get("province"),
+		PostalCode:     f.Get("postal_code"),
+		Note:           f.Get("note"),
+	}
+	ids, qtys, colors, obs := f["item_id"], f["item_qty"], f["item_color"], f["item_obs"]
+	for i := range ids {
+		itemID, err := uuid.Parse(ids[i])
+		if err != nil {
+			return in, errors.New("item inválido")
+		}
+		qty := 0
+		if f.Get("item_remove_"+itemID.String()) != "1" {
+			qty, err = strconv.Atoi(strings.TrimSpace(formIndex(qtys, i)))
+			if err != nil || qty <= 0 {
+				return in, fmt.Errorf("cantidad inválida en el item %d (para quitarlo marcá \"quitar\")", i+1)
+			}
+		}
+		in.Items = append(in.Items, usecase.OrderEditItem{ID: itemID, Qty: qty, Color: formIndex(colors, i), Observation: formIndex(obs, i)})
+	}
+	slugs, newQtys, newColors, newObs := f["new_slug"], f["new_qty"], f["new_color"], f["new_obs"]
+	for i := range slugs {
+		// las filas para agregar que quedaron vacías no cuentan
+		if strings.TrimSpace(slugs[i]) == "" {
+			continue
+		}
+		qty, err := strconv.Atoi(strings.TrimSpace(formIndex(newQtys, i)))
+		if err != nil || qty <= 0 {
+			return in, fmt.Errorf("cantidad inválida para %s", strings.TrimSpace(slugs[i]))
+		}
+		in.Items = append(in.Items, usecase.OrderEditItem{ProductSlug: slugs[i], Qty: qty, Color: formIndex(newColors, i), Observation: formIndex(newObs, i)})
+	}
+	if raw := strings.TrimSpace(f.Get("shipping_cost")); raw != "" {
+		cost, err := parseMoneyAR(raw)
+		if err != nil {
+			return in, errors.New("Costo de envío inválido")
+		}
+		in.ShippingCost = &cost
+	}
+	return in, nil
+}
+
+// formIndex devuelve el valor i de un campo repetido del formulario (vacío si falta).
+func formIndex(vals []string, i int) string {
+	if i < len(vals) {
+		return vals[i]
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package httpserver
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseOrderEditForm(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	base := func() url.Values {
+		return url.Values{
+			"item_id":  {a.String(), b.String()},
+			"item_qty": {"2", "1"},
+			"new_slug": {"maceta", "", ""},
+			"new_qty":  {"3", "1", "1"},
+		}
+	}
+
+	in, err := parseOrderEditForm(base())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(in.Items) != 3 || in.Items[0].Qty != 2 || in.Items[1].Qty != 1 || in.Items[2].ProductSlug != "maceta" || in.Items[2].Qty != 3 {
+		t.Fatalf("items = %+v", in.Items)
+	}
+
+	// una cantidad vacía o inválida no borra el item: hay que marcar "quitar"
+	for _, qty := range []string{"", "abc", "0", "-1"} {
+		f := base()
+		f["item_qty"] = []string{qty, "1"}
+		if _, err := parseOrderEditForm(f); err == nil {
+			t.Errorf("cantidad %q no dio error", qty)
+		}
+		f.Set("item_remove_"+a.String(), "1")
+		in, err := parseOrderEditForm(f)
+		if err != nil {
+			t.Fatalf("cantidad %q con quitar: %v", qty, err)
+		}
+		if in.Items[0].Qty != 0 {
+			t.Fatalf("item marcado para quitar con qty %d", in.Items[0].Qty)
+		}
+	}
+
+	f := base()
+	f["new_qty"] = []string{"", "1", "1"}
+	if _, err := parseOrderEditForm(f); err == nil {
+		t.Error("producto nuevo sin cantidad no dio error")
+	}
+	f = base()
+	f.Set("shipping_cost", "abc")
+	if _, err := parseOrderEditForm(f); err == nil {
+		t.Error("costo de envío inválido no dio error")
+	}
+}
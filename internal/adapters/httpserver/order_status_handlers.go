@@ -0,0 +1,287 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+	"github.com/phenrril/tienda3d/internal/usecase"
+)
+
+func redirectAdminOrders(w http.ResponseWriter, r *http.Request, key, msg string) {
+	http.Redirect(w, r, "/admin/orders?"+key+"="+url.QueryEscape(msg), http.StatusFound)
+}
+
+// manualNextStatuses son los estados que se pueden elegir a mano: "reembolsada" sólo se llega
+// devolviendo la plata con handleAdminOrderRefund.
+func manualNextStatuses(from domain.OrderStatus) []domain.OrderStatus {
+	var out []domain.OrderStatus
+	for _, st := range usecase.NextStatuses(from) {
+		if st != domain.OrderStatusRefunded {
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+// handleAdminOrderStatus cambia el estado de una orden a mano, respetando las transiciones válidas.
+func (s *Server) handleAdminOrderStatus(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/orders", http.StatusFound)
+		return
+	}
+	id, err := uuid.Parse(r.FormValue("id"))
+	if err != nil {
+		redirectAdminOrders(w, r, "err", "id inválido")
+		return
+	}
+	o, err := s.orders.Orders.FindByID(r.Context(), id)
+	if err != nil {
+		redirectAdminOrders(w, r, "err", "orden no encontrada")
+		return
+	}
+	to := domain.OrderStatus(strings.TrimSpace(r.FormValue("status")))
+	if to == domain.OrderStatusRefunded {
+		redirectAdminOrders(w, r, "err", "Para reembolsar usá la acción Reembolsar de la orden")
+		return
+	}
+	change := usecase.StatusChange{Actor: s.adminEmail(r), Source: domain.StatusSourceAdmin, Note: strings.TrimSpace(r.FormValue("note"))}
+	if err := s.orders.ChangeStatus(r.Context(), o, to, change); err != nil {
+		if errors.Is(err, domain.ErrInvalidTransition) {
+			redirectAdminOrders(w, r, "err", "No se puede pasar de "+domain.OrderStatusLabel(o.Status)+" a "+domain.OrderStatusLabel(to))
+			return
+		}
+		if errors.Is(err, domain.ErrStatusConflict) {
+			redirectAdminOrders(w, r, "err", "La orden "+o.Code()+" cambió de estado mientras tanto; revisala y volvé a intentar")
+			return
+		}
+		log.Error().Err(err).Str("order_id", id.String()).Msg("admin cambiar estado orden")
+		redirectAdminOrders(w, r, "err", "No se pudo guardar el estado")
+		return
+	}
+	// despachada a mano: avisamos al cliente igual que cuando se carga el seguimiento
+	if to == domain.OrderStatusShipped && s.tracking != nil && (o.ShippingMethod == domain.ShippingCourier || o.ShippingMethod == domain.ShippingCadete) {
+		if err := s.tracking.Advance(r.Context(), o, domain.ShipmentStageShipped, change); err != nil {
+			log.Error().Err(err).Str("order_id", id.String()).Msg("admin marcar despachada")
+		}
+	}
+	redirectAdminOrders(w, r, "ok", "Orden "+o.Code()+": "+domain.OrderStatusLabel(to))
+}
+
+// handleAdminOrderReopen vuelve una orden cancelada a "esperando pago" (ej. el cliente avisó que
+// va a pagar). Es la única forma de reabrirla: los avisos de pago no pueden hacerlo.
+func (s *Server) handleAdminOrderReopen(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/orders", http.StatusFound)
+		return
+	}
+	id, err := uuid.Parse(r.FormValue("id"))
+	if err != nil {
+		redirectAdminOrders(w, r, "err", "id inválido")
+		return
+	}
+	o, err := s.orders.Orders.FindByID(r.Context(), id)
+	if err != nil {
+		redirectAdminOrders(w, r, "err", "orden no encontrada")
+		return
+	}
+	change := usecase.StatusChange{Actor: s.adminEmail(r), Source: domain.StatusSourceAdmin, Note: strings.TrimSpace(r.FormValue("note"))}
+	if change.Note == "" {
+		change.Note = "reabierta desde el panel"
+	}
+	if err := s.orders.Reopen(r.Context(), o, change); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidTransition):
+			redirectAdminOrders(w, r, "err", "Sólo se pueden reabrir órdenes canceladas")
+		case errors.Is(err, domain.ErrStatusConflict):
+			redirectAdminOrders(w, r, "err", "La orden "+o.Code()+" cambió de estado mientras tanto; revisala y volvé a intentar")
+		default:
+			log.Error().Err(err).Str("order_id", id.String()).Msg("admin reabrir orden")
+			redirectAdminOrders(w, r, "err", "No se pudo reabrir la orden")
+		}
+		return
+	}
+	redirectAdminOrders(w, r, "ok", "Orden "+o.Code()+" reabierta: "+domain.OrderStatusLabel(o.Status))
+}
+
+// handleAdminOrderTracking carga a mano el correo y número de seguimiento, marca la orden como
+// despachada y avisa al cliente.
+func (s *Server) handleAdminOrderTracking(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/orders", http.StatusFound)
+		return
+	}
+	id, err := uuid.Parse(r.FormValue("id"))
+	if err != nil {
+		redirectAdminOrders(w, r, "err", "id inválido")
+		return
+	}
+	change := usecase.StatusChange{Actor: s.adminEmail(r), Source: domain.StatusSourceAdmin, Note: "seguimiento " + strings.TrimSpace(r.FormValue("tracking_number"))}
+	o, err := s.tracking.SetTracking(r.Context(), id, r.FormValue("carrier"), r.FormValue("tracking_number"), r.FormValue("tracking_url"), change)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			redirectAdminOrders(w, r, "err", "orden no encontrada")
+			return
+		}
+		log.Error().Err(err).Str("order_id", id.String()).Msg("admin cargar seguimiento")
+		redirectAdminOrders(w, r, "err", "No se pudo guardar el seguimiento: "+err.Error())
+		return
+	}
+	redirectAdminOrders(w, r, "ok", "Orden "+o.Code()+": seguimiento "+o.TrackingNumber+" cargado")
+}
+
+// handleAdminOrderRefund devuelve por MercadoPago todo lo que queda de la orden o el monto indicado.
+func (s *Server) handleAdminOrderRefund(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/orders", http.StatusFound)
+		return
+	}
+	id, err := uuid.Parse(r.FormValue("id"))
+	if err != nil {
+		redirectAdminOrders(w, r, "err", "id inválido")
+		return
+	}
+	if s.refunds == nil {
+		redirectAdminOrders(w, r, "err", "Reembolsos no disponibles")
+		return
+	}
+	amount := 0.0
+	if raw := strings.TrimSpace(r.FormValue("amount")); raw != "" {
+		amount, err = parseMoneyAR(raw)
+		if err != nil || amount <= 0 {
+			redirectAdminOrders(w, r, "err", "Monto inválido")
+			return
+		}
+	}
+	change := usecase.StatusChange{Actor: s.adminEmail(r), Source: domain.StatusSourceAdmin}
+	rf, err := s.refunds.Refund(r.Context(), id, amount, r.FormValue("reason"), change)
+	if err != nil {
+		if rf == nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				redirectAdminOrders(w, r, "err", "orden no encontrada")
+				return
+			}
+			log.Error().Err(err).Str("order_id", id.String()).Msg("admin reembolsar orden")
+			redirectAdminOrders(w, r, "err", "No se pudo reembolsar: "+err.Error())
+			return
+		}
+		// MercadoPago ya devolvió la plata; sólo falló guardar la orden
+		log.Error().Err(err).Str("order_id", id.String()).Str("refund_id", rf.GatewayID).Msg("admin guardar orden reembolsada")
+	}
+	// Refund guarda la orden; se vuelve a leer sólo para mostrar su número
+	o := &domain.Order{ID: id}
+	if found, err := s.orders.Orders.FindByID(r.Context(), id); err == nil {
+		o = found
+	}
+	redirectAdminOrders(w, r, "ok", "Orden "+o.Code()+": reembolso de $"+strconv.FormatFloat(rf.Amount, 'f', 2, 64)+" ("+rf.Status+")")
+}
+
+// handleAdminOrderDocument descarga el comprobante (/admin/orders/receipt) o la hoja de armado
+// (/admin/orders/packing-slip) de una orden en PDF.
+func (s *Server) handleAdminOrderDocument(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "id inválido", http.StatusBadRequest)
+		return
+	}
+	o, err := s.orders.Orders.FindByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "err", http.StatusInternalServerError)
+		return
+	}
+	name, gen := "comprobante", s.documents.Receipt
+	if strings.HasSuffix(r.URL.Path, "/packing-slip") {
+		name, gen = "armado", s.documents.PackingSlip
+	}
+	b, err := gen(o)
+	if err != nil {
+		log.Error().Err(err).Str("order_id", id.String()).Msg("generar PDF de orden")
+		http.Error(w, "no se pudo generar el PDF", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="`+name+"-"+o.Code()+`.pdf"`)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(b)
+}
+
+// writeTicket sirve un ticket ESC/POS para descargar (y mandarlo a la impresora a mano).
+func writeTicket(w http.ResponseWriter, name string, b []byte) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.bin"`)
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(b)
+}
+
+// handleAdminOrderTicket arma el ticket térmico de una orden: con GET se descarga y con POST se
+// manda a la impresora del mostrador.
+func (s *Server) handleAdminOrderTicket(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	id, err := uuid.Parse(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "id inválido", http.StatusBadRequest)
+		return
+	}
+	o, err := s.orders.Orders.FindByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "err", http.StatusInternalServerError)
+		return
+	}
+	b, err := s.tickets.OrderTicket(o)
+	if err != nil {
+		log.Error().Err(err).Str("order_id", id.String()).Msg("generar ticket de orden")
+		http.Error(w, "no se pudo generar el ticket", http.StatusInternalServerError)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeTicket(w, "ticket-"+o.Code(), b)
+		return
+	}
+	if s.printer == nil {
+		redirectAdminOrders(w, r, "err", "No hay impresora de tickets configurada (TICKET_PRINTER_ADDR)")
+		return
+	}
+	if err := s.printer.Print(r.Context(), b); err != nil {
+		log.Error().Err(err).Str("order_id", id.String()).Msg("imprimir ticket de orden")
+		redirectAdminOrders(w, r, "err", "No se pudo imprimir el ticket: "+err.Error())
+		return
+	}
+	redirectAdminOrders(w, r, "ok", "Orden "+o.Code()+": ticket enviado a la impresora")
+}
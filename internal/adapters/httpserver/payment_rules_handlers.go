@@ -0,0 +1,65 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+func redirectPaymentRules(w http.ResponseWriter, r *http.Request, key, msg string) {
+	http.Redirect(w, r, "/admin/pagos?"+key+"="+url.QueryEscape(msg), http.StatusFound)
+}
+
+// handleAdminPaymentRules muestra los descuentos/recargos por medio de pago y las cuotas.
+func (s *Server) handleAdminPaymentRules(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	rules, err := s.pricing.List(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("listar reglas de medio de pago")
+		http.Error(w, "err", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]any{
+		"Rules":      rules,
+		"Flash":      strings.TrimSpace(r.URL.Query().Get("ok")),
+		"FlashError": strings.TrimSpace(r.URL.Query().Get("err")),
+		"AdminToken": s.readAdminToken(r),
+	}
+	s.render(w, "admin_payment_rules.html", data)
+}
+
+func (s *Server) handleAdminPaymentRulesSave(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/pagos", http.StatusFound)
+		return
+	}
+	num := func(name string) float64 {
+		v, _ := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(r.FormValue(name)), ",", "."), 64)
+		return v
+	}
+	rule := &domain.PaymentRule{
+		Method:               r.FormValue("method"),
+		Percent:              num("percent"),
+		Label:                r.FormValue("label"),
+		Active:               r.FormValue("active") == "1",
+		InstallmentsInterest: num("installments_interest"),
+	}
+	rule.Installments, _ = strconv.Atoi(strings.TrimSpace(r.FormValue("installments")))
+	if err := s.pricing.Save(r.Context(), rule); err != nil {
+		redirectPaymentRules(w, r, "err", err.Error())
+		return
+	}
+	redirectPaymentRules(w, r, "ok", domain.PaymentMethodLabel(rule.Method)+" guardado")
+}
@@ -0,0 +1,234 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+const maxPersonalizationLogoBytes = 5 << 20
+
+var personalizationLogoExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".webp": true, ".svg": true, ".pdf": true}
+
+// readCartPersonalization toma los campos pz_<id> del formulario del producto, los valida
+// y guarda los logos subidos. Los logos se guardan recién cuando el resto es válido.
+func (s *Server) readCartPersonalization(r *http.Request, p *domain.Product) ([]domain.PersonalizationValue, error) {
+	if len(p.Personalization) == 0 {
+		return nil, nil
+	}
+	type logoFile struct {
+		name string
+		data []byte
+	}
+	input := map[uuid.UUID]string{}
+	logos := map[uuid.UUID]logoFile{}
+	for _, f := range p.Personalization {
+		key := "pz_" + f.ID.String()
+		if f.Kind != domain.PersonalizationLogo {
+			input[f.ID] = r.FormValue(key)
+			continue
+		}
+		if r.MultipartForm == nil {
+			continue
+		}
+		file, fh, err := r.FormFile(key)
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(file, maxPersonalizationLogoBytes+1))
+		_ = file.Close()
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		if len(data) > maxPersonalizationLogoBytes {
+			return nil, fmt.Errorf("\"%s\": el archivo supera %d MB", f.Label, maxPersonalizationLogoBytes>>20)
+		}
+		ext := strings.ToLower(filepath.Ext(fh.Filename))
+		ct := http.DetectContentType(data)
+		if !personalizationLogoExts[ext] || !(strings.HasPrefix(ct, "image/") || ct == "application/pdf" || (ext == ".svg" && strings.HasPrefix(ct, "text/"))) {
+			return nil, fmt.Errorf("\"%s\": subí una imagen PNG, JPG, WEBP, SVG o un PDF", f.Label)
+		}
+		logos[f.ID] = logoFile{name: fh.Filename, data: data}
+		input[f.ID] = fh.Filename
+	}
+	vals, err := s.products.ResolvePersonalization(p, input)
+	if err != nil {
+		return nil, err
+	}
+	for i := range vals {
+		lf, ok := logos[vals[i].FieldID]
+		if !ok {
+			continue
+		}
+		path, err := s.storage.SaveAttachment(r.Context(), lf.name, lf.data)
+		if err != nil {
+			log.Error().Err(err).Msg("guardar logo personalización")
+			return nil, errors.New("no se pudo guardar el logo, probá de nuevo")
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + strings.ReplaceAll(path, "\\", "/")
+		}
+		vals[i].Value = path
+	}
+	return vals, nil
+}
+
+// handleAdminAttachment sirve un archivo de clientes (logo de personalización) sólo al admin.
+func (s *Server) handleAdminAttachment(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", 302)
+		return
+	}
+	p := strings.TrimSpace(r.URL.Query().Get("path"))
+	if !strings.Contains(p, "attachments/") || strings.Contains(p, "..") {
+		http.NotFound(w, r)
+		return
+	}
+	rc, err := s.storage.Open(r.Context(), p)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer rc.Close()
+	ct := mime.TypeByExtension(strings.ToLower(filepath.Ext(p)))
+	if ct == "" || strings.Contains(ct, "svg") {
+		// SVG se descarga para que no ejecute scripts en nuestro origen
+		ct = "application/octet-stream"
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+filepath.Base(p)+"\"")
+	}
+	w.Header().Set("Content-Type", ct)
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	_, _ = io.Copy(w, rc)
+}
+
+func (s *Server) handleAdminProductPersonalization(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", 302)
+		return
+	}
+	slug := strings.TrimSpace(r.URL.Query().Get("slug"))
+	p, err := s.products.GetBySlug(r.Context(), slug)
+	if err != nil || p == nil {
+		http.Redirect(w, r, "/admin/products", 302)
+		return
+	}
+	data := map[string]any{
+		"Product":    p,
+		"Kinds":      personalizationKinds,
+		"Err":        r.URL.Query().Get("err"),
+		"Ok":         r.URL.Query().Get("ok"),
+		"AdminToken": s.readAdminToken(r),
+	}
+	s.render(w, "admin_product_personalization.html", data)
+}
+
+var personalizationKinds = []struct{ Value, Label string }{
+	{domain.PersonalizationText, "Texto"},
+	{domain.PersonalizationFont, "Tipografía"},
+	{domain.PersonalizationColor, "Color"},
+	{domain.PersonalizationLogo, "Logo (archivo)"},
+}
+
+func redirectPersonalization(w http.ResponseWriter, r *http.Request, slug, errMsg, okMsg string) {
+	u := "/admin/product_personalization?slug=" + url.QueryEscape(slug)
+	if errMsg != "" {
+		u += "&err=" + url.QueryEscape(errMsg)
+	}
+	if okMsg != "" {
+		u += "&ok=" + url.QueryEscape(okMsg)
+	}
+	http.Redirect(w, r, u, 302)
+}
+
+func (s *Server) handleAdminProductPersonalizationSave(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", 302)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/products", 302)
+		return
+	}
+	slug := strings.TrimSpace(r.FormValue("slug"))
+	p, err := s.products.GetBySlug(r.Context(), slug)
+	if err != nil || p == nil {
+		http.Redirect(w, r, "/admin/products", 302)
+		return
+	}
+	f := &domain.PersonalizationField{}
+	if id := strings.TrimSpace(r.FormValue("id")); id != "" {
+		uid, err := uuid.Parse(id)
+		if err != nil {
+			redirectPersonalization(w, r, p.Slug, "Campo inválido", "")
+			return
+		}
+		found := false
+		for i := range p.Personalization {
+			if p.Personalization[i].ID == uid {
+				f = &p.Personalization[i]
+				found = true
+				break
+			}
+		}
+		if !found {
+			redirectPersonalization(w, r, p.Slug, "El campo no pertenece al producto", "")
+			return
+		}
+	}
+	f.Label = r.FormValue("label")
+	f.Kind = r.FormValue("kind")
+	f.Required = r.FormValue("required") == "1"
+	f.Options = r.FormValue("options")
+	f.MaxLength, _ = strconv.Atoi(strings.TrimSpace(r.FormValue("max_length")))
+	if v, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(r.FormValue("surcharge")), ",", "."), 64); err == nil {
+		f.Surcharge = v
+	} else {
+		f.Surcharge = 0
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(r.FormValue("position"))); err == nil {
+		f.Position = v
+	}
+	if err := s.products.SavePersonalizationField(r.Context(), p, f); err != nil {
+		redirectPersonalization(w, r, p.Slug, err.Error(), "")
+		return
+	}
+	redirectPersonalization(w, r, p.Slug, "", "Campo guardado")
+}
+
+func (s *Server) handleAdminProductPersonalizationDelete(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", 302)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/products", 302)
+		return
+	}
+	slug := strings.TrimSpace(r.FormValue("slug"))
+	p, err := s.products.GetBySlug(r.Context(), slug)
+	if err != nil || p == nil {
+		http.Redirect(w, r, "/admin/products", 302)
+		return
+	}
+	uid, err := uuid.Parse(strings.TrimSpace(r.FormValue("id")))
+	if err != nil {
+		redirectPersonalization(w, r, p.Slug, "Campo inválido", "")
+		return
+	}
+	if err := s.products.DeletePersonalizationField(r.Context(), p.ID, uid); err != nil {
+		redirectPersonalization(w, r, p.Slug, "No se pudo eliminar el campo", "")
+		return
+	}
+	redirectPersonalization(w, r, p.Slug, "", "Campo eliminado")
+}
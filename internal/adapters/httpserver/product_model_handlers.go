@@ -0,0 +1,140 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+	"github.com/phenrril/tienda3d/internal/usecase"
+)
+
+// productModelURL devuelve la URL pública del GLB del producto, o "" si no tiene modelo 3D.
+func (s *Server) productModelURL(ctx context.Context, p *domain.Product) string {
+	if s.model3d == nil || p == nil {
+		return ""
+	}
+	pm, err := s.model3d.Get(ctx, p.ID)
+	if err != nil {
+		return ""
+	}
+	return "/product-model/" + p.Slug + ".glb?v=" + strconv.FormatInt(pm.UpdatedAt.Unix(), 10)
+}
+
+// handleProductModelAsset sirve /product-model/{slug}.glb. Sólo se publica el GLB convertido;
+// el STL/3MF original vive bajo models/ y /uploads no lo expone.
+func (s *Server) handleProductModelAsset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method", 405)
+		return
+	}
+	slug := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/product-model/"), ".glb")
+	if slug == "" || s.model3d == nil || strings.Contains(slug, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	p, err := s.products.GetBySlug(r.Context(), slug)
+	if err != nil || p == nil {
+		http.NotFound(w, r)
+		return
+	}
+	pm, rc, err := s.model3d.OpenAsset(r.Context(), p.ID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrNotFound) {
+			log.Warn().Err(err).Str("slug", slug).Msg("modelo 3D: abrir glb")
+		}
+		http.NotFound(w, r)
+		return
+	}
+	defer rc.Close()
+	w.Header().Set("Content-Type", "model/gltf-binary")
+	w.Header().Set("Content-Length", strconv.FormatInt(pm.AssetSize, 10))
+	w.Header().Set("Cache-Control", "public, max-age=604800")
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = io.Copy(w, rc)
+}
+
+func (s *Server) handleAdminProductModelUpload(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", 302)
+		return
+	}
+	if r.Method != http.MethodPost || s.model3d == nil {
+		http.Redirect(w, r, "/admin/products", 302)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, usecase.MaxProductModelBytes+(1<<20))
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "archivo demasiado grande", 400)
+		return
+	}
+	slug := strings.TrimSpace(r.FormValue("slug"))
+	p, err := s.products.GetBySlug(r.Context(), slug)
+	if err != nil || p == nil {
+		http.Redirect(w, r, "/admin/products", 302)
+		return
+	}
+	f, fh, err := r.FormFile("model")
+	if err != nil {
+		redirectProductImages(w, r, p.Slug, "Falta el archivo del modelo")
+		return
+	}
+	data, err := io.ReadAll(f)
+	_ = f.Close()
+	if err != nil {
+		redirectProductImages(w, r, p.Slug, "No se pudo leer el archivo")
+		return
+	}
+	pm, err := s.model3d.Attach(r.Context(), p.ID, fh.Filename, data)
+	if err != nil {
+		log.Error().Err(err).Str("slug", p.Slug).Msg("modelo 3D: adjuntar")
+		redirectProductImages(w, r, p.Slug, "Modelo 3D: "+err.Error())
+		return
+	}
+	log.Info().Str("slug", p.Slug).Int("triangles", pm.Triangles).Int("source_triangles", pm.SourceTriangles).Int64("bytes", pm.AssetSize).Msg("modelo 3D convertido")
+	redirectProductImages(w, r, p.Slug, "")
+}
+
+func (s *Server) handleAdminProductModelRemove(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", 302)
+		return
+	}
+	if r.Method != http.MethodPost || s.model3d == nil {
+		http.Redirect(w, r, "/admin/products", 302)
+		return
+	}
+	slug := strings.TrimSpace(r.FormValue("slug"))
+	p, err := s.products.GetBySlug(r.Context(), slug)
+	if err != nil || p == nil {
+		http.Redirect(w, r, "/admin/products", 302)
+		return
+	}
+	if err := s.model3d.Remove(r.Context(), p.ID); err != nil && !errors.Is(err, domain.ErrNotFound) {
+		log.Error().Err(err).Str("slug", p.Slug).Msg("modelo 3D: quitar")
+		redirectProductImages(w, r, p.Slug, "No se pudo quitar el modelo 3D")
+		return
+	}
+	redirectProductImages(w, r, p.Slug, "")
+}
+
+// removeProductModel borra el modelo 3D de un producto que se está eliminando.
+func (s *Server) removeProductModel(ctx context.Context, slug string) {
+	if s.model3d == nil {
+		return
+	}
+	p, err := s.products.GetBySlug(ctx, slug)
+	if err != nil || p == nil {
+		return
+	}
+	if err := s.model3d.Remove(ctx, p.ID); err != nil && !errors.Is(err, domain.ErrNotFound) {
+		log.Warn().Err(err).Str("slug", slug).Msg("modelo 3D: quitar al borrar producto")
+	}
+}
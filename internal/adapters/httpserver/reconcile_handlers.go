@@ -0,0 +1,104 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type adminReconcileView struct {
+	Issue domain.ReconcileIssue
+	Order *domain.Order
+}
+
+func (s *Server) reconcileAdmin(w http.ResponseWriter, r *http.Request, post bool) bool {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return false
+	}
+	if s.reconcile == nil {
+		http.Error(w, "conciliación de pagos no disponible", http.StatusServiceUnavailable)
+		return false
+	}
+	if post && r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/reconcile", http.StatusFound)
+		return false
+	}
+	return true
+}
+
+func redirectReconcile(w http.ResponseWriter, r *http.Request, key, msg string) {
+	http.Redirect(w, r, "/admin/reconcile?"+key+"="+url.QueryEscape(msg), http.StatusFound)
+}
+
+// handleAdminReconcile lista las diferencias entre las órdenes y MercadoPago que encontró la
+// conciliación.
+func (s *Server) handleAdminReconcile(w http.ResponseWriter, r *http.Request) {
+	if !s.reconcileAdmin(w, r, false) {
+		return
+	}
+	issues, err := s.reconcile.Open(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("admin conciliación")
+		http.Error(w, "err", http.StatusInternalServerError)
+		return
+	}
+	orders := map[uuid.UUID]*domain.Order{}
+	views := make([]adminReconcileView, 0, len(issues))
+	for _, i := range issues {
+		o, ok := orders[i.OrderID]
+		if !ok {
+			if o, err = s.orders.Orders.FindByID(r.Context(), i.OrderID); err != nil {
+				log.Warn().Err(err).Str("order_id", i.OrderID.String()).Msg("orden de la diferencia")
+				o = nil
+			}
+			orders[i.OrderID] = o
+		}
+		views = append(views, adminReconcileView{Issue: i, Order: o})
+	}
+	s.render(w, "admin_reconcile.html", map[string]any{
+		"Issues":     views,
+		"MinAge":     s.reconcile.MinAge,
+		"Lookback":   s.reconcile.Lookback,
+		"Flash":      strings.TrimSpace(r.URL.Query().Get("ok")),
+		"FlashError": strings.TrimSpace(r.URL.Query().Get("err")),
+	})
+}
+
+// handleAdminReconcileRun corre la conciliación en el momento, sin esperar al job.
+func (s *Server) handleAdminReconcileRun(w http.ResponseWriter, r *http.Request) {
+	if !s.reconcileAdmin(w, r, true) {
+		return
+	}
+	res, err := s.reconcile.Run(r.Context(), time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("conciliación manual")
+		redirectReconcile(w, r, "err", "No se pudo conciliar: "+err.Error())
+		return
+	}
+	log.Info().Str("admin", s.adminEmail(r)).Int("revisadas", res.Checked).Int("corregidas", res.Fixed).Int("diferencias", res.Issues).Msg("conciliación manual")
+	redirectReconcile(w, r, "ok", fmt.Sprintf("Se revisaron %d órdenes: %d corregidas, %d diferencias para revisar", res.Checked, res.Fixed, res.Issues))
+}
+
+func (s *Server) handleAdminReconcileDismiss(w http.ResponseWriter, r *http.Request) {
+	if !s.reconcileAdmin(w, r, true) {
+		return
+	}
+	id, err := uuid.Parse(strings.TrimSpace(r.FormValue("id")))
+	if err != nil {
+		redirectReconcile(w, r, "err", "Diferencia inválida")
+		return
+	}
+	if err := s.reconcile.Dismiss(r.Context(), id, s.adminEmail(r)); err != nil {
+		redirectReconcile(w, r, "err", "No se pudo descartar: "+err.Error())
+		return
+	}
+	redirectReconcile(w, r, "ok", "Diferencia marcada como revisada")
+}
@@ -12,11 +12,13 @@ import (
 	"html/template"
 	"io"
 	"math"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/smtp"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -29,7 +31,6 @@ import (
 	"golang.org/x/oauth2"
 
 	"github.com/phenrril/tienda3d/internal/adapters/analytics"
-	"github.com/phenrril/tienda3d/internal/adapters/payments/mercadopago"
 	"github.com/phenrril/tienda3d/internal/adapters/telegram"
 	"github.com/phenrril/tienda3d/internal/domain"
 	"github.com/phenrril/tienda3d/internal/usecase"
@@ -58,19 +59,39 @@ type Server struct {
 	analyticsID  string
 	ga4          *analytics.Client
 
-	workshop *WorkshopAdmin
+	workshop  *WorkshopAdmin
+	storageGC *usecase.StorageGCUC
+	model3d   *usecase.ProductModelUC
+	carts     *usecase.CartUC
+	shipping  *usecase.ShippingUC
+	carriers  *usecase.CarrierUC
+	tracking  *usecase.TrackingUC
+	documents domain.OrderDocuments
+	tickets   domain.OrderTickets
+	printer   domain.TicketPrinter
+	refunds   *usecase.RefundUC
+	transfers *usecase.TransferUC
+	reconcile *usecase.ReconcileUC
+	webhooks  *usecase.WebhookUC
+	pricing   *usecase.PaymentRulesUC
+	variants  *variantCache
+
+	orderEdits    *usecase.OrderEditUC
+	orderArchives *usecase.OrderArchiveUC
 }
 
 type adminOrderItemView struct {
-	Title      string
-	Color      string
-	Qty        int
-	ProductURL string
-	HasProduct bool
+	Title           string
+	Color           string
+	Qty             int
+	ProductURL      string
+	HasProduct      bool
+	Personalization []domain.PersonalizationValue
 }
 
 type adminOrderView struct {
 	ID             uuid.UUID
+	Code           string
 	Email          string
 	Status         domain.OrderStatus
 	Total          float64
@@ -79,12 +100,28 @@ type adminOrderView struct {
 	MPStatus       string
 	CreatedAt      time.Time
 	Items          []adminOrderItemView
+	History        []domain.OrderStatusChange
+	NextStatuses   []domain.OrderStatus
+	ShippingMethod string
+	Carrier        string
+	CarrierName    string
+	TrackingNumber string
+	TrackingURL    string
+	ShipmentStage  string
+	ShippingLabel  string
+	RefundedAmount float64
+	Refundable     float64
+	Refunds        []domain.Refund
+	Payments       []domain.Payment
+	Paid           float64
+	Due            float64
 }
 
 var emailRe = regexp.MustCompile(`^[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}$`)
 
-func New(t *template.Template, p *usecase.ProductUC, q *usecase.QuoteUC, o *usecase.OrderUC, pay *usecase.PaymentUC, w *usecase.WhatsAppUC, c *usecase.CouponUseCase, m domain.UploadedModelRepo, fs domain.FileStorage, customers domain.CustomerRepo, oauthCfg *oauth2.Config, fp domain.FeaturedProductRepo, emailSvc domain.EmailService, hc domain.HiddenCategoryRepo, wa *WorkshopAdmin) http.Handler {
-	s := &Server{tmpl: t, products: p, quotes: q, orders: o, payments: pay, whatsapp: w, coupons: c, models: m, featuredProducts: fp, hiddenCategories: hc, storage: fs, customers: customers, oauthCfg: oauthCfg, emailService: emailSvc, mux: http.NewServeMux(), assetVersion: strconv.FormatInt(time.Now().Unix(), 10), workshop: wa}
+func New(t *template.Template, p *usecase.ProductUC, q *usecase.QuoteUC, o *usecase.OrderUC, pay *usecase.PaymentUC, w *usecase.WhatsAppUC, c *usecase.CouponUseCase, m domain.UploadedModelRepo, fs domain.FileStorage, customers domain.CustomerRepo, oauthCfg *oauth2.Config, fp domain.FeaturedProductRepo, emailSvc domain.EmailService, hc domain.HiddenCategoryRepo, wa *WorkshopAdmin, gc *usecase.StorageGCUC, pm *usecase.ProductModelUC, carts *usecase.CartUC, ship *usecase.ShippingUC, cr *usecase.CarrierUC, tr *usecase.TrackingUC, docs domain.OrderDocuments, tk domain.OrderTickets, printer domain.TicketPrinter, rf *usecase.RefundUC, tf *usecase.TransferUC, rc *usecase.ReconcileUC, wh *usecase.WebhookUC, pr *usecase.PaymentRulesUC, oe *usecase.OrderEditUC, oa *usecase.OrderArchiveUC) http.Handler {
+	s := &Server{tmpl: t, products: p, quotes: q, orders: o, payments: pay, whatsapp: w, coupons: c, models: m, featuredProducts: fp, hiddenCategories: hc, storage: fs, customers: customers, oauthCfg: oauthCfg, emailService: emailSvc, mux: http.NewServeMux(), assetVersion: strconv.FormatInt(time.Now().Unix(), 10), workshop: wa, storageGC: gc, model3d: pm, carts: carts, shipping: ship, carriers: cr, tracking: tr, documents: docs, tickets: tk, printer: printer, refunds: rf, transfers: tf, reconcile: rc, webhooks: wh, pricing: pr, orderEdits: oe, orderArchives: oa}
+	s.variants = newVariantCache(64 << 20)
 	s.analyticsID = strings.TrimSpace(os.Getenv("GOOGLE_ANALYTICS_ID"))
 	s.ga4 = analytics.NewClient()
 
@@ -107,6 +144,11 @@ func New(t *template.Template, p *usecase.ProductUC, q *usecase.QuoteUC, o *usec
 	}
 	s.adminSecret = []byte(sec)
 
+	if wh != nil {
+		for _, src := range []string{domain.WebhookSourceMercadoPago, domain.WebhookSourceTelegram, domain.WebhookSourceWhatsApp} {
+			wh.Handle(src, s.webhookHandler(src))
+		}
+	}
 	s.routes()
 	return Chain(s.mux,
 		PublicRateLimit(map[string]int{
@@ -127,7 +169,7 @@ func (s *Server) routes() {
 
 	s.mux.Handle("/public/", http.StripPrefix("/public/", http.FileServer(http.Dir("public"))))
 
-	s.mux.Handle("/uploads/", http.StripPrefix("/uploads/", http.FileServer(http.Dir("uploads"))))
+	s.mux.HandleFunc("/uploads/", s.handleUploads)
 
 	// SEO endpoints
 	s.mux.HandleFunc("/robots.txt", s.handleRobots)
@@ -136,9 +178,11 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("/", s.handleHome)
 	s.mux.HandleFunc("/products", s.handleProducts)
 	s.mux.HandleFunc("/product/", s.handleProduct)
+	s.mux.HandleFunc("/product-model/", s.handleProductModelAsset)
 	s.mux.HandleFunc("/quote/", s.handleQuoteView)
 	s.mux.HandleFunc("/checkout", s.handleCheckout)
 	s.mux.HandleFunc("/pay/", s.handlePaySimulated)
+	s.mux.HandleFunc("/pay/proof", s.handleTransferProofUpload)
 
 	s.mux.HandleFunc("/cart", s.handleCart)
 	s.mux.HandleFunc("/cart/update", s.handleCartUpdate)
@@ -155,6 +199,8 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("/api/quote", s.apiQuote)
 	s.mux.HandleFunc("/api/checkout", s.apiCheckout)
 	s.mux.HandleFunc("/api/validate-coupon", s.handleValidateCouponAPI)
+	s.mux.HandleFunc("/api/shipping/quote", s.handleShippingQuote)
+	s.mux.HandleFunc("/api/shipping/carriers", s.handleCarrierQuote)
 	s.mux.HandleFunc("/webhooks/mp", s.webhookMP)
 	s.mux.HandleFunc("/api/products/bulk-prices", s.apiBulkPrices)
 	s.mux.HandleFunc("/api/products/delete", s.apiProductsBulkDelete)
@@ -170,6 +216,11 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("/auth/google/login", s.handleGoogleLogin)
 	s.mux.HandleFunc("/auth/google/callback", s.handleGoogleCallback)
 	s.mux.HandleFunc("/logout", s.handleLogout)
+	s.mux.HandleFunc("/account", s.handleAccount)
+	s.mux.HandleFunc("/account/orders", s.handleAccountOrders)
+	s.mux.HandleFunc("/account/orders/", s.handleAccountOrder)
+	s.mux.HandleFunc("/account/orders/pay", s.handleAccountOrderPay)
+	s.mux.HandleFunc("/account/orders/reorder", s.handleAccountOrderReorder)
 
 	s.mux.HandleFunc("/admin/login", s.handleAdminLogin)
 	s.mux.HandleFunc("/admin/auth", s.handleAdminAuth)
@@ -177,7 +228,29 @@ func (s *Server) routes() {
 
 	s.mux.HandleFunc("/admin/orders", s.handleAdminOrders)
 	s.mux.HandleFunc("/admin/orders/confirm-payment", s.handleAdminConfirmPayment)
+	s.mux.HandleFunc("/admin/orders/status", s.handleAdminOrderStatus)
+	s.mux.HandleFunc("/admin/orders/reopen", s.handleAdminOrderReopen)
+	s.mux.HandleFunc("/admin/orders/shipment", s.handleAdminOrderShipment)
+	s.mux.HandleFunc("/admin/orders/tracking", s.handleAdminOrderTracking)
+	s.mux.HandleFunc("/admin/orders/receipt", s.handleAdminOrderDocument)
+	s.mux.HandleFunc("/admin/orders/packing-slip", s.handleAdminOrderDocument)
+	s.mux.HandleFunc("/admin/orders/ticket", s.handleAdminOrderTicket)
+	s.mux.HandleFunc("/admin/orders/refund", s.handleAdminOrderRefund)
+	s.mux.HandleFunc("/admin/orders/edit", s.handleAdminOrderEdit)
+	s.mux.HandleFunc("/admin/orders/export", s.handleAdminOrdersExport)
+	s.mux.HandleFunc("/admin/transfers", s.handleAdminTransfers)
+	s.mux.HandleFunc("/admin/transfers/approve", s.handleAdminTransferApprove)
+	s.mux.HandleFunc("/admin/transfers/reject", s.handleAdminTransferReject)
+	s.mux.HandleFunc("/admin/reconcile", s.handleAdminReconcile)
+	s.mux.HandleFunc("/admin/reconcile/run", s.handleAdminReconcileRun)
+	s.mux.HandleFunc("/admin/reconcile/dismiss", s.handleAdminReconcileDismiss)
+	s.mux.HandleFunc("/admin/webhooks", s.handleAdminWebhooks)
+	s.mux.HandleFunc("/admin/webhooks/replay", s.handleAdminWebhookReplay)
 	s.mux.HandleFunc("/admin/orders/delete-range", s.handleAdminOrdersDeleteRange)
+	s.mux.HandleFunc("/admin/orders/archives", s.handleAdminOrderArchives)
+	s.mux.HandleFunc("/admin/orders/archives/view", s.handleAdminOrderArchiveView)
+	s.mux.HandleFunc("/admin/orders/archives/download", s.handleAdminOrderArchiveDownload)
+	s.mux.HandleFunc("/admin/orders/archives/restore", s.handleAdminOrderArchiveRestore)
 	s.mux.HandleFunc("/admin/products", s.handleAdminProducts)
 
 	s.mux.HandleFunc("/admin/sales", s.handleAdminSales)
@@ -188,10 +261,25 @@ func (s *Server) routes() {
 
 	// Admin: reparación de imágenes huérfanas
 	s.mux.HandleFunc("/admin/repair_images", s.handleAdminRepairImages)
+	s.mux.HandleFunc("/admin/storage-gc", s.handleAdminStorageGC)
+	s.mux.HandleFunc("/admin/storage-gc/scan", s.handleAdminStorageGCScan)
+	s.mux.HandleFunc("/admin/storage-gc/quarantine", s.handleAdminStorageGCQuarantine)
+	s.mux.HandleFunc("/admin/storage-gc/restore", s.handleAdminStorageGCRestore)
+	s.mux.HandleFunc("/admin/storage-gc/purge", s.handleAdminStorageGCPurge)
 
 	// Admin: gestor de imágenes sin JS inline (popup simple)
 	s.mux.HandleFunc("/admin/product_images", s.handleAdminProductImages)
 	s.mux.HandleFunc("/admin/product_images/delete", s.handleAdminProductImagesDelete)
+	s.mux.HandleFunc("/admin/product_images/move", s.handleAdminProductImagesMove)
+	s.mux.HandleFunc("/admin/product_images/primary", s.handleAdminProductImagesPrimary)
+	s.mux.HandleFunc("/admin/product_images/update", s.handleAdminProductImagesUpdate)
+	s.mux.HandleFunc("/admin/product_images/replace", s.handleAdminProductImagesReplace)
+	s.mux.HandleFunc("/admin/product_model/upload", s.handleAdminProductModelUpload)
+	s.mux.HandleFunc("/admin/product_model/remove", s.handleAdminProductModelRemove)
+	s.mux.HandleFunc("/admin/product_personalization", s.handleAdminProductPersonalization)
+	s.mux.HandleFunc("/admin/product_personalization/save", s.handleAdminProductPersonalizationSave)
+	s.mux.HandleFunc("/admin/product_personalization/delete", s.handleAdminProductPersonalizationDelete)
+	s.mux.HandleFunc("/admin/attachments", s.handleAdminAttachment)
 
 	// Admin: Calculadora de costos
 	s.mux.HandleFunc("/admin/costs", s.handleAdminCosts)
@@ -201,6 +289,15 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("/admin/categorias", s.handleAdminCategories)
 	s.mux.HandleFunc("/admin/categorias/guardar", s.handleAdminCategoriesSave)
 
+	// Admin: Zonas y tarifas de envío
+	s.mux.HandleFunc("/admin/envios", s.handleAdminShipping)
+	s.mux.HandleFunc("/admin/envios/guardar", s.handleAdminShippingSave)
+	s.mux.HandleFunc("/admin/envios/eliminar", s.handleAdminShippingDelete)
+
+	// Admin: Descuentos/recargos por medio de pago y cuotas
+	s.mux.HandleFunc("/admin/pagos", s.handleAdminPaymentRules)
+	s.mux.HandleFunc("/admin/pagos/guardar", s.handleAdminPaymentRulesSave)
+
 	// Admin: Cupones de descuento
 	s.mux.HandleFunc("/admin/cupones", s.handleAdminCouponsList)
 	s.mux.HandleFunc("/admin/cupones/nuevo", s.handleAdminCouponsNew)
@@ -219,6 +316,7 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("/admin/pedidos/eliminar", s.handleAdminPedidosDelete)
 	s.mux.HandleFunc("/admin/pedidos/sena", s.handleAdminPedidosDeposit)
 	s.mux.HandleFunc("/admin/pedidos/estado", s.handleAdminPedidosStatus)
+	s.mux.HandleFunc("/admin/pedidos/ticket", s.handleAdminPedidosTicket)
 
 	s.mux.HandleFunc("/api/telegram/webhook", s.handleTelegramWebhook)
 
@@ -276,7 +374,7 @@ func (s *Server) handleAdminRepairImages(w http.ResponseWriter, r *http.Request)
 				if !strings.Contains(sp, "uploads") {
 					continue
 				}
-				if _, err := os.Stat(sp); err != nil {
+				if ok, err := s.storage.Exists(r.Context(), sp); err == nil && !ok {
 					out.MissingFiles++
 					if !dry {
 						_ = s.products.DeleteImageByID(r.Context(), im.ID)
@@ -294,8 +392,73 @@ func (s *Server) handleAdminRepairImages(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, 200, out)
 }
 
-// filterExistingProductImages devuelve sólo imágenes con URL válida o archivo local existente.
-func filterExistingProductImages(imgs []domain.Image) []domain.Image {
+// handleUploads sirve los archivos subidos desde el storage configurado (disco o S3).
+func (s *Server) handleUploads(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method", 405)
+		return
+	}
+	rel, ok := publicUploadKey(strings.TrimPrefix(r.URL.Path, "/uploads/"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if strings.HasPrefix(rel, "images/") && r.URL.Query().Get("w") != "" && s.serveImageVariant(w, r, rel) {
+		return
+	}
+	rc, err := s.storage.Open(r.Context(), "uploads/"+rel)
+	if err != nil {
+		if !errors.Is(err, domain.ErrNotFound) {
+			log.Warn().Err(err).Str("path", rel).Msg("uploads: open")
+		}
+		http.NotFound(w, r)
+		return
+	}
+	defer rc.Close()
+	if ct := mime.TypeByExtension(strings.ToLower(filepath.Ext(rel))); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if f, ok := rc.(*os.File); ok {
+		var mod time.Time
+		if fi, err := f.Stat(); err == nil {
+			mod = fi.ModTime()
+		}
+		http.ServeContent(w, r, rel, mod, f)
+		return
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = io.Copy(w, rc)
+}
+
+// privateUploadDirs no se sirven por /uploads: modelos originales (STL/3MF), archivos de
+// clientes (comprobantes, logos, archivos de órdenes) y la cuarentena del GC.
+var privateUploadDirs = []string{"models", "attachments", "quarantine"}
+
+// publicUploadKey normaliza la ruta pedida igual que el storage ("/models/x", "models//x" o
+// "models\x" terminan en la misma clave) y la rechaza si cae en un directorio privado.
+func publicUploadKey(rel string) (string, bool) {
+	v := strings.ReplaceAll(rel, "\\", "/")
+	if v == "" || strings.HasSuffix(v, "/") || strings.Contains(v, "..") {
+		return "", false
+	}
+	key := strings.TrimLeft(path.Clean("/"+v), "/")
+	if key == "" {
+		return "", false
+	}
+	dir, _, _ := strings.Cut(key, "/")
+	for _, d := range privateUploadDirs {
+		if strings.EqualFold(dir, d) {
+			return "", false
+		}
+	}
+	return key, true
+}
+
+// filterExistingProductImages devuelve sólo imágenes con URL válida o archivo existente en el storage.
+func (s *Server) filterExistingProductImages(ctx context.Context, imgs []domain.Image) []domain.Image {
 	if len(imgs) == 0 {
 		return imgs
 	}
@@ -318,7 +481,7 @@ func filterExistingProductImages(imgs []domain.Image) []domain.Image {
 			filtered = append(filtered, im)
 			continue
 		}
-		if _, err := os.Stat(sp); err == nil {
+		if ok, err := s.storage.Exists(ctx, sp); err != nil || ok {
 			filtered = append(filtered, im)
 		}
 	}
@@ -343,7 +506,7 @@ func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
 		Image string
 		Alt   string
 	}
-	itemSlugs := loadCarouselItems()
+	itemSlugs := s.loadCarouselItems(r.Context())
 
 	carouselItems := []carouselItem{}
 	for _, slug := range itemSlugs {
@@ -389,7 +552,7 @@ func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
 	filteredList := make([]domain.Product, 0, len(list))
 	for i := range list {
 		p := &list[i]
-		p.Images = filterExistingProductImages(p.Images)
+		p.Images = s.filterExistingProductImages(r.Context(), p.Images)
 		if len(p.Images) > 0 {
 			filteredList = append(filteredList, *p)
 		}
@@ -438,7 +601,7 @@ func (s *Server) handleProducts(w http.ResponseWriter, r *http.Request) {
 	filteredProducts := make([]domain.Product, 0, len(list))
 	for i := range list {
 		p := &list[i]
-		p.Images = filterExistingProductImages(p.Images)
+		p.Images = s.filterExistingProductImages(r.Context(), p.Images)
 		if len(p.Images) > 0 {
 			filteredProducts = append(filteredProducts, *p)
 		}
@@ -496,7 +659,7 @@ func (s *Server) handleProduct(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Filtrar imágenes inexistentes
-	p.Images = filterExistingProductImages(p.Images)
+	p.Images = s.filterExistingProductImages(r.Context(), p.Images)
 
 	seen := map[string]struct{}{}
 	colors := []string{}
@@ -569,6 +732,19 @@ func (s *Server) handleProduct(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	data := map[string]any{"Product": p, "Colors": colors, "DefaultColor": colors[0], "Added": added, "CanonicalURL": base + "/product/" + p.Slug, "OGImage": og}
+	if msg := strings.TrimSpace(r.URL.Query().Get("err")); msg != "" {
+		data["Err"] = msg
+	}
+	if mu := s.productModelURL(r.Context(), p); mu != "" {
+		data["ModelURL"] = mu
+	}
+	if s.pricing != nil {
+		if info, err := s.pricing.ProductInfo(r.Context(), p.BasePrice); err == nil {
+			data["PaymentInfo"] = info
+		} else {
+			log.Error().Err(err).Msg("reglas de medio de pago")
+		}
+	}
 	if u := readUserSession(w, r); u != nil {
 		data["User"] = u
 	}
@@ -794,7 +970,7 @@ func (s *Server) apiProductByID(w http.ResponseWriter, r *http.Request) {
 		}
 		// Filtrar imágenes inexistentes para que el admin no cuente huérfanas
 		if p != nil {
-			p.Images = filterExistingProductImages(p.Images)
+			p.Images = s.filterExistingProductImages(r.Context(), p.Images)
 		}
 		writeJSON(w, 200, p)
 		return
@@ -882,6 +1058,7 @@ func (s *Server) apiProductByID(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		s.removeProductModel(r.Context(), idStr)
 		imgPaths, err := s.products.DeleteFullBySlug(r.Context(), idStr)
 		if err != nil {
 			if errors.Is(err, domain.ErrNotFound) {
@@ -893,22 +1070,8 @@ func (s *Server) apiProductByID(w http.ResponseWriter, r *http.Request) {
 		}
 		removedFiles := []string{}
 		for _, pth := range imgPaths {
-			sp := strings.TrimSpace(pth)
-			if sp == "" {
-				continue
-			}
-
-			if strings.HasPrefix(sp, "/") {
-				sp = sp[1:]
-			}
-
-			if !strings.Contains(sp, "uploads") {
-				continue
-			}
-			if _, err := os.Stat(sp); err == nil {
-				if err2 := os.Remove(sp); err2 == nil {
-					removedFiles = append(removedFiles, sp)
-				}
+			if sp := s.deleteImageFile(r.Context(), pth); sp != "" {
+				removedFiles = append(removedFiles, sp)
 			}
 		}
 		writeJSON(w, 200, map[string]any{"status": "ok", "slug": idStr, "removed_files": removedFiles})
@@ -938,6 +1101,7 @@ func (s *Server) apiProductsBulkDelete(w http.ResponseWriter, r *http.Request) {
 		if sl == "" {
 			continue
 		}
+		s.removeProductModel(r.Context(), sl)
 		if err := s.products.DeleteBySlug(r.Context(), sl); err != nil {
 			errorsMap[sl] = err.Error()
 		} else {
@@ -1051,106 +1215,8 @@ func (s *Server) apiCheckout(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, 200, map[string]any{"init_point": payURL, "order_id": order.ID})
 }
 
-func (s *Server) webhookMP(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method", 405)
-		return
-	}
-	body, _ := io.ReadAll(io.LimitReader(r.Body, 65536))
-	var evt struct {
-		Type   string `json:"type"`
-		Action string `json:"action"`
-		Data   struct {
-			ID string `json:"id"`
-		} `json:"data"`
-	}
-	_ = json.Unmarshal(body, &evt)
-	payID := evt.Data.ID
-	if payID == "" {
-		payID = r.URL.Query().Get("id")
-	}
-	if payID == "" {
-		log.Warn().Msg("webhook sin payment id")
-		w.WriteHeader(200)
-		return
-	}
-	status, extRef, err := s.payments.Gateway.PaymentInfo(r.Context(), payID)
-	if err != nil {
-		log.Error().Err(err).Str("payment_id", payID).Msg("payment info")
-		w.WriteHeader(200)
-		return
-	}
-	orderID, ok := mercadopago.VerifyExternalRef(extRef)
-	if !ok {
-		log.Warn().Str("ext", extRef).Msg("external ref inválido")
-		w.WriteHeader(200)
-		return
-	}
-	uid, err := uuid.Parse(orderID)
-	if err != nil {
-		w.WriteHeader(200)
-		return
-	}
-	o, err := s.orders.Orders.FindByID(r.Context(), uid)
-	if err != nil || o == nil {
-		log.Error().Err(err).Str("order_id", orderID).Msg("orden no encontrada para webhook")
-		w.WriteHeader(200)
-		return
-	}
-	approved := false
-	switch status {
-	case "approved":
-		approved = true
-		o.MPStatus = "approved"
-		o.Status = domain.OrderStatusFinished
-	case "pending", "in_process", "in_mediation":
-		o.MPStatus = status
-		if o.Status != domain.OrderStatusFinished {
-			o.Status = domain.OrderStatusAwaitingPay
-		}
-	default:
-		o.MPStatus = status
-		if status == "rejected" {
-			o.Status = domain.OrderStatusCancelled
-		}
-	}
-	notify := false
-	if approved && !o.Notified {
-		o.Notified = true
-		notify = true
-
-		// Registrar uso del cupón cuando el pago es aprobado
-		if o.CouponID != nil && o.CouponCode != "" {
-			if err := s.coupons.ApplyCoupon(r.Context(), *o.CouponID, o.ID, o.Email, o.DiscountAmount, o.Total+o.DiscountAmount); err != nil {
-				log.Error().Err(err).
-					Str("coupon_code", o.CouponCode).
-					Str("order_id", o.ID.String()).
-					Msg("error al registrar uso de cupón en webhook")
-			} else {
-				log.Info().
-					Str("coupon_code", o.CouponCode).
-					Str("order_id", o.ID.String()).
-					Float64("discount", o.DiscountAmount).
-					Msg("cupón registrado exitosamente tras confirmación de pago")
-			}
-		}
-	}
-	if err := s.orders.Orders.Save(r.Context(), o); err != nil {
-		log.Error().Err(err).Msg("guardar orden webhook")
-	}
-	if notify {
-		go s.sendOrderNotify(o, true)
-	}
-	w.WriteHeader(200)
-}
-
-type cartItem struct {
-	Slug        string  `json:"slug"`
-	Color       string  `json:"color"`
-	Observation string  `json:"observation,omitempty"`
-	Qty         int     `json:"qty"`
-	Price       float64 `json:"price"`
-}
+// cartItem es el mismo formato para la cookie y el carrito guardado en la base.
+type cartItem = domain.CartItem
 
 type cartPayload struct {
 	Items []cartItem `json:"items"`
@@ -1165,6 +1231,19 @@ type cartLine struct {
 	Subtotal    float64
 	Name        string
 	Image       string
+	PZ          []domain.PersonalizationValue
+	// PZKey identifica la personalización de la línea en los formularios del carrito.
+	PZKey string
+}
+
+// cartPZKey serializa la personalización tal como se guarda en el carrito (id + valor),
+// para distinguir líneas del mismo producto con distinto texto/logo.
+func cartPZKey(vals []domain.PersonalizationValue) string {
+	slim := make([]domain.PersonalizationValue, 0, len(vals))
+	for _, v := range vals {
+		slim = append(slim, domain.PersonalizationValue{FieldID: v.FieldID, Value: v.Value})
+	}
+	return domain.EncodePersonalization(slim)
 }
 
 func aggregateCart(cp cartPayload, lookup func(slug string) (*domain.Product, error)) []cartLine {
@@ -1172,6 +1251,7 @@ func aggregateCart(cp cartPayload, lookup func(slug string) (*domain.Product, er
 		Slug        string
 		Color       string
 		Observation string
+		PZ          string
 	}
 	m := map[cartKey]*cartLine{}
 	for _, it := range cp.Items {
@@ -1182,6 +1262,7 @@ func aggregateCart(cp cartPayload, lookup func(slug string) (*domain.Product, er
 			Slug:        it.Slug,
 			Color:       normalizeColorName(it.Color),
 			Observation: normalizeCartObservation(it.Observation),
+			PZ:          cartPZKey(it.PZ),
 		}
 		line, ok := m[key]
 		if !ok {
@@ -1191,6 +1272,8 @@ func aggregateCart(cp cartPayload, lookup func(slug string) (*domain.Product, er
 				Observation: key.Observation,
 				Qty:         0,
 				UnitPrice:   it.Price,
+				PZ:          it.PZ,
+				PZKey:       key.PZ,
 			}
 			m[key] = line
 		}
@@ -1204,10 +1287,13 @@ func aggregateCart(cp cartPayload, lookup func(slug string) (*domain.Product, er
 			if len(p.Images) > 0 {
 				l.Image = p.Images[0].URL
 			}
+			l.PZ = usecase.ApplyPersonalization(p, l.PZ)
 
 			if p.BasePrice != 0 {
-				l.UnitPrice = p.BasePrice
+				l.UnitPrice = p.BasePrice + domain.PersonalizationSurcharge(l.PZ)
 			}
+		} else {
+			l.PZ = usecase.ApplyPersonalization(nil, l.PZ)
 		}
 		l.Subtotal = l.UnitPrice * float64(l.Qty)
 		res = append(res, *l)
@@ -1215,56 +1301,54 @@ func aggregateCart(cp cartPayload, lookup func(slug string) (*domain.Product, er
 	return res
 }
 
-var provinceCosts = map[string]float64{
-	"Santa Fe":            9000,
-	"Buenos Aires":        9000,
-	"CABA":                9000,
-	"Cordoba":             9000,
-	"Entre Rios":          9000,
-	"Corrientes":          9000,
-	"Chaco":               9000,
-	"Misiones":            9000,
-	"Formosa":             9000,
-	"Santiago del Estero": 9000,
-	"Tucuman":             9000,
-	"Salta":               9000,
-	"Jujuy":               9000,
-	"Catamarca":           9000,
-	"La Rioja":            9000,
-	"San Juan":            9000,
-	"San Luis":            9000,
-	"Mendoza":             9000,
-	"La Pampa":            9000,
-	"Neuquen":             9000,
-	"Rio Negro":           9000,
-	"Chubut":              9000,
-	"Santa Cruz":          9000,
-	"Tierra del Fuego":    9000,
-}
-
-func shippingCostFor(province string) float64 {
-	if v, ok := provinceCosts[province]; ok {
-		return v
-	}
-	if province == "" {
-		return 0
-	}
-	return 9000
-}
-
 func (s *Server) handleCart(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		cp := readCart(r)
+		cp := s.loadCart(w, r)
+		var changes []domain.CartChange
+		if s.carts != nil && len(cp.Items) > 0 {
+			items, ch, changed := s.carts.Revalidate(r.Context(), cp.Items)
+			if changed {
+				cp.Items = items
+				s.saveCart(w, r, cp)
+			}
+			changes = ch
+		}
 		lines := aggregateCart(cp, func(slug string) (*domain.Product, error) { return s.products.GetBySlug(r.Context(), slug) })
 		total := 0.0
 		for _, l := range lines {
 			total += l.Subtotal
 		}
-		provs := []string{}
-		for p := range provinceCosts {
-			provs = append(provs, p)
+		shipFrom := map[string]float64{}
+		if s.shipping != nil {
+			if m, err := s.shipping.StartingPrices(r.Context()); err == nil {
+				shipFrom = m
+			} else {
+				log.Error().Err(err).Msg("tarifas de envío")
+			}
+		}
+		shipAvailable := map[string]bool{}
+		for m := range shipFrom {
+			shipAvailable[m] = true
+		}
+		payRules := map[string]domain.PaymentRule{}
+		if s.pricing != nil {
+			if m, err := s.pricing.ByMethod(r.Context()); err == nil {
+				payRules = m
+			} else {
+				log.Error().Err(err).Msg("reglas de medio de pago")
+			}
+		}
+		data := map[string]any{"Lines": lines, "Total": total, "Provinces": domain.Provinces, "ShippingFrom": shipFrom, "ShippingAvailable": shipAvailable, "CarriersEnabled": s.carriers.Enabled(), "CartChanges": changes, "PaymentRules": payRules}
+		if n, _ := strconv.Atoi(r.URL.Query().Get("reordered")); n > 0 {
+			notice := fmt.Sprintf("Agregamos %d producto(s) de tu pedido anterior con los precios actuales.", n)
+			if sk, _ := strconv.Atoi(r.URL.Query().Get("skipped")); sk > 0 {
+				notice += fmt.Sprintf(" %d ya no están disponibles.", sk)
+			}
+			data["Notice"] = notice
+		}
+		if msg := cartErrorNotice(r.URL.Query().Get("err")); msg != "" {
+			data["Notice"] = msg
 		}
-		data := map[string]any{"Lines": lines, "Total": total, "Provinces": provs, "ProvinceCosts": provinceCosts}
 		if u := readUserSession(w, r); u != nil {
 			data["User"] = u
 		}
@@ -1272,7 +1356,14 @@ func (s *Server) handleCart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if r.Method == http.MethodPost {
-		if err := r.ParseForm(); err != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, maxPersonalizationLogoBytes+(1<<20))
+		var perr error
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			perr = r.ParseMultipartForm(maxPersonalizationLogoBytes + (1 << 20))
+		} else {
+			perr = r.ParseForm()
+		}
+		if perr != nil {
 			http.Error(w, "form", 400)
 			return
 		}
@@ -1304,17 +1395,32 @@ func (s *Server) handleCart(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "prod", 404)
 			return
 		}
-		cart := readCart(r)
+		accept := r.Header.Get("Accept")
+		isFetch := strings.Contains(accept, "application/json") || r.Header.Get("X-Requested-With") == "fetch"
+		pz, err := s.readCartPersonalization(r, p)
+		if err != nil {
+			if isFetch {
+				writeJSON(w, 400, map[string]any{"status": "error", "error": err.Error()})
+				return
+			}
+			http.Redirect(w, r, "/product/"+slug+"?err="+url.QueryEscape(err.Error()), 302)
+			return
+		}
+		cart := s.loadCart(w, r)
+		slim := []domain.PersonalizationValue{}
+		if key := cartPZKey(pz); key != "" {
+			slim = domain.DecodePersonalization(key)
+		}
 		cart.Items = append(cart.Items, cartItem{
 			Slug:        slug,
 			Color:       color,
 			Observation: observation,
 			Qty:         1,
-			Price:       p.BasePrice,
+			Price:       p.BasePrice + domain.PersonalizationSurcharge(pz),
+			PZ:          slim,
 		})
-		writeCart(w, cart)
-		accept := r.Header.Get("Accept")
-		if strings.Contains(accept, "application/json") || r.Header.Get("X-Requested-With") == "fetch" {
+		s.saveCart(w, r, cart)
+		if isFetch {
 			count := 0
 			for _, it := range cart.Items {
 				count += it.Qty
@@ -1340,27 +1446,40 @@ func (s *Server) handleCartUpdate(w http.ResponseWriter, r *http.Request) {
 	slug := r.FormValue("slug")
 	color := normalizeColorName(r.FormValue("color"))
 	observation := normalizeCartObservation(r.FormValue("observation"))
+	pzKey := r.FormValue("pz")
 	op := r.FormValue("op")
 	qtyStr := r.FormValue("qty")
-	cart := readCart(r)
+	cart := s.loadCart(w, r)
 
 	type cartKey struct {
 		Slug        string
 		Color       string
 		Observation string
+		PZ          string
 	}
 	agg := map[cartKey]int{}
+	// precio con el que se agregó cada línea; el aviso de cambio de precio lo compara con el actual
+	prices := map[cartKey]float64{}
 	for _, it := range cart.Items {
 		if it.Qty > 0 {
 			key := cartKey{
 				Slug:        it.Slug,
 				Color:       normalizeColorName(it.Color),
 				Observation: normalizeCartObservation(it.Observation),
+				PZ:          cartPZKey(it.PZ),
 			}
 			agg[key] += it.Qty
+			if _, ok := prices[key]; !ok {
+				prices[key] = it.Price
+			}
 		}
 	}
-	key := cartKey{Slug: slug, Color: color, Observation: observation}
+	key := cartKey{Slug: slug, Color: color, Observation: observation, PZ: pzKey}
+	if _, ok := agg[key]; !ok && key.PZ != "" {
+		// la personalización sólo se carga desde el producto; no crear líneas con pz arbitrario
+		http.Redirect(w, r, "/cart", 302)
+		return
+	}
 	cur := agg[key]
 	switch op {
 	case "inc":
@@ -1391,16 +1510,21 @@ func (s *Server) handleCartUpdate(w http.ResponseWriter, r *http.Request) {
 			Color:       k.Color,
 			Observation: k.Observation,
 			Qty:         q,
+			Price:       prices[k],
+			PZ:          domain.DecodePersonalization(k.PZ),
 		})
 	}
 
 	for i := range newCart.Items {
+		if newCart.Items[i].Price != 0 {
+			continue
+		}
 		p, _ := s.products.GetBySlug(r.Context(), newCart.Items[i].Slug)
 		if p != nil {
-			newCart.Items[i].Price = p.BasePrice
+			newCart.Items[i].Price = p.BasePrice + domain.PersonalizationSurcharge(usecase.ApplyPersonalization(p, newCart.Items[i].PZ))
 		}
 	}
-	writeCart(w, newCart)
+	s.saveCart(w, r, newCart)
 	http.Redirect(w, r, "/cart", 302)
 }
 
@@ -1416,15 +1540,16 @@ func (s *Server) handleCartRemove(w http.ResponseWriter, r *http.Request) {
 	slug := r.FormValue("slug")
 	color := normalizeColorName(r.FormValue("color"))
 	observation := normalizeCartObservation(r.FormValue("observation"))
-	cart := readCart(r)
+	pzKey := r.FormValue("pz")
+	cart := s.loadCart(w, r)
 	newItems := []cartItem{}
 	for _, it := range cart.Items {
-		if !(it.Slug == slug && normalizeColorName(it.Color) == color && normalizeCartObservation(it.Observation) == observation) {
+		if !(it.Slug == slug && normalizeColorName(it.Color) == color && normalizeCartObservation(it.Observation) == observation && cartPZKey(it.PZ) == pzKey) {
 			newItems = append(newItems, it)
 		}
 	}
 	cart.Items = newItems
-	writeCart(w, cart)
+	s.saveCart(w, r, cart)
 	http.Redirect(w, r, "/cart", 302)
 }
 
@@ -1472,11 +1597,7 @@ func normalizeCartObservation(s string) string {
 }
 
 func buildCartItemTitle(baseTitle, observation string) string {
-	obs := normalizeCartObservation(observation)
-	if obs == "" {
-		return baseTitle
-	}
-	return fmt.Sprintf("%s | Obs: %s", baseTitle, obs)
+	return domain.ItemTitle(baseTitle, normalizeCartObservation(observation))
 }
 
 func formatColorES(c string) string {
@@ -1491,71 +1612,142 @@ func formatColorES(c string) string {
 	return s
 }
 
-func (s *Server) handleCartCheckout(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method", 405)
-		return
-	}
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "form", 400)
-		return
-	}
-	email := r.FormValue("email")
-	name := r.FormValue("name")
-	phone := r.FormValue("phone")
-	dni := r.FormValue("dni")
-	postal := r.FormValue("postal_code")
-	if email == "" || name == "" {
-		http.Redirect(w, r, "/cart?err=datos", 302)
-		return
-	}
-	shippingMethod := r.FormValue("shipping")
-	if shippingMethod == "" {
-		shippingMethod = "retiro"
-	}
-
-	paymentMethod := r.FormValue("payment_method")
-	if paymentMethod == "" {
-		paymentMethod = "efectivo"
-	}
-
-	couponCode := strings.TrimSpace(r.FormValue("coupon_code"))
+// checkoutForm son los datos del comprador que manda el formulario del carrito.
+type checkoutForm struct {
+	Email, Name, Phone, DNI   string
+	PostalCode, Province      string
+	Address                   string
+	ShippingMethod            string
+	PaymentMethod, CouponCode string
+}
 
-	addrEnvio := r.FormValue("address_envio")
-	addrCadete := r.FormValue("address_cadete")
-	legacyAddr := r.FormValue("address")
-	province := r.FormValue("province")
-	address := ""
-	switch shippingMethod {
+// parseCheckoutForm lee el formulario del carrito: completa los métodos por defecto y toma la
+// dirección (y el código postal del cadete) del bloque que corresponde al envío elegido.
+func parseCheckoutForm(v url.Values) checkoutForm {
+	f := checkoutForm{
+		Email:          v.Get("email"),
+		Name:           v.Get("name"),
+		Phone:          v.Get("phone"),
+		DNI:            v.Get("dni"),
+		PostalCode:     strings.TrimSpace(v.Get("postal_code")),
+		Province:       v.Get("province"),
+		ShippingMethod: v.Get("shipping"),
+		PaymentMethod:  v.Get("payment_method"),
+		CouponCode:     strings.TrimSpace(v.Get("coupon_code")),
+	}
+	if f.ShippingMethod == "" {
+		f.ShippingMethod = "retiro"
+	}
+	if f.PaymentMethod == "" {
+		f.PaymentMethod = "efectivo"
+	}
+	switch f.ShippingMethod {
 	case "envio":
-		address = addrEnvio
+		f.Address = v.Get("address_envio")
 	case "cadete":
-		address = addrCadete
+		f.Address = v.Get("address_cadete")
+		f.PostalCode = strings.TrimSpace(v.Get("postal_code_cadete"))
 	default:
-		address = legacyAddr
+		f.Address = v.Get("address")
 	}
+	return f
+}
 
-	if shippingMethod == "envio" {
-		if province == "" || address == "" || postal == "" || dni == "" || phone == "" {
-			http.Redirect(w, r, "/cart?err=envio", 302)
-			return
+var (
+	checkoutDNIRe    = regexp.MustCompile(`^\d{7,8}$`)
+	checkoutPostalRe = regexp.MustCompile(`^\d{4,5}$`)
+)
+
+// validate devuelve el código de error para /cart?err= ("" si el formulario está completo).
+// Al cadete sin provincia le asigna Santa Fe.
+func (f *checkoutForm) validate() string {
+	if f.Email == "" || f.Name == "" {
+		return "datos"
+	}
+	switch f.ShippingMethod {
+	case "envio":
+		if f.Province == "" || f.Address == "" || f.PostalCode == "" || f.DNI == "" || f.Phone == "" {
+			return "envio"
 		}
-		dniRe := regexp.MustCompile(`^\d{7,8}$`)
-		pcRe := regexp.MustCompile(`^\d{4,5}$`)
-		if !dniRe.MatchString(dni) || !pcRe.MatchString(postal) {
-			http.Redirect(w, r, "/cart?err=formato", 302)
-			return
+		if !checkoutDNIRe.MatchString(f.DNI) || !checkoutPostalRe.MatchString(f.PostalCode) {
+			return "formato"
 		}
-	} else if shippingMethod == "cadete" {
-		if address == "" || phone == "" {
-			http.Redirect(w, r, "/cart?err=cadete", 302)
-			return
+	case "cadete":
+		if f.Address == "" || f.Phone == "" {
+			return "cadete"
 		}
-		if province == "" {
-			province = "Santa Fe"
+		if f.Province == "" {
+			f.Province = "Santa Fe"
 		}
 	}
-	cp := readCart(r)
+	return ""
+}
+
+// cartErrorNotices son los únicos mensajes que el carrito muestra para ?err=; el texto nunca
+// viene en la URL.
+var cartErrorNotices = map[string]string{
+	"datos":                "Completá tu nombre y email para continuar.",
+	"envio":                "Para envío por correo completá provincia, dirección, código postal, DNI y teléfono.",
+	"formato":              "Revisá el DNI (7 u 8 números) y el código postal (4 o 5 números).",
+	"cadete":               "Para el cadete completá dirección y teléfono.",
+	"vacio":                "Tu carrito está vacío.",
+	"orden":                "No pudimos crear la orden, probá de nuevo en unos minutos.",
+	"envio_no_disponible":  "No hacemos envíos con ese método a ese destino, o el paquete supera las medidas máximas. Elegí otra forma de entrega.",
+	"correo_no_disponible": "La opción de correo elegida ya no está disponible. Volvé a cotizar y elegí otra.",
+	"cupon_invalido":       "El cupón no es válido.",
+	"cupon_no_encontrado":  "No encontramos ese cupón.",
+	"cupon_inactivo":       "Ese cupón está desactivado.",
+	"cupon_expirado":       "Ese cupón expiró.",
+	"cupon_agotado":        "Ese cupón ya alcanzó su límite de usos.",
+	"cupon_minimo":         "Tu compra no alcanza el monto mínimo del cupón.",
+	"cupon_usado":          "Ya usaste este cupón anteriormente.",
+	"cupon_pendiente":      "Tenés una orden pendiente con este cupón: completala o cancelala antes de volver a usarlo.",
+}
+
+// cartErrorNotice traduce el código de ?err= a su mensaje; un código desconocido no muestra nada.
+func cartErrorNotice(code string) string {
+	return cartErrorNotices[strings.TrimSpace(code)]
+}
+
+// couponErrorCode traduce un error de ValidateCoupon al código de cartErrorNotices.
+func couponErrorCode(err error) string {
+	switch {
+	case errors.Is(err, usecase.ErrCouponNotFound):
+		return "cupon_no_encontrado"
+	case errors.Is(err, usecase.ErrCouponInactive):
+		return "cupon_inactivo"
+	case errors.Is(err, usecase.ErrCouponExpired):
+		return "cupon_expirado"
+	case errors.Is(err, usecase.ErrCouponExhausted):
+		return "cupon_agotado"
+	case errors.Is(err, usecase.ErrCouponMinPurchase):
+		return "cupon_minimo"
+	case errors.Is(err, usecase.ErrCouponAlreadyUsed):
+		return "cupon_usado"
+	case errors.Is(err, usecase.ErrCouponPendingOrder):
+		return "cupon_pendiente"
+	}
+	return "cupon_invalido"
+}
+
+func (s *Server) handleCartCheckout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method", 405)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "form", 400)
+		return
+	}
+	f := parseCheckoutForm(r.Form)
+	if code := f.validate(); code != "" {
+		http.Redirect(w, r, "/cart?err="+code, 302)
+		return
+	}
+	email, name, phone, dni, postal := f.Email, f.Name, f.Phone, f.DNI, f.PostalCode
+	shippingMethod, paymentMethod, couponCode := f.ShippingMethod, f.PaymentMethod, f.CouponCode
+	address, province := f.Address, f.Province
+	cp := s.loadCart(w, r)
 	if len(cp.Items) == 0 {
 		http.Redirect(w, r, "/cart?err=vacio", 302)
 		return
@@ -1567,8 +1759,10 @@ func (s *Server) handleCartCheckout(w http.ResponseWriter, r *http.Request) {
 	}
 	o := &domain.Order{ID: uuid.New(), Status: domain.OrderStatusAwaitingPay, Email: email, Name: name, Phone: phone, DNI: dni, PostalCode: postal, ShippingMethod: shippingMethod, PaymentMethod: paymentMethod}
 	itemsTotal := 0.0
+	var shipItems []usecase.ShippingItem
 	for _, l := range lines {
 		p, _ := s.products.GetBySlug(r.Context(), l.Slug)
+		shipItems = append(shipItems, usecase.ShippingItem{Product: p, Qty: l.Qty})
 		var pid *uuid.UUID
 		var title string
 		if p != nil {
@@ -1577,19 +1771,42 @@ func (s *Server) handleCartCheckout(w http.ResponseWriter, r *http.Request) {
 		} else {
 			title = buildCartItemTitle("Producto", l.Observation)
 		}
-		o.Items = append(o.Items, domain.OrderItem{ID: uuid.New(), ProductID: pid, Qty: l.Qty, UnitPrice: l.UnitPrice, Title: title, Color: normalizeColorName(l.Color)})
+		o.Items = append(o.Items, domain.OrderItem{ID: uuid.New(), ProductID: pid, Qty: l.Qty, UnitPrice: l.UnitPrice, Title: title, Color: normalizeColorName(l.Color), Personalization: domain.EncodePersonalization(l.PZ)})
 		itemsTotal += l.UnitPrice * float64(l.Qty)
 	}
-	shippingCost := 0.0
+	// Si eligió una opción de correo la volvemos a cotizar: el precio nunca sale del formulario.
+	var carrierRate *domain.CarrierRate
+	if opt := strings.TrimSpace(r.FormValue("carrier_option")); opt != "" && shippingMethod == domain.ShippingCourier {
+		rate, err := s.carriers.QuoteOption(r.Context(), opt, postal, province, usecase.BuildPackage(shipItems), itemsTotal)
+		if err != nil {
+			log.Warn().Err(err).Str("option", opt).Msg("cotizar opción de correo")
+			http.Redirect(w, r, "/cart?err=correo_no_disponible", 302)
+			return
+		}
+		carrierRate = &rate
+	}
+	quote, err := s.shipping.Quote(r.Context(), usecase.ShippingRequest{Method: shippingMethod, Province: province, PostalCode: postal, Package: usecase.BuildPackage(shipItems), ItemsTotal: itemsTotal})
+	if err != nil {
+		log.Error().Err(err).Msg("cotizar envío")
+		http.Redirect(w, r, "/cart?err=envio", 302)
+		return
+	}
+	if !quote.Available && carrierRate == nil {
+		http.Redirect(w, r, "/cart?err=envio_no_disponible", 302)
+		return
+	}
+	shippingCost := quote.Cost
+	if carrierRate != nil {
+		shippingCost = carrierRate.Price
+		o.Carrier, o.CarrierService = carrierRate.Carrier, carrierRate.Service
+	}
 	if shippingMethod == "envio" {
-		shippingCost = shippingCostFor(province)
 		if address == "" {
 			address = "(sin dirección)"
 		}
 		o.Address = address
 		o.Province = province
 	} else if shippingMethod == "cadete" {
-		shippingCost = 5000
 		if address == "" {
 			address = "(sin dirección)"
 		}
@@ -1609,22 +1826,28 @@ func (s *Server) handleCartCheckout(w http.ResponseWriter, r *http.Request) {
 	if couponCode != "" {
 		coupon, err := s.coupons.ValidateCoupon(r.Context(), couponCode, email, subtotal)
 		if err != nil {
-			// Redirigir con error específico
-			http.Redirect(w, r, "/cart?err=cupon_invalido&msg="+url.QueryEscape(err.Error()), 302)
+			// Redirigir con el código del error; el carrito muestra un mensaje fijo
+			http.Redirect(w, r, "/cart?err="+couponErrorCode(err), 302)
 			return
 		}
 		discountAmount = s.coupons.CalculateDiscount(coupon, subtotal)
 		appliedCoupon = coupon
 	}
 
-	o.DiscountAmount = discountAmount
-	o.Total = subtotal - discountAmount
+	// Descuento o recargo por medio de pago, sobre el total con cupón
+	rule, err := s.pricing.Rule(r.Context(), paymentMethod)
+	if err != nil {
+		log.Error().Err(err).Msg("reglas de medio de pago")
+		http.Redirect(w, r, "/cart?err=orden", 302)
+		return
+	}
+	usecase.ApplyOrderTotals(o, subtotal, discountAmount, rule)
 	if appliedCoupon != nil {
 		o.CouponCode = appliedCoupon.Code
 		o.CouponID = &appliedCoupon.ID
 	}
 
-	if err := s.orders.Orders.Save(r.Context(), o); err != nil {
+	if err := s.orders.Place(r.Context(), o, usecase.StatusChange{Actor: email, Source: domain.StatusSourceCheckout, Note: "pago: " + paymentMethod}); err != nil {
 		http.Redirect(w, r, "/cart?err=orden", 302)
 		return
 	}
@@ -1636,19 +1859,17 @@ func (s *Server) handleCartCheckout(w http.ResponseWriter, r *http.Request) {
 	switch paymentMethod {
 	case "efectivo":
 		// Orden pendiente de pago en efectivo
-		o.Status = domain.OrderStatusAwaitingPay
 		o.MPStatus = "efectivo_pending"
 		_ = s.orders.Orders.Save(r.Context(), o)
 		s.sendOrderNotify(o, false) // Enviar con success=false para mostrar PENDIENTE
-		writeCart(w, cartPayload{})
+		s.saveCart(w, r, cartPayload{})
 		http.Redirect(w, r, "/pay/"+o.ID.String()+"?status=pending", 302)
 	case "transferencia":
 		// Orden con pago pendiente
-		o.Status = domain.OrderStatusAwaitingPay
 		o.MPStatus = "transferencia_pending"
 		_ = s.orders.Orders.Save(r.Context(), o)
 		s.sendOrderNotify(o, false)
-		writeCart(w, cartPayload{})
+		s.saveCart(w, r, cartPayload{})
 		http.Redirect(w, r, "/pay/"+o.ID.String()+"?status=pending", 302)
 	case "mercadopago":
 		// Redirigir a Mercado Pago
@@ -1658,7 +1879,7 @@ func (s *Server) handleCartCheckout(w http.ResponseWriter, r *http.Request) {
 		} else {
 			_ = s.orders.Orders.Save(r.Context(), o)
 		}
-		writeCart(w, cartPayload{})
+		s.saveCart(w, r, cartPayload{})
 		http.Redirect(w, r, redirURL, 302)
 	default:
 		// Fallback: usar Mercado Pago
@@ -1668,7 +1889,7 @@ func (s *Server) handleCartCheckout(w http.ResponseWriter, r *http.Request) {
 		} else {
 			_ = s.orders.Orders.Save(r.Context(), o)
 		}
-		writeCart(w, cartPayload{})
+		s.saveCart(w, r, cartPayload{})
 		http.Redirect(w, r, redirURL, 302)
 	}
 }
@@ -1693,7 +1914,7 @@ func (s *Server) apiSearchSuggestions(w http.ResponseWriter, r *http.Request) {
 	}
 	// Filtrar imágenes inexistentes
 	for i := range list {
-		list[i].Images = filterExistingProductImages(list[i].Images)
+		list[i].Images = s.filterExistingProductImages(r.Context(), list[i].Images)
 	}
 	suggestions := []map[string]any{}
 	for _, p := range list {
@@ -1755,13 +1976,19 @@ func (s *Server) handlePaySimulated(w http.ResponseWriter, r *http.Request) {
 		if status != "" {
 			if success {
 				o.MPStatus = "approved"
-				o.Status = domain.OrderStatusFinished
-				if !o.Notified {
-					o.Notified = true
-					_ = s.orders.Orders.Save(r.Context(), o)
+				notify, err := s.payments.ClaimPaid(r.Context(), o)
+				if err != nil {
+					log.Warn().Err(err).Str("order_id", o.ID.String()).Msg("marcar aviso de pago")
+				}
+				if err := s.orders.ChangeStatus(r.Context(), o, domain.OrderStatusFinished, usecase.StatusChange{Actor: "mercadopago", Source: domain.StatusSourceReturn}); err != nil {
+					log.Warn().Err(err).Str("order_id", o.ID.String()).Msg("retorno de pago")
+					if notify {
+						s.payments.ReleasePaid(r.Context(), o)
+					}
+					notify = false
+				}
+				if notify {
 					go s.sendOrderNotify(o, true)
-				} else {
-					_ = s.orders.Orders.Save(r.Context(), o)
 				}
 			} else {
 				o.MPStatus = status
@@ -1791,6 +2018,16 @@ func (s *Server) handlePaySimulated(w http.ResponseWriter, r *http.Request) {
 		"IsTransferenciaPending": isTransferenciaPending,
 		"WhatsAppPhone":          whatsappPhone,
 	}
+	if isTransferenciaPending && s.transfers != nil {
+		data["CanUploadProof"] = usecase.AwaitingTransfer(o)
+		data["ProofFlash"] = strings.TrimSpace(q.Get("proof_ok"))
+		data["ProofError"] = strings.TrimSpace(q.Get("proof_err"))
+		if p, err := s.transfers.Latest(r.Context(), o.ID); err != nil {
+			log.Error().Err(err).Str("order_id", o.ID.String()).Msg("comprobante de transferencia")
+		} else if p != nil {
+			data["TransferProof"] = p
+		}
+	}
 	if u := readUserSession(w, r); u != nil {
 		data["User"] = u
 	}
@@ -2034,23 +2271,31 @@ func (s *Server) apiProductImageByID(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "delete", 500)
 		return
 	}
-	removedFile := ""
-	sp := strings.TrimSpace(img.URL)
-	if sp != "" {
-		if strings.HasPrefix(sp, "/") {
-			sp = sp[1:]
-		}
-		if strings.Contains(sp, "uploads") {
-			if _, err := os.Stat(sp); err == nil {
-				if err2 := os.Remove(sp); err2 == nil {
-					removedFile = sp
-				}
-			}
-		}
-	}
+	removedFile := s.deleteImageFile(r.Context(), img.URL)
 	writeJSON(w, 200, map[string]any{"status": "ok", "id": uid.String(), "removed_file": removedFile})
 }
 
+// deleteImageFile borra el archivo de una imagen que ya se quitó de la base, salvo que otra imagen
+// lo siga usando. Devuelve la ruta borrada ("" si no se borró).
+func (s *Server) deleteImageFile(ctx context.Context, url string) string {
+	sp := strings.TrimPrefix(strings.TrimSpace(url), "/")
+	if !strings.Contains(sp, "uploads") {
+		return ""
+	}
+	inUse, err := s.products.ImageFileInUse(ctx, sp)
+	if err != nil {
+		log.Error().Err(err).Str("path", sp).Msg("contar imágenes del archivo")
+		return ""
+	}
+	if inUse {
+		return ""
+	}
+	if err := s.storage.Delete(ctx, sp); err != nil {
+		return ""
+	}
+	return sp
+}
+
 func (s *Server) handleAdminProducts(w http.ResponseWriter, r *http.Request) {
 	if !s.isAdminSession(r) {
 		http.Redirect(w, r, "/admin/auth", 302)
@@ -2059,7 +2304,7 @@ func (s *Server) handleAdminProducts(w http.ResponseWriter, r *http.Request) {
 	list, total, _ := s.products.List(r.Context(), domain.ProductFilter{Page: 1, PageSize: 10000})
 	// Normalizar imágenes mostradas en admin (evita contadores inflados por huérfanas)
 	for i := range list {
-		list[i].Images = filterExistingProductImages(list[i].Images)
+		list[i].Images = s.filterExistingProductImages(r.Context(), list[i].Images)
 	}
 
 	cats, _ := s.products.Categories(r.Context())
@@ -2096,26 +2341,107 @@ func (s *Server) handleAdminProductImages(w http.ResponseWriter, r *http.Request
 	b.WriteString("</h2><p style=\"margin:0 0 12px;color:#8da2b8\">Slug: ")
 	b.WriteString(template.HTMLEscapeString(p.Slug))
 	b.WriteString("</p>")
-	b.WriteString("<div style=\"display:flex;flex-wrap:wrap;gap:10px\">")
-	for _, im := range p.Images {
+	if msg := strings.TrimSpace(r.URL.Query().Get("err")); msg != "" {
+		b.WriteString("<p style=\"color:#fca5a5\">")
+		b.WriteString(template.HTMLEscapeString(msg))
+		b.WriteString("</p>")
+	}
+	b.WriteString("<p style=\"margin:0 0 12px;color:#8da2b8;font-size:13px\">La primera imagen (o la marcada como portada) se usa en listados. Recorte y foco en % de la imagen original; se aplican a las miniaturas y versiones redimensionadas.</p>")
+	b.WriteString("<div style=\"display:flex;flex-wrap:wrap;gap:14px\">")
+	slugField := "<input type=\"hidden\" name=\"slug\" value=\"" + template.HTMLEscapeString(p.Slug) + "\">"
+	pct := func(v float64) string { return strconv.FormatFloat(math.Round(v*1000)/10, 'f', -1, 64) }
+	for i, im := range p.Images {
 		u := strings.TrimSpace(im.URL)
 		if u == "" {
 			continue
 		}
-		b.WriteString("<div style=\"position:relative;width:120px;height:120px\">")
+		id := template.HTMLEscapeString(im.ID.String())
+		b.WriteString("<div class=\"admin-card\" style=\"width:260px;padding:10px;display:flex;flex-direction:column;gap:8px\">")
+		b.WriteString("<div style=\"position:relative;width:100%;aspect-ratio:1/1\">")
 		b.WriteString("<img src=\"")
 		b.WriteString(template.HTMLEscapeString(u))
-		b.WriteString("\" alt=\"\" style=\"width:100%;height:100%;object-fit:cover;border-radius:10px;border:1px solid #223140\">")
+		b.WriteString("?w=480&amp;h=480\" alt=\"")
+		b.WriteString(template.HTMLEscapeString(im.Alt))
+		b.WriteString("\" style=\"width:100%;height:100%;object-fit:cover;border-radius:10px;border:1px solid #223140\">")
+		if i == 0 {
+			b.WriteString("<span style=\"position:absolute;left:6px;top:6px;background:#0ea5e9;color:#fff;font-size:11px;padding:2px 8px;border-radius:999px\">Portada</span>")
+		}
 		b.WriteString("<form method=\"post\" action=\"/admin/product_images/delete\" style=\"position:absolute;top:-8px;right:-8px\">")
+		b.WriteString(slugField)
 		b.WriteString("<input type=\"hidden\" name=\"id\" value=\"")
-		b.WriteString(template.HTMLEscapeString(im.ID.String()))
+		b.WriteString(id)
 		b.WriteString("\"><button class=\"btn-danger\" style=\"padding:4px 8px;border-radius:999px\" onclick=\"return confirm('Eliminar imagen?')\">✖</button></form>")
 		b.WriteString("</div>")
+
+		// orden y portada
+		b.WriteString("<div style=\"display:flex;gap:6px\">")
+		for _, mv := range []struct{ dir, label string }{{"up", "◀"}, {"down", "▶"}} {
+			b.WriteString("<form method=\"post\" action=\"/admin/product_images/move\">")
+			b.WriteString(slugField)
+			b.WriteString("<input type=\"hidden\" name=\"id\" value=\"" + id + "\"><input type=\"hidden\" name=\"dir\" value=\"" + mv.dir + "\">")
+			b.WriteString("<button class=\"btn-secondary\" style=\"padding:4px 10px\">" + mv.label + "</button></form>")
+		}
+		if i != 0 {
+			b.WriteString("<form method=\"post\" action=\"/admin/product_images/primary\">")
+			b.WriteString(slugField)
+			b.WriteString("<input type=\"hidden\" name=\"id\" value=\"" + id + "\">")
+			b.WriteString("<button class=\"btn-secondary\" style=\"padding:4px 10px\">Portada</button></form>")
+		}
+		b.WriteString("</div>")
+
+		// alt, recorte y foco
+		b.WriteString("<form method=\"post\" action=\"/admin/product_images/update\" style=\"display:flex;flex-direction:column;gap:4px;font-size:12px\">")
+		b.WriteString(slugField)
+		b.WriteString("<input type=\"hidden\" name=\"id\" value=\"" + id + "\">")
+		b.WriteString("<label>Texto alternativo<input type=\"text\" name=\"alt\" maxlength=\"140\" value=\"")
+		b.WriteString(template.HTMLEscapeString(im.Alt))
+		b.WriteString("\"></label>")
+		b.WriteString("<div style=\"display:flex;gap:4px\">")
+		for _, f := range []struct {
+			name, label string
+			v           float64
+		}{{"focal_x", "Foco X", im.FocalX}, {"focal_y", "Foco Y", im.FocalY}} {
+			b.WriteString("<label style=\"flex:1\">" + f.label + " %<input type=\"number\" step=\"0.1\" min=\"0\" max=\"100\" name=\"" + f.name + "\" value=\"" + pct(f.v) + "\"></label>")
+		}
+		b.WriteString("</div><div style=\"display:flex;gap:4px\">")
+		for _, f := range []struct {
+			name, label string
+			v           float64
+		}{{"crop_x", "X", im.CropX}, {"crop_y", "Y", im.CropY}, {"crop_w", "Ancho", im.CropW}, {"crop_h", "Alto", im.CropH}} {
+			b.WriteString("<label style=\"flex:1\">" + f.label + " %<input type=\"number\" step=\"0.1\" min=\"0\" max=\"100\" name=\"" + f.name + "\" value=\"" + pct(f.v) + "\"></label>")
+		}
+		b.WriteString("</div><button class=\"btn-primary\" style=\"padding:4px 10px\">Guardar</button></form>")
+
+		// reemplazo en el lugar
+		b.WriteString("<form method=\"post\" action=\"/admin/product_images/replace\" enctype=\"multipart/form-data\" style=\"display:flex;gap:4px;align-items:center;font-size:12px\">")
+		b.WriteString(slugField)
+		b.WriteString("<input type=\"hidden\" name=\"id\" value=\"" + id + "\">")
+		b.WriteString("<input type=\"file\" name=\"image\" accept=\"image/*\" required style=\"flex:1;min-width:0\">")
+		b.WriteString("<button class=\"btn-secondary\" style=\"padding:4px 10px\">Reemplazar</button></form>")
+		b.WriteString("</div>")
 	}
 	if len(p.Images) == 0 {
 		b.WriteString("<p style=\"color:#8da2b8\">Sin imágenes.</p>")
 	}
-	b.WriteString("</div><p style=\"margin-top:16px\"><a class=\"btn-secondary\" href=\"/admin/products\">Volver</a></p>")
+	b.WriteString("</div>")
+	if s.model3d != nil {
+		b.WriteString("<h3 style=\"margin:20px 0 8px\">Modelo 3D</h3>")
+		if pm, err := s.model3d.Get(r.Context(), p.ID); err == nil {
+			b.WriteString("<p style=\"margin:0 0 8px;color:#8da2b8;font-size:13px\">")
+			b.WriteString(template.HTMLEscapeString(pm.SourceFilename))
+			b.WriteString(fmt.Sprintf(" · %.1f × %.1f × %.1f mm · %d triángulos (original %d) · GLB %d KB</p>", pm.SizeXMM, pm.SizeYMM, pm.SizeZMM, pm.Triangles, pm.SourceTriangles, pm.AssetSize/1024))
+			b.WriteString("<form method=\"post\" action=\"/admin/product_model/remove\" style=\"margin:0 0 8px\">")
+			b.WriteString(slugField)
+			b.WriteString("<button class=\"btn-danger\" style=\"padding:4px 10px\" onclick=\"return confirm('Quitar modelo 3D?')\">Quitar modelo</button></form>")
+		} else {
+			b.WriteString("<p style=\"margin:0 0 8px;color:#8da2b8;font-size:13px\">Sin modelo 3D. Se convierte a GLB para el visor; el archivo original no se publica.</p>")
+		}
+		b.WriteString("<form method=\"post\" action=\"/admin/product_model/upload\" enctype=\"multipart/form-data\" style=\"display:flex;gap:6px;align-items:center\">")
+		b.WriteString(slugField)
+		b.WriteString("<input type=\"file\" name=\"model\" accept=\".stl,.3mf\" required>")
+		b.WriteString("<button class=\"btn-primary\" style=\"padding:4px 10px\">Subir STL/3MF</button></form>")
+	}
+	b.WriteString("<p style=\"margin-top:16px\"><a class=\"btn-secondary\" href=\"/admin/products\">Volver</a></p>")
 	b.WriteString("</body></html>")
 	_, _ = w.Write([]byte(b.String()))
 }
@@ -2137,20 +2463,170 @@ func (s *Server) handleAdminProductImagesDelete(w http.ResponseWriter, r *http.R
 	}
 	img, err := s.products.GetImageByID(r.Context(), uid)
 	if err == nil && img != nil {
-		_ = s.products.DeleteImageByID(r.Context(), uid)
-		sp := strings.TrimSpace(img.URL)
-		if strings.HasPrefix(sp, "/") {
-			sp = sp[1:]
-		}
-		if strings.Contains(sp, "uploads") {
-			if _, err := os.Stat(sp); err == nil {
-				_ = os.Remove(sp)
-			}
+		if err := s.products.DeleteImageByID(r.Context(), uid); err == nil {
+			s.deleteImageFile(r.Context(), img.URL)
 		}
 	}
+	if slug := strings.TrimSpace(r.FormValue("slug")); slug != "" {
+		http.Redirect(w, r, "/admin/product_images?slug="+url.QueryEscape(slug), 302)
+		return
+	}
 	http.Redirect(w, r, "/admin/products", 302)
 }
 
+// productImageForm resuelve producto e imagen de los formularios del gestor de galería.
+func (s *Server) productImageForm(w http.ResponseWriter, r *http.Request) (*domain.Product, *domain.Image, bool) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", 302)
+		return nil, nil, false
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/products", 302)
+		return nil, nil, false
+	}
+	slug := strings.TrimSpace(r.FormValue("slug"))
+	uid, err := uuid.Parse(strings.TrimSpace(r.FormValue("id")))
+	if err != nil || slug == "" {
+		http.Redirect(w, r, "/admin/products", 302)
+		return nil, nil, false
+	}
+	p, err := s.products.GetBySlug(r.Context(), slug)
+	if err != nil || p == nil {
+		http.Redirect(w, r, "/admin/products", 302)
+		return nil, nil, false
+	}
+	for i := range p.Images {
+		if p.Images[i].ID == uid {
+			return p, &p.Images[i], true
+		}
+	}
+	redirectProductImages(w, r, slug, "La imagen no pertenece al producto")
+	return nil, nil, false
+}
+
+func redirectProductImages(w http.ResponseWriter, r *http.Request, slug, errMsg string) {
+	u := "/admin/product_images?slug=" + url.QueryEscape(slug)
+	if errMsg != "" {
+		u += "&err=" + url.QueryEscape(errMsg)
+	}
+	http.Redirect(w, r, u, 302)
+}
+
+func (s *Server) handleAdminProductImagesMove(w http.ResponseWriter, r *http.Request) {
+	p, img, ok := s.productImageForm(w, r)
+	if !ok {
+		return
+	}
+	delta := 1
+	if r.FormValue("dir") == "up" {
+		delta = -1
+	}
+	if err := s.products.MoveImage(r.Context(), p, img.ID, delta); err != nil {
+		log.Error().Err(err).Msg("mover imagen")
+		redirectProductImages(w, r, p.Slug, "No se pudo reordenar")
+		return
+	}
+	redirectProductImages(w, r, p.Slug, "")
+}
+
+func (s *Server) handleAdminProductImagesPrimary(w http.ResponseWriter, r *http.Request) {
+	p, img, ok := s.productImageForm(w, r)
+	if !ok {
+		return
+	}
+	if err := s.products.SetPrimaryImage(r.Context(), p.ID, img.ID); err != nil {
+		log.Error().Err(err).Msg("portada imagen")
+		redirectProductImages(w, r, p.Slug, "No se pudo marcar como portada")
+		return
+	}
+	redirectProductImages(w, r, p.Slug, "")
+}
+
+func (s *Server) handleAdminProductImagesUpdate(w http.ResponseWriter, r *http.Request) {
+	p, img, ok := s.productImageForm(w, r)
+	if !ok {
+		return
+	}
+	pct := func(name string, def float64) float64 {
+		v, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(r.FormValue(name)), ",", "."), 64)
+		if err != nil {
+			return def
+		}
+		return v / 100
+	}
+	img.Alt = r.FormValue("alt")
+	img.FocalX = pct("focal_x", 0.5)
+	img.FocalY = pct("focal_y", 0.5)
+	img.CropX = pct("crop_x", 0)
+	img.CropY = pct("crop_y", 0)
+	img.CropW = pct("crop_w", 0)
+	img.CropH = pct("crop_h", 0)
+	if err := s.products.UpdateImageMeta(r.Context(), img); err != nil {
+		redirectProductImages(w, r, p.Slug, err.Error())
+		return
+	}
+	redirectProductImages(w, r, p.Slug, "")
+}
+
+// maxProductImageBytes es el tamaño máximo de una imagen de reemplazo.
+const maxProductImageBytes = 25 << 20
+
+// handleAdminProductImagesReplace cambia el archivo de una imagen sin tocar orden, portada ni alt
+// (no cuenta contra el límite de 6 imágenes).
+func (s *Server) handleAdminProductImagesReplace(w http.ResponseWriter, r *http.Request) {
+	// la sesión se valida antes de leer el body para no parsear uploads anónimos
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", 302)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/products", 302)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxProductImageBytes+(1<<20))
+	if err := r.ParseMultipartForm(maxProductImageBytes); err != nil {
+		http.Error(w, "archivo demasiado grande", 400)
+		return
+	}
+	p, img, ok := s.productImageForm(w, r)
+	if !ok {
+		return
+	}
+	f, fh, err := r.FormFile("image")
+	if err != nil {
+		redirectProductImages(w, r, p.Slug, "Falta el archivo")
+		return
+	}
+	data, err := io.ReadAll(f)
+	_ = f.Close()
+	if err != nil || len(data) == 0 {
+		redirectProductImages(w, r, p.Slug, "Archivo vacío")
+		return
+	}
+	if ct := http.DetectContentType(data); !strings.HasPrefix(ct, "image/") {
+		redirectProductImages(w, r, p.Slug, "El archivo no es una imagen")
+		return
+	}
+	storedPath, err := s.storage.SaveImage(r.Context(), fh.Filename, data)
+	if err != nil {
+		log.Error().Err(err).Msg("guardar imagen reemplazo")
+		redirectProductImages(w, r, p.Slug, "No se pudo guardar la imagen")
+		return
+	}
+	if !strings.HasPrefix(storedPath, "/") {
+		storedPath = "/" + strings.ReplaceAll(storedPath, "\\", "/")
+	}
+	old, err := s.products.ReplaceImage(r.Context(), img.ID, storedPath)
+	if err != nil {
+		log.Error().Err(err).Msg("reemplazar imagen")
+		_ = s.storage.Delete(r.Context(), storedPath)
+		redirectProductImages(w, r, p.Slug, "No se pudo reemplazar")
+		return
+	}
+	s.deleteImageFile(r.Context(), old)
+	redirectProductImages(w, r, p.Slug, "")
+}
+
 func (s *Server) handleAdminOrders(w http.ResponseWriter, r *http.Request) {
 	if !s.isAdminSession(r) {
 		http.Redirect(w, r, "/admin/auth", 302)
@@ -2167,7 +2643,8 @@ func (s *Server) handleAdminOrders(w http.ResponseWriter, r *http.Request) {
 		mpStatus = &st
 		filterApproved = true
 	}
-	list, total, err := s.orders.Orders.List(r.Context(), nil, mpStatus, page, 20)
+	search := strings.TrimSpace(r.URL.Query().Get("q"))
+	list, total, err := s.orders.Orders.List(r.Context(), nil, mpStatus, search, page, 20)
 	if err != nil {
 		http.Error(w, "err", 500)
 		return
@@ -2197,14 +2674,38 @@ func (s *Server) handleAdminOrders(w http.ResponseWriter, r *http.Request) {
 			productURLByID[product.ID] = "/product/" + product.Slug
 		}
 	}
+	orderIDs := make([]uuid.UUID, 0, len(list))
+	for _, order := range list {
+		orderIDs = append(orderIDs, order.ID)
+	}
+	history, err := s.orders.History(r.Context(), orderIDs)
+	if err != nil {
+		log.Error().Err(err).Msg("admin orders historial")
+	}
+	refunds := map[uuid.UUID][]domain.Refund{}
+	if s.refunds != nil {
+		rl, err := s.refunds.Refunds.ListByOrders(r.Context(), orderIDs)
+		if err != nil {
+			log.Error().Err(err).Msg("admin orders reembolsos")
+		}
+		for _, rf := range rl {
+			refunds[rf.OrderID] = append(refunds[rf.OrderID], rf)
+		}
+	}
+	payments, err := s.payments.Payments.ListByOrders(r.Context(), orderIDs)
+	if err != nil {
+		log.Error().Err(err).Msg("admin orders pagos")
+	}
+	carrierNames := s.carriers.Names()
 	orderViews := make([]adminOrderView, 0, len(list))
 	for _, order := range list {
 		itemViews := make([]adminOrderItemView, 0, len(order.Items))
 		for _, item := range order.Items {
 			itemView := adminOrderItemView{
-				Title: item.Title,
-				Color: item.Color,
-				Qty:   item.Qty,
+				Title:           item.Title,
+				Color:           item.Color,
+				Qty:             item.Qty,
+				Personalization: item.PersonalizationValues(),
 			}
 			if item.ProductID != nil {
 				if productURL := productURLByID[*item.ProductID]; productURL != "" {
@@ -2216,6 +2717,7 @@ func (s *Server) handleAdminOrders(w http.ResponseWriter, r *http.Request) {
 		}
 		orderViews = append(orderViews, adminOrderView{
 			ID:             order.ID,
+			Code:           order.Code(),
 			Email:          order.Email,
 			Status:         order.Status,
 			Total:          order.Total,
@@ -2224,10 +2726,54 @@ func (s *Server) handleAdminOrders(w http.ResponseWriter, r *http.Request) {
 			MPStatus:       order.MPStatus,
 			CreatedAt:      order.CreatedAt,
 			Items:          itemViews,
+			History:        history[order.ID],
+			NextStatuses:   manualNextStatuses(order.Status),
+			ShippingMethod: order.ShippingMethod,
+			Carrier:        order.Carrier,
+			CarrierName:    carrierNames[order.Carrier],
+			TrackingNumber: order.TrackingNumber,
+			TrackingURL:    order.TrackingURL,
+			ShipmentStage:  order.ShipmentStage,
+			ShippingLabel:  order.ShippingLabel,
+			RefundedAmount: order.RefundedAmount,
+			Refunds:        refunds[order.ID],
 		})
+		v := &orderViews[len(orderViews)-1]
+		for _, p := range payments {
+			if p.OrderID == order.ID {
+				v.Payments = append(v.Payments, p)
+			}
+		}
+		b := domain.NewOrderBalance(&order, v.Payments)
+		v.Paid, v.Due = b.Paid, b.Due
+		if s.refunds != nil {
+			orderViews[len(orderViews)-1].Refundable = usecase.Refundable(&order)
+		}
 	}
 	pages := (int(total) + 19) / 20
-	data := map[string]any{"Orders": orderViews, "Page": page, "Pages": pages, "AdminToken": s.readAdminToken(r), "FilterApproved": filterApproved}
+	data := map[string]any{"Orders": orderViews, "Page": page, "Pages": pages, "AdminToken": s.readAdminToken(r), "FilterApproved": filterApproved, "Search": search, "Carriers": carrierNames, "TicketPrinter": s.printer != nil,
+		"Flash": strings.TrimSpace(r.URL.Query().Get("ok")), "FlashError": strings.TrimSpace(r.URL.Query().Get("err"))}
+	if s.transfers != nil {
+		if pending, err := s.transfers.Pending(r.Context()); err == nil {
+			data["PendingTransfers"] = len(pending)
+		}
+	}
+	if s.reconcile != nil {
+		if issues, err := s.reconcile.Open(r.Context()); err == nil {
+			open := 0
+			for _, i := range issues {
+				if !i.Fixed {
+					open++
+				}
+			}
+			data["ReconcileIssues"] = open
+		}
+	}
+	if s.webhooks != nil {
+		if counts, err := s.webhooks.Events.CountByStatus(r.Context()); err == nil {
+			data["FailedWebhooks"] = counts[domain.WebhookFailed]
+		}
+	}
 	s.render(w, "admin_orders.html", data)
 }
 
@@ -2274,45 +2820,59 @@ func (s *Server) handleAdminConfirmPayment(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "esta orden ya fue confirmada", http.StatusBadRequest)
 		return
 	}
-
-	// Actualizar el estado de la orden
+	if !usecase.CanTransition(order.Status, domain.OrderStatusFinished) {
+		http.Error(w, "no se puede confirmar una orden en estado "+domain.OrderStatusLabel(order.Status), http.StatusBadRequest)
+		return
+	}
 	oldStatus := order.Status
-	order.Status = domain.OrderStatusFinished
 
-	// Actualizar MPStatus para reflejar la confirmación manual
-	if order.MPStatus == "efectivo_pending" {
-		order.MPStatus = "efectivo_confirmed"
-	} else if order.MPStatus == "transferencia_pending" {
-		order.MPStatus = "transferencia_confirmed"
+	balance, _, err := s.payments.Balance(r.Context(), order)
+	if err != nil {
+		log.Error().Err(err).Str("order_id", orderIDStr).Msg("saldo de la orden")
+		http.Error(w, "error al leer los pagos", http.StatusInternalServerError)
+		return
 	}
-
-	// Registrar uso del cupón si aplica
-	if order.CouponID != nil && order.CouponCode != "" {
-		if err := s.coupons.ApplyCoupon(r.Context(), *order.CouponID, order.ID, order.Email, order.DiscountAmount, order.Total+order.DiscountAmount); err != nil {
-			log.Error().Err(err).
-				Str("coupon_code", order.CouponCode).
-				Str("order_id", order.ID.String()).
-				Msg("error al registrar uso de cupón en confirmación manual")
-			http.Error(w, "error al registrar cupón: "+err.Error(), http.StatusInternalServerError)
+	// amount vacío = se cobró todo el saldo; menos es una seña
+	amount, err := parseMoneyAR(r.FormValue("amount"))
+	if err != nil || amount < 0 {
+		http.Error(w, "monto inválido", http.StatusBadRequest)
+		return
+	}
+	if amount == 0 {
+		amount = balance.Due
+	}
+	if amount > balance.Due+0.009 {
+		http.Error(w, "el monto supera el saldo ($"+strconv.FormatFloat(balance.Due, 'f', 2, 64)+")", http.StatusBadRequest)
+		return
+	}
+	provider := domain.PaymentProviderCash
+	if order.MPStatus == "transferencia_pending" {
+		provider = domain.PaymentProviderTransfer
+	}
+	if amount > 0 {
+		if _, err := s.payments.RecordManual(r.Context(), order, provider, amount, s.adminEmail(r), strings.TrimSpace(r.FormValue("note"))); err != nil {
+			log.Error().Err(err).Str("order_id", orderIDStr).Msg("registrar pago manual")
+			http.Error(w, "error al registrar el pago", http.StatusInternalServerError)
+			return
+		}
+		if balance, _, err = s.payments.Balance(r.Context(), order); err != nil {
+			log.Error().Err(err).Str("order_id", orderIDStr).Msg("saldo de la orden")
+			http.Error(w, "pago registrado, pero no se pudo recalcular el saldo", http.StatusInternalServerError)
 			return
 		}
-		log.Info().
-			Str("coupon_code", order.CouponCode).
-			Str("order_id", order.ID.String()).
-			Float64("discount", order.DiscountAmount).
-			Msg("cupón registrado exitosamente tras confirmación manual")
 	}
-
-	// Marcar como notificada y enviar notificación si no se había enviado
-	if !order.Notified {
-		order.Notified = true
-		go s.sendOrderNotify(order, true)
+	// con saldo pendiente la orden sigue esperando el resto
+	if balance.Partial() {
+		log.Info().Str("order_id", order.ID.String()).Str("provider", provider).Float64("amount", amount).Float64("due", balance.Due).Msg("seña registrada por admin")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Seña registrada. Saldo pendiente: $" + strconv.FormatFloat(balance.Due, 'f', 2, 64)))
+		return
 	}
 
-	// Guardar la orden actualizada
-	if err := s.orders.Orders.Save(r.Context(), order); err != nil {
-		log.Error().Err(err).Msg("error al guardar orden confirmada")
-		http.Error(w, "error al guardar cambios", http.StatusInternalServerError)
+	change := usecase.StatusChange{Actor: s.adminEmail(r), Source: domain.StatusSourceAdmin, Note: "pago " + order.PaymentMethod + " confirmado"}
+	if err := s.confirmOrderPaid(r.Context(), order, change); err != nil {
+		log.Error().Err(err).Str("order_id", order.ID.String()).Msg("error al confirmar pago manual")
+		http.Error(w, "error al confirmar el pago: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -2329,6 +2889,51 @@ func (s *Server) handleAdminConfirmPayment(w http.ResponseWriter, r *http.Reques
 	w.Write([]byte("Pago confirmado exitosamente"))
 }
 
+// confirmOrderPaid aplica los efectos de un pago en efectivo o transferencia confirmado por el
+// admin, igual que el webhook con un pago aprobado: registra el cupón, avisa y finaliza la orden.
+func (s *Server) confirmOrderPaid(ctx context.Context, order *domain.Order, change usecase.StatusChange) error {
+	// Actualizar MPStatus para reflejar la confirmación manual
+	if order.MPStatus == "efectivo_pending" {
+		order.MPStatus = "efectivo_confirmed"
+	} else if order.MPStatus == "transferencia_pending" {
+		order.MPStatus = "transferencia_confirmed"
+	}
+
+	// sólo quien marca la orden como avisada registra el cupón y avisa (doble clic, webhook a la vez)
+	notify, err := s.payments.ClaimPaid(ctx, order)
+	if err != nil {
+		return err
+	}
+	if err := s.orders.ChangeStatus(ctx, order, domain.OrderStatusFinished, change); err != nil {
+		if notify {
+			s.payments.ReleasePaid(ctx, order)
+		}
+		return err
+	}
+
+	// Registrar uso del cupón si aplica
+	if notify && order.CouponID != nil && order.CouponCode != "" {
+		if err := s.coupons.ApplyCoupon(ctx, *order.CouponID, order.ID, order.Email, order.DiscountAmount, order.Subtotal()); err != nil {
+			log.Error().Err(err).
+				Str("coupon_code", order.CouponCode).
+				Str("order_id", order.ID.String()).
+				Msg("error al registrar uso de cupón en confirmación manual")
+		} else {
+			log.Info().
+				Str("coupon_code", order.CouponCode).
+				Str("order_id", order.ID.String()).
+				Float64("discount", order.DiscountAmount).
+				Msg("cupón registrado exitosamente tras confirmación manual")
+		}
+	}
+
+	// Enviar notificación si no se había enviado
+	if notify {
+		go s.sendOrderNotify(order, true)
+	}
+	return nil
+}
+
 func (s *Server) handleAdminOrdersDeleteRange(w http.ResponseWriter, r *http.Request) {
 	if !s.isAdminSession(r) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
@@ -2380,20 +2985,32 @@ func (s *Server) handleAdminOrdersDeleteRange(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	deleted, err := s.orders.Orders.DeleteRange(r.Context(), from, to)
+	if s.orderArchives == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]any{"error": "El archivo de órdenes no está disponible; no se borró nada."})
+		return
+	}
+	// antes de borrar se guarda el archivo comprimido; si eso falla no se borra nada
+	archive, deleted, err := s.orderArchives.ArchiveAndDelete(r.Context(), from, to, s.adminEmail(r))
 	if err != nil {
 		log.Error().Err(err).Str("from", fromStr).Str("to", toStr).Msg("admin orders delete range")
 		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(map[string]any{"error": "No pude eliminar las órdenes del período seleccionado."})
+		msg := "No pude eliminar las órdenes del período seleccionado."
+		if archive != nil {
+			msg = "Las órdenes quedaron archivadas pero no se pudieron eliminar. Probá de nuevo."
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"error": msg})
 		return
 	}
 
-	log.Warn().
-		Str("from", fromStr).
-		Str("to", toStr).
-		Int64("deleted_orders", deleted).
-		Msg("admin deleted orders by range")
-	_ = json.NewEncoder(w).Encode(map[string]any{"deleted": deleted})
+	resp := map[string]any{"deleted": deleted}
+	ev := log.Warn().Str("from", fromStr).Str("to", toStr).Int64("deleted_orders", deleted)
+	if archive != nil {
+		resp["archive"] = archive.ID.String()
+		ev = ev.Str("archive_id", archive.ID.String()).Str("archive_path", archive.Path)
+	}
+	ev.Msg("admin deleted orders by range")
+	_ = json.NewEncoder(w).Encode(resp)
 }
 
 func (s *Server) handleAdminSales(w http.ResponseWriter, r *http.Request) {
@@ -2459,8 +3076,11 @@ func (s *Server) handleAdminSales(w http.ResponseWriter, r *http.Request) {
 		Orders  int
 	}{}
 
+	refundedTotal := 0.0
 	for _, o := range orders {
-		totalRevenue += o.Total
+		// los reembolsos parciales se descuentan del ingreso (los totales ya no son "approved")
+		totalRevenue += o.Total - o.RefundedAmount
+		refundedTotal += o.RefundedAmount
 		shippingRevenue += o.ShippingCost
 		statusCounts[string(o.Status)]++
 		if o.MPStatus != "" {
@@ -2474,7 +3094,7 @@ func (s *Server) handleAdminSales(w http.ResponseWriter, r *http.Request) {
 		}
 		dayKey := o.CreatedAt.Format("2006-01-02")
 		dr := dayRevenue[dayKey]
-		dr.Revenue += o.Total
+		dr.Revenue += o.Total - o.RefundedAmount
 		dr.Orders++
 		dayRevenue[dayKey] = dr
 		lineItems := 0.0
@@ -2535,9 +3155,9 @@ func (s *Server) handleAdminSales(w http.ResponseWriter, r *http.Request) {
 	if strings.ToLower(q.Get("format")) == "csv" {
 		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=ventas_%s_%s.csv", from.Format(layoutIn), to.Format(layoutIn)))
-		fmt.Fprintln(w, "order_id,created_at,status,mp_status,total,shipping_method,shipping_cost,province")
+		fmt.Fprintln(w, "order_id,order_number,created_at,status,mp_status,total,shipping_method,shipping_cost,province")
 		for _, o := range orders {
-			fmt.Fprintf(w, "%s,%s,%s,%s,%.2f,%s,%.2f,%s\n", o.ID, o.CreatedAt.Format(time.RFC3339), o.Status, o.MPStatus, o.Total, o.ShippingMethod, o.ShippingCost, strings.ReplaceAll(o.Province, ",", " "))
+			fmt.Fprintf(w, "%s,%s,%s,%s,%s,%.2f,%s,%.2f,%s\n", o.ID, o.Code(), o.CreatedAt.Format(time.RFC3339), o.Status, o.MPStatus, o.Total, o.ShippingMethod, o.ShippingCost, strings.ReplaceAll(o.Province, ",", " "))
 		}
 		return
 	}
@@ -2547,6 +3167,7 @@ func (s *Server) handleAdminSales(w http.ResponseWriter, r *http.Request) {
 		"To":                   to.Format(layoutIn),
 		"OrdersCount":          len(orders),
 		"TotalRevenue":         totalRevenue,
+		"RefundedTotal":        refundedTotal,
 		"ItemsRevenue":         itemsRevenue,
 		"ShippingRevenue":      shippingRevenue,
 		"AvgOrderValue":        avgOrderValue,
@@ -2966,7 +3587,7 @@ func (s *Server) handleAdminDestacada(w http.ResponseWriter, r *http.Request) {
 	log.Info().Int("featured_count", len(featured)).Msg("loaded featured products")
 
 	// Cargar productos actuales del carrusel desde carousel.json
-	carouselSlugs := loadCarouselItems()
+	carouselSlugs := s.loadCarouselItems(r.Context())
 
 	// Obtener información de los productos del carrusel
 	carouselProducts := make([]map[string]interface{}, 0, len(carouselSlugs))
@@ -3014,6 +3635,14 @@ func (s *Server) readAdminToken(r *http.Request) string {
 	return c.Value
 }
 
+// adminEmail devuelve el email de la sesión admin (para auditoría), o "admin" si no se puede leer.
+func (s *Server) adminEmail(r *http.Request) string {
+	if email, err := s.verifyAdminToken(s.readAdminToken(r)); err == nil {
+		return email
+	}
+	return "admin"
+}
+
 func (s *Server) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
 	auth := r.Header.Get("Authorization")
 	if strings.HasPrefix(strings.ToLower(auth), "bearer ") {
@@ -3032,6 +3661,17 @@ func (s *Server) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
 	return false
 }
 
+// writePersonalizationLines agrega debajo del ítem lo que hay que imprimir/grabar.
+func writePersonalizationLines(w io.Writer, it domain.OrderItem) {
+	for _, v := range it.PersonalizationValues() {
+		if v.Surcharge > 0 {
+			_, _ = fmt.Fprintf(w, "    · %s: %s (+$%.2f)\n", v.Label, v.Display(), v.Surcharge)
+		} else {
+			_, _ = fmt.Fprintf(w, "    · %s: %s\n", v.Label, v.Display())
+		}
+	}
+}
+
 func sendOrderEmail(o *domain.Order, success bool) error {
 	host := os.Getenv("SMTP_HOST")
 	port := os.Getenv("SMTP_PORT")
@@ -3051,25 +3691,21 @@ func sendOrderEmail(o *domain.Order, success bool) error {
 		statusTxt = "PAGO APROBADO"
 	}
 	var buf bytes.Buffer
-	_, _ = fmt.Fprintf(&buf, "Subject: Nueva orden %s #%s\r\n", statusTxt, o.ID.String())
+	_, _ = fmt.Fprintf(&buf, "Subject: Nueva orden %s #%s\r\n", statusTxt, o.Code())
 	_, _ = fmt.Fprintf(&buf, "From: %s\r\n", user)
 	_, _ = fmt.Fprintf(&buf, "To: %s\r\n", to)
 	buf.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
 	_, _ = fmt.Fprintf(&buf, "Estado: %s\n", statusTxt)
-	_, _ = fmt.Fprintf(&buf, "Orden: %s\n", o.ID)
+	_, _ = fmt.Fprintf(&buf, "Orden: %s (%s)\n", o.Code(), o.ID)
 	_, _ = fmt.Fprintf(&buf, "Nombre: %s\nEmail: %s\nTel: %s\nDNI: %s\n", o.Name, o.Email, o.Phone, o.DNI)
 
-	// Método de pago
-	paymentMethod := "Mercado Pago"
-	if o.PaymentMethod == "efectivo" {
-		paymentMethod = "Efectivo"
-	} else if o.PaymentMethod == "transferencia" {
-		paymentMethod = "Transferencia (10% OFF)"
-	}
-	_, _ = fmt.Fprintf(&buf, "Método de pago: %s\n", paymentMethod)
+	_, _ = fmt.Fprintf(&buf, "Método de pago: %s\n", orderPaymentLine(o))
 	if o.DiscountAmount > 0 {
 		_, _ = fmt.Fprintf(&buf, "Descuento: -$%.2f\n", o.DiscountAmount)
 	}
+	if o.PaymentAdjustment != 0 {
+		_, _ = fmt.Fprintf(&buf, "Ajuste por medio de pago: %s$%.2f\n", signPrefix(o.PaymentAdjustment), math.Abs(o.PaymentAdjustment))
+	}
 
 	if o.ShippingMethod == "envio" || o.ShippingMethod == "cadete" {
 		_, _ = fmt.Fprintf(&buf, "Envío (%s) a: %s (%s) CP:%s\n", o.ShippingMethod, o.Address, o.Province, o.PostalCode)
@@ -3084,6 +3720,7 @@ func sendOrderEmail(o *domain.Order, success bool) error {
 		} else {
 			_, _ = fmt.Fprintf(&buf, "- %s x%d $%.2f\n", it.Title, it.Qty, it.UnitPrice)
 		}
+		writePersonalizationLines(&buf, it)
 	}
 	_, _ = fmt.Fprintf(&buf, "Total: $%.2f (Envío: $%.2f)\n", o.Total, o.ShippingCost)
 	auth := smtp.PlainAuth("", user, pass, host)
@@ -3113,23 +3750,19 @@ func sendOrderTelegram(o *domain.Order, success bool) error {
 	var b strings.Builder
 
 	b.WriteString("Orden ")
-	b.WriteString(o.ID.String())
+	b.WriteString(o.Code())
 	b.WriteString(" - ")
 	b.WriteString(statusTxt)
 	b.WriteString("\n")
 	fmt.Fprintf(&b, "Nombre: %s\nEmail: %s\nTel: %s\nDNI: %s\n", o.Name, o.Email, o.Phone, o.DNI)
 
-	// Método de pago
-	paymentMethod := "Mercado Pago"
-	if o.PaymentMethod == "efectivo" {
-		paymentMethod = "Efectivo"
-	} else if o.PaymentMethod == "transferencia" {
-		paymentMethod = "Transferencia (10% OFF)"
-	}
-	fmt.Fprintf(&b, "Método de pago: %s\n", paymentMethod)
+	fmt.Fprintf(&b, "Método de pago: %s\n", orderPaymentLine(o))
 	if o.DiscountAmount > 0 {
 		fmt.Fprintf(&b, "Descuento: -$%.2f\n", o.DiscountAmount)
 	}
+	if o.PaymentAdjustment != 0 {
+		fmt.Fprintf(&b, "Ajuste por medio de pago: %s$%.2f\n", signPrefix(o.PaymentAdjustment), math.Abs(o.PaymentAdjustment))
+	}
 
 	if o.ShippingMethod == "envio" || o.ShippingMethod == "cadete" {
 		fmt.Fprintf(&b, "Envío (%s) a: %s (%s %s) CP:%s\n", o.ShippingMethod, o.Address, o.Province, o.ShippingMethod, o.PostalCode)
@@ -3144,12 +3777,42 @@ func sendOrderTelegram(o *domain.Order, success bool) error {
 		} else {
 			fmt.Fprintf(&b, "- %s x%d — $%.2f\n", it.Title, it.Qty, it.UnitPrice)
 		}
+		writePersonalizationLines(&b, it)
 	}
 	fmt.Fprintf(&b, "Total: $%.2f (Envio: $%.2f)\n", o.Total, o.ShippingCost)
 	return telegram.SendPlain(b.String())
 }
 
+// orderPaymentLine es el medio de pago de la orden para los avisos, con el ajuste aplicado.
+func orderPaymentLine(o *domain.Order) string {
+	label := domain.PaymentMethodLabel(domain.PaymentProviderMercadoPago)
+	if o.PaymentMethod == domain.PaymentProviderCash || o.PaymentMethod == domain.PaymentProviderTransfer {
+		label = domain.PaymentMethodLabel(o.PaymentMethod)
+	}
+	if o.PaymentAdjustment != 0 {
+		label += " (" + o.PaymentAdjustmentLabel() + ")"
+	}
+	return label
+}
+
+func signPrefix(v float64) string {
+	if v < 0 {
+		return "-"
+	}
+	return "+"
+}
+
 func (s *Server) sendOrderNotify(o *domain.Order, success bool) {
+	notifyOrder(s.emailService, o, success)
+}
+
+// OrderPaidNotifier devuelve el aviso de orden paga (al admin y al comprador) para usarlo fuera
+// del servidor, ej. cuando la conciliación encuentra un pago cuyo webhook no llegó.
+func OrderPaidNotifier(emailSvc domain.EmailService) func(o *domain.Order) {
+	return func(o *domain.Order) { notifyOrder(emailSvc, o, true) }
+}
+
+func notifyOrder(emailSvc domain.EmailService, o *domain.Order, success bool) {
 	// Notificar al admin por Telegram
 	if err := sendOrderTelegram(o, success); err != nil {
 		log.Warn().Err(err).Msg("telegram notif fallo")
@@ -3159,8 +3822,8 @@ func (s *Server) sendOrderNotify(o *domain.Order, success bool) {
 	}
 
 	// Enviar email de confirmación al comprador
-	if s.emailService != nil {
-		if err := s.emailService.SendOrderConfirmation(context.Background(), o); err != nil {
+	if emailSvc != nil {
+		if err := emailSvc.SendOrderConfirmation(context.Background(), o); err != nil {
 			log.Error().Err(err).Str("order_id", o.ID.String()).Msg("error enviando email al comprador")
 		}
 	}
@@ -3221,6 +3884,10 @@ func (s *Server) handleGoogleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 	state := uuid.New().String()
 	http.SetCookie(w, &http.Cookie{Name: "oauth_state", Value: state, Path: "/", MaxAge: 300, HttpOnly: true, Secure: false})
+	// next: sólo rutas locales, para volver a la página que pidió el login (ej. /account/orders)
+	if next := r.URL.Query().Get("next"); isLocalPath(next) {
+		http.SetCookie(w, &http.Cookie{Name: "login_next", Value: url.QueryEscape(next), Path: "/", MaxAge: 300, HttpOnly: true})
+	}
 	loginURL := s.oauthCfg.AuthCodeURL(state, oauth2.AccessTypeOnline)
 	http.Redirect(w, r, loginURL, 302)
 }
@@ -3270,7 +3937,20 @@ func (s *Server) handleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	writeUserSession(w, &sessionUser{Email: info.Email, Name: info.Name})
-	http.Redirect(w, r, "/", 302)
+	s.mergeCartOnLogin(w, r, info.Email)
+	dest := "/"
+	if nc, err := r.Cookie("login_next"); err == nil {
+		if next, err := url.QueryUnescape(nc.Value); err == nil && isLocalPath(next) {
+			dest = next
+		}
+		http.SetCookie(w, &http.Cookie{Name: "login_next", Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	}
+	http.Redirect(w, r, dest, 302)
+}
+
+// isLocalPath evita redirecciones abiertas: sólo rutas del propio sitio.
+func isLocalPath(p string) bool {
+	return strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "//") && !strings.Contains(p, "\\")
 }
 
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
@@ -3378,44 +4058,6 @@ func secureCompare(a, b string) bool {
 	return v == 0
 }
 
-// handleWhatsAppWebhook maneja webhooks de WhatsApp Business
-func (s *Server) handleWhatsAppWebhook(w http.ResponseWriter, r *http.Request) {
-	// Manejar validación inicial (GET)
-	if r.Method == http.MethodGet {
-		s.handleWhatsAppVerification(w, r)
-		return
-	}
-
-	// Manejar mensajes de webhook (POST)
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", 405)
-		return
-	}
-
-	body, err := io.ReadAll(io.LimitReader(r.Body, 65536))
-	if err != nil {
-		http.Error(w, "error reading body", 400)
-		return
-	}
-
-	// Verificar firma del webhook si está configurada
-	if verifyToken := os.Getenv("WHATSAPP_VERIFY_TOKEN"); verifyToken != "" {
-		if !s.verifyWhatsAppWebhook(r, body, verifyToken) {
-			http.Error(w, "unauthorized", 401)
-			return
-		}
-	}
-
-	// Procesar el webhook
-	if err := s.whatsapp.ProcessWhatsAppWebhook(r.Context(), body); err != nil {
-		log.Error().Err(err).Msg("error processing WhatsApp webhook")
-		http.Error(w, "error processing webhook", 500)
-		return
-	}
-
-	w.WriteHeader(200)
-}
-
 // handleWhatsAppVerification maneja la validación inicial del webhook de WhatsApp
 func (s *Server) handleWhatsAppVerification(w http.ResponseWriter, r *http.Request) {
 	// Obtener parámetros de la URL
@@ -3443,19 +4085,6 @@ func (s *Server) handleWhatsAppVerification(w http.ResponseWriter, r *http.Reque
 	w.Write([]byte(challenge))
 }
 
-// verifyWhatsAppWebhook verifica la firma del webhook de WhatsApp
-func (s *Server) verifyWhatsAppWebhook(r *http.Request, body []byte, verifyToken string) bool {
-	signature := r.Header.Get("X-Hub-Signature-256")
-	if signature == "" {
-		return false
-	}
-
-	// Implementar verificación de firma HMAC
-	// Por simplicidad, aquí solo verificamos el token de verificación
-	token := r.URL.Query().Get("hub.verify_token")
-	return token == verifyToken
-}
-
 // handleWhatsAppSyncProducts sincroniza productos con WhatsApp Business
 func (s *Server) handleWhatsAppSyncProducts(w http.ResponseWriter, r *http.Request) {
 	if !s.requireAdmin(w, r) {
@@ -3639,42 +4268,27 @@ func (s *Server) apiFeaturedRemove(w http.ResponseWriter, r *http.Request) {
 }
 
 // API Carousel
-const carouselFileSubdir = "config"
-
-// getCarouselFilePath devuelve la ruta absoluta al archivo de carrusel
-func getCarouselFilePath() string {
-	base := os.Getenv("STORAGE_DIR")
-	if strings.TrimSpace(base) == "" {
-		base = "uploads"
-	}
-	// uploads/config
-	dir := base
-	if carouselFileSubdir != "" {
-		dir = dir + string(os.PathSeparator) + carouselFileSubdir
-	}
-	// Intentar crear el directorio si no existe
-	_ = os.MkdirAll(dir, 0755)
-	return dir + string(os.PathSeparator) + "carousel.json"
-}
+// carouselKey es la clave del carrusel en el storage (uploads/config/carousel.json en disco);
+// al pasar por FileStorage todas las réplicas ven la misma configuración cuando se usa S3.
+const carouselKey = "config/carousel.json"
 
 // loadCarouselItems carga los items del carrusel desde el archivo JSON
-func loadCarouselItems() []string {
-	path := getCarouselFilePath()
-	data, err := os.ReadFile(path)
+func (s *Server) loadCarouselItems(ctx context.Context) []string {
+	rc, err := s.storage.Open(ctx, carouselKey)
 	if err != nil {
-		if os.IsNotExist(err) {
-			// Si el archivo no existe, retornar lista vacía
-			return make([]string, 5)
+		if !errors.Is(err, domain.ErrNotFound) {
+			log.Error().Err(err).Str("key", carouselKey).Msg("error reading carousel.json")
 		}
-		log.Error().Err(err).Str("path", path).Msg("error reading carousel.json")
+		// Si el archivo no existe, retornar lista vacía
 		return make([]string, 5)
 	}
+	defer rc.Close()
 
 	var carouselData struct {
 		Items []string `json:"items"`
 	}
-	if err := json.Unmarshal(data, &carouselData); err != nil {
-		log.Error().Err(err).Str("path", path).Msg("error parsing carousel.json")
+	if err := json.NewDecoder(rc).Decode(&carouselData); err != nil {
+		log.Error().Err(err).Str("key", carouselKey).Msg("error parsing carousel.json")
 		return make([]string, 5)
 	}
 
@@ -3691,7 +4305,7 @@ func loadCarouselItems() []string {
 }
 
 // saveCarouselItems guarda los items del carrusel en el archivo JSON
-func saveCarouselItems(items []string) error {
+func (s *Server) saveCarouselItems(ctx context.Context, items []string) error {
 	// Asegurar que siempre tenemos máximo 5 items
 	if len(items) > 5 {
 		items = items[:5]
@@ -3712,10 +4326,7 @@ func saveCarouselItems(items []string) error {
 		return fmt.Errorf("error marshaling carousel data: %w", err)
 	}
 
-	path := getCarouselFilePath()
-	// Asegurarnos de que el directorio existe (por si falla arriba)
-	_ = os.MkdirAll(filepath.Dir(path), 0755)
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := s.storage.Put(ctx, carouselKey, data, "application/json"); err != nil {
 		return fmt.Errorf("error writing carousel.json: %w", err)
 	}
 
@@ -3762,7 +4373,7 @@ func (s *Server) apiCarouselUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Guardar los items del carrusel en el archivo JSON
-	if err := saveCarouselItems(req.Items); err != nil {
+	if err := s.saveCarouselItems(r.Context(), req.Items); err != nil {
 		log.Error().Err(err).Msg("error saving carousel items")
 		http.Error(w, fmt.Sprintf("error saving carousel items: %v", err), 500)
 		return
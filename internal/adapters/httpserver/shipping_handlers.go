@@ -0,0 +1,211 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+	"github.com/phenrril/tienda3d/internal/usecase"
+)
+
+// cartShippingRequest arma la cotización con el carrito actual del visitante.
+func (s *Server) cartShippingRequest(w http.ResponseWriter, r *http.Request, method, province, postal string) usecase.ShippingRequest {
+	lines := aggregateCart(s.loadCart(w, r), func(slug string) (*domain.Product, error) { return s.products.GetBySlug(r.Context(), slug) })
+	items := make([]usecase.ShippingItem, 0, len(lines))
+	total := 0.0
+	for _, l := range lines {
+		p, _ := s.products.GetBySlug(r.Context(), l.Slug)
+		items = append(items, usecase.ShippingItem{Product: p, Qty: l.Qty})
+		total += l.Subtotal
+	}
+	return usecase.ShippingRequest{Method: method, Province: province, PostalCode: postal, Package: usecase.BuildPackage(items), ItemsTotal: total}
+}
+
+// handleShippingQuote cotiza el envío del carrito para el checkout (se llama al cambiar
+// método, provincia o código postal).
+func (s *Server) handleShippingQuote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	method := strings.TrimSpace(q.Get("method"))
+	req := s.cartShippingRequest(w, r, method, strings.TrimSpace(q.Get("province")), strings.TrimSpace(q.Get("postal_code")))
+	quote, err := s.shipping.Quote(r.Context(), req)
+	if err != nil {
+		log.Error().Err(err).Msg("cotizar envío")
+		writeJSON(w, 500, map[string]any{"available": false, "message": "No se pudo cotizar el envío"})
+		return
+	}
+	writeJSON(w, 200, map[string]any{
+		"available": quote.Available,
+		"cost":      quote.Cost,
+		"free":      quote.Free,
+		"zone":      quote.Zone,
+		"message":   quote.Reason,
+	})
+}
+
+type shippingZoneView struct {
+	domain.ShippingZone
+	RatesText   string
+	ProvinceSet map[string]bool
+}
+
+func redirectShipping(w http.ResponseWriter, r *http.Request, key, msg string) {
+	http.Redirect(w, r, "/admin/envios?"+key+"="+url.QueryEscape(msg), http.StatusFound)
+}
+
+func (s *Server) handleAdminShipping(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	zones, err := s.shipping.List(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("listar zonas de envío")
+		http.Error(w, "err", http.StatusInternalServerError)
+		return
+	}
+	views := make([]shippingZoneView, 0, len(zones))
+	for _, z := range zones {
+		set := map[string]bool{}
+		for _, p := range z.ProvinceList() {
+			set[p] = true
+		}
+		views = append(views, shippingZoneView{ShippingZone: z, RatesText: usecase.FormatShippingRates(z.Rates), ProvinceSet: set})
+	}
+	data := map[string]any{
+		"Zones":      views,
+		"Provinces":  domain.Provinces,
+		"Flash":      strings.TrimSpace(r.URL.Query().Get("ok")),
+		"FlashError": strings.TrimSpace(r.URL.Query().Get("err")),
+		"AdminToken": s.readAdminToken(r),
+	}
+	s.render(w, "admin_shipping.html", data)
+}
+
+func (s *Server) handleAdminShippingSave(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/envios", http.StatusFound)
+		return
+	}
+	z := &domain.ShippingZone{}
+	if id := strings.TrimSpace(r.FormValue("id")); id != "" {
+		uid, err := uuid.Parse(id)
+		if err != nil {
+			redirectShipping(w, r, "err", "Zona inválida")
+			return
+		}
+		z, err = s.shipping.Get(r.Context(), uid)
+		if err != nil {
+			redirectShipping(w, r, "err", "La zona no existe")
+			return
+		}
+	}
+	z.Name = r.FormValue("name")
+	z.Method = r.FormValue("method")
+	z.Provinces = strings.Join(r.Form["provinces"], "\n")
+	z.PostalRanges = r.FormValue("postal_ranges")
+	z.Priority, _ = strconv.Atoi(strings.TrimSpace(r.FormValue("priority")))
+	z.FreeFrom, _ = strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(r.FormValue("free_from")), ",", "."), 64)
+	z.Active = r.FormValue("active") == "1"
+	if err := s.shipping.SaveZone(r.Context(), z, r.FormValue("rates")); err != nil {
+		redirectShipping(w, r, "err", err.Error())
+		return
+	}
+	redirectShipping(w, r, "ok", "Zona guardada")
+}
+
+func (s *Server) handleAdminShippingDelete(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/envios", http.StatusFound)
+		return
+	}
+	uid, err := uuid.Parse(strings.TrimSpace(r.FormValue("id")))
+	if err != nil {
+		redirectShipping(w, r, "err", "Zona inválida")
+		return
+	}
+	if err := s.shipping.DeleteZone(r.Context(), uid); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			redirectShipping(w, r, "err", "La zona no existe")
+			return
+		}
+		log.Error().Err(err).Msg("eliminar zona de envío")
+		redirectShipping(w, r, "err", "No se pudo eliminar la zona")
+		return
+	}
+	redirectShipping(w, r, "ok", "Zona eliminada")
+}
+
+// handleCarrierQuote devuelve las opciones de los correos para el carrito y el destino
+// (se muestran junto a la tarifa por zona cuando el cliente elige envío a domicilio).
+func (s *Server) handleCarrierQuote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	postal := strings.TrimSpace(q.Get("postal_code"))
+	if !s.carriers.Enabled() || postal == "" {
+		writeJSON(w, 200, map[string]any{"options": []any{}})
+		return
+	}
+	req := s.cartShippingRequest(w, r, domain.ShippingCourier, strings.TrimSpace(q.Get("province")), postal)
+	rates := s.carriers.Quote(r.Context(), postal, req.Province, req.Package, req.ItemsTotal)
+	opts := make([]map[string]any, 0, len(rates))
+	for _, rt := range rates {
+		opts = append(opts, map[string]any{
+			"option":   rt.Option(),
+			"carrier":  rt.CarrierName,
+			"service":  rt.ServiceName,
+			"cost":     rt.Price,
+			"days_min": rt.DaysMin,
+			"days_max": rt.DaysMax,
+		})
+	}
+	writeJSON(w, 200, map[string]any{"options": opts})
+}
+
+// handleAdminOrderShipment da de alta el envío de una orden en el correo y guarda seguimiento y etiqueta.
+func (s *Server) handleAdminOrderShipment(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/orders", http.StatusFound)
+		return
+	}
+	if !s.carriers.Enabled() {
+		redirectAdminOrders(w, r, "err", "No hay correos configurados")
+		return
+	}
+	id, err := uuid.Parse(r.FormValue("id"))
+	if err != nil {
+		redirectAdminOrders(w, r, "err", "id inválido")
+		return
+	}
+	o, err := s.carriers.CreateShipment(r.Context(), id, strings.TrimSpace(r.FormValue("carrier")))
+	if err != nil {
+		log.Error().Err(err).Str("order_id", id.String()).Msg("alta de envío en correo")
+		redirectAdminOrders(w, r, "err", "No se pudo generar el envío: "+err.Error())
+		return
+	}
+	redirectAdminOrders(w, r, "ok", "Orden "+o.Code()+": envío "+o.TrackingNumber+" generado")
+}
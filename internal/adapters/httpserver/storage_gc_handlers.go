@@ -0,0 +1,131 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+func (s *Server) storageGCAdmin(w http.ResponseWriter, r *http.Request, post bool) bool {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return false
+	}
+	if s.storageGC == nil {
+		http.Error(w, "limpieza de imágenes no disponible", http.StatusServiceUnavailable)
+		return false
+	}
+	if post && r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/storage-gc", http.StatusFound)
+		return false
+	}
+	return true
+}
+
+func redirectStorageGC(w http.ResponseWriter, r *http.Request, key, msg string) {
+	http.Redirect(w, r, "/admin/storage-gc?"+key+"="+url.QueryEscape(msg), http.StatusFound)
+}
+
+func (s *Server) handleAdminStorageGC(w http.ResponseWriter, r *http.Request) {
+	if !s.storageGCAdmin(w, r, false) {
+		return
+	}
+	rep, err := s.storageGC.Report(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("storage gc report")
+		http.Error(w, "err", http.StatusInternalServerError)
+		return
+	}
+	days := int(s.storageGC.QuarantineTTL / (24 * time.Hour))
+	data := map[string]any{
+		"Report":         rep,
+		"QuarantineDays": days,
+		"Flash":          strings.TrimSpace(r.URL.Query().Get("ok")),
+		"FlashError":     strings.TrimSpace(r.URL.Query().Get("err")),
+		"AdminToken":     s.readAdminToken(r),
+	}
+	s.render(w, "admin_storage_gc.html", data)
+}
+
+func (s *Server) handleAdminStorageGCScan(w http.ResponseWriter, r *http.Request) {
+	if !s.storageGCAdmin(w, r, true) {
+		return
+	}
+	res, err := s.storageGC.Scan(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("storage gc scan")
+		redirectStorageGC(w, r, "err", "No se pudo escanear: "+err.Error())
+		return
+	}
+	redirectStorageGC(w, r, "ok", fmt.Sprintf("Escaneados %d archivos: %d huérfanos, %d duplicados", res.ScannedFiles, res.Orphans, res.Duplicates))
+}
+
+func (s *Server) handleAdminStorageGCQuarantine(w http.ResponseWriter, r *http.Request) {
+	if !s.storageGCAdmin(w, r, true) {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "form", http.StatusBadRequest)
+		return
+	}
+	var ids []uuid.UUID
+	for _, raw := range r.Form["id"] {
+		if id, err := uuid.Parse(strings.TrimSpace(raw)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 && r.FormValue("all") != "1" {
+		redirectStorageGC(w, r, "err", "Seleccioná al menos un archivo")
+		return
+	}
+	n, err := s.storageGC.Quarantine(r.Context(), ids)
+	if err != nil {
+		log.Error().Err(err).Msg("storage gc quarantine")
+		redirectStorageGC(w, r, "err", fmt.Sprintf("Se movieron %d archivos antes del error: %v", n, err))
+		return
+	}
+	redirectStorageGC(w, r, "ok", fmt.Sprintf("%d archivos movidos a cuarentena", n))
+}
+
+func (s *Server) handleAdminStorageGCRestore(w http.ResponseWriter, r *http.Request) {
+	if !s.storageGCAdmin(w, r, true) {
+		return
+	}
+	id, err := uuid.Parse(strings.TrimSpace(r.FormValue("id")))
+	if err != nil {
+		http.Error(w, "id", http.StatusBadRequest)
+		return
+	}
+	if err := s.storageGC.Restore(r.Context(), id); err != nil {
+		redirectStorageGC(w, r, "err", "No se pudo restaurar: "+err.Error())
+		return
+	}
+	redirectStorageGC(w, r, "ok", "Archivo restaurado")
+}
+
+func (s *Server) handleAdminStorageGCPurge(w http.ResponseWriter, r *http.Request) {
+	if !s.storageGCAdmin(w, r, true) {
+		return
+	}
+	var (
+		n     int
+		freed int64
+		err   error
+	)
+	if r.FormValue("all") == "1" {
+		n, freed, err = s.storageGC.Purge(r.Context(), time.Now())
+	} else {
+		n, freed, err = s.storageGC.PurgeExpired(r.Context())
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("storage gc purge")
+		redirectStorageGC(w, r, "err", "No se pudo purgar: "+err.Error())
+		return
+	}
+	redirectStorageGC(w, r, "ok", fmt.Sprintf("%d archivos eliminados (%d KB liberados)", n, freed/1024))
+}
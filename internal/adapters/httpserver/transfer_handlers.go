@@ -0,0 +1,186 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+	"github.com/phenrril/tienda3d/internal/usecase"
+)
+
+const maxTransferProofBytes = 8 << 20
+
+var transferProofExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".webp": true, ".pdf": true}
+
+type adminTransferView struct {
+	Proof domain.TransferProof
+	Order *domain.Order
+	Due   float64
+}
+
+// handleTransferProofUpload recibe el comprobante que sube el cliente desde /pay/{id}.
+func (s *Server) handleTransferProofUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || s.transfers == nil {
+		http.NotFound(w, r)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxTransferProofBytes+1<<20)
+	if err := r.ParseMultipartForm(maxTransferProofBytes); err != nil {
+		http.Error(w, "el archivo supera el tamaño permitido", http.StatusRequestEntityTooLarge)
+		return
+	}
+	id, err := uuid.Parse(strings.TrimSpace(r.FormValue("id")))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	back := func(key, msg string) {
+		http.Redirect(w, r, "/pay/"+id.String()+"?"+key+"="+url.QueryEscape(msg)+"#comprobante", http.StatusFound)
+	}
+	file, fh, err := r.FormFile("proof")
+	if err != nil {
+		back("proof_err", "Elegí el archivo del comprobante")
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxTransferProofBytes+1))
+	_ = file.Close()
+	if err != nil || len(data) == 0 {
+		back("proof_err", "No se pudo leer el archivo")
+		return
+	}
+	if len(data) > maxTransferProofBytes {
+		back("proof_err", fmt.Sprintf("El archivo supera %d MB", maxTransferProofBytes>>20))
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(fh.Filename))
+	ct := http.DetectContentType(data)
+	if !transferProofExts[ext] || !(strings.HasPrefix(ct, "image/") || ct == "application/pdf") {
+		back("proof_err", "Subí una imagen (PNG, JPG, WEBP) o un PDF")
+		return
+	}
+	p, err := s.transfers.Submit(r.Context(), id, fh.Filename, ct, data)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if errors.Is(err, usecase.ErrTransferNotPending) {
+			back("proof_err", "Esta orden no está esperando una transferencia")
+			return
+		}
+		log.Error().Err(err).Str("order_id", id.String()).Msg("subir comprobante de transferencia")
+		back("proof_err", "No se pudo guardar el comprobante, probá de nuevo")
+		return
+	}
+	log.Info().Str("order_id", id.String()).Str("proof_id", p.ID.String()).Int64("size", p.Size).Msg("comprobante de transferencia recibido")
+	back("proof_ok", "Recibimos tu comprobante. Te avisamos cuando verifiquemos la transferencia.")
+}
+
+func (s *Server) transfersAdmin(w http.ResponseWriter, r *http.Request, post bool) bool {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return false
+	}
+	if s.transfers == nil {
+		http.Error(w, "verificación de transferencias no disponible", http.StatusServiceUnavailable)
+		return false
+	}
+	if post && r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/transfers", http.StatusFound)
+		return false
+	}
+	return true
+}
+
+func redirectTransfers(w http.ResponseWriter, r *http.Request, key, msg string) {
+	http.Redirect(w, r, "/admin/transfers?"+key+"="+url.QueryEscape(msg), http.StatusFound)
+}
+
+// handleAdminTransfers muestra la cola de comprobantes a verificar contra el banco.
+func (s *Server) handleAdminTransfers(w http.ResponseWriter, r *http.Request) {
+	if !s.transfersAdmin(w, r, false) {
+		return
+	}
+	proofs, err := s.transfers.Pending(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("admin transferencias")
+		http.Error(w, "err", http.StatusInternalServerError)
+		return
+	}
+	views := make([]adminTransferView, 0, len(proofs))
+	for _, p := range proofs {
+		o, err := s.orders.Orders.FindByID(r.Context(), p.OrderID)
+		if err != nil {
+			log.Warn().Err(err).Str("order_id", p.OrderID.String()).Msg("orden del comprobante")
+			continue
+		}
+		v := adminTransferView{Proof: p, Order: o, Due: o.Total}
+		if b, _, err := s.payments.Balance(r.Context(), o); err == nil {
+			v.Due = b.Due
+		}
+		views = append(views, v)
+	}
+	s.render(w, "admin_transfers.html", map[string]any{
+		"Transfers":  views,
+		"Flash":      strings.TrimSpace(r.URL.Query().Get("ok")),
+		"FlashError": strings.TrimSpace(r.URL.Query().Get("err")),
+	})
+}
+
+func (s *Server) handleAdminTransferApprove(w http.ResponseWriter, r *http.Request) {
+	if !s.transfersAdmin(w, r, true) {
+		return
+	}
+	id, err := uuid.Parse(strings.TrimSpace(r.FormValue("id")))
+	if err != nil {
+		redirectTransfers(w, r, "err", "Comprobante inválido")
+		return
+	}
+	amount, err := parseMoneyAR(r.FormValue("amount"))
+	if err != nil {
+		redirectTransfers(w, r, "err", "Monto inválido")
+		return
+	}
+	actor := s.adminEmail(r)
+	o, b, err := s.transfers.Approve(r.Context(), id, amount, actor)
+	if err != nil {
+		redirectTransfers(w, r, "err", "No se pudo aprobar: "+err.Error())
+		return
+	}
+	if !b.Settled() {
+		redirectTransfers(w, r, "ok", fmt.Sprintf("Transferencia registrada para %s. Saldo pendiente: $%.2f", o.Name, b.Due))
+		return
+	}
+	change := usecase.StatusChange{Actor: actor, Source: domain.StatusSourceAdmin, Note: "transferencia verificada (orden " + o.Code() + ")"}
+	if err := s.confirmOrderPaid(r.Context(), o, change); err != nil {
+		log.Error().Err(err).Str("order_id", o.ID.String()).Msg("confirmar orden por transferencia")
+		redirectTransfers(w, r, "err", "La transferencia quedó registrada pero no se pudo finalizar la orden: "+err.Error())
+		return
+	}
+	log.Info().Str("order_id", o.ID.String()).Str("admin", actor).Float64("total", o.Total).Msg("transferencia verificada por admin")
+	redirectTransfers(w, r, "ok", "Transferencia aprobada: la orden de "+o.Name+" quedó paga")
+}
+
+func (s *Server) handleAdminTransferReject(w http.ResponseWriter, r *http.Request) {
+	if !s.transfersAdmin(w, r, true) {
+		return
+	}
+	id, err := uuid.Parse(strings.TrimSpace(r.FormValue("id")))
+	if err != nil {
+		redirectTransfers(w, r, "err", "Comprobante inválido")
+		return
+	}
+	if _, err := s.transfers.Reject(r.Context(), id, r.FormValue("reason"), s.adminEmail(r)); err != nil {
+		redirectTransfers(w, r, "err", "No se pudo rechazar: "+err.Error())
+		return
+	}
+	redirectTransfers(w, r, "ok", "Comprobante rechazado; le avisamos al cliente el motivo")
+}
@@ -0,0 +1,72 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/phenrril/tienda3d/internal/adapters/storage/localfs"
+)
+
+func TestPublicUploadKey(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"images/x.jpg", "images/x.jpg", true},
+		{"images//x.jpg", "images/x.jpg", true},
+		{"config/carousel.json", "config/carousel.json", true},
+		{"models/pieza.stl", "", false},
+		{"/models/pieza.stl", "", false},
+		{"//attachments/comprobante.pdf", "", false},
+		{"./quarantine/x.jpg", "", false},
+		{"Models/pieza.stl", "", false},
+		{"attachments\\comprobante.pdf", "", false},
+		{"images/../models/pieza.stl", "", false},
+		{"images/", "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		got, ok := publicUploadKey(c.in)
+		if got != c.want || ok != c.ok {
+			t.Errorf("publicUploadKey(%q) = %q, %v; want %q, %v", c.in, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestHandleUploadsHidesPrivateDirs(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"images/foto.jpg", "models/pieza.stl", "attachments/comprobante.pdf", "attachments/ordenes.jsonl.gz"} {
+		full := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := &Server{storage: localfs.New(dir)}
+
+	cases := []struct {
+		target string
+		code   int
+	}{
+		{"/uploads/images/foto.jpg", http.StatusOK},
+		{"/uploads/models/pieza.stl", http.StatusNotFound},
+		{"/uploads/%2Fmodels/pieza.stl", http.StatusNotFound},
+		{"/uploads/%2fattachments/comprobante.pdf", http.StatusNotFound},
+		{"/uploads//attachments/ordenes.jsonl.gz", http.StatusNotFound},
+		{"/uploads/images%2F..%2Fmodels/pieza.stl", http.StatusNotFound},
+		{"/uploads/attachments%5Ccomprobante.pdf", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		s.handleUploads(rec, httptest.NewRequest(http.MethodGet, c.target, nil))
+		if rec.Code != c.code {
+			t.Errorf("GET %s = %d; want %d (body %q)", c.target, rec.Code, c.code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/adapters/payments/mercadopago"
+	"github.com/phenrril/tienda3d/internal/domain"
+	"github.com/phenrril/tienda3d/internal/usecase"
+)
+
+// headers que no se guardan con el evento
+var webhookSecretHeaders = []string{"Cookie", "Authorization", "X-Telegram-Bot-Api-Secret-Token"}
+
+// headers que se guardan de un evento con firma inválida, para ver por qué no validó
+var webhookRejectedHeaders = []string{"Content-Type", "User-Agent", "X-Request-Id", "X-Signature", "X-Forwarded-For"}
+
+// newWebhookEvent arma el evento a guardar. Sin eventID del proveedor se deduplica por un hash de
+// la query y el body, así que sólo se descartan los reenvíos idénticos.
+//
+// Con firma inválida el remitente no es confiable: se guardan sólo algunos headers y el tamaño del
+// body, y el id va aparte ("rejected:" + hash) para que no tape al evento legítimo con el mismo id.
+func newWebhookEvent(r *http.Request, source, eventID string, body []byte, signature string) *domain.WebhookEvent {
+	h := r.Header.Clone()
+	for _, k := range webhookSecretHeaders {
+		h.Del(k)
+	}
+	invalid := signature == domain.WebhookSignatureInvalid
+	if invalid {
+		h = http.Header{}
+		for _, k := range webhookRejectedHeaders {
+			if v := r.Header.Get(k); v != "" {
+				if len(v) > 200 {
+					v = v[:200]
+				}
+				h.Set(k, v)
+			}
+		}
+	}
+	headers, _ := json.Marshal(h)
+	switch {
+	case invalid:
+		sum := sha256.Sum256(append([]byte(eventID+"\n"+r.URL.RawQuery+"\n"), body...))
+		eventID = "rejected:" + hex.EncodeToString(sum[:16])
+	case eventID == "":
+		sum := sha256.Sum256(append([]byte(r.URL.RawQuery+"\n"), body...))
+		eventID = "sha256:" + hex.EncodeToString(sum[:16])
+	}
+	if len(eventID) > 120 {
+		eventID = eventID[:120]
+	}
+	query := r.URL.RawQuery
+	if len(query) > 500 {
+		query = query[:500]
+	}
+	e := &domain.WebhookEvent{Source: source, EventID: eventID, Headers: string(headers), Query: query, Body: string(body), Signature: signature}
+	if invalid {
+		e.Body, e.Note = "", fmt.Sprintf("firma inválida: body de %d bytes descartado", len(body))
+	}
+	return e
+}
+
+// webhookHandler devuelve el procesamiento de cada fuente; se registra en la bandeja al armar el
+// servidor.
+func (s *Server) webhookHandler(source string) usecase.WebhookHandler {
+	switch source {
+	case domain.WebhookSourceMercadoPago:
+		return s.processMPEvent
+	case domain.WebhookSourceTelegram:
+		return s.processTelegramEvent
+	case domain.WebhookSourceWhatsApp:
+		return s.processWhatsAppEvent
+	}
+	return nil
+}
+
+// acceptWebhook guarda el evento en la bandeja y responde enseguida; el job de la app lo procesa.
+// Sin bandeja se procesa en el momento. Con firma inválida queda registrado (sin el body, ver
+// newWebhookEvent) y se responde 401.
+func (s *Server) acceptWebhook(w http.ResponseWriter, r *http.Request, e *domain.WebhookEvent) {
+	invalid := e.Signature == domain.WebhookSignatureInvalid
+	if s.webhooks == nil {
+		if invalid {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := s.webhookHandler(e.Source)(r.Context(), e); err != nil && !errors.Is(err, usecase.ErrWebhookSkip) {
+			log.Error().Err(err).Str("source", e.Source).Msg("procesar webhook")
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	created, err := s.webhooks.Receive(r.Context(), e)
+	if err != nil {
+		// sin guardarlo no hay reintento: que lo reenvíe el proveedor
+		log.Error().Err(err).Str("source", e.Source).Str("event_id", e.EventID).Msg("guardar webhook")
+		http.Error(w, "error", http.StatusInternalServerError)
+		return
+	}
+	if !created {
+		log.Debug().Str("source", e.Source).Str("event_id", e.EventID).Msg("webhook duplicado")
+	}
+	if invalid {
+		log.Warn().Str("source", e.Source).Str("event_id", e.EventID).Msg("webhook con firma inválida")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type mpWebhookEvent struct {
+	ID     json.RawMessage `json:"id"`
+	Type   string          `json:"type"`
+	Action string          `json:"action"`
+	Data   struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+func (ev mpWebhookEvent) paymentID(q url.Values) string {
+	if ev.Data.ID != "" {
+		return ev.Data.ID
+	}
+	if id := q.Get("data.id"); id != "" {
+		return id
+	}
+	return q.Get("id")
+}
+
+func (s *Server) webhookMP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method", 405)
+		return
+	}
+	body, _ := io.ReadAll(io.LimitReader(r.Body, 65536))
+	var ev mpWebhookEvent
+	_ = json.Unmarshal(body, &ev)
+	eventID := ""
+	if id := strings.Trim(string(ev.ID), `"`); id != "" && id != "null" {
+		eventID = "notification:" + id
+	}
+	sig := domain.WebhookSignatureUnchecked
+	if secret := strings.TrimSpace(os.Getenv("MP_WEBHOOK_SECRET")); secret != "" {
+		sig = domain.WebhookSignatureInvalid
+		if mercadopago.VerifySignature(secret, r.Header.Get("X-Signature"), r.Header.Get("X-Request-Id"), ev.paymentID(r.URL.Query())) {
+			sig = domain.WebhookSignatureValid
+		}
+	}
+	s.acceptWebhook(w, r, newWebhookEvent(r, domain.WebhookSourceMercadoPago, eventID, body, sig))
+}
+
+// processMPEvent consulta el pago avisado y lo aplica a su orden (PaymentUC.Apply).
+func (s *Server) processMPEvent(ctx context.Context, e *domain.WebhookEvent) error {
+	var ev mpWebhookEvent
+	_ = json.Unmarshal([]byte(e.Body), &ev)
+	q, _ := url.ParseQuery(e.Query)
+	topic := ev.Type
+	if topic == "" {
+		topic = q.Get("topic")
+	}
+	if topic == "" {
+		topic = q.Get("type")
+	}
+	if topic != "" && topic != "payment" {
+		return fmt.Errorf("%w: tipo %s", usecase.ErrWebhookSkip, topic)
+	}
+	payID := ev.paymentID(q)
+	if payID == "" {
+		return fmt.Errorf("%w: webhook sin payment id", usecase.ErrWebhookSkip)
+	}
+	gp, err := s.payments.Gateway.PaymentInfo(ctx, payID)
+	if err != nil {
+		return fmt.Errorf("payment info %s: %w", payID, err)
+	}
+	orderID, ok := mercadopago.VerifyExternalRef(gp.ExternalRef)
+	if !ok {
+		return fmt.Errorf("%w: external ref inválido %q", usecase.ErrWebhookSkip, gp.ExternalRef)
+	}
+	uid, err := uuid.Parse(orderID)
+	if err != nil {
+		return fmt.Errorf("%w: orden inválida %q", usecase.ErrWebhookSkip, orderID)
+	}
+	o, err := s.orders.Orders.FindByID(ctx, uid)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return fmt.Errorf("%w: orden %s no encontrada", usecase.ErrWebhookSkip, orderID)
+		}
+		return err
+	}
+	res, err := s.payments.Apply(ctx, o, gp, usecase.StatusChange{Actor: "mercadopago", Source: domain.StatusSourceWebhook})
+	if err != nil {
+		return fmt.Errorf("guardar orden %s: %w", o.ID, err)
+	}
+	if res.Ignored != "" {
+		log.Warn().Str("order_id", o.ID.String()).Str("status", string(o.Status)).Str("mp_status", gp.Status).Msg("webhook ignorado: " + res.Ignored)
+		e.Note = fmt.Sprintf("orden %s, pago %s %s: ignorado (%s)", o.Code(), gp.ID, gp.Status, res.Ignored)
+		return nil
+	}
+	e.Note = fmt.Sprintf("orden %s, pago %s %s: %s → %s", o.Code(), gp.ID, gp.Status, res.From, res.To)
+	return nil
+}
+
+// handleWhatsAppWebhook maneja webhooks de WhatsApp Business
+func (s *Server) handleWhatsAppWebhook(w http.ResponseWriter, r *http.Request) {
+	// Manejar validación inicial (GET)
+	if r.Method == http.MethodGet {
+		s.handleWhatsAppVerification(w, r)
+		return
+	}
+
+	// Manejar mensajes de webhook (POST)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 65536))
+	if err != nil {
+		http.Error(w, "error reading body", 400)
+		return
+	}
+
+	// Verificar la firma si está configurado el secreto de la app de Meta
+	sig := domain.WebhookSignatureUnchecked
+	if secret := strings.TrimSpace(os.Getenv("WHATSAPP_APP_SECRET")); secret != "" {
+		sig = domain.WebhookSignatureInvalid
+		if verifyWhatsAppSignature(r.Header.Get("X-Hub-Signature-256"), body, secret) {
+			sig = domain.WebhookSignatureValid
+		}
+	}
+	s.acceptWebhook(w, r, newWebhookEvent(r, domain.WebhookSourceWhatsApp, whatsAppEventID(body), body, sig))
+}
+
+func (s *Server) processWhatsAppEvent(ctx context.Context, e *domain.WebhookEvent) error {
+	if s.whatsapp == nil {
+		return fmt.Errorf("%w: WhatsApp no configurado", usecase.ErrWebhookSkip)
+	}
+	return s.whatsapp.ProcessWhatsAppWebhook(ctx, []byte(e.Body))
+}
+
+// whatsAppEventID usa el id del primer mensaje o estado (wamid) del payload.
+func whatsAppEventID(body []byte) string {
+	var p struct {
+		Entry []struct {
+			Changes []struct {
+				Field string `json:"field"`
+				Value struct {
+					Messages []struct {
+						ID string `json:"id"`
+					} `json:"messages"`
+					Statuses []struct {
+						ID     string `json:"id"`
+						Status string `json:"status"`
+					} `json:"statuses"`
+				} `json:"value"`
+			} `json:"changes"`
+		} `json:"entry"`
+	}
+	if json.Unmarshal(body, &p) != nil {
+		return ""
+	}
+	for _, en := range p.Entry {
+		for _, ch := range en.Changes {
+			if len(ch.Value.Messages) > 0 && ch.Value.Messages[0].ID != "" {
+				return "message:" + ch.Value.Messages[0].ID
+			}
+			// un mismo mensaje pasa por sent, delivered y read
+			if len(ch.Value.Statuses) > 0 && ch.Value.Statuses[0].ID != "" {
+				return "status:" + ch.Value.Statuses[0].Status + ":" + ch.Value.Statuses[0].ID
+			}
+		}
+	}
+	return ""
+}
+
+// verifyWhatsAppSignature valida X-Hub-Signature-256 ("sha256=<hex>"), el HMAC del body con el
+// secreto de la app.
+func verifyWhatsAppSignature(header string, body []byte, secret string) bool {
+	got, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	want, err := hex.DecodeString(got)
+	if err != nil {
+		return false
+	}
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write(body)
+	return hmac.Equal(m.Sum(nil), want)
+}
+
+type webhookStatusCount struct {
+	Status string
+	Count  int64
+}
+
+// handleAdminWebhooks muestra la bandeja de webhooks con filtros por fuente y estado.
+func (s *Server) handleAdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !s.webhooksAdmin(w, r, false) {
+		return
+	}
+	q := r.URL.Query()
+	f := domain.WebhookEventFilter{Source: strings.TrimSpace(q.Get("source")), Status: strings.TrimSpace(q.Get("status"))}
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	const pageSize = 50
+	list, total, err := s.webhooks.Events.List(r.Context(), f, page, pageSize)
+	if err != nil {
+		log.Error().Err(err).Msg("admin webhooks")
+		http.Error(w, "err", http.StatusInternalServerError)
+		return
+	}
+	counts, err := s.webhooks.Events.CountByStatus(r.Context())
+	if err != nil {
+		log.Warn().Err(err).Msg("contar webhooks")
+	}
+	statuses := []string{domain.WebhookPending, domain.WebhookRetrying, domain.WebhookFailed, domain.WebhookProcessed, domain.WebhookSkipped, domain.WebhookRejected}
+	summary := make([]webhookStatusCount, 0, len(statuses))
+	for _, st := range statuses {
+		summary = append(summary, webhookStatusCount{Status: st, Count: counts[st]})
+	}
+	filter := url.Values{}
+	if f.Source != "" {
+		filter.Set("source", f.Source)
+	}
+	if f.Status != "" {
+		filter.Set("status", f.Status)
+	}
+	s.render(w, "admin_webhooks.html", map[string]any{
+		"Events":     list,
+		"Summary":    summary,
+		"Sources":    []string{domain.WebhookSourceMercadoPago, domain.WebhookSourceTelegram, domain.WebhookSourceWhatsApp},
+		"Statuses":   statuses,
+		"Filter":     f,
+		"FilterQS":   filter.Encode(),
+		"Page":       page,
+		"Pages":      (int(total) + pageSize - 1) / pageSize,
+		"Total":      total,
+		"Flash":      strings.TrimSpace(q.Get("ok")),
+		"FlashError": strings.TrimSpace(q.Get("err")),
+	})
+}
+
+func (s *Server) handleAdminWebhookReplay(w http.ResponseWriter, r *http.Request) {
+	if !s.webhooksAdmin(w, r, true) {
+		return
+	}
+	back := func(key, msg string) {
+		qs := r.FormValue("back")
+		if qs != "" {
+			qs += "&"
+		}
+		http.Redirect(w, r, "/admin/webhooks?"+qs+key+"="+url.QueryEscape(msg), http.StatusFound)
+	}
+	id, err := uuid.Parse(strings.TrimSpace(r.FormValue("id")))
+	if err != nil {
+		back("err", "Evento inválido")
+		return
+	}
+	e, err := s.webhooks.Replay(r.Context(), id)
+	if err != nil {
+		back("err", "No se pudo reprocesar: "+err.Error())
+		return
+	}
+	log.Info().Str("admin", s.adminEmail(r)).Str("source", e.Source).Str("event_id", e.EventID).Msg("webhook reencolado")
+	back("ok", "Evento "+e.EventID+" encolado para reprocesar")
+}
+
+func (s *Server) webhooksAdmin(w http.ResponseWriter, r *http.Request, post bool) bool {
+	if !s.isAdminSession(r) {
+		http.Redirect(w, r, "/admin/auth", http.StatusFound)
+		return false
+	}
+	if s.webhooks == nil {
+		http.Error(w, "bandeja de webhooks no disponible", http.StatusServiceUnavailable)
+		return false
+	}
+	if post && r.Method != http.MethodPost {
+		http.Redirect(w, r, "/admin/webhooks", http.StatusFound)
+		return false
+	}
+	return true
+}
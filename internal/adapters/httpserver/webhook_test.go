@@ -0,0 +1,88 @@
+package httpserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phenrril/tienda3d/internal/adapters/payments/mercadopago"
+	"github.com/phenrril/tienda3d/internal/domain"
+	"github.com/phenrril/tienda3d/internal/usecase"
+)
+
+// memWebhookRepo guarda los eventos recibidos; el resto de WebhookEventRepo no se usa.
+type memWebhookRepo struct {
+	domain.WebhookEventRepo
+
+	mu     sync.Mutex
+	events []domain.WebhookEvent
+}
+
+func (r *memWebhookRepo) Create(ctx context.Context, e *domain.WebhookEvent) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.events {
+		if r.events[i].Source == e.Source && r.events[i].EventID == e.EventID {
+			r.events[i].Duplicates++
+			return false, nil
+		}
+	}
+	r.events = append(r.events, *e)
+	return true, nil
+}
+
+func postMPWebhook(s *Server, body string, sign bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/mp?data.id=123&type=payment", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Cookie", "admin=secreto")
+	req.Header.Set("X-Extra", strings.Repeat("x", 4096))
+	req.Header.Set("X-Request-Id", "req-1")
+	if sign {
+		req.Header.Set("X-Signature", mercadopago.SignWebhook("whsec", "123", "req-1", time.Now().Unix()))
+	} else {
+		req.Header.Set("X-Signature", "ts=1,v1=00")
+	}
+	rec := httptest.NewRecorder()
+	s.webhookMP(rec, req)
+	return rec
+}
+
+func TestWebhookInvalidSignatureStoresOnlyMetadata(t *testing.T) {
+	t.Setenv("MP_WEBHOOK_SECRET", "whsec")
+	repo := &memWebhookRepo{}
+	s := &Server{webhooks: usecase.NewWebhookUC(repo, 0)}
+	body := `{"id":777,"type":"payment","action":"payment.updated","data":{"id":"123"},"pad":"` + strings.Repeat("a", 60000) + `"}`
+
+	if rec := postMPWebhook(s, body, false); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("firma inválida: status %d", rec.Code)
+	}
+	if len(repo.events) != 1 {
+		t.Fatalf("eventos = %d", len(repo.events))
+	}
+	e := repo.events[0]
+	if e.Body != "" || e.Status != domain.WebhookRejected || !strings.HasPrefix(e.EventID, "rejected:") {
+		t.Fatalf("evento rechazado: status %s, id %q, body de %d bytes", e.Status, e.EventID, len(e.Body))
+	}
+	if strings.Contains(e.Headers, "secreto") || strings.Contains(e.Headers, "X-Extra") || !strings.Contains(e.Headers, "req-1") {
+		t.Fatalf("headers guardados = %s", e.Headers)
+	}
+	if want := fmt.Sprintf("firma inválida: body de %d bytes descartado", len(body)); e.Note != want {
+		t.Fatalf("nota = %q", e.Note)
+	}
+
+	// el aviso legítimo con el mismo id de notificación no queda tapado por el rechazado
+	if rec := postMPWebhook(s, body, true); rec.Code != http.StatusOK {
+		t.Fatalf("firma válida: status %d", rec.Code)
+	}
+	if len(repo.events) != 2 {
+		t.Fatalf("eventos = %d", len(repo.events))
+	}
+	if e := repo.events[1]; e.EventID != "notification:777" || e.Status != domain.WebhookPending || e.Body != body {
+		t.Fatalf("evento válido: status %s, id %q", e.Status, e.EventID)
+	}
+}
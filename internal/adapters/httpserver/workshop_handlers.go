@@ -21,6 +21,7 @@ import (
 
 	"github.com/phenrril/tienda3d/internal/adapters/telegram"
 	"github.com/phenrril/tienda3d/internal/domain"
+	"github.com/phenrril/tienda3d/internal/usecase"
 )
 
 var workshopClientSlugRe = regexp.MustCompile(`^[a-z0-9_]+$`)
@@ -274,11 +275,14 @@ func (s *Server) handleAdminPedidos(w http.ResponseWriter, r *http.Request) {
 	}
 	stock, _ := wa.Filament.StockByColor(ctx)
 	data := map[string]any{
-		"Orders":     list,
-		"Statuses":   workshopStatuses(),
-		"Stock":      stock,
-		"AdminToken": s.readAdminToken(r),
-		"FlashError": strings.TrimSpace(r.URL.Query().Get("err")),
+		"Orders":        list,
+		"Statuses":      workshopStatuses(),
+		"Stock":         stock,
+		"AdminToken":    s.readAdminToken(r),
+		"Flash":         strings.TrimSpace(r.URL.Query().Get("ok")),
+		"FlashError":    strings.TrimSpace(r.URL.Query().Get("err")),
+		"TicketError":   r.URL.Query().Get("ticket") != "",
+		"TicketPrinter": s.printer != nil,
 	}
 	s.render(w, "admin_pedidos.html", data)
 }
@@ -550,10 +554,51 @@ func (s *Server) handleAdminPedidosStatus(w http.ResponseWriter, r *http.Request
 	http.Redirect(w, r, "/admin/pedidos", http.StatusFound)
 }
 
+// handleAdminPedidosTicket arma el ticket térmico de un pedido del taller (señas y saldo): con GET
+// se descarga y con POST se manda a la impresora del mostrador.
+func (s *Server) handleAdminPedidosTicket(w http.ResponseWriter, r *http.Request) {
+	wa, ok := s.workshopAdmin(w, r)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(strings.TrimSpace(r.FormValue("id")))
+	if err != nil {
+		http.Error(w, "id", http.StatusBadRequest)
+		return
+	}
+	o, err := wa.Orders.FindByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "pedido no encontrado", http.StatusNotFound)
+		return
+	}
+	b, err := s.tickets.WorkshopTicket(o)
+	if err != nil {
+		log.Error().Err(err).Msg("workshop ticket")
+		http.Error(w, "err", http.StatusInternalServerError)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeTicket(w, "ticket-taller-"+id.String()[:8], b)
+		return
+	}
+	msg := "No hay impresora de tickets configurada (TICKET_PRINTER_ADDR)"
+	if s.printer != nil {
+		if err := s.printer.Print(r.Context(), b); err != nil {
+			log.Error().Err(err).Msg("workshop ticket print")
+			msg = "No se pudo imprimir el ticket: " + err.Error()
+		} else {
+			http.Redirect(w, r, "/admin/pedidos?ok="+url.QueryEscape("Ticket de "+o.ClientSlug+" enviado a la impresora"), http.StatusFound)
+			return
+		}
+	}
+	http.Redirect(w, r, "/admin/pedidos?ticket=1&err="+url.QueryEscape(msg), http.StatusFound)
+}
+
 // --- Telegram webhook ---
 
 type tgWebhookMsg struct {
-	Message *struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
 		Chat struct {
 			ID int64 `json:"id"`
 		} `json:"chat"`
@@ -604,75 +649,73 @@ func (s *Server) handleTelegramWebhook(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method", http.StatusMethodNotAllowed)
 		return
 	}
-	secret := strings.TrimSpace(os.Getenv("TELEGRAM_WEBHOOK_SECRET"))
-	if secret != "" {
-		got := strings.TrimSpace(r.Header.Get("X-Telegram-Bot-Api-Secret-Token"))
-		if subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
-	}
 	body, err := io.ReadAll(io.LimitReader(r.Body, 65536))
 	if err != nil {
 		http.Error(w, "body", http.StatusBadRequest)
 		return
 	}
+	sig := domain.WebhookSignatureUnchecked
+	if secret := strings.TrimSpace(os.Getenv("TELEGRAM_WEBHOOK_SECRET")); secret != "" {
+		sig = domain.WebhookSignatureValid
+		got := strings.TrimSpace(r.Header.Get("X-Telegram-Bot-Api-Secret-Token"))
+		if subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+			sig = domain.WebhookSignatureInvalid
+		}
+	}
 	var up tgWebhookMsg
-	if err := json.Unmarshal(body, &up); err != nil {
-		w.WriteHeader(http.StatusOK)
-		return
+	eventID := ""
+	if json.Unmarshal(body, &up) == nil && up.UpdateID != 0 {
+		eventID = "update:" + strconv.FormatInt(up.UpdateID, 10)
+	}
+	s.acceptWebhook(w, r, newWebhookEvent(r, domain.WebhookSourceTelegram, eventID, body, sig))
+}
+
+// processTelegramEvent ejecuta el comando /estado del chat de admins.
+func (s *Server) processTelegramEvent(ctx context.Context, e *domain.WebhookEvent) error {
+	var up tgWebhookMsg
+	if err := json.Unmarshal([]byte(e.Body), &up); err != nil {
+		return fmt.Errorf("%w: json inválido", usecase.ErrWebhookSkip)
 	}
 	if up.Message == nil || up.Message.Text == "" {
-		w.WriteHeader(http.StatusOK)
-		return
+		return nil
 	}
-	text := strings.TrimSpace(up.Message.Text)
-	parts := strings.Fields(text)
+	parts := strings.Fields(strings.TrimSpace(up.Message.Text))
 	if len(parts) == 0 {
-		w.WriteHeader(http.StatusOK)
-		return
+		return nil
 	}
 	cmd := parts[0]
 	if i := strings.Index(cmd, "@"); i > 0 {
 		cmd = cmd[:i]
 	}
 	if cmd != "/estado" {
-		w.WriteHeader(http.StatusOK)
-		return
+		return nil
+	}
+	chat := strconv.FormatInt(up.Message.Chat.ID, 10)
+	reply := func(msg string) error {
+		_ = telegram.SendToChat(chat, msg)
+		e.Note = msg
+		return nil
 	}
 	if s.workshop == nil {
-		_ = telegram.SendToChat(strconv.FormatInt(up.Message.Chat.ID, 10), "módulo pedidos no disponible")
-		w.WriteHeader(http.StatusOK)
-		return
+		return reply("módulo pedidos no disponible")
 	}
 	if !isTelegramAdminChat(up.Message.Chat.ID) {
-		_ = telegram.SendToChat(strconv.FormatInt(up.Message.Chat.ID, 10), "no autorizado")
-		w.WriteHeader(http.StatusOK)
-		return
+		return reply("no autorizado")
 	}
 	if len(parts) < 3 {
-		_ = telegram.SendToChat(strconv.FormatInt(up.Message.Chat.ID, 10), "uso: /estado <estado> <cliente_snake_case>\nej: /estado en_impresion fede_bertoqui")
-		w.WriteHeader(http.StatusOK)
-		return
+		return reply("uso: /estado <estado> <cliente_snake_case>\nej: /estado en_impresion fede_bertoqui")
 	}
 	st, ok := parseWorkshopStatusToken(parts[1])
 	if !ok {
-		_ = telegram.SendToChat(strconv.FormatInt(up.Message.Chat.ID, 10), "estado no reconocido. Usá: pendiente, disenado, en_impresion, listo_entrega, entregado")
-		w.WriteHeader(http.StatusOK)
-		return
+		return reply("estado no reconocido. Usá: pendiente, disenado, en_impresion, listo_entrega, entregado")
 	}
 	slug, err := normalizeWorkshopClientSlug(parts[2])
 	if err != nil {
-		_ = telegram.SendToChat(strconv.FormatInt(up.Message.Chat.ID, 10), err.Error())
-		w.WriteHeader(http.StatusOK)
-		return
+		return reply(err.Error())
 	}
-	ctx := context.Background()
 	list, err := s.workshop.Orders.FindUndeliveredByClientSlug(ctx, slug)
 	if err != nil || len(list) == 0 {
-		_ = telegram.SendToChat(strconv.FormatInt(up.Message.Chat.ID, 10), "no hay pedidos activos para ese cliente")
-		w.WriteHeader(http.StatusOK)
-		return
+		return reply("no hay pedidos activos para ese cliente")
 	}
 	if len(list) > 1 {
 		var b strings.Builder
@@ -680,15 +723,10 @@ func (s *Server) handleTelegramWebhook(w http.ResponseWriter, r *http.Request) {
 		for _, o := range list {
 			fmt.Fprintf(&b, "- %s estado=%s entrega=%s\n", o.ID.String()[:8], o.Status, o.DeliveryDate.Format("2006-01-02"))
 		}
-		_ = telegram.SendToChat(strconv.FormatInt(up.Message.Chat.ID, 10), b.String())
-		w.WriteHeader(http.StatusOK)
-		return
+		return reply(b.String())
 	}
 	if err := s.workshop.Orders.UpdateStatus(ctx, list[0].ID, st); err != nil {
-		_ = telegram.SendToChat(strconv.FormatInt(up.Message.Chat.ID, 10), "error actualizando")
-		w.WriteHeader(http.StatusOK)
-		return
+		return reply("error actualizando")
 	}
-	_ = telegram.SendToChat(strconv.FormatInt(up.Message.Chat.ID, 10), fmt.Sprintf("ok: %s -> %s", slug, st))
-	w.WriteHeader(http.StatusOK)
+	return reply(fmt.Sprintf("ok: %s -> %s", slug, st))
 }
@@ -0,0 +1,73 @@
+package mesh3d
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// DefaultMaxTriangles es el límite usado si no se configura otro; alcanza para ver bien
+// una pieza y deja el GLB en el orden de 1-2 MB.
+const DefaultMaxTriangles = 100000
+
+// Converter implementa domain.ModelConverter.
+type Converter struct{}
+
+func NewConverter() *Converter { return &Converter{} }
+
+// Supported indica si la extensión del archivo se puede convertir.
+func Supported(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".stl", ".3mf":
+		return true
+	}
+	return false
+}
+
+func (c *Converter) ToGLB(filename string, data []byte, maxTriangles int) ([]byte, *domain.MeshStats, error) {
+	var (
+		m   *mesh
+		err error
+	)
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".stl":
+		m, err = parseSTL(data)
+	case ".3mf":
+		m, err = parse3MF(data)
+	default:
+		return nil, nil, errors.New("formato no soportado (usar STL o 3MF)")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if maxTriangles <= 0 {
+		maxTriangles = DefaultMaxTriangles
+	}
+	stats := &domain.MeshStats{SourceTriangles: m.triangleCount()}
+	min, max := m.bounds()
+	stats.SizeXMM = float64(max[0] - min[0])
+	stats.SizeYMM = float64(max[1] - min[1])
+	stats.SizeZMM = float64(max[2] - min[2])
+
+	m = decimate(m, maxTriangles)
+
+	// mm -> m, Z arriba -> Y arriba, centrado en X/Z y apoyado en el piso (y=0)
+	cx, cy := (min[0]+max[0])/2, (min[1]+max[1])/2
+	for i := 0; i < len(m.verts); i += 3 {
+		x, y, z := m.verts[i], m.verts[i+1], m.verts[i+2]
+		m.verts[i] = (x - cx) * 0.001
+		m.verts[i+1] = (z - min[2]) * 0.001
+		m.verts[i+2] = -(y - cy) * 0.001
+	}
+	stats.Triangles = m.triangleCount()
+	stats.Vertices = m.vertexCount()
+
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	glb, err := writeGLB(m, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return glb, stats, nil
+}
@@ -0,0 +1,110 @@
+package mesh3d
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+)
+
+const (
+	glbMagic     = 0x46546C67 // "glTF"
+	glbChunkJSON = 0x4E4F534A
+	glbChunkBIN  = 0x004E4942
+
+	glArrayBuffer        = 34962
+	glElementArrayBuffer = 34963
+	glFloat              = 5126
+	glUnsignedShort      = 5123
+	glUnsignedInt        = 5125
+)
+
+// writeGLB arma un glTF 2.0 binario con una sola primitiva: POSITION + índices. Sin normales:
+// el visor calcula normales planas, que es lo que mejor muestra las caras de una pieza impresa.
+// verts ya debe estar en metros y con Y hacia arriba.
+func writeGLB(m *mesh, name string) ([]byte, error) {
+	var bin bytes.Buffer
+	for _, v := range m.verts {
+		_ = binary.Write(&bin, binary.LittleEndian, math.Float32bits(v))
+	}
+	posLen := bin.Len()
+
+	idxType := glUnsignedInt
+	if m.vertexCount() <= math.MaxUint16 {
+		idxType = glUnsignedShort
+		for _, i := range m.tris {
+			_ = binary.Write(&bin, binary.LittleEndian, uint16(i))
+		}
+	} else {
+		for _, i := range m.tris {
+			_ = binary.Write(&bin, binary.LittleEndian, i)
+		}
+	}
+	idxLen := bin.Len() - posLen
+	for bin.Len()%4 != 0 {
+		bin.WriteByte(0)
+	}
+
+	min, max := m.bounds()
+	doc := map[string]any{
+		"asset":  map[string]any{"version": "2.0", "generator": "tienda3d"},
+		"scene":  0,
+		"scenes": []any{map[string]any{"nodes": []int{0}}},
+		"nodes":  []any{map[string]any{"mesh": 0, "name": name}},
+		"meshes": []any{map[string]any{
+			"name": name,
+			"primitives": []any{map[string]any{
+				"attributes": map[string]int{"POSITION": 0},
+				"indices":    1,
+				"material":   0,
+				"mode":       4,
+			}},
+		}},
+		"materials": []any{map[string]any{
+			"name": "pla",
+			"pbrMetallicRoughness": map[string]any{
+				"baseColorFactor": []float32{0.85, 0.85, 0.88, 1},
+				"metallicFactor":  0,
+				"roughnessFactor": 0.6,
+			},
+			"doubleSided": true,
+		}},
+		"accessors": []any{
+			map[string]any{
+				"bufferView":    0,
+				"componentType": glFloat,
+				"count":         m.vertexCount(),
+				"type":          "VEC3",
+				"min":           min[:],
+				"max":           max[:],
+			},
+			map[string]any{
+				"bufferView":    1,
+				"componentType": idxType,
+				"count":         len(m.tris),
+				"type":          "SCALAR",
+			},
+		},
+		"bufferViews": []any{
+			map[string]any{"buffer": 0, "byteOffset": 0, "byteLength": posLen, "target": glArrayBuffer},
+			map[string]any{"buffer": 0, "byteOffset": posLen, "byteLength": idxLen, "target": glElementArrayBuffer},
+		},
+		"buffers": []any{map[string]any{"byteLength": bin.Len()}},
+	}
+	js, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	for len(js)%4 != 0 {
+		js = append(js, ' ')
+	}
+
+	var out bytes.Buffer
+	total := 12 + 8 + len(js) + 8 + bin.Len()
+	_ = binary.Write(&out, binary.LittleEndian, []uint32{glbMagic, 2, uint32(total)})
+	_ = binary.Write(&out, binary.LittleEndian, []uint32{uint32(len(js)), glbChunkJSON})
+	out.Write(js)
+	_ = binary.Write(&out, binary.LittleEndian, []uint32{uint32(bin.Len()), glbChunkBIN})
+	out.Write(bin.Bytes())
+	return out.Bytes(), nil
+}
@@ -0,0 +1,159 @@
+// Package mesh3d convierte modelos STL/3MF a glTF binario (GLB) para el visor 3D de productos.
+package mesh3d
+
+import (
+	"errors"
+	"math"
+)
+
+// mesh es una malla triangular indexada; verts guarda x,y,z consecutivos en milímetros.
+type mesh struct {
+	verts []float32
+	tris  []uint32
+}
+
+func (m *mesh) vertexCount() int   { return len(m.verts) / 3 }
+func (m *mesh) triangleCount() int { return len(m.tris) / 3 }
+
+// welder une vértices con coordenadas idénticas (STL repite cada vértice por triángulo).
+type welder struct {
+	m   *mesh
+	idx map[[3]float32]uint32
+}
+
+func newWelder(m *mesh) *welder { return &welder{m: m, idx: map[[3]float32]uint32{}} }
+
+func (w *welder) add(x, y, z float32) uint32 {
+	k := [3]float32{x, y, z}
+	if i, ok := w.idx[k]; ok {
+		return i
+	}
+	i := uint32(w.m.vertexCount())
+	w.m.verts = append(w.m.verts, x, y, z)
+	w.idx[k] = i
+	return i
+}
+
+func (w *welder) tri(a, b, c uint32) {
+	if a == b || b == c || a == c {
+		return
+	}
+	w.m.tris = append(w.m.tris, a, b, c)
+}
+
+func (m *mesh) bounds() (min, max [3]float32) {
+	for i := 0; i < 3; i++ {
+		min[i] = float32(math.Inf(1))
+		max[i] = float32(math.Inf(-1))
+	}
+	for i := 0; i < len(m.verts); i += 3 {
+		for k := 0; k < 3; k++ {
+			v := m.verts[i+k]
+			if v < min[k] {
+				min[k] = v
+			}
+			if v > max[k] {
+				max[k] = v
+			}
+		}
+	}
+	return min, max
+}
+
+// decimate reduce la malla por agrupamiento de vértices en una grilla (vertex clustering):
+// cada celda colapsa a un vértice promedio y se descartan triángulos degenerados. Se achica
+// la grilla hasta quedar por debajo de maxTris. Es simple y estable para piezas impresas.
+func decimate(m *mesh, maxTris int) *mesh {
+	if maxTris <= 0 || m.triangleCount() <= maxTris {
+		return m
+	}
+	min, max := m.bounds()
+	ext := float32(0)
+	for k := 0; k < 3; k++ {
+		if d := max[k] - min[k]; d > ext {
+			ext = d
+		}
+	}
+	if ext <= 0 {
+		return m
+	}
+	// estimación inicial: superficie ~ celdas^2, triángulos ~ 2 por celda de superficie
+	res := int(math.Sqrt(float64(maxTris))*1.5) + 2
+	best := m
+	for ; res >= 4; res = res * 3 / 4 {
+		out := cluster(m, min, ext/float32(res))
+		best = out
+		if out.triangleCount() <= maxTris {
+			break
+		}
+	}
+	return best
+}
+
+func cluster(m *mesh, min [3]float32, cell float32) *mesh {
+	type acc struct {
+		x, y, z float64
+		n       int
+		idx     uint32
+	}
+	cells := map[[3]int32]*acc{}
+	order := make([]*acc, 0)
+	remap := make([]uint32, m.vertexCount())
+	for i := 0; i < m.vertexCount(); i++ {
+		x, y, z := m.verts[i*3], m.verts[i*3+1], m.verts[i*3+2]
+		k := [3]int32{int32((x - min[0]) / cell), int32((y - min[1]) / cell), int32((z - min[2]) / cell)}
+		a, ok := cells[k]
+		if !ok {
+			a = &acc{idx: uint32(len(order))}
+			cells[k] = a
+			order = append(order, a)
+		}
+		a.x += float64(x)
+		a.y += float64(y)
+		a.z += float64(z)
+		a.n++
+		remap[i] = a.idx
+	}
+	out := &mesh{verts: make([]float32, 0, len(order)*3)}
+	for _, a := range order {
+		n := float64(a.n)
+		out.verts = append(out.verts, float32(a.x/n), float32(a.y/n), float32(a.z/n))
+	}
+	seen := map[[3]uint32]struct{}{}
+	for i := 0; i < len(m.tris); i += 3 {
+		a, b, c := remap[m.tris[i]], remap[m.tris[i+1]], remap[m.tris[i+2]]
+		if a == b || b == c || a == c {
+			continue
+		}
+		// clave canónica (rotación mínima) para eliminar triángulos repetidos
+		k := [3]uint32{a, b, c}
+		for k[0] > k[1] || k[0] > k[2] {
+			k = [3]uint32{k[1], k[2], k[0]}
+		}
+		if _, dup := seen[k]; dup {
+			continue
+		}
+		seen[k] = struct{}{}
+		out.tris = append(out.tris, a, b, c)
+	}
+	return compact(out)
+}
+
+// compact elimina vértices que ya no usa ningún triángulo.
+func compact(m *mesh) *mesh {
+	used := make([]int64, m.vertexCount())
+	for i := range used {
+		used[i] = -1
+	}
+	out := &mesh{tris: make([]uint32, len(m.tris))}
+	for i, v := range m.tris {
+		if used[v] < 0 {
+			used[v] = int64(out.vertexCount())
+			out.verts = append(out.verts, m.verts[v*3], m.verts[v*3+1], m.verts[v*3+2])
+		}
+		out.tris[i] = uint32(used[v])
+	}
+	return out
+}
+
+var errEmptyMesh = errors.New("el modelo no tiene triángulos")
@@ -0,0 +1,294 @@
+package mesh3d
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// cubeTris son los 12 triángulos de un cubo de lado 1 con un vértice en el origen.
+func cubeTris() [][3][3]float32 {
+	v := [8][3]float32{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}, {0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1}}
+	faces := [][3]int{
+		{0, 2, 1}, {0, 3, 2}, {4, 5, 6}, {4, 6, 7},
+		{0, 1, 5}, {0, 5, 4}, {1, 2, 6}, {1, 6, 5},
+		{2, 3, 7}, {2, 7, 6}, {3, 0, 4}, {3, 4, 7},
+	}
+	out := make([][3][3]float32, len(faces))
+	for i, f := range faces {
+		out[i] = [3][3]float32{v[f[0]], v[f[1]], v[f[2]]}
+	}
+	return out
+}
+
+func binarySTL(tris [][3][3]float32) []byte {
+	b := make([]byte, 84+50*len(tris))
+	binary.LittleEndian.PutUint32(b[80:], uint32(len(tris)))
+	for i, t := range tris {
+		off := 84 + i*50 + 12
+		for v := 0; v < 3; v++ {
+			for k := 0; k < 3; k++ {
+				binary.LittleEndian.PutUint32(b[off+v*12+k*4:], math.Float32bits(t[v][k]))
+			}
+		}
+	}
+	return b
+}
+
+func asciiSTL(tris [][3][3]float32) []byte {
+	var sb strings.Builder
+	sb.WriteString("solid cubo\n")
+	for _, t := range tris {
+		sb.WriteString("  facet normal 0 0 0\n    outer loop\n")
+		for _, v := range t {
+			fmt.Fprintf(&sb, "      vertex %g %g %g\n", v[0], v[1], v[2])
+		}
+		sb.WriteString("    endloop\n  endfacet\n")
+	}
+	sb.WriteString("endsolid cubo\n")
+	return []byte(sb.String())
+}
+
+func TestParseSTL(t *testing.T) {
+	cube := cubeTris()
+	quad := "solid q\nfacet normal 0 0 1\nouter loop\nvertex 0 0 0\nvertex 2 0 0\nvertex 2 2 0\nvertex 0 2 0\nendloop\nendfacet\nendsolid q\n"
+	degenerate := "solid d\nfacet normal 0 0 1\nouter loop\nvertex 0 0 0\nvertex 0 0 0\nvertex 1 1 1\nendloop\nendfacet\nendsolid d\n"
+	cases := []struct {
+		name        string
+		data        []byte
+		tris, verts int
+		wantErr     string
+		maxX, maxZ  float32
+	}{
+		{name: "binario", data: binarySTL(cube), tris: 12, verts: 8, maxX: 1, maxZ: 1},
+		{name: "ascii", data: asciiSTL(cube), tris: 12, verts: 8, maxX: 1, maxZ: 1},
+		{name: "cuadrilátero en abanico", data: []byte(quad), tris: 2, verts: 4, maxX: 2, maxZ: 0},
+		{name: "binario con la cantidad mal", data: binarySTL(cube)[:84+50*11], wantErr: "STL inválido"},
+		{name: "sólo triángulos degenerados", data: []byte(degenerate), wantErr: errEmptyMesh.Error()},
+		{name: "coordenada inválida", data: []byte("solid x\nouter loop\nvertex 0 a 0\n"), wantErr: "coordenada inválida"},
+		{name: "vacío", data: nil, wantErr: "STL inválido"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m, err := parseSTL(c.data)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("err = %v; want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.triangleCount() != c.tris || m.vertexCount() != c.verts {
+				t.Fatalf("%d triángulos, %d vértices; want %d, %d", m.triangleCount(), m.vertexCount(), c.tris, c.verts)
+			}
+			_, max := m.bounds()
+			if max[0] != c.maxX || max[2] != c.maxZ {
+				t.Fatalf("max = %v", max)
+			}
+		})
+	}
+}
+
+func zip3MF(t *testing.T, name, model string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(model)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+const tmfTriangle = `<object id="1"><mesh><vertices>
+<vertex x="0" y="0" z="0"/><vertex x="1" y="0" z="0"/><vertex x="0" y="1" z="0"/>
+</vertices><triangles><triangle v1="0" v2="1" v3="2"/></triangles></mesh></object>`
+
+func TestParse3MF(t *testing.T) {
+	model := func(unit, resources, build string) string {
+		return `<?xml version="1.0" encoding="UTF-8"?><model unit="` + unit + `" xmlns="http://schemas.microsoft.com/3dmanufacturing/core/2015/02"><resources>` +
+			resources + `</resources><build>` + build + `</build></model>`
+	}
+	cases := []struct {
+		name    string
+		file    string
+		model   string
+		tris    int
+		min     [3]float32
+		max     [3]float32
+		wantErr string
+	}{
+		{
+			name: "centímetros a milímetros", file: "3D/3dmodel.model",
+			model: model("centimeter", tmfTriangle, `<item objectid="1"/>`),
+			tris:  1, min: [3]float32{0, 0, 0}, max: [3]float32{10, 10, 0},
+		},
+		{
+			name: "item trasladado", file: "3D/3dmodel.model",
+			model: model("millimeter", tmfTriangle, `<item objectid="1" transform="1 0 0 0 1 0 0 0 1 5 0 2"/>`),
+			tris:  1, min: [3]float32{5, 0, 2}, max: [3]float32{6, 1, 2},
+		},
+		{
+			name: "componentes anidados, dos copias", file: "3D/3dmodel.model",
+			model: model("millimeter", tmfTriangle+
+				`<object id="2"><components><component objectid="1"/><component objectid="1" transform="2 0 0 0 2 0 0 0 2 10 0 0"/></components></object>`,
+				`<item objectid="2" transform="1 0 0 0 1 0 0 0 1 0 0 3"/>`),
+			tris: 2, min: [3]float32{0, 0, 3}, max: [3]float32{12, 2, 3},
+		},
+		{
+			name: "sin build usa todos los objetos", file: "3D/pieza.model",
+			model: model("", tmfTriangle, ""),
+			tris:  1, min: [3]float32{0, 0, 0}, max: [3]float32{1, 1, 0},
+		},
+		{
+			name: "índice fuera de rango", file: "3D/3dmodel.model",
+			model:   model("", `<object id="1"><mesh><vertices><vertex x="0" y="0" z="0"/></vertices><triangles><triangle v1="0" v2="1" v3="2"/></triangles></mesh></object>`, `<item objectid="1"/>`),
+			wantErr: "fuera de rango",
+		},
+		{
+			name: "componente que se incluye a sí mismo", file: "3D/3dmodel.model",
+			model:   model("", `<object id="1"><components><component objectid="1"/></components></object>`, `<item objectid="1"/>`),
+			wantErr: "anidados en exceso",
+		},
+		{
+			name: "objeto inexistente", file: "3D/3dmodel.model",
+			model:   model("", tmfTriangle, `<item objectid="9"/>`),
+			wantErr: "objeto inexistente",
+		},
+		{
+			name: "paquete sin modelo", file: "Metadata/thumbnail.png",
+			model:   "png",
+			wantErr: "sin modelo",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m, err := parse3MF(zip3MF(t, c.file, c.model))
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("err = %v; want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			min, max := m.bounds()
+			if m.triangleCount() != c.tris || min != c.min || max != c.max {
+				t.Fatalf("%d triángulos, bounds %v - %v", m.triangleCount(), min, max)
+			}
+		})
+	}
+	if _, err := parse3MF([]byte("no es un zip")); err == nil {
+		t.Fatal("se aceptó un 3MF que no es zip")
+	}
+}
+
+// sphere arma una esfera UV de radio r con n paralelos y 2n meridianos.
+func sphere(r float32, n int) *mesh {
+	m := &mesh{}
+	w := newWelder(m)
+	pt := func(i, j int) uint32 {
+		th := math.Pi * float64(i) / float64(n)
+		ph := math.Pi * float64(j%(2*n)) / float64(n)
+		if i == 0 || i == n {
+			ph = 0
+		}
+		return w.add(r*float32(math.Sin(th)*math.Cos(ph)), r*float32(math.Sin(th)*math.Sin(ph)), r*float32(math.Cos(th)))
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < 2*n; j++ {
+			a, b, c, d := pt(i, j), pt(i+1, j), pt(i+1, j+1), pt(i, j+1)
+			w.tri(a, b, c)
+			w.tri(a, c, d)
+		}
+	}
+	return m
+}
+
+func TestDecimate(t *testing.T) {
+	src := sphere(20, 120)
+	srcMin, srcMax := src.bounds()
+	cases := []struct {
+		maxTris int
+		same    bool
+	}{
+		{0, true},
+		{src.triangleCount(), true},
+		{20000, false},
+		{2000, false},
+		{200, false},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprint(c.maxTris), func(t *testing.T) {
+			out := decimate(src, c.maxTris)
+			if c.same {
+				if out != src {
+					t.Fatal("se redujo una malla que ya estaba dentro del límite")
+				}
+				return
+			}
+			if n := out.triangleCount(); n == 0 || n > c.maxTris || n < c.maxTris/10 {
+				t.Fatalf("%d triángulos con límite %d", n, c.maxTris)
+			}
+			// todos los vértices se usan, no hay triángulos degenerados ni repetidos
+			used := make([]bool, out.vertexCount())
+			seen := map[[3]uint32]bool{}
+			for i := 0; i < len(out.tris); i += 3 {
+				a, b, cc := out.tris[i], out.tris[i+1], out.tris[i+2]
+				if a == b || b == cc || a == cc {
+					t.Fatalf("triángulo degenerado %d %d %d", a, b, cc)
+				}
+				k := [3]uint32{a, b, cc}
+				for k[0] > k[1] || k[0] > k[2] {
+					k = [3]uint32{k[1], k[2], k[0]}
+				}
+				if seen[k] {
+					t.Fatalf("triángulo repetido %v", k)
+				}
+				seen[k] = true
+				used[a], used[b], used[cc] = true, true, true
+			}
+			for i, u := range used {
+				if !u {
+					t.Fatalf("vértice %d sin usar", i)
+				}
+			}
+			// los promedios de cada celda quedan dentro de la pieza original
+			min, max := out.bounds()
+			for k := 0; k < 3; k++ {
+				if min[k] < srcMin[k] || max[k] > srcMax[k] || max[k]-min[k] < (srcMax[k]-srcMin[k])*0.8 {
+					t.Fatalf("bounds %v - %v; original %v - %v", min, max, srcMin, srcMax)
+				}
+			}
+		})
+	}
+}
+
+func TestToGLB(t *testing.T) {
+	glb, stats, err := NewConverter().ToGLB("Cubo.STL", binarySTL(cubeTris()), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(glb[:4]) != "glTF" || binary.LittleEndian.Uint32(glb[8:12]) != uint32(len(glb)) {
+		t.Fatalf("cabecera GLB % x", glb[:12])
+	}
+	if stats.SourceTriangles != 12 || stats.Triangles != 12 || stats.Vertices != 8 || stats.SizeXMM != 1 || stats.SizeZMM != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if _, _, err := NewConverter().ToGLB("pieza.obj", []byte("v 0 0 0"), 0); err == nil {
+		t.Fatal("se aceptó un OBJ")
+	}
+}
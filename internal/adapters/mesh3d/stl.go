@@ -0,0 +1,90 @@
+package mesh3d
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parseSTL lee STL binario o ASCII y devuelve la malla con vértices unidos.
+func parseSTL(data []byte) (*mesh, error) {
+	if len(data) >= 84 {
+		n := binary.LittleEndian.Uint32(data[80:84])
+		if uint64(84)+uint64(n)*50 == uint64(len(data)) {
+			return parseBinarySTL(data, int(n))
+		}
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("solid")) {
+		return parseASCIISTL(data)
+	}
+	return nil, errors.New("STL inválido")
+}
+
+func parseBinarySTL(data []byte, n int) (*mesh, error) {
+	m := &mesh{}
+	w := newWelder(m)
+	off := 84
+	for i := 0; i < n; i++ {
+		var idx [3]uint32
+		for v := 0; v < 3; v++ {
+			p := off + 12 + v*12
+			x := math.Float32frombits(binary.LittleEndian.Uint32(data[p:]))
+			y := math.Float32frombits(binary.LittleEndian.Uint32(data[p+4:]))
+			z := math.Float32frombits(binary.LittleEndian.Uint32(data[p+8:]))
+			idx[v] = w.add(x, y, z)
+		}
+		w.tri(idx[0], idx[1], idx[2])
+		off += 50
+	}
+	if m.triangleCount() == 0 {
+		return nil, errEmptyMesh
+	}
+	return m, nil
+}
+
+func parseASCIISTL(data []byte) (*mesh, error) {
+	m := &mesh{}
+	w := newWelder(m)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var face []uint32
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 {
+			continue
+		}
+		switch strings.ToLower(f[0]) {
+		case "outer":
+			face = face[:0]
+		case "vertex":
+			if len(f) < 4 {
+				return nil, errors.New("STL ASCII: vértice incompleto")
+			}
+			var c [3]float32
+			for k := 0; k < 3; k++ {
+				v, err := strconv.ParseFloat(f[k+1], 32)
+				if err != nil {
+					return nil, errors.New("STL ASCII: coordenada inválida")
+				}
+				c[k] = float32(v)
+			}
+			face = append(face, w.add(c[0], c[1], c[2]))
+		case "endloop":
+			// polígonos de más de 3 vértices se triangulan en abanico
+			for i := 1; i+1 < len(face); i++ {
+				w.tri(face[0], face[i], face[i+1])
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if m.triangleCount() == 0 {
+		return nil, errEmptyMesh
+	}
+	return m, nil
+}
@@ -0,0 +1,197 @@
+package mesh3d
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const max3MFModelBytes = 256 << 20
+
+type tmfModel struct {
+	Unit      string      `xml:"unit,attr"`
+	Objects   []tmfObject `xml:"resources>object"`
+	BuildItem []tmfItem   `xml:"build>item"`
+}
+
+type tmfObject struct {
+	ID         string      `xml:"id,attr"`
+	Vertices   []tmfVertex `xml:"mesh>vertices>vertex"`
+	Triangles  []tmfTri    `xml:"mesh>triangles>triangle"`
+	Components []tmfItem   `xml:"components>component"`
+}
+
+type tmfVertex struct {
+	X float32 `xml:"x,attr"`
+	Y float32 `xml:"y,attr"`
+	Z float32 `xml:"z,attr"`
+}
+
+type tmfTri struct {
+	V1 uint32 `xml:"v1,attr"`
+	V2 uint32 `xml:"v2,attr"`
+	V3 uint32 `xml:"v3,attr"`
+}
+
+type tmfItem struct {
+	ObjectID  string `xml:"objectid,attr"`
+	Transform string `xml:"transform,attr"`
+}
+
+// matrix es la transformación afín 3x4 de 3MF: p' = p * M (fila m30..m32 = traslación).
+type matrix [12]float32
+
+var identity = matrix{1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0}
+
+func parseMatrix(s string) matrix {
+	f := strings.Fields(s)
+	if len(f) != 12 {
+		return identity
+	}
+	var m matrix
+	for i, v := range f {
+		x, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return identity
+		}
+		m[i] = float32(x)
+	}
+	return m
+}
+
+func (m matrix) apply(x, y, z float32) (float32, float32, float32) {
+	return x*m[0] + y*m[3] + z*m[6] + m[9],
+		x*m[1] + y*m[4] + z*m[7] + m[10],
+		x*m[2] + y*m[5] + z*m[8] + m[11]
+}
+
+// mul devuelve a seguido de b (primero a, después b).
+func (a matrix) mul(b matrix) matrix {
+	var r matrix
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 3; col++ {
+			var v float32
+			for k := 0; k < 3; k++ {
+				v += a[row*3+k] * b[k*3+col]
+			}
+			if row == 3 {
+				v += b[9+col]
+			}
+			r[row*3+col] = v
+		}
+	}
+	return r
+}
+
+// unitScale lleva la unidad del modelo a milímetros.
+func unitScale(u string) float32 {
+	switch strings.ToLower(u) {
+	case "micron":
+		return 0.001
+	case "centimeter":
+		return 10
+	case "inch":
+		return 25.4
+	case "foot":
+		return 304.8
+	case "meter":
+		return 1000
+	default:
+		return 1
+	}
+}
+
+// parse3MF lee el modelo principal del paquete 3MF y aplana los objetos de la build
+// (incluyendo componentes anidados) en una única malla en milímetros.
+func parse3MF(data []byte) (*mesh, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New("3MF inválido")
+	}
+	var mf *zip.File
+	for _, f := range zr.File {
+		name := strings.ToLower(path.Clean(strings.TrimPrefix(f.Name, "/")))
+		if name == "3d/3dmodel.model" {
+			mf = f
+			break
+		}
+		if mf == nil && strings.HasSuffix(name, ".model") {
+			mf = f
+		}
+	}
+	if mf == nil {
+		return nil, errors.New("3MF sin modelo 3D")
+	}
+	rc, err := mf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	raw, err := io.ReadAll(io.LimitReader(rc, max3MFModelBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > max3MFModelBytes {
+		return nil, errors.New("3MF demasiado grande")
+	}
+	var doc tmfModel
+	if err := xml.Unmarshal(raw, &doc); err != nil {
+		return nil, errors.New("3MF: XML inválido")
+	}
+	objs := make(map[string]*tmfObject, len(doc.Objects))
+	for i := range doc.Objects {
+		objs[doc.Objects[i].ID] = &doc.Objects[i]
+	}
+	s := unitScale(doc.Unit)
+	unit := matrix{s, 0, 0, 0, s, 0, 0, 0, s, 0, 0, 0}
+
+	m := &mesh{}
+	w := newWelder(m)
+	var emit func(id string, tr matrix, depth int) error
+	emit = func(id string, tr matrix, depth int) error {
+		o, ok := objs[id]
+		if !ok {
+			return errors.New("3MF: objeto inexistente " + id)
+		}
+		if depth > 16 {
+			return errors.New("3MF: componentes anidados en exceso")
+		}
+		idx := make([]uint32, len(o.Vertices))
+		for i, v := range o.Vertices {
+			x, y, z := tr.apply(v.X, v.Y, v.Z)
+			idx[i] = w.add(x, y, z)
+		}
+		for _, t := range o.Triangles {
+			if int(t.V1) >= len(idx) || int(t.V2) >= len(idx) || int(t.V3) >= len(idx) {
+				return errors.New("3MF: índice de vértice fuera de rango")
+			}
+			w.tri(idx[t.V1], idx[t.V2], idx[t.V3])
+		}
+		for _, c := range o.Components {
+			if err := emit(c.ObjectID, parseMatrix(c.Transform).mul(tr), depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	items := doc.BuildItem
+	if len(items) == 0 {
+		for _, o := range doc.Objects {
+			items = append(items, tmfItem{ObjectID: o.ID})
+		}
+	}
+	for _, it := range items {
+		if err := emit(it.ObjectID, parseMatrix(it.Transform).mul(unit), 0); err != nil {
+			return nil, err
+		}
+	}
+	if m.triangleCount() == 0 {
+		return nil, errEmptyMesh
+	}
+	return m, nil
+}
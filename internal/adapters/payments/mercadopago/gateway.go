@@ -10,8 +10,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,13 +21,32 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// DefaultBaseURL es la API de MercadoPago.
+const DefaultBaseURL = "https://api.mercadopago.com"
+
 type Gateway struct {
 	token      string
 	httpClient *http.Client
+	// BaseURL permite apuntar a un doble local de la API (pruebas, conciliación); vacío = DefaultBaseURL.
+	BaseURL string
 }
 
+// NewGateway arma el gateway contra la API de MercadoPago, o contra MP_BASE_URL si está definida
+// (ej. el emulador de cmd/mp-emulator).
 func NewGateway(token string) *Gateway {
-	return &Gateway{token: token, httpClient: &http.Client{Timeout: 10 * time.Second}}
+	base := strings.TrimSpace(os.Getenv("MP_BASE_URL"))
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return &Gateway{token: token, httpClient: &http.Client{Timeout: 10 * time.Second}, BaseURL: base}
+}
+
+func (g *Gateway) api(path string) string {
+	base := strings.TrimRight(g.BaseURL, "/")
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return base + path
 }
 
 type mpItem struct {
@@ -55,9 +76,29 @@ type mpPrefResp struct {
 }
 
 type mpPaymentResp struct {
-	ID                int64  `json:"id"`
-	Status            string `json:"status"`
-	ExternalReference string `json:"external_reference"`
+	ID                int64   `json:"id"`
+	Status            string  `json:"status"`
+	StatusDetail      string  `json:"status_detail"`
+	ExternalReference string  `json:"external_reference"`
+	TransactionAmount float64 `json:"transaction_amount"`
+	DateApproved      string  `json:"date_approved"`
+}
+
+func (p mpPaymentResp) toDomain(raw []byte) domain.GatewayPayment {
+	gp := domain.GatewayPayment{
+		Status:       p.Status,
+		StatusDetail: p.StatusDetail,
+		ExternalRef:  p.ExternalReference,
+		Amount:       p.TransactionAmount,
+		Raw:          raw,
+	}
+	if p.ID != 0 {
+		gp.ID = strconv.FormatInt(p.ID, 10)
+	}
+	if t, err := time.Parse(time.RFC3339Nano, p.DateApproved); err == nil {
+		gp.ApprovedAt = &t
+	}
+	return gp
 }
 
 func signExternal(orderID string) string {
@@ -70,14 +111,12 @@ func signExternal(orderID string) string {
 	return hex.EncodeToString(h.Sum(nil))[:24]
 }
 
-func (g *Gateway) CreatePreference(ctx context.Context, o *domain.Order) (string, error) {
-	if g.token == "" {
-		return "", errors.New("MP token faltante (MP_ACCESS_TOKEN)")
-	}
-	if o == nil {
-		return "", errors.New("orden nil")
-	}
-	items := make([]mpItem, 0, len(o.Items)+1)
+// preferenceItems arma los ítems de la preferencia: productos, envío y el recargo del medio de
+// pago, con el número de orden en el título. MercadoPago no acepta precios negativos, así que
+// los descuentos (cupón, pago con descuento) se reparten entre los ítems para que sumen
+// exactamente o.Total.
+func preferenceItems(o *domain.Order) ([]mpItem, float64) {
+	items := make([]mpItem, 0, len(o.Items)+2)
 	subtotal := 0.0
 	for _, it := range o.Items {
 		items = append(items, mpItem{Title: it.Title, Quantity: it.Qty, UnitPrice: it.UnitPrice, CurrencyID: "ARS"})
@@ -90,10 +129,60 @@ func (g *Gateway) CreatePreference(ctx context.Context, o *domain.Order) (string
 		}
 		items = append(items, mpItem{Title: label, Quantity: 1, UnitPrice: o.ShippingCost, CurrencyID: "ARS"})
 	}
-	calcTotal := subtotal + o.ShippingCost
-	if o.Total == 0 || (o.Total-calcTotal) > 0.01 || (calcTotal-o.Total) > 0.01 {
-		o.Total = calcTotal
+	gross := subtotal + o.ShippingCost
+	if o.PaymentAdjustment > 0 {
+		items = append(items, mpItem{Title: domain.PaymentMethodLabel(o.PaymentMethod) + ": " + o.PaymentAdjustmentLabel(), Quantity: 1, UnitPrice: o.PaymentAdjustment, CurrencyID: "ARS"})
+		gross += o.PaymentAdjustment
+	}
+	// Total inválido o mayor a lo que suman los ítems: se cobra lo que suman
+	if o.Total <= 0 || o.Total > gross+0.01 {
+		o.Total = math.Round(gross*100) / 100
+	} else if o.Total < gross-0.01 {
+		items = discountItems(items, gross, o.Total)
+	}
+	for i := range items {
+		items[i].Title = o.Code() + " · " + items[i].Title
 	}
+	return items, subtotal
+}
+
+// discountItems escala los precios para que los ítems sumen total. La diferencia de redondeo
+// va a un ítem de cantidad 1; si no hay, se separa una unidad del primero.
+func discountItems(items []mpItem, gross, total float64) []mpItem {
+	factor := total / gross
+	sum := 0.0
+	for i := range items {
+		items[i].UnitPrice = math.Round(items[i].UnitPrice*factor*100) / 100
+		sum += items[i].UnitPrice * float64(items[i].Quantity)
+	}
+	diff := math.Round((total-sum)*100) / 100
+	if diff == 0 {
+		return items
+	}
+	for i := range items {
+		if items[i].Quantity == 1 && items[i].UnitPrice+diff > 0 {
+			items[i].UnitPrice = math.Round((items[i].UnitPrice+diff)*100) / 100
+			return items
+		}
+	}
+	if len(items) == 0 || items[0].Quantity < 2 || items[0].UnitPrice+diff <= 0 {
+		return items
+	}
+	first := items[0]
+	items[0].Quantity--
+	first.Quantity = 1
+	first.UnitPrice = math.Round((first.UnitPrice+diff)*100) / 100
+	return append(items, first)
+}
+
+func (g *Gateway) CreatePreference(ctx context.Context, o *domain.Order) (string, error) {
+	if g.token == "" {
+		return "", errors.New("MP token faltante (MP_ACCESS_TOKEN)")
+	}
+	if o == nil {
+		return "", errors.New("orden nil")
+	}
+	items, subtotal := preferenceItems(o)
 	log.Debug().Str("order", o.ID.String()).Float64("subtotal", subtotal).Float64("shipping", o.ShippingCost).Float64("total", o.Total).Int("items", len(items)).Msg("MP preference build (shipping as item)")
 	baseURL := os.Getenv("PUBLIC_BASE_URL")
 	if baseURL == "" {
@@ -122,7 +211,7 @@ func (g *Gateway) CreatePreference(ctx context.Context, o *domain.Order) (string
 	if os.Getenv("MP_DEBUG") == "1" {
 		log.Debug().RawJSON("mp_pref_payload", buf).Msg("MP preference payload")
 	}
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.mercadopago.com/checkout/preferences", bytes.NewReader(buf))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, g.api("/checkout/preferences"), bytes.NewReader(buf))
 	if err != nil {
 		return "", err
 	}
@@ -156,30 +245,37 @@ func (g *Gateway) CreatePreference(ctx context.Context, o *domain.Order) (string
 	return initPoint, nil
 }
 
-func (g *Gateway) PaymentInfo(ctx context.Context, paymentID string) (string, string, error) {
+func (g *Gateway) PaymentInfo(ctx context.Context, paymentID string) (*domain.GatewayPayment, error) {
 	if g.token == "" || paymentID == "" {
-		return "", "", errors.New("params")
+		return nil, errors.New("params")
 	}
-	url := "https://api.mercadopago.com/v1/payments/" + paymentID
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.api("/v1/payments/"+paymentID), nil)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+g.token)
 	res, err := g.httpClient.Do(req)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 	defer res.Body.Close()
 	if res.StatusCode >= 300 {
 		b, _ := io.ReadAll(res.Body)
-		return "", "", fmt.Errorf("mp payment status %d: %s", res.StatusCode, string(b))
+		return nil, fmt.Errorf("mp payment status %d: %s", res.StatusCode, string(b))
+	}
+	raw, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
 	}
 	var pr mpPaymentResp
-	if err := json.NewDecoder(res.Body).Decode(&pr); err != nil {
-		return "", "", err
+	if err := json.Unmarshal(raw, &pr); err != nil {
+		return nil, err
+	}
+	gp := pr.toDomain(raw)
+	if gp.ID == "" {
+		gp.ID = paymentID
 	}
-	return pr.Status, pr.ExternalReference, nil
+	return &gp, nil
 }
 
 func (g *Gateway) VerifyWebhook(signature string, body []byte) (interface{}, error) {
@@ -0,0 +1,178 @@
+package mercadopago_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/adapters/payments/mercadopago"
+	"github.com/phenrril/tienda3d/internal/adapters/payments/mercadopago/mpemu"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// webhookSink recibe los webhooks del emulador como si fuera la app.
+type webhookSink struct {
+	mu   sync.Mutex
+	reqs []*http.Request
+}
+
+func (s *webhookSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.Copy(io.Discard, r.Body)
+	s.mu.Lock()
+	s.reqs = append(s.reqs, r)
+	s.mu.Unlock()
+}
+
+func (s *webhookSink) list() []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*http.Request(nil), s.reqs...)
+}
+
+// newEmulator levanta el emulador y la app falsa y apunta MP_BASE_URL / PUBLIC_BASE_URL a ellos.
+func newEmulator(t *testing.T) (*mpemu.Emulator, string, *webhookSink) {
+	t.Helper()
+	sink := &webhookSink{}
+	app := httptest.NewServer(sink)
+	t.Cleanup(app.Close)
+	emu := mpemu.New("whsec")
+	mp := httptest.NewServer(emu)
+	t.Cleanup(mp.Close)
+	t.Setenv("MP_BASE_URL", mp.URL)
+	t.Setenv("PUBLIC_BASE_URL", app.URL)
+	t.Setenv("APP_ENV", "development")
+	return emu, mp.URL, sink
+}
+
+func testOrder() *domain.Order {
+	return &domain.Order{
+		ID: uuid.New(), Number: 15, Status: domain.OrderStatusAwaitingPay, Email: "c@example.com",
+		Items:        []domain.OrderItem{{Title: "Maceta", Qty: 2, UnitPrice: 5000}},
+		ShippingCost: 2000, Total: 12000,
+	}
+}
+
+func TestGatewayPreferenceAndPaymentAgainstEmulator(t *testing.T) {
+	ctx := context.Background()
+	emu, mpURL, sink := newEmulator(t)
+	g := mercadopago.NewGateway("TEST-local")
+	if g.BaseURL != mpURL {
+		t.Fatalf("BaseURL = %q; want MP_BASE_URL %q", g.BaseURL, mpURL)
+	}
+	o := testOrder()
+
+	initPoint, err := g.CreatePreference(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.MPPreferenceID == "" || !strings.HasPrefix(initPoint, mpURL+"/checkout/v1/redirect?pref_id="+o.MPPreferenceID) {
+		t.Fatalf("preferencia %q, init_point %q", o.MPPreferenceID, initPoint)
+	}
+
+	// todavía no hay pagos para la orden
+	if _, err := g.FindPayment(ctx, o); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("FindPayment sin pagos: err = %v", err)
+	}
+
+	if _, err := emu.Pay(o.MPPreferenceID, mpemu.StatusRejected, 0); err != nil {
+		t.Fatal(err)
+	}
+	p, err := emu.Pay(o.MPPreferenceID, mpemu.StatusApproved, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.FormatInt(p.ID, 10)
+
+	gp, err := g.PaymentInfo(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gp.ID != id || gp.Status != "approved" || gp.Amount != 12000 || gp.ApprovedAt == nil || len(gp.Raw) == 0 {
+		t.Fatalf("PaymentInfo = %+v", gp)
+	}
+	if ref, _, _ := strings.Cut(gp.ExternalRef, "|"); ref != o.ID.String() {
+		t.Fatalf("external_reference = %q", gp.ExternalRef)
+	}
+
+	list, err := g.SearchPayments(ctx, o)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("SearchPayments = %+v, %v", list, err)
+	}
+	if found, err := g.FindPayment(ctx, o); err != nil || found != id {
+		t.Fatalf("FindPayment = %q, %v; want %q", found, err, id)
+	}
+	if _, err := g.PaymentInfo(ctx, "999"); err == nil {
+		t.Fatal("PaymentInfo de un pago inexistente no falló")
+	}
+
+	// el emulador avisa a la notification_url de la preferencia con una firma que valida
+	reqs := sink.list()
+	if len(reqs) != 2 {
+		t.Fatalf("webhooks = %d", len(reqs))
+	}
+	last := reqs[1]
+	if last.URL.Path != "/webhooks/mp" || last.URL.Query().Get("data.id") != id {
+		t.Fatalf("webhook a %s", last.URL)
+	}
+	if !mercadopago.VerifySignature("whsec", last.Header.Get("X-Signature"), last.Header.Get("X-Request-Id"), id) {
+		t.Fatal("la firma del webhook no valida")
+	}
+}
+
+func TestGatewayRefundsAgainstEmulator(t *testing.T) {
+	ctx := context.Background()
+	emu, _, _ := newEmulator(t)
+	g := mercadopago.NewGateway("TEST-local")
+	o := testOrder()
+	if _, err := g.CreatePreference(ctx, o); err != nil {
+		t.Fatal(err)
+	}
+	p, err := emu.Pay(o.MPPreferenceID, mpemu.StatusApproved, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.FormatInt(p.ID, 10)
+
+	r1, err := g.Refund(ctx, id, 2000, "k1")
+	if err != nil || r1.Amount != 2000 {
+		t.Fatalf("Refund = %+v, %v", r1, err)
+	}
+	if again, err := g.Refund(ctx, id, 2000, "k1"); err != nil || again.ID != r1.ID {
+		t.Fatalf("Refund con la misma clave = %+v, %v", again, err)
+	}
+	if _, err := g.Refund(ctx, id, 50000, "k2"); err == nil {
+		t.Fatal("reembolsar más de lo cobrado no falló")
+	}
+	if _, err := g.Refund(ctx, id, 0, "k3"); err != nil {
+		t.Fatal(err)
+	}
+	list, err := g.Refunds(ctx, id)
+	if err != nil || len(list) != 2 || list[1].Amount != 10000 {
+		t.Fatalf("Refunds = %+v, %v", list, err)
+	}
+	if gp, err := g.PaymentInfo(ctx, id); err != nil || gp.Status != "refunded" {
+		t.Fatalf("pago después del reembolso total = %+v, %v", gp, err)
+	}
+}
+
+func TestGatewayWithoutTokenAgainstEmulator(t *testing.T) {
+	newEmulator(t)
+	g := mercadopago.NewGateway("")
+	if _, err := g.CreatePreference(context.Background(), testOrder()); err == nil {
+		t.Fatal("sin token no debería crear la preferencia")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := g.PaymentInfo(ctx, "1"); err == nil {
+		t.Fatal("sin token no debería consultar pagos")
+	}
+}
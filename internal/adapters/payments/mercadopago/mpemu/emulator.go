@@ -0,0 +1,638 @@
+// Package mpemu es un emulador local de la API de MercadoPago para desarrollo y pruebas. Implementa
+// los endpoints que usa mercadopago.Gateway (preferencias, consulta y búsqueda de pagos, reembolsos),
+// una página de checkout para elegir el resultado del pago y manda los webhooks firmados a la
+// notification_url de la preferencia, como hace MercadoPago.
+//
+// Se levanta con cmd/mp-emulator y se apunta la app con MP_BASE_URL; en pruebas se puede montar
+// con httptest.NewServer(mpemu.New(secret)).
+package mpemu
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/adapters/payments/mercadopago"
+)
+
+// Resultados que se pueden simular.
+const (
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+	StatusPending  = "pending"
+	StatusRefunded = "refunded"
+)
+
+var statusDetails = map[string]string{
+	StatusApproved: "accredited",
+	StatusRejected: "cc_rejected_insufficient_amount",
+	StatusPending:  "pending_waiting_payment",
+	StatusRefunded: "refunded",
+}
+
+// ErrNotFound: la preferencia o el pago no existen en el emulador.
+var ErrNotFound = errors.New("no encontrado")
+
+type Item struct {
+	Title      string  `json:"title"`
+	Quantity   int     `json:"quantity"`
+	UnitPrice  float64 `json:"unit_price"`
+	CurrencyID string  `json:"currency_id"`
+}
+
+type Preference struct {
+	ID                string            `json:"id"`
+	Items             []Item            `json:"items"`
+	Payer             map[string]string `json:"payer,omitempty"`
+	BackURLs          map[string]string `json:"back_urls,omitempty"`
+	NotificationURL   string            `json:"notification_url,omitempty"`
+	ExternalReference string            `json:"external_reference"`
+	InitPoint         string            `json:"init_point"`
+	SandboxInitPoint  string            `json:"sandbox_init_point"`
+	DateCreated       string            `json:"date_created"`
+}
+
+// Total suma los items de la preferencia.
+func (p *Preference) Total() float64 {
+	t := 0.0
+	for _, it := range p.Items {
+		t += it.UnitPrice * float64(it.Quantity)
+	}
+	return math.Round(t*100) / 100
+}
+
+type Payment struct {
+	ID                int64    `json:"id"`
+	Status            string   `json:"status"`
+	StatusDetail      string   `json:"status_detail"`
+	ExternalReference string   `json:"external_reference"`
+	PreferenceID      string   `json:"preference_id,omitempty"`
+	TransactionAmount float64  `json:"transaction_amount"`
+	CurrencyID        string   `json:"currency_id"`
+	DateCreated       string   `json:"date_created"`
+	DateApproved      string   `json:"date_approved,omitempty"`
+	LiveMode          bool     `json:"live_mode"`
+	Refunds           []Refund `json:"refunds"`
+}
+
+type Refund struct {
+	ID          int64   `json:"id"`
+	PaymentID   int64   `json:"payment_id"`
+	Amount      float64 `json:"amount"`
+	Status      string  `json:"status"`
+	DateCreated string  `json:"date_created"`
+}
+
+// Delivery es un webhook que mandó el emulador.
+type Delivery struct {
+	URL       string    `json:"url"`
+	PaymentID int64     `json:"payment_id"`
+	Action    string    `json:"action"`
+	Status    int       `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// Emulator guarda todo en memoria; se pierde al reiniciar.
+type Emulator struct {
+	// Secret firma los webhooks (el mismo MP_WEBHOOK_SECRET de la app). Vacío = sin x-signature.
+	Secret string
+	// PublicURL es la dirección del emulador que ve el navegador, para armar el init_point.
+	// Vacía = la del request.
+	PublicURL string
+	// NotifyURL reemplaza la notification_url de las preferencias (ej. la app dentro de Docker).
+	NotifyURL string
+	Client    *http.Client
+
+	mu          sync.Mutex
+	seq         int64
+	prefs       map[string]*Preference
+	payments    map[int64]*Payment
+	idempotency map[string]*Refund
+	deliveries  []Delivery
+	mux         *http.ServeMux
+}
+
+func New(secret string) *Emulator {
+	e := &Emulator{
+		Secret:      secret,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		seq:         1000000,
+		prefs:       map[string]*Preference{},
+		payments:    map[int64]*Payment{},
+		idempotency: map[string]*Refund{},
+		mux:         http.NewServeMux(),
+	}
+	e.mux.HandleFunc("/checkout/preferences", e.auth(e.handleCreatePreference))
+	e.mux.HandleFunc("/v1/payments/search", e.auth(e.handleSearch))
+	e.mux.HandleFunc("/v1/payments/", e.auth(e.handlePayment))
+	e.mux.HandleFunc("/checkout/v1/redirect", e.handleCheckoutPage)
+	e.mux.HandleFunc("/checkout/v1/pay", e.handleCheckoutPay)
+	e.mux.HandleFunc("/emulator/payments", e.handleEmulatorPay)
+	e.mux.HandleFunc("/emulator/payments/", e.handleEmulatorStatus)
+	e.mux.HandleFunc("/emulator/webhooks", e.handleEmulatorDeliveries)
+	return e
+}
+
+func (e *Emulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mux.ServeHTTP(w, r)
+}
+
+func (e *Emulator) nextID() int64 {
+	e.seq++
+	return e.seq
+}
+
+func now() string { return time.Now().Format("2006-01-02T15:04:05.000-07:00") }
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]any{"message": msg, "error": http.StatusText(status), "status": status})
+}
+
+// auth exige un Bearer como la API real (no valida el token).
+func (e *Emulator) auth(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (e *Emulator) publicURL(r *http.Request) string {
+	if e.PublicURL != "" {
+		return strings.TrimRight(e.PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func (e *Emulator) handleCreatePreference(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method")
+		return
+	}
+	var p Preference
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, "json inválido")
+		return
+	}
+	if len(p.Items) == 0 {
+		writeError(w, http.StatusBadRequest, "items vacío")
+		return
+	}
+	p.ID = "emu-" + uuid.NewString()
+	p.InitPoint = e.publicURL(r) + "/checkout/v1/redirect?pref_id=" + p.ID
+	p.SandboxInitPoint = p.InitPoint
+	p.DateCreated = now()
+	e.mu.Lock()
+	e.prefs[p.ID] = &p
+	e.mu.Unlock()
+	log.Info().Str("pref_id", p.ID).Str("external_reference", p.ExternalReference).Float64("total", p.Total()).Msg("mpemu: preferencia creada")
+	writeJSON(w, http.StatusCreated, p)
+}
+
+// handlePayment atiende /v1/payments/{id} y /v1/payments/{id}/refunds.
+func (e *Emulator) handlePayment(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/payments/"), "/")
+	idStr, sub, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "payment not found")
+		return
+	}
+	switch {
+	case sub == "" && r.Method == http.MethodGet:
+		e.mu.Lock()
+		p, ok := e.payments[id]
+		var out Payment
+		if ok {
+			out = *p
+		}
+		e.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "payment not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, out)
+	case sub == "refunds" && r.Method == http.MethodGet:
+		e.mu.Lock()
+		p, ok := e.payments[id]
+		var out []Refund
+		if ok {
+			out = append([]Refund{}, p.Refunds...)
+		}
+		e.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "payment not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, out)
+	case sub == "refunds" && r.Method == http.MethodPost:
+		var body struct {
+			Amount float64 `json:"amount"`
+		}
+		_ = json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&body)
+		ref, err := e.Refund(id, body.Amount, r.Header.Get("X-Idempotency-Key"))
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, ref)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (e *Emulator) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	ext := q.Get("external_reference")
+	e.mu.Lock()
+	results := []Payment{}
+	for _, p := range e.payments {
+		if ext == "" || p.ExternalReference == ext {
+			results = append(results, *p)
+		}
+	}
+	e.mu.Unlock()
+	desc := q.Get("criteria") != "asc"
+	sort.Slice(results, func(i, j int) bool {
+		if desc {
+			return results[i].ID > results[j].ID
+		}
+		return results[i].ID < results[j].ID
+	})
+	writeJSON(w, http.StatusOK, map[string]any{
+		"paging":  map[string]int{"total": len(results), "limit": 30, "offset": 0},
+		"results": results,
+	})
+}
+
+// Pay crea un pago de la preferencia con el resultado pedido (amount 0 = el total) y manda el
+// webhook "payment.created".
+func (e *Emulator) Pay(prefID, status string, amount float64) (*Payment, error) {
+	if _, ok := statusDetails[status]; !ok || status == StatusRefunded {
+		return nil, fmt.Errorf("estado no soportado: %q", status)
+	}
+	e.mu.Lock()
+	pref, ok := e.prefs[prefID]
+	if !ok {
+		e.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	if amount <= 0 {
+		amount = pref.Total()
+	}
+	p := &Payment{
+		ID:                e.nextID(),
+		Status:            status,
+		StatusDetail:      statusDetails[status],
+		ExternalReference: pref.ExternalReference,
+		PreferenceID:      pref.ID,
+		TransactionAmount: math.Round(amount*100) / 100,
+		CurrencyID:        "ARS",
+		DateCreated:       now(),
+		Refunds:           []Refund{},
+	}
+	if status == StatusApproved {
+		p.DateApproved = p.DateCreated
+	}
+	e.payments[p.ID] = p
+	out := *p
+	notify := e.notifyURL(pref)
+	e.mu.Unlock()
+	log.Info().Int64("payment_id", p.ID).Str("status", status).Float64("amount", p.TransactionAmount).Msg("mpemu: pago creado")
+	e.sendWebhook(notify, p.ID, "payment.created")
+	return &out, nil
+}
+
+// SetStatus cambia el estado de un pago existente (ej. pending → approved) y manda el webhook
+// "payment.updated".
+func (e *Emulator) SetStatus(paymentID int64, status string) (*Payment, error) {
+	if _, ok := statusDetails[status]; !ok {
+		return nil, fmt.Errorf("estado no soportado: %q", status)
+	}
+	e.mu.Lock()
+	p, ok := e.payments[paymentID]
+	if !ok {
+		e.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	p.Status, p.StatusDetail = status, statusDetails[status]
+	if status == StatusApproved && p.DateApproved == "" {
+		p.DateApproved = now()
+	}
+	out := *p
+	notify := e.notifyURL(e.prefs[p.PreferenceID])
+	e.mu.Unlock()
+	e.sendWebhook(notify, p.ID, "payment.updated")
+	return &out, nil
+}
+
+// Refund devuelve amount (0 = lo que queda) de un pago aprobado. Con la misma idempotencyKey
+// devuelve el reembolso ya hecho.
+func (e *Emulator) Refund(paymentID int64, amount float64, idempotencyKey string) (*Refund, error) {
+	e.mu.Lock()
+	if idempotencyKey != "" {
+		if ref, ok := e.idempotency[idempotencyKey]; ok {
+			out := *ref
+			e.mu.Unlock()
+			return &out, nil
+		}
+	}
+	p, ok := e.payments[paymentID]
+	if !ok {
+		e.mu.Unlock()
+		return nil, ErrNotFound
+	}
+	if p.Status != StatusApproved {
+		e.mu.Unlock()
+		return nil, fmt.Errorf("el pago está %s", p.Status)
+	}
+	refunded := 0.0
+	for _, r := range p.Refunds {
+		refunded += r.Amount
+	}
+	left := math.Round((p.TransactionAmount-refunded)*100) / 100
+	if amount <= 0 {
+		amount = left
+	}
+	amount = math.Round(amount*100) / 100
+	if amount > left+0.001 {
+		e.mu.Unlock()
+		return nil, fmt.Errorf("el monto supera lo disponible (%.2f)", left)
+	}
+	ref := Refund{ID: e.nextID(), PaymentID: p.ID, Amount: amount, Status: StatusApproved, DateCreated: now()}
+	p.Refunds = append(p.Refunds, ref)
+	if amount >= left-0.001 {
+		p.Status, p.StatusDetail = StatusRefunded, statusDetails[StatusRefunded]
+	}
+	if idempotencyKey != "" {
+		e.idempotency[idempotencyKey] = &ref
+	}
+	notify := e.notifyURL(e.prefs[p.PreferenceID])
+	e.mu.Unlock()
+	e.sendWebhook(notify, p.ID, "payment.updated")
+	return &ref, nil
+}
+
+// Deliveries devuelve los webhooks mandados, del más viejo al más nuevo.
+func (e *Emulator) Deliveries() []Delivery {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Delivery{}, e.deliveries...)
+}
+
+func (e *Emulator) notifyURL(pref *Preference) string {
+	if e.NotifyURL != "" {
+		return e.NotifyURL
+	}
+	if pref == nil {
+		return ""
+	}
+	return pref.NotificationURL
+}
+
+// sendWebhook avisa el pago como lo hace MercadoPago: data.id en la query y en el body, con
+// x-request-id y x-signature.
+func (e *Emulator) sendWebhook(target string, paymentID int64, action string) {
+	if target == "" {
+		return
+	}
+	dataID := strconv.FormatInt(paymentID, 10)
+	u, err := url.Parse(target)
+	if err != nil {
+		e.record(Delivery{URL: target, PaymentID: paymentID, Action: action, Error: err.Error(), At: time.Now()})
+		return
+	}
+	q := u.Query()
+	q.Set("data.id", dataID)
+	q.Set("type", "payment")
+	u.RawQuery = q.Encode()
+	e.mu.Lock()
+	notifID := e.nextID()
+	e.mu.Unlock()
+	body, _ := json.Marshal(map[string]any{
+		"id":           notifID,
+		"live_mode":    false,
+		"type":         "payment",
+		"date_created": now(),
+		"api_version":  "v1",
+		"action":       action,
+		"data":         map[string]string{"id": dataID},
+	})
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		e.record(Delivery{URL: u.String(), PaymentID: paymentID, Action: action, Error: err.Error(), At: time.Now()})
+		return
+	}
+	reqID := uuid.NewString()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-Id", reqID)
+	if e.Secret != "" {
+		req.Header.Set("X-Signature", mercadopago.SignWebhook(e.Secret, dataID, reqID, time.Now().Unix()))
+	}
+	d := Delivery{URL: u.String(), PaymentID: paymentID, Action: action, At: time.Now()}
+	res, err := e.Client.Do(req)
+	if err != nil {
+		d.Error = err.Error()
+	} else {
+		d.Status = res.StatusCode
+		_, _ = io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}
+	e.record(d)
+	log.Info().Int64("payment_id", paymentID).Str("action", action).Int("status", d.Status).Str("error", d.Error).Msg("mpemu: webhook enviado")
+}
+
+func (e *Emulator) record(d Delivery) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.deliveries = append(e.deliveries, d)
+	if len(e.deliveries) > 500 {
+		e.deliveries = e.deliveries[len(e.deliveries)-500:]
+	}
+}
+
+var checkoutTmpl = template.Must(template.New("checkout").Parse(`<!doctype html>
+<html lang="es"><head><meta charset="utf-8"><title>MercadoPago (emulador)</title>
+<style>body{font-family:sans-serif;max-width:520px;margin:40px auto;padding:0 16px}table{width:100%;border-collapse:collapse}td{padding:4px 0}button{padding:10px 14px;margin:4px 4px 0 0;cursor:pointer}</style>
+</head><body>
+<h1>Checkout emulado</h1>
+<p>Preferencia <code>{{.ID}}</code></p>
+<table>{{range .Items}}<tr><td>{{.Quantity}} × {{.Title}}</td><td style="text-align:right">${{printf "%.2f" .UnitPrice}}</td></tr>{{end}}
+<tr><td><strong>Total</strong></td><td style="text-align:right"><strong>${{printf "%.2f" .Total}}</strong></td></tr></table>
+<form method="POST" action="/checkout/v1/pay">
+<input type="hidden" name="pref_id" value="{{.ID}}">
+<button name="status" value="approved">Pagar (aprobado)</button>
+<button name="status" value="pending">Dejar pendiente</button>
+<button name="status" value="rejected">Rechazar</button>
+</form>
+</body></html>`))
+
+func (e *Emulator) handleCheckoutPage(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	pref, ok := e.prefs[r.URL.Query().Get("pref_id")]
+	e.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = checkoutTmpl.Execute(w, pref)
+}
+
+// handleCheckoutPay simula que el comprador pagó y lo devuelve a la back_url correspondiente con
+// los parámetros que agrega MercadoPago.
+func (e *Emulator) handleCheckoutPay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	prefID := r.FormValue("pref_id")
+	p, err := e.Pay(prefID, r.FormValue("status"), 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	e.mu.Lock()
+	back := e.prefs[prefID].BackURLs
+	e.mu.Unlock()
+	key := map[string]string{StatusApproved: "success", StatusPending: "pending", StatusRejected: "failure"}[p.Status]
+	target := back[key]
+	if target == "" {
+		writeJSON(w, http.StatusOK, p)
+		return
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		http.Error(w, "back_url inválida", http.StatusBadRequest)
+		return
+	}
+	id := strconv.FormatInt(p.ID, 10)
+	q := u.Query()
+	q.Set("collection_id", id)
+	q.Set("collection_status", p.Status)
+	q.Set("payment_id", id)
+	q.Set("status", p.Status)
+	q.Set("external_reference", p.ExternalReference)
+	q.Set("preference_id", prefID)
+	u.RawQuery = q.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// handleEmulatorPay: POST /emulator/payments {"preference_id","status","amount"}, para scripts y
+// pruebas sin pasar por la página de checkout. Sin preference_id usa la última preferencia.
+func (e *Emulator) handleEmulatorPay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method")
+		return
+	}
+	var req struct {
+		PreferenceID      string  `json:"preference_id"`
+		ExternalReference string  `json:"external_reference"`
+		Status            string  `json:"status"`
+		Amount            float64 `json:"amount"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "json inválido")
+		return
+	}
+	if req.Status == "" {
+		req.Status = StatusApproved
+	}
+	prefID := req.PreferenceID
+	if prefID == "" {
+		prefID = e.findPreference(req.ExternalReference)
+	}
+	p, err := e.Pay(prefID, req.Status, req.Amount)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, p)
+}
+
+// findPreference busca la preferencia por external_reference (o por el id de orden, la parte
+// antes del "|"); vacío = la más nueva.
+func (e *Emulator) findPreference(ext string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var best *Preference
+	for _, p := range e.prefs {
+		if ext != "" && p.ExternalReference != ext && !strings.HasPrefix(p.ExternalReference, ext+"|") {
+			continue
+		}
+		if best == nil || p.DateCreated > best.DateCreated || (p.DateCreated == best.DateCreated && p.ID > best.ID) {
+			best = p
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.ID
+}
+
+// handleEmulatorStatus: POST /emulator/payments/{id}/status {"status"} cambia el estado de un pago.
+func (e *Emulator) handleEmulatorStatus(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/emulator/payments/"), "/")
+	idStr, sub, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || sub != "status" || r.Method != http.MethodPost {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "json inválido")
+		return
+	}
+	p, err := e.SetStatus(id, req.Status)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (e *Emulator) handleEmulatorDeliveries(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, e.Deliveries())
+}
@@ -0,0 +1,226 @@
+// Package pdf arma PDFs simples (texto, líneas y rectángulos) con las fuentes Helvetica estándar,
+// sin dependencias externas. Las coordenadas se miden en puntos desde la esquina superior izquierda.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Tamaños de página en puntos.
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+	A6Width  = 297.64
+	A6Height = 419.53
+)
+
+type Doc struct {
+	w, h  float64
+	pages []*bytes.Buffer
+	cur   *bytes.Buffer
+}
+
+func New(width, height float64) *Doc {
+	d := &Doc{w: width, h: height}
+	d.AddPage()
+	return d
+}
+
+func (d *Doc) Width() float64  { return d.w }
+func (d *Doc) Height() float64 { return d.h }
+
+func (d *Doc) AddPage() {
+	d.cur = &bytes.Buffer{}
+	d.pages = append(d.pages, d.cur)
+}
+
+func font(bold bool) string {
+	if bold {
+		return "F2"
+	}
+	return "F1"
+}
+
+// Text escribe s con la línea base en y.
+func (d *Doc) Text(x, y, size float64, bold bool, s string) {
+	fmt.Fprintf(d.cur, "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font(bold), size, x, d.h-y, escape(s))
+}
+
+// TextRight escribe s alineado a la derecha en right.
+func (d *Doc) TextRight(right, y, size float64, bold bool, s string) {
+	d.Text(right-TextWidth(s, size, bold), y, size, bold, s)
+}
+
+// Line traza una línea de grosor width.
+func (d *Doc) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.cur, "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, d.h-y1, x2, d.h-y2)
+}
+
+// Rect rellena un rectángulo en escala de grises (0 negro, 1 blanco).
+func (d *Doc) Rect(x, y, w, h, gray float64) {
+	fmt.Fprintf(d.cur, "q %.2f g %.2f %.2f %.2f %.2f re f Q\n", gray, x, d.h-y-h, w, h)
+}
+
+// Box dibuja el borde de un rectángulo.
+func (d *Doc) Box(x, y, w, h, width float64) {
+	fmt.Fprintf(d.cur, "%.2f w %.2f %.2f %.2f %.2f re S\n", width, x, d.h-y-h, w, h)
+}
+
+// Bytes serializa el documento.
+func (d *Doc) Bytes() []byte {
+	var objs []string
+	// 1 catálogo, 2 árbol de páginas, 3 y 4 fuentes; después página + contenido por cada página
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	objs = append(objs,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	)
+	for i, p := range d.pages {
+		objs = append(objs,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Contents %d 0 R /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> >>", d.w, d.h, 6+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", p.Len(), p.String()),
+		)
+	}
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objs))
+	for i, o := range objs {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	return b.Bytes()
+}
+
+// winAnsi pasa los caracteres fuera de Latin-1 que sí existen en WinAnsiEncoding.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+}
+
+func encode(r rune) (byte, bool) {
+	if b, ok := winAnsi[r]; ok {
+		return b, true
+	}
+	if r < 0x20 || (r >= 0x7f && r < 0xa0) || r > 0xff {
+		return 0, false
+	}
+	return byte(r), true
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		c, ok := encode(r)
+		if !ok {
+			c = '?'
+		}
+		switch c {
+		case '\\', '(', ')':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			if c >= 0x80 {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	return b.String()
+}
+
+// TextWidth mide s en puntos con las métricas de Helvetica.
+func TextWidth(s string, size float64, bold bool) float64 {
+	table := &helvetica
+	if bold {
+		table = &helveticaBold
+	}
+	units := 0
+	for _, r := range s {
+		units += glyphWidth(table, r)
+	}
+	return float64(units) * size / 1000
+}
+
+// Wrap parte s en líneas que no superen maxWidth.
+func Wrap(s string, size float64, bold bool, maxWidth float64) []string {
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		words := strings.Fields(para)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		line := words[0]
+		for _, w := range words[1:] {
+			if TextWidth(line+" "+w, size, bold) > maxWidth {
+				lines = append(lines, line)
+				line = w
+				continue
+			}
+			line += " " + w
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// base reduce las letras acentuadas a su base para medirlas (el ancho es el mismo en Helvetica).
+var base = map[rune]rune{
+	'á': 'a', 'à': 'a', 'ä': 'a', 'â': 'a', 'ã': 'a', 'é': 'e', 'è': 'e', 'ë': 'e', 'ê': 'e',
+	'í': 'i', 'ì': 'i', 'ï': 'i', 'î': 'i', 'ó': 'o', 'ò': 'o', 'ö': 'o', 'ô': 'o', 'õ': 'o',
+	'ú': 'u', 'ù': 'u', 'ü': 'u', 'û': 'u', 'ñ': 'n', 'ç': 'c',
+	'Á': 'A', 'À': 'A', 'Ä': 'A', 'Â': 'A', 'Ã': 'A', 'É': 'E', 'È': 'E', 'Ë': 'E', 'Ê': 'E',
+	'Í': 'I', 'Ì': 'I', 'Ï': 'I', 'Î': 'I', 'Ó': 'O', 'Ò': 'O', 'Ö': 'O', 'Ô': 'O', 'Õ': 'O',
+	'Ú': 'U', 'Ù': 'U', 'Ü': 'U', 'Û': 'U', 'Ñ': 'N', 'Ç': 'C', '¿': '?', '¡': '!',
+}
+
+func glyphWidth(table *[95]int, r rune) int {
+	if b, ok := base[r]; ok {
+		r = b
+	}
+	switch {
+	case r >= 32 && r <= 126:
+		return table[r-32]
+	case r == '—':
+		return 1000
+	case r == '–':
+		return 556
+	case r == '°':
+		return 400
+	case r == '·', r == '•':
+		return 350
+	}
+	return 556
+}
+
+// Anchos (1/1000 em) de los caracteres 32..126.
+var helvetica = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBold = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
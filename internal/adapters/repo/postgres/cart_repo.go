@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type CartRepo struct{ db *gorm.DB }
+
+func NewCartRepo(db *gorm.DB) *CartRepo { return &CartRepo{db: db} }
+
+func (r *CartRepo) FindByEmail(ctx context.Context, email string) (*domain.CustomerCart, error) {
+	var c domain.CustomerCart
+	if err := r.db.WithContext(ctx).First(&c, "email = ?", strings.ToLower(strings.TrimSpace(email))).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *CartRepo) Save(ctx context.Context, c *domain.CustomerCart) error {
+	c.Email = strings.ToLower(strings.TrimSpace(c.Email))
+	c.UpdatedAt = time.Now()
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "email"}},
+		DoUpdates: clause.AssignmentColumns([]string{"items", "updated_at"}),
+	}).Create(c).Error
+}
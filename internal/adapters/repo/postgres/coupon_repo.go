@@ -117,6 +117,23 @@ func (r *CouponRepo) SaveUsage(ctx context.Context, usage *domain.CouponUsage) e
 	return r.db.WithContext(ctx).Create(usage).Error
 }
 
+func (r *CouponRepo) DeleteUsageByOrder(ctx context.Context, couponID, orderID uuid.UUID) (bool, error) {
+	deleted := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("coupon_id = ? AND order_id = ?", couponID, orderID).Delete(&domain.CouponUsage{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		deleted = true
+		return tx.Model(&domain.Coupon{}).Where("id = ? AND current_uses > 0", couponID).
+			UpdateColumn("current_uses", gorm.Expr("current_uses - ?", 1)).Error
+	})
+	return deleted, err
+}
+
 func (r *CouponRepo) FindUsagesByEmail(ctx context.Context, email string, couponID uuid.UUID) ([]domain.CouponUsage, error) {
 	var usages []domain.CouponUsage
 	if err := r.db.WithContext(ctx).
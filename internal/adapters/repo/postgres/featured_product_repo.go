@@ -32,7 +32,7 @@ func (r *FeaturedProductRepo) FindByProductID(ctx context.Context, productID uui
 	var fp domain.FeaturedProduct
 	if err := r.db.WithContext(ctx).
 		Preload("Product").
-		Preload("Product.Images").
+		Preload("Product.Images", orderImages).
 		First(&fp, "product_id = ?", productID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, domain.ErrNotFound
@@ -46,7 +46,7 @@ func (r *FeaturedProductRepo) FindAll(ctx context.Context) ([]domain.FeaturedPro
 	var list []domain.FeaturedProduct
 	if err := r.db.WithContext(ctx).
 		Preload("Product").
-		Preload("Product.Images").
+		Preload("Product.Images", orderImages).
 		Where("active = ?", true).
 		Order("display_order asc, created_at asc").
 		Find(&list).Error; err != nil {
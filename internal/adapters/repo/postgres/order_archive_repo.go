@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type OrderArchiveRepo struct{ db *gorm.DB }
+
+func NewOrderArchiveRepo(db *gorm.DB) *OrderArchiveRepo { return &OrderArchiveRepo{db: db} }
+
+func (r *OrderArchiveRepo) Add(ctx context.Context, a *domain.OrderArchive) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return r.db.WithContext(ctx).Create(a).Error
+}
+
+func (r *OrderArchiveRepo) Save(ctx context.Context, a *domain.OrderArchive) error {
+	return r.db.WithContext(ctx).Save(a).Error
+}
+
+func (r *OrderArchiveRepo) FindByID(ctx context.Context, id uuid.UUID) (*domain.OrderArchive, error) {
+	var a domain.OrderArchive
+	if err := r.db.WithContext(ctx).First(&a, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *OrderArchiveRepo) List(ctx context.Context) ([]domain.OrderArchive, error) {
+	var list []domain.OrderArchive
+	if err := r.db.WithContext(ctx).Order("created_at desc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type OrderEditRepo struct{ db *gorm.DB }
+
+func NewOrderEditRepo(db *gorm.DB) *OrderEditRepo { return &OrderEditRepo{db: db} }
+
+func (r *OrderEditRepo) Add(ctx context.Context, e *domain.OrderEdit) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return r.db.WithContext(ctx).Create(e).Error
+}
+
+func (r *OrderEditRepo) ListByOrder(ctx context.Context, orderID uuid.UUID) ([]domain.OrderEdit, error) {
+	var list []domain.OrderEdit
+	if err := r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("created_at desc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,216 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	pgdriver "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// seqDB emula lo que nextOrderNumber necesita de postgres: el INSERT ... ON CONFLICT DO NOTHING
+// del contador y el UPDATE ... RETURNING, que bloquea la fila hasta el commit o el rollback.
+type seqDB struct {
+	row    sync.Mutex // bloqueo de la fila del contador
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+func (db *seqDB) Connect(ctx context.Context) (driver.Conn, error) { return &seqConn{db: db}, nil }
+func (db *seqDB) Driver() driver.Driver                            { return seqDriver{} }
+
+type seqDriver struct{}
+
+func (seqDriver) Open(string) (driver.Conn, error) { return nil, errors.New("usar OpenDB") }
+
+type seqConn struct {
+	db *seqDB
+	tx *seqTx
+}
+
+type seqTx struct {
+	c      *seqConn
+	locked bool
+	before map[string]int64
+}
+
+func (c *seqConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare no soportado: " + query)
+}
+func (c *seqConn) Close() error { return nil }
+func (c *seqConn) Begin() (driver.Tx, error) {
+	c.tx = &seqTx{c: c}
+	return c.tx, nil
+}
+
+func (t *seqTx) Commit() error   { return t.end(false) }
+func (t *seqTx) Rollback() error { return t.end(true) }
+
+func (t *seqTx) end(rollback bool) error {
+	db := t.c.db
+	if rollback && t.before != nil {
+		db.mu.Lock()
+		db.values = t.before
+		db.mu.Unlock()
+	}
+	if t.locked {
+		db.row.Unlock()
+	}
+	t.c.tx = nil
+	return nil
+}
+
+func (c *seqConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if !strings.HasPrefix(query, `INSERT INTO "order_sequences"`) || !strings.Contains(query, "ON CONFLICT DO NOTHING") {
+		return nil, errors.New("consulta inesperada: " + query)
+	}
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	name := args[0].Value.(string)
+	if _, ok := c.db.values[name]; ok {
+		return driver.RowsAffected(0), nil
+	}
+	c.db.values[name] = 0
+	return driver.RowsAffected(1), nil
+}
+
+func (c *seqConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !strings.HasPrefix(query, "UPDATE order_sequences SET value = value + 1 WHERE name = $1 RETURNING value") {
+		return nil, errors.New("consulta inesperada: " + query)
+	}
+	if c.tx == nil {
+		return nil, errors.New("nextOrderNumber fuera de una transacción")
+	}
+	if !c.tx.locked {
+		c.db.row.Lock()
+		c.tx.locked = true
+	}
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	if c.tx.before == nil {
+		c.tx.before = make(map[string]int64, len(c.db.values))
+		for k, v := range c.db.values {
+			c.tx.before[k] = v
+		}
+	}
+	name := args[0].Value.(string)
+	v, ok := c.db.values[name]
+	if !ok {
+		return &seqRows{}, nil
+	}
+	v++
+	c.db.values[name] = v
+	return &seqRows{vals: []int64{v}}, nil
+}
+
+type seqRows struct{ vals []int64 }
+
+func (r *seqRows) Columns() []string { return []string{"value"} }
+func (r *seqRows) Close() error      { return nil }
+func (r *seqRows) Next(dest []driver.Value) error {
+	if len(r.vals) == 0 {
+		return io.EOF
+	}
+	dest[0], r.vals = r.vals[0], r.vals[1:]
+	return nil
+}
+
+func openSeqDB(t *testing.T) (*gorm.DB, *seqDB) {
+	t.Helper()
+	seq := &seqDB{values: map[string]int64{}}
+	db, err := gorm.Open(pgdriver.New(pgdriver.Config{Conn: sql.OpenDB(seq)}), &gorm.Config{Logger: logger.Discard, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, seq
+}
+
+func TestNextOrderNumber(t *testing.T) {
+	db, seq := openSeqDB(t)
+	next := func(fail bool) (int64, error) {
+		var n int64
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if n, err = nextOrderNumber(tx); err != nil {
+				return err
+			}
+			if fail {
+				return errors.New("falló el alta de la orden")
+			}
+			return nil
+		})
+		return n, err
+	}
+	steps := []struct {
+		fail bool
+		want int64
+	}{
+		{false, 1},
+		{false, 2},
+		// el alta que falla devuelve el número: la siguiente no deja hueco
+		{true, 3},
+		{false, 3},
+		{false, 4},
+	}
+	for i, s := range steps {
+		n, err := next(s.fail)
+		if (err != nil) != s.fail || n != s.want {
+			t.Fatalf("paso %d: número %d, %v; want %d", i, n, err, s.want)
+		}
+	}
+	if seq.values[orderSequenceName] != 4 {
+		t.Fatalf("contador = %d", seq.values[orderSequenceName])
+	}
+}
+
+func TestNextOrderNumberConcurrent(t *testing.T) {
+	db, seq := openSeqDB(t)
+	const workers = 20
+	nums := make(chan int64, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(fail bool) {
+			defer wg.Done()
+			var n int64
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				if n, err = nextOrderNumber(tx); err != nil {
+					return err
+				}
+				if fail {
+					return errors.New("rollback")
+				}
+				return nil
+			})
+			if err == nil {
+				nums <- n
+			}
+		}(i%4 == 0)
+	}
+	wg.Wait()
+	close(nums)
+	seen := map[int64]bool{}
+	for n := range nums {
+		if seen[n] {
+			t.Fatalf("número %d repetido", n)
+		}
+		seen[n] = true
+	}
+	const ok = workers - workers/4
+	for n := int64(1); n <= ok; n++ {
+		if !seen[n] {
+			t.Fatalf("falta el número %d (%d órdenes)", n, len(seen))
+		}
+	}
+	if len(seen) != ok || seq.values[orderSequenceName] != ok {
+		t.Fatalf("%d números, contador %d; want %d", len(seen), seq.values[orderSequenceName], ok)
+	}
+}
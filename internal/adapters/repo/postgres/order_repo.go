@@ -3,16 +3,20 @@ package postgres
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/phenrril/tienda3d/internal/domain"
 )
 
 type OrderRepo struct{ db *gorm.DB }
 
+const orderSequenceName = "orders"
+
 func NewOrderRepo(db *gorm.DB) *OrderRepo { return &OrderRepo{db: db} }
 
 func (r *OrderRepo) Save(ctx context.Context, o *domain.Order) error {
@@ -27,27 +31,45 @@ func (r *OrderRepo) Save(ctx context.Context, o *domain.Order) error {
 	}
 	if count == 0 {
 
-		core := domain.Order{ID: o.ID, Status: o.Status, Email: o.Email, Name: o.Name, Phone: o.Phone, DNI: o.DNI, Address: o.Address, PostalCode: o.PostalCode, Province: o.Province, MPPreferenceID: o.MPPreferenceID, MPStatus: o.MPStatus, Total: o.Total, ShippingMethod: o.ShippingMethod, ShippingCost: o.ShippingCost, PaymentMethod: o.PaymentMethod, DiscountAmount: o.DiscountAmount, CouponCode: o.CouponCode, CouponID: o.CouponID, Notified: o.Notified}
-		if err := r.db.WithContext(ctx).Create(&core).Error; err != nil {
-			return err
-		}
-
-		if len(o.Items) > 0 {
-			for i := range o.Items {
-				o.Items[i].OrderID = o.ID
-				if o.Items[i].ID == uuid.Nil {
-					o.Items[i].ID = uuid.New()
-				}
+		core := domain.Order{ID: o.ID, Status: o.Status, Email: o.Email, Name: o.Name, Phone: o.Phone, DNI: o.DNI, Address: o.Address, PostalCode: o.PostalCode, Province: o.Province, MPPreferenceID: o.MPPreferenceID, MPStatus: o.MPStatus, Total: o.Total, ShippingMethod: o.ShippingMethod, ShippingCost: o.ShippingCost, PaymentMethod: o.PaymentMethod, DiscountAmount: o.DiscountAmount, CouponCode: o.CouponCode, CouponID: o.CouponID, Notified: o.Notified, PaymentAdjustment: o.PaymentAdjustment, PaymentAdjustmentPct: o.PaymentAdjustmentPct, PaymentReminderAt: o.PaymentReminderAt, Carrier: o.Carrier, CarrierService: o.CarrierService, TrackingNumber: o.TrackingNumber, ShippingLabel: o.ShippingLabel, TrackingURL: o.TrackingURL, ShipmentStage: o.ShipmentStage, MPPaymentID: o.MPPaymentID, RefundedAmount: o.RefundedAmount}
+		err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			n, err := nextOrderNumber(tx)
+			if err != nil {
+				return err
 			}
-			if err := r.db.WithContext(ctx).Create(&o.Items).Error; err != nil {
+			core.Number = n
+			if err := tx.Create(&core).Error; err != nil {
 				return err
 			}
+
+			if len(o.Items) > 0 {
+				for i := range o.Items {
+					o.Items[i].OrderID = o.ID
+					if o.Items[i].ID == uuid.Nil {
+						o.Items[i].ID = uuid.New()
+					}
+				}
+				if err := tx.Create(&o.Items).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
+		o.Number = core.Number
 		return nil
 	}
 
-	return r.db.WithContext(ctx).Model(&domain.Order{}).Where("id = ?", o.ID).Updates(map[string]any{
-		"status":           o.Status,
+	return r.db.WithContext(ctx).Model(&domain.Order{}).Where("id = ?", o.ID).Updates(orderColumns(o)).Error
+}
+
+// orderColumns son las columnas que se actualizan al guardar una orden existente. status y
+// notified no están: sólo cambian con SaveIfStatus y SetNotified, así un caller con la orden
+// desactualizada no deshace una transición ni un aviso de pago ya reclamado.
+func orderColumns(o *domain.Order) map[string]any {
+	return map[string]any{
 		"email":            o.Email,
 		"name":             o.Name,
 		"phone":            o.Phone,
@@ -64,8 +86,55 @@ func (r *OrderRepo) Save(ctx context.Context, o *domain.Order) error {
 		"discount_amount":  o.DiscountAmount,
 		"coupon_code":      o.CouponCode,
 		"coupon_id":        o.CouponID,
-		"notified":         o.Notified,
-	}).Error
+
+		"payment_adjustment":     o.PaymentAdjustment,
+		"payment_adjustment_pct": o.PaymentAdjustmentPct,
+
+		"payment_reminder_at": o.PaymentReminderAt,
+		"carrier":             o.Carrier,
+		"carrier_service":     o.CarrierService,
+		"tracking_number":     o.TrackingNumber,
+		"shipping_label":      o.ShippingLabel,
+		"tracking_url":        o.TrackingURL,
+		"shipment_stage":      o.ShipmentStage,
+		"mp_payment_id":       o.MPPaymentID,
+		"refunded_amount":     o.RefundedAmount,
+	}
+}
+
+func (r *OrderRepo) SaveWithItems(ctx context.Context, o *domain.Order) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.Order{}).Where("id = ?", o.ID).Updates(orderColumns(o)).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("order_id = ?", o.ID).Delete(&domain.OrderItem{}).Error; err != nil {
+			return err
+		}
+		if len(o.Items) == 0 {
+			return nil
+		}
+		for i := range o.Items {
+			o.Items[i].OrderID = o.ID
+			if o.Items[i].ID == uuid.Nil {
+				o.Items[i].ID = uuid.New()
+			}
+		}
+		return tx.Create(&o.Items).Error
+	})
+}
+
+// nextOrderNumber incrementa el contador de órdenes dentro de tx. El UPDATE bloquea la fila
+// hasta el commit, así dos altas simultáneas no comparten número y un rollback lo devuelve.
+func nextOrderNumber(tx *gorm.DB) (int64, error) {
+	seq := domain.OrderSequence{Name: orderSequenceName}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+		return 0, err
+	}
+	var n int64
+	if err := tx.Raw("UPDATE order_sequences SET value = value + 1 WHERE name = ? RETURNING value", orderSequenceName).Scan(&n).Error; err != nil {
+		return 0, err
+	}
+	return n, nil
 }
 
 func (r *OrderRepo) FindByID(ctx context.Context, id uuid.UUID) (*domain.Order, error) {
@@ -94,7 +163,35 @@ func (r *OrderRepo) UpdateStatus(ctx context.Context, id uuid.UUID, st domain.Or
 	return r.db.WithContext(ctx).Model(&domain.Order{}).Where("id = ?", id).Update("status", st).Error
 }
 
-func (r *OrderRepo) List(ctx context.Context, status *domain.OrderStatus, mpStatus *string, page, pageSize int) ([]domain.Order, int64, error) {
+func (r *OrderRepo) SaveIfStatus(ctx context.Context, o *domain.Order, from domain.OrderStatus) (bool, error) {
+	cols := orderColumns(o)
+	cols["status"] = o.Status
+	res := r.db.WithContext(ctx).Model(&domain.Order{}).Where("id = ? AND status = ?", o.ID, from).Updates(cols)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *OrderRepo) SetNotified(ctx context.Context, id uuid.UUID, notified bool) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&domain.Order{}).Where("id = ? AND notified = ?", id, !notified).Update("notified", notified)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *OrderRepo) SetPreference(ctx context.Context, id uuid.UUID, preferenceID string, total float64) error {
+	return r.db.WithContext(ctx).Model(&domain.Order{}).Where("id = ?", id).
+		Updates(map[string]any{"mp_preference_id": preferenceID, "total": total}).Error
+}
+
+func (r *OrderRepo) SetRefunded(ctx context.Context, id uuid.UUID, paymentID string, refunded float64) error {
+	return r.db.WithContext(ctx).Model(&domain.Order{}).Where("id = ?", id).
+		Updates(map[string]any{"mp_payment_id": paymentID, "refunded_amount": refunded}).Error
+}
+
+func (r *OrderRepo) List(ctx context.Context, status *domain.OrderStatus, mpStatus *string, search string, page, pageSize int) ([]domain.Order, int64, error) {
 	if page <= 0 {
 		page = 1
 	}
@@ -108,6 +205,20 @@ func (r *OrderRepo) List(ctx context.Context, status *domain.OrderStatus, mpStat
 	if mpStatus != nil && *mpStatus != "" {
 		q = q.Where("mp_status = ?", *mpStatus)
 	}
+	if search = strings.TrimSpace(search); search != "" {
+		// un número ("1234", "TD-1234") también puede ser parte de un teléfono, DNI o código postal:
+		// se busca el número de orden además de los campos de texto
+		like := "%" + strings.ToLower(search) + "%"
+		cond := r.db.Where("LOWER(email) LIKE ? OR LOWER(name) LIKE ? OR phone LIKE ? OR dni LIKE ? OR LOWER(postal_code) LIKE ? OR CAST(id AS TEXT) LIKE ?",
+			like, like, like, like, like, like)
+		if n, ok := domain.ParseOrderNumber(search); ok {
+			cond = cond.Or("number = ?", n)
+		}
+		if id, err := uuid.Parse(search); err == nil {
+			cond = cond.Or("id = ?", id)
+		}
+		q = q.Where(cond)
+	}
 	var total int64
 	if err := q.Count(&total).Error; err != nil {
 		return nil, 0, err
@@ -134,7 +245,10 @@ func (r *OrderRepo) ListInRange(ctx context.Context, from, to time.Time) ([]doma
 	return list, nil
 }
 
-func (r *OrderRepo) DeleteRange(ctx context.Context, from, to time.Time) (int64, error) {
+// archiveChunk: IDs por consulta al archivar, borrar o restaurar órdenes.
+const archiveChunk = 500
+
+func (r *OrderRepo) ListArchiveEntries(ctx context.Context, from, to time.Time) ([]domain.OrderArchiveEntry, error) {
 	if to.Before(from) {
 		from, to = to, from
 	}
@@ -142,29 +256,194 @@ func (r *OrderRepo) DeleteRange(ctx context.Context, from, to time.Time) (int64,
 	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
 	to = time.Date(to.Year(), to.Month(), to.Day(), 23, 59, 59, int(time.Second-time.Nanosecond), to.Location())
 
+	db := r.db.WithContext(ctx)
+	var orders []domain.Order
+	if err := db.Where("created_at BETWEEN ? AND ?", from, to).Order("created_at asc").Preload("Items").Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	entries := make([]domain.OrderArchiveEntry, len(orders))
+	ids := make([]uuid.UUID, len(orders))
+	for i := range orders {
+		entries[i].Order = orders[i]
+		ids[i] = orders[i].ID
+	}
+
+	usages, err := listByOrderIDs(db, ids, func(v domain.CouponUsage) uuid.UUID { return *v.OrderID })
+	if err != nil {
+		return nil, err
+	}
+	history, err := listByOrderIDs(db, ids, func(v domain.OrderStatusChange) uuid.UUID { return v.OrderID })
+	if err != nil {
+		return nil, err
+	}
+	payments, err := listByOrderIDs(db, ids, func(v domain.Payment) uuid.UUID { return v.OrderID })
+	if err != nil {
+		return nil, err
+	}
+	refunds, err := listByOrderIDs(db, ids, func(v domain.Refund) uuid.UUID { return v.OrderID })
+	if err != nil {
+		return nil, err
+	}
+	proofs, err := listByOrderIDs(db, ids, func(v domain.TransferProof) uuid.UUID { return v.OrderID })
+	if err != nil {
+		return nil, err
+	}
+	events, err := listByOrderIDs(db, ids, func(v domain.TrackingEvent) uuid.UUID { return v.OrderID })
+	if err != nil {
+		return nil, err
+	}
+	issues, err := listByOrderIDs(db, ids, func(v domain.ReconcileIssue) uuid.UUID { return v.OrderID })
+	if err != nil {
+		return nil, err
+	}
+	edits, err := listByOrderIDs(db, ids, func(v domain.OrderEdit) uuid.UUID { return v.OrderID })
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		id := entries[i].Order.ID
+		entries[i].CouponUsages = usages[id]
+		entries[i].StatusHistory = history[id]
+		entries[i].Payments = payments[id]
+		entries[i].Refunds = refunds[id]
+		entries[i].TransferProofs = proofs[id]
+		entries[i].TrackingEvents = events[id]
+		entries[i].ReconcileIssues = issues[id]
+		entries[i].Edits = edits[id]
+	}
+	return entries, nil
+}
+
+// listByOrderIDs trae las filas de T de las órdenes pedidas (por tandas de archiveChunk),
+// agrupadas por orden.
+func listByOrderIDs[T any](db *gorm.DB, ids []uuid.UUID, orderID func(T) uuid.UUID) (map[uuid.UUID][]T, error) {
+	out := map[uuid.UUID][]T{}
+	for start := 0; start < len(ids); start += archiveChunk {
+		var list []T
+		if err := db.Where("order_id IN ?", ids[start:min(start+archiveChunk, len(ids))]).Find(&list).Error; err != nil {
+			return nil, err
+		}
+		for _, v := range list {
+			out[orderID(v)] = append(out[orderID(v)], v)
+		}
+	}
+	return out, nil
+}
+
+func (r *OrderRepo) DeleteByIDs(ctx context.Context, ids []uuid.UUID) (int64, error) {
 	var deleted int64
 	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		orderScope := tx.Model(&domain.Order{}).Where("created_at BETWEEN ? AND ?", from, to)
-		if err := orderScope.Count(&deleted).Error; err != nil {
-			return err
+		for start := 0; start < len(ids); start += archiveChunk {
+			chunk := ids[start:min(start+archiveChunk, len(ids))]
+			// los usos de cupón no se borran (cuentan para los límites por cupón y por email): se
+			// desvinculan de la orden
+			if err := tx.Model(&domain.CouponUsage{}).Where("order_id IN ?", chunk).Update("order_id", nil).Error; err != nil {
+				return err
+			}
+			for _, model := range []any{
+				&domain.OrderItem{}, &domain.OrderStatusChange{}, &domain.TrackingEvent{},
+				&domain.Refund{}, &domain.Payment{}, &domain.TransferProof{}, &domain.ReconcileIssue{}, &domain.OrderEdit{},
+			} {
+				if err := tx.Where("order_id IN ?", chunk).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			res := tx.Where("id IN ?", chunk).Delete(&domain.Order{})
+			if res.Error != nil {
+				return res.Error
+			}
+			deleted += res.RowsAffected
 		}
-		if deleted == 0 {
-			return nil
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+func (r *OrderRepo) RestoreArchiveEntries(ctx context.Context, entries []domain.OrderArchiveEntry) (int, error) {
+	restored := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// los usos de un cupón que ya no existe romperían la FK: se restaura la orden sin ellos
+		couponIDs := []uuid.UUID{}
+		for _, e := range entries {
+			for _, u := range e.CouponUsages {
+				couponIDs = append(couponIDs, u.CouponID)
+			}
+		}
+		coupons := map[uuid.UUID]bool{}
+		if len(couponIDs) > 0 {
+			var existing []uuid.UUID
+			if err := tx.Model(&domain.Coupon{}).Where("id IN ?", couponIDs).Pluck("id", &existing).Error; err != nil {
+				return err
+			}
+			for _, id := range existing {
+				coupons[id] = true
+			}
 		}
 
-		subquery := tx.Model(&domain.Order{}).Select("id").Where("created_at BETWEEN ? AND ?", from, to)
-		if err := tx.Where("order_id IN (?)", subquery).Delete(&domain.OrderItem{}).Error; err != nil {
-			return err
+		insert := func(rows any) error {
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(rows).Error
 		}
-		if err := tx.Where("created_at BETWEEN ? AND ?", from, to).Delete(&domain.Order{}).Error; err != nil {
-			return err
+		for i := range entries {
+			e := &entries[i]
+			o := e.Order
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(&o)
+			if res.Error != nil {
+				return res.Error
+			}
+			restored += int(res.RowsAffected)
+			if len(o.Items) > 0 {
+				if err := insert(&o.Items); err != nil {
+					return err
+				}
+			}
+			usages := e.CouponUsages[:0:0]
+			for _, u := range e.CouponUsages {
+				if coupons[u.CouponID] {
+					usages = append(usages, u)
+				}
+			}
+			if len(usages) > 0 {
+				// el uso que quedó desvinculado al borrar la orden se vuelve a asociar
+				err := tx.Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "id"}},
+					DoUpdates: clause.AssignmentColumns([]string{"order_id"}),
+				}).Omit(clause.Associations).Create(&usages).Error
+				if err != nil {
+					return err
+				}
+			}
+			for _, rows := range []struct {
+				n    int
+				rows any
+			}{
+				{len(e.StatusHistory), &e.StatusHistory},
+				{len(e.Payments), &e.Payments},
+				{len(e.Refunds), &e.Refunds},
+				{len(e.TransferProofs), &e.TransferProofs},
+				{len(e.TrackingEvents), &e.TrackingEvents},
+				{len(e.ReconcileIssues), &e.ReconcileIssues},
+				{len(e.Edits), &e.Edits},
+			} {
+				if rows.n == 0 {
+					continue
+				}
+				if err := insert(rows.rows); err != nil {
+					return err
+				}
+			}
 		}
 		return nil
 	})
 	if err != nil {
 		return 0, err
 	}
-	return deleted, nil
+	return restored, nil
 }
 
 // FindPendingByEmailAndCoupon busca órdenes pendientes de un usuario con un cupón específico
@@ -184,3 +463,117 @@ func (r *OrderRepo) FindPendingByEmailAndCoupon(ctx context.Context, email, coup
 	}
 	return list, nil
 }
+
+func (r *OrderRepo) ListByEmail(ctx context.Context, email string, page, pageSize int) ([]domain.Order, int64, error) {
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	q := r.db.WithContext(ctx).Model(&domain.Order{}).Where("LOWER(email) = LOWER(?)", email)
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []domain.Order
+	if err := q.Order("created_at desc").Offset((page - 1) * pageSize).Limit(pageSize).Preload("Items").Find(&list).Error; err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+func (r *OrderRepo) ListAwaitingPayment(ctx context.Context, createdBefore time.Time) ([]domain.Order, error) {
+	var list []domain.Order
+	if err := r.db.WithContext(ctx).Where("status = ? AND created_at < ?", domain.OrderStatusAwaitingPay, createdBefore).
+		Order("created_at asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *OrderRepo) ListUnpaidMP(ctx context.Context, from, to time.Time) ([]domain.Order, error) {
+	var list []domain.Order
+	if err := r.db.WithContext(ctx).
+		Where("status IN ? AND mp_preference_id <> '' AND created_at BETWEEN ? AND ?",
+			[]domain.OrderStatus{domain.OrderStatusAwaitingPay, domain.OrderStatusCancelled}, from, to).
+		Order("created_at asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *OrderRepo) MarkPaymentReminder(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&domain.Order{}).Where("id = ?", id).Update("payment_reminder_at", at).Error
+}
+
+func (r *OrderRepo) AddStatusChange(ctx context.Context, c *domain.OrderStatusChange) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return r.db.WithContext(ctx).Create(c).Error
+}
+
+func (r *OrderRepo) ListStatusChanges(ctx context.Context, orderIDs []uuid.UUID) ([]domain.OrderStatusChange, error) {
+	var list []domain.OrderStatusChange
+	if len(orderIDs) == 0 {
+		return list, nil
+	}
+	if err := r.db.WithContext(ctx).Where("order_id IN ?", orderIDs).Order("created_at asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *OrderRepo) ListTrackable(ctx context.Context) ([]domain.Order, error) {
+	var list []domain.Order
+	if err := r.db.WithContext(ctx).Where("tracking_number <> '' AND carrier <> '' AND shipment_stage <> ? AND status <> ?", domain.ShipmentStageDelivered, domain.OrderStatusCancelled).
+		Order("created_at asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// exportBatchSize: órdenes por consulta al exportar.
+const exportBatchSize = 200
+
+func (r *OrderRepo) EachForExport(ctx context.Context, f domain.OrderExportFilter, fn func(o *domain.Order) error) error {
+	from, to := f.From, f.To
+	if to.Before(from) {
+		from, to = to, from
+	}
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	to = time.Date(to.Year(), to.Month(), to.Day(), 23, 59, 59, int(time.Second-time.Nanosecond), to.Location())
+
+	// paginado por (created_at, id) en vez de OFFSET: cada tanda arranca donde terminó la anterior
+	var lastAt time.Time
+	var lastID uuid.UUID
+	for first := true; ; first = false {
+		q := r.db.WithContext(ctx).Where("created_at BETWEEN ? AND ?", from, to)
+		if f.Status != "" {
+			q = q.Where("status = ?", f.Status)
+		}
+		if f.PaymentMethod != "" {
+			q = q.Where("payment_method = ?", f.PaymentMethod)
+		}
+		if f.ShippingMethod != "" {
+			q = q.Where("shipping_method = ?", f.ShippingMethod)
+		}
+		if !first {
+			q = q.Where("(created_at, id) > (?, ?)", lastAt, lastID)
+		}
+		var batch []domain.Order
+		if err := q.Order("created_at asc, id asc").Limit(exportBatchSize).Preload("Items").Find(&batch).Error; err != nil {
+			return err
+		}
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+		lastAt, lastID = batch[len(batch)-1].CreatedAt, batch[len(batch)-1].ID
+	}
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type PaymentRepo struct{ db *gorm.DB }
+
+func NewPaymentRepo(db *gorm.DB) *PaymentRepo { return &PaymentRepo{db: db} }
+
+// Save hace upsert de los pagos de la pasarela por proveedor + id externo (índice único parcial,
+// ver app.uniquePaymentExternalIDs): dos avisos del mismo pago al mismo tiempo terminan en una sola
+// fila. Los manuales no tienen ExternalID y siempre se insertan.
+func (r *PaymentRepo) Save(ctx context.Context, p *domain.Payment) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return r.db.WithContext(ctx).Clauses(
+		clause.OnConflict{
+			Columns:     []clause.Column{{Name: "provider"}, {Name: "external_id"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Neq{Column: "external_id", Value: ""}}},
+			DoUpdates: append(clause.AssignmentColumns([]string{"order_id", "amount", "status", "status_detail", "raw", "updated_at"}),
+				clause.Assignment{Column: clause.Column{Name: "paid_at"}, Value: gorm.Expr("COALESCE(EXCLUDED.paid_at, order_payments.paid_at)")}),
+		},
+		// trae id, created_at y paid_at de la fila que ya existía
+		clause.Returning{},
+	).Create(p).Error
+}
+
+func (r *PaymentRepo) ListByOrders(ctx context.Context, orderIDs []uuid.UUID) ([]domain.Payment, error) {
+	if len(orderIDs) == 0 {
+		return nil, nil
+	}
+	var list []domain.Payment
+	if err := r.db.WithContext(ctx).Where("order_id IN ?", orderIDs).Order("created_at asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type PaymentRuleRepo struct{ db *gorm.DB }
+
+func NewPaymentRuleRepo(db *gorm.DB) *PaymentRuleRepo { return &PaymentRuleRepo{db: db} }
+
+func (r *PaymentRuleRepo) List(ctx context.Context) ([]domain.PaymentRule, error) {
+	var list []domain.PaymentRule
+	if err := r.db.WithContext(ctx).Order("method asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *PaymentRuleRepo) Save(ctx context.Context, rule *domain.PaymentRule) error {
+	rule.UpdatedAt = time.Now()
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "method"}},
+		UpdateAll: true,
+	}).Create(rule).Error
+}
@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type ProductModelRepo struct{ db *gorm.DB }
+
+func NewProductModelRepo(db *gorm.DB) *ProductModelRepo { return &ProductModelRepo{db: db} }
+
+func (r *ProductModelRepo) FindByProductID(ctx context.Context, productID uuid.UUID) (*domain.ProductModel3D, error) {
+	var m domain.ProductModel3D
+	if err := r.db.WithContext(ctx).First(&m, "product_id = ?", productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *ProductModelRepo) Save(ctx context.Context, m *domain.ProductModel3D) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	now := time.Now()
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = now
+	}
+	m.UpdatedAt = now
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "product_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"source_model_id", "source_filename", "asset_path", "asset_size", "triangles",
+			"source_triangles", "size_xmm", "size_ymm", "size_zmm", "updated_at",
+		}),
+	}).Create(m).Error
+}
+
+func (r *ProductModelRepo) DeleteByProductID(ctx context.Context, productID uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("product_id = ?", productID).Delete(&domain.ProductModel3D{}).Error
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,6 +16,15 @@ type ProductRepo struct{ db *gorm.DB }
 
 func NewProductRepo(db *gorm.DB) *ProductRepo { return &ProductRepo{db: db} }
 
+func orderPersonalization(db *gorm.DB) *gorm.DB {
+	return db.Order("position asc, created_at asc")
+}
+
+// orderImages deja la portada primero y después el orden manual de la galería.
+func orderImages(db *gorm.DB) *gorm.DB {
+	return db.Order("is_primary desc, position asc, created_at asc")
+}
+
 func (r *ProductRepo) Save(ctx context.Context, p *domain.Product) error {
 	return r.db.WithContext(ctx).Save(p).Error
 }
@@ -23,11 +33,24 @@ func (r *ProductRepo) AddImages(ctx context.Context, productID uuid.UUID, imgs [
 	if len(imgs) == 0 {
 		return nil
 	}
+	var maxPos *int
+	if err := r.db.WithContext(ctx).Model(&domain.Image{}).Where("product_id = ?", productID).
+		Select("MAX(position)").Scan(&maxPos).Error; err != nil {
+		return err
+	}
+	next := 0
+	if maxPos != nil {
+		next = *maxPos + 1
+	}
 	for i := range imgs {
 		if imgs[i].ID == uuid.Nil {
 			imgs[i].ID = uuid.New()
 		}
 		imgs[i].ProductID = productID
+		imgs[i].Position = next + i
+		if imgs[i].FocalX == 0 && imgs[i].FocalY == 0 {
+			imgs[i].FocalX, imgs[i].FocalY = 0.5, 0.5
+		}
 		if imgs[i].CreatedAt.IsZero() {
 			imgs[i].CreatedAt = time.Now()
 		}
@@ -37,7 +60,7 @@ func (r *ProductRepo) AddImages(ctx context.Context, productID uuid.UUID, imgs [
 
 func (r *ProductRepo) FindBySlug(ctx context.Context, slug string) (*domain.Product, error) {
 	var p domain.Product
-	if err := r.db.WithContext(ctx).Preload("Images").Preload("Variants").First(&p, "slug = ?", slug).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("Images", orderImages).Preload("Variants").Preload("Personalization", orderPersonalization).First(&p, "slug = ?", slug).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, domain.ErrNotFound
 		}
@@ -109,7 +132,7 @@ func (r *ProductRepo) List(ctx context.Context, f domain.ProductFilter) ([]domai
 		f.PageSize = 20
 	}
 	offset := (f.Page - 1) * f.PageSize
-	if err := q.Offset(offset).Limit(f.PageSize).Preload("Images", func(db *gorm.DB) *gorm.DB { return db.Order("created_at asc") }).Find(&list).Error; err != nil {
+	if err := q.Offset(offset).Limit(f.PageSize).Preload("Images", orderImages).Find(&list).Error; err != nil {
 		return nil, 0, err
 	}
 	return list, total, nil
@@ -124,7 +147,7 @@ func (r *ProductRepo) DeleteFullBySlug(ctx context.Context, slug string) ([]stri
 		return nil, errors.New("slug vacío")
 	}
 	var p domain.Product
-	if err := r.db.WithContext(ctx).Preload("Images").Preload("Variants").First(&p, "slug = ?", slug).Error; err != nil {
+	if err := r.db.WithContext(ctx).Preload("Images", orderImages).Preload("Variants").Preload("Personalization", orderPersonalization).First(&p, "slug = ?", slug).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, domain.ErrNotFound
 		}
@@ -141,6 +164,9 @@ func (r *ProductRepo) DeleteFullBySlug(ctx context.Context, slug string) ([]stri
 		if err := tx.Where("product_id = ?", p.ID).Delete(&domain.Variant{}).Error; err != nil {
 			return err
 		}
+		if err := tx.Where("product_id = ?", p.ID).Delete(&domain.PersonalizationField{}).Error; err != nil {
+			return err
+		}
 		if err := tx.Delete(&domain.Product{}, "id = ?", p.ID).Error; err != nil {
 			return err
 		}
@@ -184,3 +210,147 @@ func (r *ProductRepo) DistinctCategories(ctx context.Context) ([]string, error)
 	}
 	return cats, nil
 }
+
+func (r *ProductRepo) ImageURLs(ctx context.Context) ([]string, error) {
+	urls := []string{}
+	if err := r.db.WithContext(ctx).Model(&domain.Image{}).Distinct("url").Pluck("url", &urls).Error; err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+func (r *ProductRepo) ReplaceImageURL(ctx context.Context, from, to string) (int64, error) {
+	res := r.db.WithContext(ctx).Model(&domain.Image{}).Where("url = ?", from).Update("url", to)
+	return res.RowsAffected, res.Error
+}
+
+func (r *ProductRepo) CountImagesByURL(ctx context.Context, url string) (int64, error) {
+	// la misma ruta puede estar guardada con o sin "/" adelante
+	key := strings.TrimPrefix(strings.TrimSpace(url), "/")
+	var n int64
+	err := r.db.WithContext(ctx).Model(&domain.Image{}).Where("url IN ?", []string{key, "/" + key}).Count(&n).Error
+	return n, err
+}
+
+func (r *ProductRepo) FindImageByURL(ctx context.Context, url string) (*domain.Image, error) {
+	var img domain.Image
+	if err := r.db.WithContext(ctx).Where("url = ?", url).Order("created_at asc").First(&img).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &img, nil
+}
+
+// UpdateImage guarda URL, alt y recorte/foco de una imagen existente.
+func (r *ProductRepo) UpdateImage(ctx context.Context, img *domain.Image) error {
+	res := r.db.WithContext(ctx).Model(&domain.Image{}).Where("id = ?", img.ID).Updates(map[string]any{
+		"url":     img.URL,
+		"alt":     img.Alt,
+		"crop_x":  img.CropX,
+		"crop_y":  img.CropY,
+		"crop_w":  img.CropW,
+		"crop_h":  img.CropH,
+		"focal_x": img.FocalX,
+		"focal_y": img.FocalY,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// ReorderImages asigna position según el orden de ids; las imágenes no listadas quedan al final.
+func (r *ProductRepo) ReorderImages(ctx context.Context, productID uuid.UUID, ids []uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current []domain.Image
+		if err := tx.Where("product_id = ?", productID).Order("position asc, created_at asc").Find(&current).Error; err != nil {
+			return err
+		}
+		seen := map[uuid.UUID]bool{}
+		order := make([]uuid.UUID, 0, len(current))
+		valid := map[uuid.UUID]bool{}
+		for _, im := range current {
+			valid[im.ID] = true
+		}
+		for _, id := range ids {
+			if valid[id] && !seen[id] {
+				seen[id] = true
+				order = append(order, id)
+			}
+		}
+		for _, im := range current {
+			if !seen[im.ID] {
+				order = append(order, im.ID)
+			}
+		}
+		for i, id := range order {
+			if err := tx.Model(&domain.Image{}).Where("id = ?", id).Update("position", i).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *ProductRepo) SetPrimaryImage(ctx context.Context, productID, imageID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&domain.Image{}).Where("id = ? AND product_id = ?", imageID, productID).Update("is_primary", true)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return domain.ErrNotFound
+		}
+		return tx.Model(&domain.Image{}).Where("product_id = ? AND id <> ?", productID, imageID).Update("is_primary", false).Error
+	})
+}
+
+func (r *ProductRepo) SavePersonalizationField(ctx context.Context, f *domain.PersonalizationField) error {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+		var maxPos *int
+		if err := r.db.WithContext(ctx).Model(&domain.PersonalizationField{}).Where("product_id = ?", f.ProductID).
+			Select("MAX(position)").Scan(&maxPos).Error; err != nil {
+			return err
+		}
+		if maxPos != nil {
+			f.Position = *maxPos + 1
+		}
+		if f.CreatedAt.IsZero() {
+			f.CreatedAt = time.Now()
+		}
+		return r.db.WithContext(ctx).Create(f).Error
+	}
+	res := r.db.WithContext(ctx).Model(&domain.PersonalizationField{}).Where("id = ? AND product_id = ?", f.ID, f.ProductID).Updates(map[string]any{
+		"label":      f.Label,
+		"kind":       f.Kind,
+		"required":   f.Required,
+		"max_length": f.MaxLength,
+		"options":    f.Options,
+		"surcharge":  f.Surcharge,
+		"position":   f.Position,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *ProductRepo) DeletePersonalizationField(ctx context.Context, productID, fieldID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Where("id = ? AND product_id = ?", fieldID, productID).Delete(&domain.PersonalizationField{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
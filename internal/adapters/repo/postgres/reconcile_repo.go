@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type ReconcileIssueRepo struct{ db *gorm.DB }
+
+func NewReconcileIssueRepo(db *gorm.DB) *ReconcileIssueRepo { return &ReconcileIssueRepo{db: db} }
+
+func (r *ReconcileIssueRepo) Save(ctx context.Context, i *domain.ReconcileIssue) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "order_id"}, {Name: "payment_id"}, {Name: "kind"}},
+		DoUpdates: clause.AssignmentColumns([]string{"order_status", "mp_status", "amount", "detail", "fixed", "updated_at"}),
+	}).Create(i).Error
+}
+
+func (r *ReconcileIssueRepo) ListOpen(ctx context.Context, limit int) ([]domain.ReconcileIssue, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+	var list []domain.ReconcileIssue
+	if err := r.db.WithContext(ctx).Where("dismissed_at IS NULL").Order("created_at desc").Limit(limit).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *ReconcileIssueRepo) Dismiss(ctx context.Context, id uuid.UUID, actor string, at time.Time) error {
+	res := r.db.WithContext(ctx).Model(&domain.ReconcileIssue{}).Where("id = ? AND dismissed_at IS NULL", id).
+		Updates(map[string]any{"dismissed_by": actor, "dismissed_at": at})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type RefundRepo struct{ db *gorm.DB }
+
+func NewRefundRepo(db *gorm.DB) *RefundRepo { return &RefundRepo{db: db} }
+
+func (r *RefundRepo) Save(ctx context.Context, rf *domain.Refund) error {
+	if rf.ID == uuid.Nil {
+		rf.ID = uuid.New()
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "gateway_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "actor", "source", "status"}),
+	}).Create(rf).Error
+}
+
+func (r *RefundRepo) FindByGatewayID(ctx context.Context, gatewayID string) (*domain.Refund, error) {
+	var rf domain.Refund
+	if err := r.db.WithContext(ctx).Where("gateway_id = ?", gatewayID).First(&rf).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &rf, nil
+}
+
+func (r *RefundRepo) ListByOrders(ctx context.Context, orderIDs []uuid.UUID) ([]domain.Refund, error) {
+	if len(orderIDs) == 0 {
+		return nil, nil
+	}
+	var list []domain.Refund
+	if err := r.db.WithContext(ctx).Where("order_id IN ?", orderIDs).Order("created_at asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
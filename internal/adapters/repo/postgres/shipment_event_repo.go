@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type ShipmentEventRepo struct{ db *gorm.DB }
+
+func NewShipmentEventRepo(db *gorm.DB) *ShipmentEventRepo { return &ShipmentEventRepo{db: db} }
+
+func (r *ShipmentEventRepo) Add(ctx context.Context, e *domain.TrackingEvent) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return r.db.WithContext(ctx).Create(e).Error
+}
+
+func (r *ShipmentEventRepo) ListByOrder(ctx context.Context, orderID uuid.UUID) ([]domain.TrackingEvent, error) {
+	var list []domain.TrackingEvent
+	if err := r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("occurred_at asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
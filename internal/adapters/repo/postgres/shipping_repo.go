@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type ShippingZoneRepo struct{ db *gorm.DB }
+
+func NewShippingZoneRepo(db *gorm.DB) *ShippingZoneRepo { return &ShippingZoneRepo{db: db} }
+
+func orderRates(db *gorm.DB) *gorm.DB {
+	return db.Order("max_grams = 0, max_grams asc, max_side_mm = 0, max_side_mm asc")
+}
+
+func (r *ShippingZoneRepo) List(ctx context.Context) ([]domain.ShippingZone, error) {
+	var list []domain.ShippingZone
+	if err := r.db.WithContext(ctx).Preload("Rates", orderRates).Order("priority desc, name asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *ShippingZoneRepo) FindByID(ctx context.Context, id uuid.UUID) (*domain.ShippingZone, error) {
+	var z domain.ShippingZone
+	if err := r.db.WithContext(ctx).Preload("Rates", orderRates).First(&z, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &z, nil
+}
+
+func (r *ShippingZoneRepo) Save(ctx context.Context, z *domain.ShippingZone) error {
+	now := time.Now()
+	if z.ID == uuid.Nil {
+		z.ID = uuid.New()
+		z.CreatedAt = now
+	}
+	z.UpdatedAt = now
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Rates").Save(z).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("zone_id = ?", z.ID).Delete(&domain.ShippingRate{}).Error; err != nil {
+			return err
+		}
+		for i := range z.Rates {
+			z.Rates[i].ID = uuid.New()
+			z.Rates[i].ZoneID = z.ID
+		}
+		if len(z.Rates) == 0 {
+			return nil
+		}
+		return tx.Create(&z.Rates).Error
+	})
+}
+
+func (r *ShippingZoneRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("zone_id = ?", id).Delete(&domain.ShippingRate{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id = ?", id).Delete(&domain.ShippingZone{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return domain.ErrNotFound
+		}
+		return nil
+	})
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type StorageGCRepo struct{ db *gorm.DB }
+
+func NewStorageGCRepo(db *gorm.DB) *StorageGCRepo { return &StorageGCRepo{db: db} }
+
+// ReplaceDetected reemplaza el último reporte (ítems en estado detected) por items.
+func (r *StorageGCRepo) ReplaceDetected(ctx context.Context, items []domain.StorageGCItem) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("status = ?", domain.StorageGCDetected).Delete(&domain.StorageGCItem{}).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for i := range items {
+			if items[i].ID == uuid.Nil {
+				items[i].ID = uuid.New()
+			}
+		}
+		return tx.CreateInBatches(&items, 200).Error
+	})
+}
+
+func (r *StorageGCRepo) List(ctx context.Context, status string) ([]domain.StorageGCItem, error) {
+	var list []domain.StorageGCItem
+	q := r.db.WithContext(ctx).Model(&domain.StorageGCItem{})
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if err := q.Order("size desc, path asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *StorageGCRepo) FindByID(ctx context.Context, id uuid.UUID) (*domain.StorageGCItem, error) {
+	var it domain.StorageGCItem
+	if err := r.db.WithContext(ctx).First(&it, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &it, nil
+}
+
+func (r *StorageGCRepo) Save(ctx context.Context, it *domain.StorageGCItem) error {
+	if it.ID == uuid.Nil {
+		it.ID = uuid.New()
+	}
+	return r.db.WithContext(ctx).Save(it).Error
+}
+
+func (r *StorageGCRepo) ListQuarantinedBefore(ctx context.Context, before time.Time) ([]domain.StorageGCItem, error) {
+	var list []domain.StorageGCItem
+	if err := r.db.WithContext(ctx).
+		Where("status = ? AND quarantined_at < ?", domain.StorageGCQuarantined, before).
+		Order("quarantined_at asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type TransferProofRepo struct{ db *gorm.DB }
+
+func NewTransferProofRepo(db *gorm.DB) *TransferProofRepo { return &TransferProofRepo{db: db} }
+
+func (r *TransferProofRepo) Save(ctx context.Context, p *domain.TransferProof) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return r.db.WithContext(ctx).Save(p).Error
+}
+
+func (r *TransferProofRepo) SaveIfPending(ctx context.Context, p *domain.TransferProof) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&domain.TransferProof{}).
+		Where("id = ? AND status = ?", p.ID, domain.TransferProofPending).
+		Select("*").Omit("id", "created_at").Updates(p)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (r *TransferProofRepo) FindByID(ctx context.Context, id uuid.UUID) (*domain.TransferProof, error) {
+	var p domain.TransferProof
+	if err := r.db.WithContext(ctx).First(&p, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *TransferProofRepo) ListByStatus(ctx context.Context, status string) ([]domain.TransferProof, error) {
+	var list []domain.TransferProof
+	if err := r.db.WithContext(ctx).Where("status = ?", status).Order("created_at asc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *TransferProofRepo) ListByOrder(ctx context.Context, orderID uuid.UUID) ([]domain.TransferProof, error) {
+	var list []domain.TransferProof
+	if err := r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("created_at desc").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type WebhookEventRepo struct{ db *gorm.DB }
+
+func NewWebhookEventRepo(db *gorm.DB) *WebhookEventRepo { return &WebhookEventRepo{db: db} }
+
+func (r *WebhookEventRepo) Create(ctx context.Context, e *domain.WebhookEvent) (bool, error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(e)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return true, nil
+	}
+	err := r.db.WithContext(ctx).Model(&domain.WebhookEvent{}).
+		Where("source = ? AND event_id = ?", e.Source, e.EventID).
+		Updates(map[string]any{"duplicates": gorm.Expr("duplicates + 1"), "updated_at": time.Now()}).Error
+	return false, err
+}
+
+func (r *WebhookEventRepo) Save(ctx context.Context, e *domain.WebhookEvent) error {
+	return r.db.WithContext(ctx).Save(e).Error
+}
+
+func (r *WebhookEventRepo) FindByID(ctx context.Context, id uuid.UUID) (*domain.WebhookEvent, error) {
+	var e domain.WebhookEvent
+	if err := r.db.WithContext(ctx).First(&e, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *WebhookEventRepo) ListDue(ctx context.Context, now time.Time, limit int) ([]domain.WebhookEvent, error) {
+	var list []domain.WebhookEvent
+	if err := r.db.WithContext(ctx).
+		Where("status IN ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", []string{domain.WebhookPending, domain.WebhookRetrying}, now).
+		Order("created_at asc").Limit(limit).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *WebhookEventRepo) List(ctx context.Context, f domain.WebhookEventFilter, page, pageSize int) ([]domain.WebhookEvent, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 50
+	}
+	q := r.db.WithContext(ctx).Model(&domain.WebhookEvent{})
+	if f.Source != "" {
+		q = q.Where("source = ?", f.Source)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []domain.WebhookEvent
+	if err := q.Order("created_at desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&list).Error; err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+func (r *WebhookEventRepo) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		N      int64
+	}
+	if err := r.db.WithContext(ctx).Model(&domain.WebhookEvent{}).Select("status, COUNT(*) AS n").Group("status").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		out[row.Status] = row.N
+	}
+	return out, nil
+}
+
+func (r *WebhookEventRepo) DeleteProcessedBefore(ctx context.Context, t time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).
+		Where("status IN ? AND created_at < ?", []string{domain.WebhookProcessed, domain.WebhookSkipped, domain.WebhookRejected}, t).
+		Delete(&domain.WebhookEvent{})
+	return res.RowsAffected, res.Error
+}
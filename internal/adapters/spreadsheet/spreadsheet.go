@@ -0,0 +1,124 @@
+// Package spreadsheet escribe planillas (CSV o XLSX) fila por fila directo al io.Writer, sin
+// armar el archivo en memoria.
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formatos soportados.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// DateLayout es el formato de las fechas en las celdas.
+const DateLayout = "2006-01-02 15:04:05"
+
+// Writer escribe una o más hojas; las celdas pueden ser string, int, int64, float64,
+// time.Time o nil (vacía).
+type Writer interface {
+	// Sheet empieza una hoja nueva con su fila de encabezados.
+	Sheet(name string, header []string) error
+	Row(cells ...any) error
+	// Close termina el archivo; no cierra el io.Writer de destino.
+	Close() error
+}
+
+// ErrSingleSheet: el CSV tiene una sola hoja.
+var ErrSingleSheet = errors.New("el CSV admite una sola hoja")
+
+// New devuelve el Writer del formato pedido.
+func New(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSV(w), nil
+	case FormatXLSX:
+		return NewXLSX(w), nil
+	}
+	return nil, fmt.Errorf("formato %q no soportado", format)
+}
+
+// ContentType devuelve el Content-Type del formato.
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+type csvWriter struct {
+	out    io.Writer
+	w      *csv.Writer
+	sheets int
+	rows   int
+}
+
+// NewCSV escribe un CSV separado por comas con BOM UTF-8 (así Excel respeta los acentos).
+func NewCSV(w io.Writer) Writer {
+	return &csvWriter{out: w, w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Sheet(_ string, header []string) error {
+	if c.sheets > 0 {
+		return ErrSingleSheet
+	}
+	c.sheets++
+	if _, err := io.WriteString(c.out, "\ufeff"); err != nil {
+		return err
+	}
+	return c.w.Write(header)
+}
+
+func (c *csvWriter) Row(cells ...any) error {
+	rec := make([]string, len(cells))
+	for i, v := range cells {
+		rec[i] = cellText(v)
+		// un texto que arranca con =, +, - o @ Excel lo toma como fórmula (nombres, direcciones);
+		// con tab o retorno adelante también, porque los saltea al interpretar la celda
+		if _, ok := v.(string); ok && rec[i] != "" && strings.ContainsRune("=+-@\t\r", rune(rec[i][0])) {
+			rec[i] = "'" + rec[i]
+		}
+	}
+	if err := c.w.Write(rec); err != nil {
+		return err
+	}
+	// se vuelca cada tanda para que la descarga avance mientras se recorren las órdenes
+	if c.rows++; c.rows%500 == 0 {
+		c.w.Flush()
+	}
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// cellText convierte una celda a texto.
+func cellText(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case int:
+		return strconv.Itoa(x)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'f', 2, 64)
+	case time.Time:
+		if x.IsZero() {
+			return ""
+		}
+		return x.Format(DateLayout)
+	}
+	return fmt.Sprint(v)
+}
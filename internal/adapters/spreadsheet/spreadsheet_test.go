@@ -0,0 +1,114 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSV(&buf)
+	if err := w.Sheet("", []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	in := []string{"=1+1", "+54 11", "-3", "@SUM(A1)", "\t=1+1", "\r=1+1", "Juan", ""}
+	for _, s := range in {
+		if err := w.Row(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Row(-3.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "\ufeffa\n'=1+1\n'+54 11\n'-3\n'@SUM(A1)\n'\t=1+1\n\"'\r=1+1\"\nJuan\n\n-3.50\n"
+	if got := buf.String(); got != want {
+		t.Errorf("csv =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestExcelSerial(t *testing.T) {
+	cases := []struct {
+		t    time.Time
+		want float64
+	}{
+		{time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC), 0},
+		{time.Date(1900, 3, 1, 0, 0, 0, 0, time.UTC), 61},
+		{time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC), 45730.5},
+		// la hora de pared en su zona, igual que el CSV
+		{time.Date(2025, 3, 14, 18, 0, 0, 0, time.FixedZone("ART", -3*3600)), 45730.75},
+	}
+	for _, c := range cases {
+		if got := excelSerial(c.t); got != c.want {
+			t.Errorf("excelSerial(%v) = %v, want %v", c.t, got, c.want)
+		}
+	}
+}
+
+func TestXLSXWritesDatesAsNumbers(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewXLSX(&buf)
+	if err := w.Sheet("Órdenes", []string{"fecha", "pagada"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Row(time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sheet := readZipPart(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	if !strings.Contains(sheet, `<c s="3"><v>45730.5</v></c><c/>`) {
+		t.Errorf("la fecha no quedó como número con estilo 3:\n%s", sheet)
+	}
+
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs struct {
+			Count int `xml:"count,attr"`
+			Xf    []struct {
+				NumFmtID int `xml:"numFmtId,attr"`
+			} `xml:"xf"`
+		} `xml:"cellXfs"`
+	}
+	if err := xml.Unmarshal([]byte(readZipPart(t, buf.Bytes(), "xl/styles.xml")), &styles); err != nil {
+		t.Fatal(err)
+	}
+	if styles.CellXfs.Count != len(styles.CellXfs.Xf) || len(styles.CellXfs.Xf) < 4 {
+		t.Fatalf("cellXfs count=%d con %d xf", styles.CellXfs.Count, len(styles.CellXfs.Xf))
+	}
+	id := styles.CellXfs.Xf[3].NumFmtID
+	if id != 164 || len(styles.NumFmts) != 1 || styles.NumFmts[0].ID != id || styles.NumFmts[0].Code != "yyyy-mm-dd hh:mm:ss" {
+		t.Errorf("estilo 3 con numFmtId %d, numFmts %+v", id, styles.NumFmts)
+	}
+}
+
+func readZipPart(t *testing.T, b []byte, name string) string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := zr.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	body, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
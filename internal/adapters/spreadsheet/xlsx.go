@@ -0,0 +1,199 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xlsxWriter arma un XLSX mínimo (SpreadsheetML) escribiendo cada hoja como una entrada del
+// zip a medida que llegan las filas; el libro y sus relaciones se agregan al cerrar, cuando ya
+// se conocen todas las hojas. Los textos van como inline strings para no tener que juntar la
+// tabla de strings compartidos.
+type xlsxWriter struct {
+	zw     *zip.Writer
+	sheet  io.Writer
+	sheets []string
+	err    error
+}
+
+// NewXLSX escribe un libro de Excel con una hoja por cada llamada a Sheet.
+func NewXLSX(w io.Writer) Writer {
+	return &xlsxWriter{zw: zip.NewWriter(w)}
+}
+
+func (x *xlsxWriter) Sheet(name string, header []string) error {
+	if x.err != nil {
+		return x.err
+	}
+	x.endSheet()
+	x.sheets = append(x.sheets, sheetName(name, len(x.sheets)+1))
+	x.sheet, x.err = x.zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(x.sheets)))
+	if x.err != nil {
+		return x.err
+	}
+	x.write(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+		`<sheetData>`)
+	x.write(`<row>`)
+	for _, h := range header {
+		// s="1": encabezado en negrita (ver xlsxStyles)
+		x.write(`<c t="inlineStr" s="1"><is><t xml:space="preserve">`)
+		x.escape(h)
+		x.write(`</t></is></c>`)
+	}
+	x.write(`</row>`)
+	return x.err
+}
+
+func (x *xlsxWriter) Row(cells ...any) error {
+	if x.err != nil {
+		return x.err
+	}
+	if x.sheet == nil {
+		return fmt.Errorf("fila sin hoja")
+	}
+	x.write(`<row>`)
+	for _, v := range cells {
+		switch n := v.(type) {
+		case nil:
+			x.write(`<c/>`)
+		case int:
+			x.write(`<c><v>` + strconv.Itoa(n) + `</v></c>`)
+		case int64:
+			x.write(`<c><v>` + strconv.FormatInt(n, 10) + `</v></c>`)
+		case float64:
+			// s="2": número con dos decimales
+			x.write(`<c s="2"><v>` + strconv.FormatFloat(n, 'f', -1, 64) + `</v></c>`)
+		case time.Time:
+			if n.IsZero() {
+				x.write(`<c/>`)
+				continue
+			}
+			// s="3": fecha y hora; Excel guarda las fechas como número de serie
+			x.write(`<c s="3"><v>` + strconv.FormatFloat(excelSerial(n), 'f', -1, 64) + `</v></c>`)
+		default:
+			x.write(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			x.escape(cellText(v))
+			x.write(`</t></is></c>`)
+		}
+	}
+	x.write(`</row>`)
+	return x.err
+}
+
+func (x *xlsxWriter) Close() error {
+	if x.err != nil {
+		return x.err
+	}
+	if len(x.sheets) == 0 {
+		// un libro sin hojas no abre en Excel
+		if err := x.Sheet("Hoja1", nil); err != nil {
+			return err
+		}
+	}
+	x.endSheet()
+
+	var ct, wb, rels strings.Builder
+	ct.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	wb.WriteString(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i, name := range x.sheets {
+		n := i + 1
+		fmt.Fprintf(&ct, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		var esc strings.Builder
+		_ = xml.EscapeText(&esc, []byte(name))
+		fmt.Fprintf(&wb, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, esc.String(), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+	}
+	ct.WriteString(`</Types>`)
+	wb.WriteString(`</sheets></workbook>`)
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`, len(x.sheets)+1)
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", ct.String()},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", wb.String()},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, p := range parts {
+		f, err := x.zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			return err
+		}
+	}
+	return x.zw.Close()
+}
+
+// xlsxStyles: 0 normal, 1 negrita (encabezados), 2 número "#,##0.00", 3 fecha (formato propio 164,
+// el mismo DateLayout que el CSV).
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="4"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>` +
+	`</styleSheet>`
+
+// excelEpoch es el día 0 de las fechas de Excel (sistema 1900, con su 29/02/1900 inexistente).
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// excelSerial convierte la fecha y hora de t (en su propia zona, como la muestra el CSV) al número
+// de serie de Excel: días desde excelEpoch más la fracción del día.
+func excelSerial(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return float64(wall.Unix()-excelEpoch.Unix()) / 86400
+}
+
+func (x *xlsxWriter) endSheet() {
+	if x.sheet != nil && x.err == nil {
+		x.write(`</sheetData></worksheet>`)
+	}
+	x.sheet = nil
+}
+
+func (x *xlsxWriter) write(s string) {
+	if x.err == nil {
+		_, x.err = io.WriteString(x.sheet, s)
+	}
+}
+
+// escape escribe s como texto XML (los caracteres inválidos en XML quedan como U+FFFD).
+func (x *xlsxWriter) escape(s string) {
+	if x.err == nil {
+		x.err = xml.EscapeText(x.sheet, []byte(s))
+	}
+}
+
+// sheetName limpia el nombre de la hoja: Excel no acepta []:*?/\ ni más de 31 caracteres.
+func sheetName(name string, n int) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	if name == "" {
+		name = "Hoja" + strconv.Itoa(n)
+	}
+	return name
+}
@@ -2,16 +2,26 @@ package localfs
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/phenrril/tienda3d/internal/domain"
 )
 
 type Storage struct{ base string }
 
 func New(base string) *Storage { return &Storage{base: base} }
 
+// Base devuelve el directorio raíz donde se guardan los archivos.
+func (s *Storage) Base() string { return s.base }
+
 func (s *Storage) SaveModel(ctx context.Context, filename string, data []byte) (string, error) {
 	return s.save(ctx, "models", filename, data)
 }
@@ -20,16 +30,213 @@ func (s *Storage) SaveImage(ctx context.Context, filename string, data []byte) (
 	return s.save(ctx, "images", filename, data)
 }
 
+func (s *Storage) SaveAttachment(ctx context.Context, filename string, data []byte) (string, error) {
+	return s.save(ctx, "attachments", filename, data)
+}
+
+func (s *Storage) SaveAttachmentFrom(ctx context.Context, filename string, r io.Reader) (string, error) {
+	path, err := s.newPath("attachments", filename)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
 func (s *Storage) save(ctx context.Context, sub, filename string, data []byte) (string, error) {
 	_ = ctx
-	dir := filepath.Join(s.base, sub)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	path, err := s.newPath(sub, filename)
+	if err != nil {
 		return "", err
 	}
-	fname := fmt.Sprintf("%d-%s", time.Now().UnixNano(), filename)
-	path := filepath.Join(dir, fname)
 	if err := os.WriteFile(path, data, 0644); err != nil {
 		return "", err
 	}
 	return path, nil
 }
+
+// newPath crea el subdirectorio y arma la ruta de un archivo nuevo con prefijo de timestamp.
+func (s *Storage) newPath(sub, filename string) (string, error) {
+	dir := filepath.Join(s.base, sub)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	fname := fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(filename))
+	return filepath.Join(dir, fname), nil
+}
+
+// Put guarda data bajo la clave indicada (relativa a base), pisando el archivo si existe.
+// Escribe a un temporal y renombra para que un lector concurrente no vea el archivo a medias.
+func (s *Storage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	full, err := s.resolve(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return err
+	}
+	tmp := full + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, full)
+}
+
+func (s *Storage) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	full, err := s.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	if fi, err := f.Stat(); err == nil && fi.IsDir() {
+		_ = f.Close()
+		return nil, domain.ErrNotFound
+	}
+	return f, nil
+}
+
+func (s *Storage) Delete(ctx context.Context, p string) error {
+	full, err := s.resolve(p)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(full); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return domain.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) Exists(ctx context.Context, p string) (bool, error) {
+	full, err := s.resolve(p)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	fi, err := os.Stat(full)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return fi.Mode().IsRegular(), nil
+}
+
+func (s *Storage) List(ctx context.Context, prefix string) ([]domain.StoredFile, error) {
+	root := s.base
+	if strings.Trim(prefix, "/") != "" {
+		full, err := s.resolve(prefix)
+		if err != nil {
+			return nil, err
+		}
+		root = full
+	}
+	out := []domain.StoredFile{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil || !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.base, p)
+		if err != nil {
+			return nil
+		}
+		out = append(out, domain.StoredFile{Path: "/uploads/" + filepath.ToSlash(rel), Size: fi.Size(), ModTime: fi.ModTime()})
+		return nil
+	})
+	return out, err
+}
+
+func (s *Storage) Move(ctx context.Context, from, to string) error {
+	src, err := s.resolve(from)
+	if err != nil {
+		return err
+	}
+	dst, err := s.resolve(to)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return domain.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Key convierte una ruta guardada ("uploads/images/x.jpg", "/uploads/images/x.jpg"
+// o la ruta con STORAGE_DIR) en la clave relativa al directorio base ("images/x.jpg").
+func (s *Storage) Key(p string) (string, bool) {
+	v := strings.ReplaceAll(strings.TrimSpace(p), "\\", "/")
+	if v == "" {
+		return "", false
+	}
+	low := strings.ToLower(v)
+	if strings.HasPrefix(low, "http://") || strings.HasPrefix(low, "https://") {
+		return "", false
+	}
+	if i := strings.IndexAny(v, "?#"); i >= 0 {
+		v = v[:i]
+	}
+	base := strings.TrimSuffix(filepath.ToSlash(filepath.Clean(s.base)), "/")
+	switch {
+	case base != "" && base != "." && strings.HasPrefix(v, base+"/"):
+		v = strings.TrimPrefix(v, base+"/")
+	case strings.HasPrefix(v, "/"+base+"/"):
+		v = strings.TrimPrefix(v, "/"+base+"/")
+	case strings.HasPrefix(v, "/uploads/"):
+		v = strings.TrimPrefix(v, "/uploads/")
+	case strings.HasPrefix(v, "uploads/"):
+		v = strings.TrimPrefix(v, "uploads/")
+	default:
+		v = strings.TrimPrefix(v, "/")
+	}
+	clean := path.Clean("/" + v)
+	if clean == "/" || strings.Contains(v, "..") {
+		return "", false
+	}
+	return strings.TrimPrefix(clean, "/"), true
+}
+
+func (s *Storage) resolve(p string) (string, error) {
+	key, ok := s.Key(p)
+	if !ok {
+		return "", domain.ErrNotFound
+	}
+	return filepath.Join(s.base, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,493 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// Config describe un bucket compatible con S3 (AWS, MinIO, R2, etc).
+type Config struct {
+	Endpoint  string // ej: https://s3.us-east-1.amazonaws.com o http://minio:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // true para MinIO y la mayoría de compatibles
+}
+
+// ConfigFromEnv lee S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY, S3_SECRET_KEY y S3_PATH_STYLE.
+func ConfigFromEnv() Config {
+	c := Config{
+		Endpoint:  strings.TrimRight(strings.TrimSpace(os.Getenv("S3_ENDPOINT")), "/"),
+		Region:    strings.TrimSpace(os.Getenv("S3_REGION")),
+		Bucket:    strings.TrimSpace(os.Getenv("S3_BUCKET")),
+		AccessKey: strings.TrimSpace(os.Getenv("S3_ACCESS_KEY")),
+		SecretKey: strings.TrimSpace(os.Getenv("S3_SECRET_KEY")),
+		PathStyle: true,
+	}
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = "https://s3." + c.Region + ".amazonaws.com"
+	}
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("S3_PATH_STYLE"))); v == "0" || v == "false" || v == "no" {
+		c.PathStyle = false
+	}
+	return c
+}
+
+// Storage implementa domain.FileStorage sobre un bucket S3. Las rutas devueltas
+// mantienen el formato "/uploads/<sub>/<archivo>" para que las URLs guardadas
+// sigan sirviéndose por /uploads/ sin importar el backend.
+type Storage struct {
+	cfg        Config
+	endpoint   *url.URL
+	httpClient *http.Client
+
+	// cache de Exists: los listados filtran imágenes por existencia en cada request.
+	mu     sync.Mutex
+	exists map[string]existsEntry
+}
+
+type existsEntry struct {
+	ok  bool
+	exp time.Time
+}
+
+const existsTTL = 5 * time.Minute
+
+func New(cfg Config) (*Storage, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("S3_BUCKET requerido")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("S3_ACCESS_KEY / S3_SECRET_KEY requeridos")
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT inválido: %q", cfg.Endpoint)
+	}
+	return &Storage{
+		cfg:        cfg,
+		endpoint:   u,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+		exists:     map[string]existsEntry{},
+	}, nil
+}
+
+func (s *Storage) SaveModel(ctx context.Context, filename string, data []byte) (string, error) {
+	return s.save(ctx, "models", filename, data)
+}
+
+func (s *Storage) SaveImage(ctx context.Context, filename string, data []byte) (string, error) {
+	return s.save(ctx, "images", filename, data)
+}
+
+func (s *Storage) SaveAttachment(ctx context.Context, filename string, data []byte) (string, error) {
+	return s.save(ctx, "attachments", filename, data)
+}
+
+// SaveAttachmentFrom pasa r por un temporal en disco: la firma V4 necesita el hash y el largo
+// del cuerpo antes de mandarlo.
+func (s *Storage) SaveAttachmentFrom(ctx context.Context, filename string, r io.Reader) (string, error) {
+	tmp, err := os.CreateTemp("", "s3-upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	key := newKey("attachments", filename)
+	resp, err := s.send(ctx, http.MethodPut, key, nil, io.NewSectionReader(tmp, 0, size), size, hex.EncodeToString(h.Sum(nil)),
+		map[string]string{"Content-Type": "application/octet-stream"})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", s.respError("put", key, resp)
+	}
+	s.remember(key, true)
+	return "/uploads/" + key, nil
+}
+
+func (s *Storage) save(ctx context.Context, sub, filename string, data []byte) (string, error) {
+	key := newKey(sub, filename)
+	if err := s.Put(ctx, key, data, ""); err != nil {
+		return "", err
+	}
+	return "/uploads/" + key, nil
+}
+
+func newKey(sub, filename string) string {
+	return sub + "/" + fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(filename))
+}
+
+// Put sube data bajo la clave indicada. contentType vacío se infiere del contenido.
+func (s *Storage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, data, map[string]string{"Content-Type": contentType})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return s.respError("put", key, resp)
+	}
+	s.remember(key, true)
+	return nil
+}
+
+func (s *Storage) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	key, ok := Key(p)
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, domain.ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, s.respError("get", key, resp)
+	}
+	return resp.Body, nil
+}
+
+func (s *Storage) Delete(ctx context.Context, p string) error {
+	key, ok := Key(p)
+	if !ok {
+		return domain.ErrNotFound
+	}
+	exists, err := s.Exists(ctx, p)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return domain.ErrNotFound
+	}
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return s.respError("delete", key, resp)
+	}
+	s.remember(key, false)
+	return nil
+}
+
+func (s *Storage) Exists(ctx context.Context, p string) (bool, error) {
+	key, ok := Key(p)
+	if !ok {
+		return false, nil
+	}
+	s.mu.Lock()
+	e, hit := s.exists[key]
+	s.mu.Unlock()
+	if hit && time.Now().Before(e.exp) {
+		return e.ok, nil
+	}
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		s.remember(key, false)
+		return false, nil
+	case resp.StatusCode/100 == 2:
+		s.remember(key, true)
+		return true, nil
+	}
+	return false, s.respError("head", key, resp)
+}
+
+func (s *Storage) remember(key string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.exists) > 10000 {
+		s.exists = map[string]existsEntry{}
+	}
+	s.exists[key] = existsEntry{ok: ok, exp: time.Now().Add(existsTTL)}
+}
+
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *Storage) List(ctx context.Context, prefix string) ([]domain.StoredFile, error) {
+	pfx := strings.Trim(strings.TrimPrefix(strings.Trim(prefix, "/"), "uploads"), "/")
+	if pfx != "" {
+		pfx += "/"
+	}
+	out := []domain.StoredFile{}
+	token := ""
+	for {
+		q := url.Values{}
+		q.Set("list-type", "2")
+		if pfx != "" {
+			q.Set("prefix", pfx)
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := s.doQuery(ctx, http.MethodGet, "", q, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode/100 != 2 {
+			err := s.respError("list", pfx, resp)
+			resp.Body.Close()
+			return nil, err
+		}
+		var lr listResult
+		err = xml.NewDecoder(resp.Body).Decode(&lr)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range lr.Contents {
+			if strings.HasSuffix(c.Key, "/") {
+				continue
+			}
+			out = append(out, domain.StoredFile{Path: "/uploads/" + c.Key, Size: c.Size, ModTime: c.LastModified})
+		}
+		if !lr.IsTruncated || lr.NextContinuationToken == "" {
+			break
+		}
+		token = lr.NextContinuationToken
+	}
+	return out, nil
+}
+
+// Move copia el objeto a la nueva clave y borra el original (S3 no tiene rename).
+func (s *Storage) Move(ctx context.Context, from, to string) error {
+	src, ok := Key(from)
+	if !ok {
+		return domain.ErrNotFound
+	}
+	dst, ok := Key(to)
+	if !ok {
+		return domain.ErrNotFound
+	}
+	srcPath := "/" + s.cfg.Bucket + "/" + src
+	resp, err := s.do(ctx, http.MethodPut, dst, nil, map[string]string{"X-Amz-Copy-Source": uriEncode(srcPath, false)})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return domain.ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		return s.respError("copy", src, resp)
+	}
+	s.remember(dst, true)
+	return s.Delete(ctx, from)
+}
+
+// Key convierte una ruta guardada ("/uploads/images/x.jpg", "uploads/images/x.jpg")
+// en la clave del objeto ("images/x.jpg").
+func Key(p string) (string, bool) {
+	v := strings.ReplaceAll(strings.TrimSpace(p), "\\", "/")
+	if v == "" {
+		return "", false
+	}
+	low := strings.ToLower(v)
+	if strings.HasPrefix(low, "http://") || strings.HasPrefix(low, "https://") {
+		return "", false
+	}
+	if i := strings.IndexAny(v, "?#"); i >= 0 {
+		v = v[:i]
+	}
+	v = strings.TrimPrefix(v, "/")
+	v = strings.TrimPrefix(v, "uploads/")
+	if v == "" || strings.Contains(v, "..") {
+		return "", false
+	}
+	return strings.TrimPrefix(path.Clean("/"+v), "/"), true
+}
+
+func (s *Storage) respError(op, key string, resp *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("s3 %s %s: status %d: %s", op, key, resp.StatusCode, strings.TrimSpace(string(b)))
+}
+
+func (s *Storage) objectURL(key string) (host, uriPath string) {
+	host = s.endpoint.Host
+	base := strings.TrimRight(s.endpoint.Path, "/")
+	if s.cfg.PathStyle {
+		uriPath = base + "/" + s.cfg.Bucket + "/" + key
+	} else {
+		host = s.cfg.Bucket + "." + host
+		uriPath = base + "/" + key
+	}
+	return host, uriPath
+}
+
+func (s *Storage) do(ctx context.Context, method, key string, body []byte, headers map[string]string) (*http.Response, error) {
+	return s.doQuery(ctx, method, key, nil, body, headers)
+}
+
+func (s *Storage) doQuery(ctx context.Context, method, key string, query url.Values, body []byte, headers map[string]string) (*http.Response, error) {
+	sum := sha256.Sum256(body)
+	return s.send(ctx, method, key, query, bytes.NewReader(body), int64(len(body)), hex.EncodeToString(sum[:]), headers)
+}
+
+// send arma, firma y manda la request; payloadHash es el sha256 en hex de los size bytes de body.
+func (s *Storage) send(ctx context.Context, method, key string, query url.Values, body io.Reader, size int64, payloadHash string, headers map[string]string) (*http.Response, error) {
+	host, uriPath := s.objectURL(key)
+	escaped := uriEncode(uriPath, false)
+	canonQuery := canonicalQuery(query)
+	u := s.endpoint.Scheme + "://" + host + escaped
+	if canonQuery != "" {
+		u += "?" + canonQuery
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	s.sign(req, host, escaped, canonQuery, payloadHash, time.Now().UTC())
+	return s.httpClient.Do(req)
+}
+
+// sign firma la request con AWS Signature V4.
+func (s *Storage) sign(req *http.Request, host, escapedPath, canonQuery, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Host = host
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	hdrs := map[string]string{"host": host}
+	for k, vs := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || strings.HasPrefix(lk, "x-amz-") {
+			hdrs[lk] = strings.TrimSpace(strings.Join(vs, ","))
+		}
+	}
+	names := make([]string, 0, len(hdrs))
+	for k := range hdrs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + hdrs[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		escapedPath,
+		canonQuery,
+		canonHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	crh := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(crh[:])
+
+	k := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), day)
+	k = hmacSHA256(k, s.cfg.Region)
+	k = hmacSHA256(k, "s3")
+	k = hmacSHA256(k, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(k, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.cfg.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+sig)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func canonicalQuery(q url.Values) string {
+	if len(q) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode aplica el encoding de SigV4: sólo los caracteres no reservados quedan sin escapar.
+func uriEncode(v string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
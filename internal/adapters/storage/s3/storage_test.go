@@ -0,0 +1,302 @@
+package s3
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// fakeS3 es un bucket en memoria con lo mínimo que usa Storage: PUT (incluida la copia),
+// GET, HEAD, DELETE y ListObjectsV2 paginado de a pageSize claves.
+type fakeS3 struct {
+	t        *testing.T
+	bucket   string
+	pageSize int
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{t: t, bucket: bucket, pageSize: 2, objects: map[string]fakeObject{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AK/") {
+		http.Error(w, "missing signature", http.StatusForbidden)
+		return
+	}
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "payload hash mismatch", http.StatusBadRequest)
+		return
+	}
+	p, err := url.PathUnescape(r.URL.EscapedPath())
+	if err != nil || !strings.HasPrefix(p, "/"+f.bucket+"/") {
+		http.Error(w, "bad bucket", http.StatusBadRequest)
+		return
+	}
+	key := strings.TrimPrefix(p, "/"+f.bucket+"/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, r.URL.Query())
+	case r.Method == http.MethodPut:
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			src, _ = url.PathUnescape(src)
+			obj, ok := f.objects[strings.TrimPrefix(src, "/"+f.bucket+"/")]
+			if !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			f.objects[key] = obj
+			return
+		}
+		f.objects[key] = fakeObject{data: body, contentType: r.Header.Get("Content-Type")}
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(obj.data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, q url.Values) {
+	if q.Get("list-type") != "2" {
+		http.Error(w, "list-type", http.StatusBadRequest)
+		return
+	}
+	keys := []string{}
+	for k := range f.objects {
+		if strings.HasPrefix(k, q.Get("prefix")) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	start, _ := strconv.Atoi(q.Get("continuation-token"))
+	end := start + f.pageSize
+	if end > len(keys) {
+		end = len(keys)
+	}
+	type content struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	out := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []content
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}{}
+	for _, k := range keys[start:end] {
+		out.Contents = append(out.Contents, content{Key: k, Size: int64(len(f.objects[k].data)), LastModified: time.Now().UTC()})
+	}
+	if end < len(keys) {
+		out.IsTruncated = true
+		out.NextContinuationToken = strconv.Itoa(end)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(out)
+}
+
+func newTestStorage(t *testing.T) (*Storage, *fakeS3) {
+	t.Helper()
+	fake, srv := newFakeS3(t, "tienda")
+	st, err := New(Config{Endpoint: srv.URL, Region: "us-east-1", Bucket: "tienda", AccessKey: "AK", SecretKey: "SK", PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st, fake
+}
+
+func readAll(t *testing.T, st *Storage, p string) string {
+	t.Helper()
+	rc, err := st.Open(context.Background(), p)
+	if err != nil {
+		t.Fatalf("Open(%q): %v", p, err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestStorageRoundTrip(t *testing.T) {
+	st, fake := newTestStorage(t)
+	ctx := context.Background()
+
+	p, err := st.SaveImage(ctx, "../fotos/pieza final.jpg", []byte("jpeg bytes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(p, "/uploads/images/") || !strings.HasSuffix(p, "-pieza final.jpg") {
+		t.Fatalf("ruta inesperada %q", p)
+	}
+	if got := readAll(t, st, p); got != "jpeg bytes" {
+		t.Fatalf("contenido = %q", got)
+	}
+	if ok, err := st.Exists(ctx, p); err != nil || !ok {
+		t.Fatalf("Exists = %v, %v", ok, err)
+	}
+
+	if _, err := st.Open(ctx, "/uploads/images/no-existe.jpg"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("Open inexistente: %v", err)
+	}
+	if ok, err := st.Exists(ctx, "/uploads/images/no-existe.jpg"); err != nil || ok {
+		t.Fatalf("Exists inexistente = %v, %v", ok, err)
+	}
+
+	moved := "/uploads/quarantine/" + strings.TrimPrefix(p, "/uploads/")
+	if err := st.Move(ctx, p, moved); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, st, moved); got != "jpeg bytes" {
+		t.Fatalf("contenido movido = %q", got)
+	}
+	if ok, _ := st.Exists(ctx, p); ok {
+		t.Fatal("el original sigue existiendo después de Move")
+	}
+
+	if err := st.Delete(ctx, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Delete(ctx, moved); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("segundo Delete: %v", err)
+	}
+	if n := len(fake.objects); n != 0 {
+		t.Fatalf("quedaron %d objetos en el bucket", n)
+	}
+}
+
+func TestStoragePutFixedKey(t *testing.T) {
+	st, fake := newTestStorage(t)
+	ctx := context.Background()
+
+	if err := st.Put(ctx, "config/carousel.json", []byte(`{"items":["a"]}`), "application/json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Put(ctx, "config/carousel.json", []byte(`{"items":["b"]}`), "application/json"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, st, "config/carousel.json"); got != `{"items":["b"]}` {
+		t.Fatalf("contenido = %q", got)
+	}
+	if ct := fake.objects["config/carousel.json"].contentType; ct != "application/json" {
+		t.Fatalf("content-type = %q", ct)
+	}
+}
+
+func TestStorageSaveAttachmentFrom(t *testing.T) {
+	st, fake := newTestStorage(t)
+	ctx := context.Background()
+
+	body := strings.Repeat("orden\n", 10000)
+	p, err := st.SaveAttachmentFrom(ctx, "ordenes.jsonl.gz", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(p, "/uploads/attachments/") || !strings.HasSuffix(p, "-ordenes.jsonl.gz") {
+		t.Fatalf("ruta inesperada %q", p)
+	}
+	if got := readAll(t, st, p); got != body {
+		t.Fatalf("contenido de %d bytes, want %d", len(got), len(body))
+	}
+	if ct := fake.objects[strings.TrimPrefix(p, "/uploads/")].contentType; ct != "application/octet-stream" {
+		t.Fatalf("content-type = %q", ct)
+	}
+
+	if _, err := st.SaveAttachmentFrom(ctx, "roto.gz", io.MultiReader(strings.NewReader("a medias"), iotest.ErrReader(errors.New("lectura cortada")))); err == nil {
+		t.Fatal("un error al leer debería cortar la subida")
+	}
+	if n := len(fake.objects); n != 1 {
+		t.Fatalf("objetos en el bucket = %d, want 1", n)
+	}
+}
+
+func TestStorageListPaginates(t *testing.T) {
+	st, _ := newTestStorage(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg", "e.jpg"} {
+		if err := st.Put(ctx, "images/"+name, []byte(name), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.Put(ctx, "models/x.stl", []byte("solid"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := st.List(ctx, "uploads/images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range files {
+		got = append(got, f.Path)
+	}
+	want := "/uploads/images/a.jpg /uploads/images/b.jpg /uploads/images/c.jpg /uploads/images/d.jpg /uploads/images/e.jpg"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("List = %v", got)
+	}
+}
+
+func TestKey(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"/uploads/images/x.jpg", "images/x.jpg", true},
+		{"uploads/images/x.jpg?w=480", "images/x.jpg", true},
+		{"images//x.jpg", "images/x.jpg", true},
+		{"config/carousel.json", "config/carousel.json", true},
+		{"/uploads/../etc/passwd", "", false},
+		{"https://cdn.example.com/x.jpg", "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		got, ok := Key(c.in)
+		if got != c.want || ok != c.ok {
+			t.Errorf("Key(%q) = %q, %v; want %q, %v", c.in, got, ok, c.want, c.ok)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package whatsapp manda avisos a clientes por la API de WhatsApp Business (Cloud API).
+// Los mensajes que inicia el negocio tienen que ser plantillas aprobadas por Meta.
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+const graphURL = "https://graph.facebook.com/v19.0"
+
+// Notifier avisa los cambios de envío con la plantilla WHATSAPP_SHIPMENT_TEMPLATE, que recibe
+// cuatro parámetros: nombre, número de pedido, etapa y link (o número) de seguimiento.
+type Notifier struct {
+	token      string
+	phoneID    string
+	template   string
+	lang       string
+	httpClient *http.Client
+}
+
+// NewNotifierFromEnv devuelve nil si falta alguna variable: el aviso por WhatsApp queda apagado.
+func NewNotifierFromEnv() *Notifier {
+	n := &Notifier{
+		token:      strings.TrimSpace(os.Getenv("WHATSAPP_ACCESS_TOKEN")),
+		phoneID:    strings.TrimSpace(os.Getenv("WHATSAPP_PHONE_NUMBER_ID")),
+		template:   strings.TrimSpace(os.Getenv("WHATSAPP_SHIPMENT_TEMPLATE")),
+		lang:       strings.TrimSpace(os.Getenv("WHATSAPP_TEMPLATE_LANG")),
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+	if n.token == "" || n.phoneID == "" || n.template == "" {
+		return nil
+	}
+	if n.lang == "" {
+		n.lang = "es_AR"
+	}
+	return n
+}
+
+// normalizePhone lleva un teléfono argentino al formato internacional de WhatsApp (549 + área + número).
+func normalizePhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	switch {
+	case digits == "":
+		return ""
+	case strings.HasPrefix(digits, "549"):
+		return digits
+	case strings.HasPrefix(digits, "54"):
+		return "549" + digits[2:]
+	}
+	digits = strings.TrimPrefix(digits, "0")
+	// el "15" de los celulares va después del código de área; con 12 dígitos lo sacamos
+	if len(digits) == 12 {
+		for _, area := range []int{2, 3, 4} {
+			if digits[area:area+2] == "15" {
+				digits = digits[:area] + digits[area+2:]
+				break
+			}
+		}
+	}
+	return "549" + digits
+}
+
+func (n *Notifier) NotifyShipment(ctx context.Context, o *domain.Order, stage string) error {
+	to := normalizePhone(o.Phone)
+	if to == "" {
+		return nil
+	}
+	tracking := o.TrackingURL
+	if tracking == "" {
+		tracking = o.TrackingNumber
+	}
+	if tracking == "" {
+		tracking = "-"
+	}
+	param := func(v string) map[string]string { return map[string]string{"type": "text", "text": v} }
+	body := map[string]any{
+		"messaging_product": "whatsapp",
+		"to":                to,
+		"type":              "template",
+		"template": map[string]any{
+			"name":     n.template,
+			"language": map[string]string{"code": n.lang},
+			"components": []map[string]any{{
+				"type":       "body",
+				"parameters": []map[string]string{param(o.Name), param(o.Code()), param(domain.ShipmentStageLabel(stage)), param(tracking)},
+			}},
+		},
+	}
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, graphURL+"/"+n.phoneID+"/messages", bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+n.token)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 4<<10))
+		return fmt.Errorf("whatsapp status %d: %s", res.StatusCode, string(b))
+	}
+	return nil
+}
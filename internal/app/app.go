@@ -1,12 +1,15 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"html/template"
+	"math"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
@@ -16,9 +19,11 @@ import (
 
 	"github.com/phenrril/tienda3d/internal/adapters/email/smtp"
 	"github.com/phenrril/tienda3d/internal/adapters/httpserver"
+	"github.com/phenrril/tienda3d/internal/adapters/mesh3d"
 	"github.com/phenrril/tienda3d/internal/adapters/payments/mercadopago"
 	"github.com/phenrril/tienda3d/internal/adapters/repo/postgres"
 	"github.com/phenrril/tienda3d/internal/adapters/storage/localfs"
+	"github.com/phenrril/tienda3d/internal/adapters/storage/s3"
 	"github.com/phenrril/tienda3d/internal/domain"
 	"github.com/phenrril/tienda3d/internal/usecase"
 	"github.com/phenrril/tienda3d/internal/views"
@@ -32,6 +37,19 @@ type App struct {
 	OrderUC             *usecase.OrderUC
 	PaymentUC           *usecase.PaymentUC
 	WhatsAppUC          *usecase.WhatsAppUC
+	StorageGCUC         *usecase.StorageGCUC
+	ProductModelUC      *usecase.ProductModelUC
+	CartUC              *usecase.CartUC
+	ShippingUC          *usecase.ShippingUC
+	CarrierUC           *usecase.CarrierUC
+	TrackingUC          *usecase.TrackingUC
+	RefundUC            *usecase.RefundUC
+	TransferUC          *usecase.TransferUC
+	ReconcileUC         *usecase.ReconcileUC
+	WebhookUC           *usecase.WebhookUC
+	PaymentRulesUC      *usecase.PaymentRulesUC
+	OrderEditUC         *usecase.OrderEditUC
+	OrderArchiveUC      *usecase.OrderArchiveUC
 	CouponUC            *usecase.CouponUseCase
 	WorkshopAdmin       *httpserver.WorkshopAdmin
 	ModelRepo           domain.UploadedModelRepo
@@ -43,6 +61,9 @@ type App struct {
 	Customers           domain.CustomerRepo
 	OAuthConfig         *oauth2.Config
 	EmailService        domain.EmailService
+	Documents           domain.OrderDocuments
+	Tickets             domain.OrderTickets
+	TicketPrinter       domain.TicketPrinter
 }
 
 func NewApp(db *gorm.DB) (*App, error) {
@@ -54,13 +75,25 @@ func NewApp(db *gorm.DB) (*App, error) {
 	featuredRepo := postgres.NewFeaturedProductRepo(db)
 	couponRepo := postgres.NewCouponRepo(db)
 	hiddenCatRepo := postgres.NewHiddenCategoryRepo(db)
-	storageDir := os.Getenv("STORAGE_DIR")
-	if storageDir == "" {
-		storageDir = "uploads"
+	var storage domain.FileStorage
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE_BACKEND"))) {
+	case "s3":
+		cfg := s3.ConfigFromEnv()
+		st, err := s3.New(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("storage s3: %w", err)
+		}
+		log.Info().Str("endpoint", cfg.Endpoint).Str("bucket", cfg.Bucket).Msg("using s3 storage")
+		storage = st
+	default:
+		storageDir := os.Getenv("STORAGE_DIR")
+		if storageDir == "" {
+			storageDir = "uploads"
+		}
+		_ = os.MkdirAll(storageDir, 0755)
+		log.Info().Str("storage_dir", storageDir).Msg("using storage directory")
+		storage = localfs.New(storageDir)
 	}
-	_ = os.MkdirAll(storageDir, 0755)
-	log.Info().Str("storage_dir", storageDir).Msg("using storage directory")
-	storage := localfs.New(storageDir)
 
 	token := os.Getenv("MP_ACCESS_TOKEN")
 	appEnv := strings.ToLower(os.Getenv("APP_ENV"))
@@ -80,6 +113,9 @@ func NewApp(db *gorm.DB) (*App, error) {
 	}
 
 	payment := mercadopago.NewGateway(token)
+	if payment.BaseURL != mercadopago.DefaultBaseURL {
+		log.Warn().Str("url", payment.BaseURL).Msg("MercadoPago apuntando a una API alternativa (MP_BASE_URL)")
+	}
 
 	var oauthCfg *oauth2.Config
 	googleID := os.Getenv("GOOGLE_CLIENT_ID")
@@ -110,20 +146,66 @@ func NewApp(db *gorm.DB) (*App, error) {
 	app := &App{}
 	app.ProductUC = &usecase.ProductUC{Products: prodRepo}
 	app.OrderUC = &usecase.OrderUC{Orders: orderRepo, Products: prodRepo}
-	app.PaymentUC = &usecase.PaymentUC{Orders: orderRepo, Gateway: payment}
+	issueRepo := postgres.NewReconcileIssueRepo(db)
+	app.PaymentUC = &usecase.PaymentUC{Orders: orderRepo, Gateway: payment, Payments: postgres.NewPaymentRepo(db), Issues: issueRepo}
 	app.WhatsAppUC = &usecase.WhatsAppUC{
 		WhatsAppRepo: whatsappRepo,
 		Products:     prodRepo,
 		Orders:       orderRepo,
-		Payments:     &usecase.PaymentUC{Orders: orderRepo, Gateway: payment},
+		Payments:     app.PaymentUC,
 	}
 	app.CouponUC = usecase.NewCouponUseCase(couponRepo, orderRepo)
+	app.PaymentUC.Lifecycle = app.OrderUC
+	app.PaymentUC.Coupons = app.CouponUC
+	app.PaymentUC.OnPaid = httpserver.OrderPaidNotifier(emailService)
 	app.WorkshopAdmin = &httpserver.WorkshopAdmin{
 		Orders:   postgres.NewWorkshopRepo(db),
 		Filament: postgres.NewFilamentLedgerRepo(db),
 		Expenses: postgres.NewBusinessExpenseRepo(db),
 		Settings: postgres.NewAppSettingRepo(db),
 	}
+	app.StorageGCUC = &usecase.StorageGCUC{
+		Storage:       storage,
+		Products:      prodRepo,
+		Items:         postgres.NewStorageGCRepo(db),
+		MinAge:        24 * time.Hour,
+		QuarantineTTL: time.Duration(envInt("STORAGE_GC_QUARANTINE_DAYS", 7)) * 24 * time.Hour,
+	}
+	app.ProductModelUC = &usecase.ProductModelUC{
+		Storage:      storage,
+		Uploads:      modelRepo,
+		Models:       postgres.NewProductModelRepo(db),
+		Converter:    mesh3d.NewConverter(),
+		MaxTriangles: envInt("MODEL3D_MAX_TRIANGLES", mesh3d.DefaultMaxTriangles),
+	}
+	docs := newOrderDocuments()
+	emailService.Documents = docs
+	app.Documents = docs
+	app.Tickets = docs
+	app.TicketPrinter = newTicketPrinter()
+	app.CartUC = &usecase.CartUC{Carts: postgres.NewCartRepo(db), Products: prodRepo}
+	app.ShippingUC = &usecase.ShippingUC{Zones: postgres.NewShippingZoneRepo(db)}
+	originPostal := strings.TrimSpace(os.Getenv("SHIPPING_ORIGIN_POSTAL"))
+	if originPostal == "" {
+		originPostal = "2000"
+	}
+	carriers := newShippingCarriers()
+	app.CarrierUC = &usecase.CarrierUC{Carriers: carriers, Orders: orderRepo, Products: prodRepo, Storage: storage, OriginPostal: originPostal}
+	app.TrackingUC = &usecase.TrackingUC{Orders: app.OrderUC, Events: postgres.NewShipmentEventRepo(db), Trackers: shipmentTrackers(carriers), Notifiers: shipmentNotifiers(emailService)}
+	app.RefundUC = &usecase.RefundUC{Orders: app.OrderUC, Refunds: postgres.NewRefundRepo(db), Gateway: payment, Coupons: app.CouponUC}
+	app.PaymentUC.Refunds = app.RefundUC
+	app.ReconcileUC = &usecase.ReconcileUC{
+		Orders:   orderRepo,
+		Payments: app.PaymentUC,
+		Issues:   issueRepo,
+		MinAge:   time.Duration(max(envInt("MP_RECONCILE_MIN_AGE_MINUTES", 20), 1)) * time.Minute,
+		Lookback: time.Duration(max(envInt("MP_RECONCILE_LOOKBACK_HOURS", 72), 1)) * time.Hour,
+	}
+	app.WebhookUC = usecase.NewWebhookUC(postgres.NewWebhookEventRepo(db), envInt("WEBHOOK_MAX_ATTEMPTS", 8))
+	app.PaymentRulesUC = &usecase.PaymentRulesUC{Rules: postgres.NewPaymentRuleRepo(db)}
+	app.TransferUC = &usecase.TransferUC{Orders: app.OrderUC, Proofs: postgres.NewTransferProofRepo(db), Payments: app.PaymentUC, Storage: storage, Notifier: emailService}
+	app.OrderEditUC = &usecase.OrderEditUC{Orders: app.OrderUC, Edits: postgres.NewOrderEditRepo(db), Products: app.ProductUC, Coupons: app.CouponUC, Shipping: app.ShippingUC, Notifier: emailService}
+	app.OrderArchiveUC = &usecase.OrderArchiveUC{Orders: orderRepo, Archives: postgres.NewOrderArchiveRepo(db), Storage: storage}
 	app.DB = db
 	app.ModelRepo = modelRepo
 	app.FeaturedProductRepo = featuredRepo
@@ -136,6 +218,7 @@ func NewApp(db *gorm.DB) (*App, error) {
 	funcMap := template.FuncMap{
 		"add": func(a, b int) int { return a + b },
 		"sub": func(a, b int) int { return a - b },
+		"abs": math.Abs,
 		"deref": func(p *float64) float64 {
 			if p == nil {
 				return 0
@@ -213,6 +296,37 @@ func NewApp(db *gorm.DB) (*App, error) {
 			base = strings.ReplaceAll(base, " ", "%20")
 			return fmt.Sprintf("%s?w=%d", base, w)
 		},
+		// imgsq: variante cuadrada (recorte centrado en el punto focal de la imagen)
+		"imgsq": func(u string, w int) string {
+			base := strings.TrimSpace(u)
+			if base == "" {
+				return base
+			}
+			if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") && !strings.HasPrefix(base, "/") {
+				base = "/" + base
+			}
+			base = strings.ReplaceAll(base, " ", "%20")
+			return fmt.Sprintf("%s?w=%d&h=%d", base, w, w)
+		},
+		// formatBytes: tamaño legible (ej: 1536 -> "1.5 KB")
+		"formatBytes": func(n int64) string {
+			const unit = 1024
+			if n < unit {
+				return fmt.Sprintf("%d B", n)
+			}
+			div, exp := int64(unit), 0
+			for m := n / unit; m >= unit; m /= unit {
+				div *= unit
+				exp++
+			}
+			return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+		},
+		// orderStatusLabel: nombre legible de un estado de orden
+		"orderStatusLabel": domain.OrderStatusLabel,
+		// paymentMethodLabel: nombre legible de un medio de pago del checkout
+		"paymentMethodLabel": domain.PaymentMethodLabel,
+		// shipmentStageLabel: nombre legible de una etapa del envío
+		"shipmentStageLabel": domain.ShipmentStageLabel,
 		// formatPrice: formatea un número con puntos de miles (ej: 1000 -> "1.000", 1234.56 -> "1.234,56")
 		"formatPrice": func(n float64) string {
 			defer func() {
@@ -266,13 +380,14 @@ func NewApp(db *gorm.DB) (*App, error) {
 }
 
 func (a *App) HTTPHandler() http.Handler {
-	return httpserver.New(a.Tmpl, a.ProductUC, a.QuoteUC, a.OrderUC, a.PaymentUC, a.WhatsAppUC, a.CouponUC, a.ModelRepo, a.Storage, a.Customers, a.OAuthConfig, a.FeaturedProductRepo, a.EmailService, a.HiddenCategoryRepo, a.WorkshopAdmin)
+	return httpserver.New(a.Tmpl, a.ProductUC, a.QuoteUC, a.OrderUC, a.PaymentUC, a.WhatsAppUC, a.CouponUC, a.ModelRepo, a.Storage, a.Customers, a.OAuthConfig, a.FeaturedProductRepo, a.EmailService, a.HiddenCategoryRepo, a.WorkshopAdmin, a.StorageGCUC, a.ProductModelUC, a.CartUC, a.ShippingUC, a.CarrierUC, a.TrackingUC, a.Documents, a.Tickets, a.TicketPrinter, a.RefundUC, a.TransferUC, a.ReconcileUC, a.WebhookUC, a.PaymentRulesUC, a.OrderEditUC, a.OrderArchiveUC)
 }
 
 func (a *App) MigrateAndSeed() error {
 	if err := a.DB.AutoMigrate(
 		&domain.Product{}, &domain.Variant{}, &domain.Image{}, &domain.Order{}, &domain.OrderItem{}, &domain.UploadedModel{}, &domain.Quote{}, &domain.Page{}, &domain.Customer{}, &domain.WhatsAppOrder{}, &domain.WhatsAppProductSync{}, &domain.FeaturedProduct{}, &domain.Coupon{}, &domain.CouponUsage{}, &domain.HiddenCategory{},
 		&domain.WorkshopOrder{}, &domain.WorkshopDeposit{}, &domain.WorkshopOrderFilament{}, &domain.FilamentLedgerEntry{}, &domain.BusinessExpense{}, &domain.AppSetting{},
+		&domain.StorageGCItem{}, &domain.ProductModel3D{}, &domain.PersonalizationField{}, &domain.OrderStatusChange{}, &domain.CustomerCart{}, &domain.ShippingZone{}, &domain.ShippingRate{}, &domain.TrackingEvent{}, &domain.Refund{}, &domain.Payment{}, &domain.TransferProof{}, &domain.ReconcileIssue{}, &domain.WebhookEvent{}, &domain.PaymentRule{}, &domain.OrderSequence{}, &domain.OrderEdit{}, &domain.OrderArchive{},
 	); err != nil {
 		return err
 	}
@@ -281,6 +396,26 @@ func (a *App) MigrateAndSeed() error {
 		return err
 	}
 
+	if err := backfillPayments(a.DB); err != nil {
+		return err
+	}
+
+	if err := uniquePaymentExternalIDs(a.DB); err != nil {
+		return err
+	}
+
+	if err := backfillOrderNumbers(a.DB); err != nil {
+		return err
+	}
+
+	if err := a.ShippingUC.SeedDefaults(context.Background()); err != nil {
+		return err
+	}
+
+	if err := a.PaymentRulesUC.SeedDefaults(context.Background()); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -327,6 +462,76 @@ func backfillSlugs(db *gorm.DB) error {
 	return nil
 }
 
+// backfillPayments crea el pago de las órdenes cobradas antes de que existiera order_payments, a
+// partir de MPStatus, para que el saldo calculado no las muestre como impagas.
+func backfillPayments(db *gorm.DB) error {
+	var orders []domain.Order
+	if err := db.Where("mp_status IN ?", []string{"approved", "refunded", "charged_back", "efectivo_confirmed", "transferencia_confirmed"}).
+		Where("NOT EXISTS (SELECT 1 FROM order_payments p WHERE p.order_id = orders.id)").
+		Find(&orders).Error; err != nil {
+		return err
+	}
+	for _, o := range orders {
+		p := domain.Payment{ID: uuid.New(), OrderID: o.ID, Amount: o.Total, Status: domain.PaymentStatusApproved, Actor: "migración", Note: "pago previo a order_payments", CreatedAt: o.UpdatedAt}
+		switch o.MPStatus {
+		case "efectivo_confirmed":
+			p.Provider = domain.PaymentProviderCash
+		case "transferencia_confirmed":
+			p.Provider = domain.PaymentProviderTransfer
+		default:
+			p.Provider, p.ExternalID, p.Status = domain.PaymentProviderMercadoPago, o.MPPaymentID, o.MPStatus
+		}
+		paidAt := o.UpdatedAt
+		p.PaidAt = &paidAt
+		if err := db.Create(&p).Error; err != nil {
+			return err
+		}
+	}
+	if len(orders) > 0 {
+		log.Info().Int("orders", len(orders)).Msg("pagos históricos registrados en order_payments")
+	}
+	return nil
+}
+
+// uniquePaymentExternalIDs deja un solo pago por proveedor + id externo (el primero registrado) y
+// reemplaza el índice común por uno único, que es el que usa el upsert de PaymentRepo.Save.
+func uniquePaymentExternalIDs(db *gorm.DB) error {
+	res := db.Exec(`DELETE FROM order_payments a USING order_payments b
+		WHERE a.provider = b.provider AND a.external_id = b.external_id AND a.external_id <> ''
+			AND (a.created_at, a.id) > (b.created_at, b.id)`)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		log.Warn().Int64("payments", res.RowsAffected).Msg("pagos duplicados de la pasarela eliminados")
+	}
+	if err := db.Exec("DROP INDEX IF EXISTS idx_payments_provider_external").Error; err != nil {
+		return err
+	}
+	return db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_provider_external_id ON order_payments (provider, external_id) WHERE external_id <> ''").Error
+}
+
+// backfillOrderNumbers numera por fecha de creación las órdenes anteriores al número correlativo
+// y deja el contador en el último número usado.
+func backfillOrderNumbers(db *gorm.DB) error {
+	res := db.Exec(`UPDATE orders SET number = n.base + n.rn
+		FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY created_at, id) AS rn,
+			(SELECT COALESCE(MAX(number), 0) FROM orders) AS base
+			FROM orders WHERE number = 0) n
+		WHERE orders.id = n.id`)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		log.Info().Int64("orders", res.RowsAffected).Msg("órdenes numeradas")
+	}
+	if err := db.Exec(`INSERT INTO order_sequences (name, value) SELECT 'orders', COALESCE(MAX(number), 0) FROM orders
+		ON CONFLICT (name) DO UPDATE SET value = GREATEST(order_sequences.value, EXCLUDED.value)`).Error; err != nil {
+		return err
+	}
+	return db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_number ON orders (number) WHERE number > 0").Error
+}
+
 func seedProducts(db *gorm.DB) {
 	prods := []domain.Product{
 		{ID: uuid.New(), Slug: "llavero-logo", Name: "Llavero Logo", BasePrice: 1200, Category: "accesorios", ShortDesc: "Llavero impreso", ReadyToShip: true},
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/adapters/carriers/andreani"
+	"github.com/phenrril/tienda3d/internal/adapters/carriers/correoargentino"
+	"github.com/phenrril/tienda3d/internal/adapters/carriers/fake"
+	"github.com/phenrril/tienda3d/internal/adapters/whatsapp"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// newShippingCarriers arma la lista de correos según las credenciales presentes en el entorno.
+// CARRIER_FAKE=1 suma un correo de prueba que no sale a internet (desarrollo).
+func newShippingCarriers() []domain.ShippingCarrier {
+	var out []domain.ShippingCarrier
+	if envBool("CARRIER_FAKE") {
+		out = append(out, fake.New())
+	}
+	if user, pass := strings.TrimSpace(os.Getenv("ANDREANI_USER")), os.Getenv("ANDREANI_PASSWORD"); user != "" && pass != "" {
+		out = append(out, andreani.New(andreani.Config{
+			BaseURL:  os.Getenv("ANDREANI_BASE_URL"),
+			User:     user,
+			Password: pass,
+			Client:   os.Getenv("ANDREANI_CLIENT"),
+			Contract: os.Getenv("ANDREANI_CONTRACT"),
+		}))
+	}
+	if user, pass := strings.TrimSpace(os.Getenv("CORREO_ARGENTINO_USER")), os.Getenv("CORREO_ARGENTINO_PASSWORD"); user != "" && pass != "" {
+		out = append(out, correoargentino.New(correoargentino.Config{
+			BaseURL:    os.Getenv("CORREO_ARGENTINO_BASE_URL"),
+			User:       user,
+			Password:   pass,
+			CustomerID: os.Getenv("CORREO_ARGENTINO_CUSTOMER_ID"),
+		}))
+	}
+	for _, c := range out {
+		log.Info().Str("carrier", c.Code()).Msg("correo habilitado")
+	}
+	return out
+}
+
+// shipmentTrackers devuelve los correos configurados que permiten consultar el seguimiento.
+func shipmentTrackers(carriers []domain.ShippingCarrier) []domain.ShipmentTracker {
+	var out []domain.ShipmentTracker
+	for _, c := range carriers {
+		if t, ok := c.(domain.ShipmentTracker); ok {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// shipmentNotifiers arma los canales de aviso de envío: email siempre (sin SMTP sólo loguea) y
+// WhatsApp si está configurada la plantilla.
+func shipmentNotifiers(email domain.ShipmentNotifier) []domain.ShipmentNotifier {
+	out := []domain.ShipmentNotifier{email}
+	if wa := whatsapp.NewNotifierFromEnv(); wa != nil {
+		log.Info().Msg("avisos de envío por WhatsApp habilitados")
+		out = append(out, wa)
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/adapters/documents"
+	"github.com/phenrril/tienda3d/internal/adapters/escpos"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// newOrderDocuments arma el generador de comprobantes con los datos del negocio (BUSINESS_*).
+func newOrderDocuments() *documents.Generator {
+	env := func(key, def string) string {
+		if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+			return v
+		}
+		return def
+	}
+	g := documents.New(domain.BusinessInfo{
+		Name:         env("BUSINESS_NAME", "Chroma3D"),
+		LegalName:    env("BUSINESS_LEGAL_NAME", ""),
+		TaxID:        env("BUSINESS_CUIT", ""),
+		TaxCondition: env("BUSINESS_TAX_CONDITION", ""),
+		Address:      env("BUSINESS_ADDRESS", ""),
+		Email:        env("BUSINESS_EMAIL", ""),
+		Phone:        env("BUSINESS_PHONE", ""),
+		Website:      env("BUSINESS_WEBSITE", env("PUBLIC_BASE_URL", "")),
+	})
+	if loc, err := time.LoadLocation("America/Argentina/Buenos_Aires"); err == nil {
+		g.Location = loc
+	}
+	g.TicketWidth = envInt("TICKET_WIDTH", escpos.Width80mm)
+	return g
+}
+
+// newTicketPrinter devuelve la impresora térmica del mostrador (TICKET_PRINTER_ADDR, host:puerto)
+// o nil si no está configurada; en ese caso los tickets sólo se pueden descargar.
+func newTicketPrinter() domain.TicketPrinter {
+	addr := strings.TrimSpace(os.Getenv("TICKET_PRINTER_ADDR"))
+	if addr == "" {
+		return nil
+	}
+	if !strings.Contains(addr, ":") {
+		addr += ":9100"
+	}
+	log.Info().Str("addr", addr).Msg("impresora de tickets configurada")
+	return escpos.NewPrinter(addr)
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/usecase"
+)
+
+// RunOrderExpiryLoop cancela cada 15 minutos las órdenes impagas vencidas según el método de pago
+// y manda un recordatorio por email antes de cancelarlas. ORDER_EXPIRY_*_HOURS=0 desactiva el método.
+func (a *App) RunOrderExpiryLoop(ctx context.Context) {
+	if a.OrderUC == nil {
+		return
+	}
+	hours := func(key string, def int) time.Duration {
+		n := envInt(key, def)
+		if n < 0 {
+			n = 0
+		}
+		return time.Duration(n) * time.Hour
+	}
+	uc := &usecase.OrderExpiryUC{
+		Orders: a.OrderUC,
+		Email:  a.EmailService,
+		Policy: usecase.ExpiryPolicy{
+			TTL: map[string]time.Duration{
+				"efectivo":      hours("ORDER_EXPIRY_EFECTIVO_HOURS", 72),
+				"transferencia": hours("ORDER_EXPIRY_TRANSFERENCIA_HOURS", 48),
+				"mercadopago":   hours("ORDER_EXPIRY_MERCADOPAGO_HOURS", 24),
+			},
+			ReminderBefore: hours("ORDER_EXPIRY_REMINDER_HOURS", 12),
+		},
+	}
+	go func() {
+		ticker := time.NewTicker(15 * time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				res, err := uc.Run(ctx, time.Now())
+				if err != nil {
+					log.Warn().Err(err).Msg("vencimiento de órdenes")
+					continue
+				}
+				if res.Cancelled > 0 || res.Reminded > 0 {
+					log.Info().Int("canceladas", res.Cancelled).Int("recordatorios", res.Reminded).Msg("vencimiento de órdenes impagas")
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RunPaymentReconcileLoop concilia cada MP_RECONCILE_MINUTES (default 15) las órdenes impagas con
+// los pagos de MercadoPago, por si se perdió algún webhook. 0 lo desactiva.
+func (a *App) RunPaymentReconcileLoop(ctx context.Context) {
+	if a.ReconcileUC == nil {
+		return
+	}
+	minutes := envInt("MP_RECONCILE_MINUTES", 15)
+	if minutes <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(minutes) * time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				res, err := a.ReconcileUC.Run(ctx, time.Now())
+				if err != nil {
+					log.Warn().Err(err).Msg("conciliación de pagos")
+					continue
+				}
+				if res.Fixed > 0 || res.Issues > 0 {
+					log.Info().Int("revisadas", res.Checked).Int("corregidas", res.Fixed).Int("diferencias", res.Issues).Msg("conciliación de pagos con mercadopago")
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RunShipmentTrackingLoop consulta cada SHIPMENT_TRACKING_MINUTES (default 60) el seguimiento de
+// las órdenes despachadas y avisa al cliente cuando cambian de etapa. 0 lo desactiva.
+func (a *App) RunShipmentTrackingLoop(ctx context.Context) {
+	if a.TrackingUC == nil || len(a.TrackingUC.Trackers) == 0 {
+		return
+	}
+	minutes := envInt("SHIPMENT_TRACKING_MINUTES", 60)
+	if minutes <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(minutes) * time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				res, err := a.TrackingUC.Poll(ctx)
+				if err != nil {
+					log.Warn().Err(err).Msg("seguimiento de envíos")
+					continue
+				}
+				if res.Updated > 0 {
+					log.Info().Int("consultados", res.Checked).Int("actualizados", res.Updated).Msg("seguimiento de envíos")
+				}
+			}
+		}
+	}()
+}
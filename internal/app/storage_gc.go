@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// RunStorageGCLoop escanea una vez por día las imágenes huérfanas/duplicadas y purga
+// la cuarentena vencida. Con STORAGE_GC_AUTO_QUARANTINE=true también mueve a cuarentena
+// lo detectado sin esperar la revisión en el admin.
+func (a *App) RunStorageGCLoop(ctx context.Context) {
+	if a.StorageGCUC == nil || a.WorkshopAdmin == nil {
+		return
+	}
+	hour := envInt("STORAGE_GC_HOUR", 4)
+	if hour < 0 || hour > 23 {
+		hour = 4
+	}
+	auto := envBool("STORAGE_GC_AUTO_QUARANTINE")
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.maybeRunStorageGC(ctx, hour, auto)
+			}
+		}
+	}()
+}
+
+func (a *App) maybeRunStorageGC(ctx context.Context, hour int, auto bool) {
+	now := time.Now()
+	if now.Hour() != hour {
+		return
+	}
+	settings := a.WorkshopAdmin.Settings
+	todayStr := now.Format("2006-01-02")
+	if last, _ := settings.Get(ctx, domain.SettingStorageGCLast); last == todayStr {
+		return
+	}
+	_ = settings.Set(ctx, domain.SettingStorageGCLast, todayStr)
+
+	uc := a.StorageGCUC
+	res, err := uc.Scan(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("storage gc scan")
+		return
+	}
+	log.Info().Int("archivos", res.ScannedFiles).Int("huerfanos", res.Orphans).Int("duplicados", res.Duplicates).Dur("duracion", res.Duration).Msg("storage gc scan")
+	if auto {
+		if n, err := uc.Quarantine(ctx, nil); err != nil {
+			log.Warn().Err(err).Msg("storage gc quarantine")
+		} else if n > 0 {
+			log.Info().Int("archivos", n).Msg("storage gc: movidos a cuarentena")
+		}
+	}
+	if n, freed, err := uc.PurgeExpired(ctx); err != nil {
+		log.Warn().Err(err).Msg("storage gc purge")
+	} else if n > 0 {
+		log.Info().Int("archivos", n).Int64("bytes", freed).Msg("storage gc: cuarentena purgada")
+	}
+}
+
+func envInt(key string, def int) int {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+func envBool(key string) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(key))) {
+	case "1", "true", "yes", "si", "sí":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RunWebhookInboxLoop procesa la bandeja de webhooks: apenas entra un evento y cada
+// WEBHOOK_RETRY_SECONDS (default 30) los reintentos vencidos. Una vez por día borra los eventos
+// procesados con más de WEBHOOK_RETENTION_DAYS (default 30; 0 los guarda para siempre).
+func (a *App) RunWebhookInboxLoop(ctx context.Context) {
+	if a.WebhookUC == nil {
+		return
+	}
+	every := envInt("WEBHOOK_RETRY_SECONDS", 30)
+	if every <= 0 {
+		every = 30
+	}
+	retention := envInt("WEBHOOK_RETENTION_DAYS", 30)
+	run := func() {
+		res, err := a.WebhookUC.ProcessDue(ctx, time.Now())
+		if err != nil {
+			log.Warn().Err(err).Msg("bandeja de webhooks")
+			return
+		}
+		if res.Retrying > 0 || res.Failed > 0 {
+			log.Info().Int("procesados", res.Processed).Int("reintentos", res.Retrying).Int("fallidos", res.Failed).Msg("bandeja de webhooks")
+		}
+	}
+	purge := func() {
+		if retention <= 0 {
+			return
+		}
+		n, err := a.WebhookUC.Events.DeleteProcessedBefore(ctx, time.Now().AddDate(0, 0, -retention))
+		if err != nil {
+			log.Warn().Err(err).Msg("limpiar bandeja de webhooks")
+			return
+		}
+		if n > 0 {
+			log.Info().Int64("borrados", n).Msg("eventos de webhook viejos borrados")
+		}
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(every) * time.Second)
+		defer ticker.Stop()
+		daily := time.NewTicker(24 * time.Hour)
+		defer daily.Stop()
+		// lo que quedó pendiente de antes de un reinicio
+		run()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-a.WebhookUC.Wake():
+				run()
+			case <-ticker.C:
+				run()
+			case <-daily.C:
+				purge()
+			}
+		}
+	}()
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"math"
+	"strings"
+)
+
+// CarrierQuoteRequest son los datos para cotizar con un correo (Andreani, Correo Argentino...).
+type CarrierQuoteRequest struct {
+	OriginPostal  string
+	DestPostal    string
+	DestProvince  string
+	Package       ShippingPackage
+	DeclaredValue float64
+}
+
+// CarrierRate es un servicio cotizado por un correo.
+type CarrierRate struct {
+	Carrier     string
+	CarrierName string
+	Service     string
+	ServiceName string
+	Price       float64
+	DaysMin     int
+	DaysMax     int
+}
+
+// Option identifica la tarifa en el checkout ("andreani:estandar").
+func (r CarrierRate) Option() string { return r.Carrier + ":" + r.Service }
+
+// ParseCarrierOption separa "correo:servicio".
+func ParseCarrierOption(opt string) (carrier, service string, ok bool) {
+	carrier, service, ok = strings.Cut(strings.TrimSpace(opt), ":")
+	return carrier, service, ok && carrier != "" && service != ""
+}
+
+// CarrierShipmentRequest es el alta de un envío para una orden ya paga.
+type CarrierShipmentRequest struct {
+	Service      string
+	OriginPostal string
+	Order        *Order
+	Package      ShippingPackage
+}
+
+// CarrierShipment es lo que devuelve el correo al dar de alta el envío. LabelPDF puede venir
+// vacío si el correo no entrega la etiqueta por API.
+type CarrierShipment struct {
+	TrackingNumber string
+	LabelPDF       []byte
+}
+
+// Box estima las medidas de una caja (mm) para el paquete: el lado más largo y una base
+// cuadrada que contenga el volumen. Sin medidas cargadas devuelve una caja chica de 10 cm.
+func (p ShippingPackage) Box() (length, width, height float64) {
+	if p.LongMM <= 0 || p.VolumeMM <= 0 {
+		return 100, 100, 100
+	}
+	side := math.Sqrt(p.VolumeMM / p.LongMM)
+	if side > p.LongMM {
+		side = p.LongMM
+	}
+	return p.LongMM, math.Max(side, 10), math.Max(side, 10)
+}
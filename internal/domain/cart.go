@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+// CartItem es una línea del carrito tal como se guarda (cookie firmada o tabla customer_carts).
+// Price es el precio unitario al momento de agregarlo; se compara con el actual para avisar cambios.
+type CartItem struct {
+	Slug        string  `json:"slug"`
+	Color       string  `json:"color"`
+	Observation string  `json:"observation,omitempty"`
+	Qty         int     `json:"qty"`
+	Price       float64 `json:"price"`
+	// PZ: personalización elegida (sólo id de campo y valor)
+	PZ []PersonalizationValue `json:"pz,omitempty"`
+}
+
+// CustomerCart es el carrito persistido de un cliente logueado (uno por email).
+type CustomerCart struct {
+	Email     string `gorm:"size:140;primaryKey"`
+	Items     string `gorm:"type:text"` // JSON de []CartItem
+	UpdatedAt time.Time
+}
+
+func (CustomerCart) TableName() string { return "customer_carts" }
+
+// CartChange describe un ajuste hecho al revalidar el carrito.
+type CartChange struct {
+	Name     string
+	OldPrice float64
+	NewPrice float64
+	// Removed: la línea se sacó del carrito (producto inexistente o personalización inválida).
+	Removed bool
+	Reason  string
+}
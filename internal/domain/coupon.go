@@ -30,13 +30,14 @@ type Coupon struct {
 }
 
 type CouponUsage struct {
-	ID              uuid.UUID `gorm:"type:uuid;primaryKey"`
-	CouponID        uuid.UUID `gorm:"type:uuid;index;not null"`
-	OrderID         uuid.UUID `gorm:"type:uuid;index;not null"`
-	Email           string    `gorm:"size:140;index"`
-	DiscountApplied float64   `gorm:"type:decimal(12,2);not null"`
-	OrderTotal      float64   `gorm:"type:decimal(12,2);not null"`
-	UsedAt          time.Time `gorm:"not null"`
+	ID       uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CouponID uuid.UUID `gorm:"type:uuid;index;not null"`
+	// OrderID queda en nil cuando la orden se borra: el uso sigue contando para los límites del cupón.
+	OrderID         *uuid.UUID `gorm:"type:uuid;index"`
+	Email           string     `gorm:"size:140;index"`
+	DiscountApplied float64    `gorm:"type:decimal(12,2);not null"`
+	OrderTotal      float64    `gorm:"type:decimal(12,2);not null"`
+	UsedAt          time.Time  `gorm:"not null"`
 
 	Coupon *Coupon `gorm:"foreignKey:CouponID"`
 	Order  *Order  `gorm:"foreignKey:OrderID"`
@@ -0,0 +1,35 @@
+package domain
+
+import "context"
+
+// BusinessInfo son los datos del negocio que se imprimen en los comprobantes.
+type BusinessInfo struct {
+	Name         string
+	LegalName    string
+	TaxID        string // CUIT
+	TaxCondition string // ej. "Monotributo"
+	Address      string
+	Email        string
+	Phone        string
+	Website      string
+}
+
+// OrderDocuments genera los PDFs imprimibles de una orden.
+type OrderDocuments interface {
+	// Receipt es el comprobante para el cliente: datos del negocio, artículos, descuento, envío y pago.
+	Receipt(o *Order) ([]byte, error)
+	// PackingSlip es la hoja de armado para producción y despacho (sin precios).
+	PackingSlip(o *Order) ([]byte, error)
+}
+
+// OrderTickets arma los tickets ESC/POS para la impresora térmica del mostrador.
+type OrderTickets interface {
+	OrderTicket(o *Order) ([]byte, error)
+	// WorkshopTicket es el ticket de un pedido del taller: cliente, detalle, señas y saldo.
+	WorkshopTicket(o *WorkshopOrder) ([]byte, error)
+}
+
+// TicketPrinter manda un ticket ya armado a la impresora.
+type TicketPrinter interface {
+	Print(ctx context.Context, data []byte) error
+}
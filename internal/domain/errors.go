@@ -6,3 +6,9 @@ var ErrNotFound = errors.New("not found")
 
 // ErrFilamentInsufficientStock indica que no hay gramos suficientes en inventario para el consumo pedido.
 var ErrFilamentInsufficientStock = errors.New("filamento: stock insuficiente")
+
+// ErrInvalidTransition indica un cambio de estado de orden no permitido por la máquina de estados.
+var ErrInvalidTransition = errors.New("transición de estado inválida")
+
+// ErrStatusConflict indica que la orden cambió de estado entre que se leyó y se quiso guardar.
+var ErrStatusConflict = errors.New("la orden cambió de estado mientras se actualizaba")
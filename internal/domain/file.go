@@ -16,3 +16,68 @@ type UploadedModel struct {
 	Hash             string    `gorm:"size:120;index"`
 	CreatedAt        time.Time
 }
+
+// StoredFile es un archivo listado desde el FileStorage.
+type StoredFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+const (
+	StorageGCReasonOrphan    = "orphan"
+	StorageGCReasonDuplicate = "duplicate"
+
+	StorageGCDetected    = "detected"
+	StorageGCQuarantined = "quarantined"
+	StorageGCRestored    = "restored"
+	StorageGCPurged      = "purged"
+)
+
+// StorageGCItem es un archivo de imagen sin referencia (huérfano) o repetido detectado por el
+// recolector. Se mueve a cuarentena antes de borrarse definitivamente.
+type StorageGCItem struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Path           string    `gorm:"size:400;index"`
+	QuarantinePath string    `gorm:"size:400"`
+	Hash           string    `gorm:"size:64;index"`
+	Size           int64
+	Reason         string `gorm:"size:20;index"`
+	DuplicateOf    string `gorm:"size:400"`
+	Status         string `gorm:"size:20;index"`
+	DetectedAt     time.Time
+	QuarantinedAt  *time.Time
+	PurgedAt       *time.Time
+}
+
+func (StorageGCItem) TableName() string { return "storage_gc_items" }
+
+// ProductModel3D es el modelo 3D de un producto para el visor web. El archivo original
+// (STL/3MF) queda como UploadedModel y nunca se sirve; sólo se publica el GLB convertido.
+type ProductModel3D struct {
+	ID              uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ProductID       uuid.UUID `gorm:"type:uuid;uniqueIndex"`
+	SourceModelID   uuid.UUID `gorm:"type:uuid;index"`
+	SourceFilename  string    `gorm:"size:255"`
+	AssetPath       string    `gorm:"size:400"`
+	AssetSize       int64
+	Triangles       int
+	SourceTriangles int
+	SizeXMM         float64 `gorm:"type:decimal(10,2)"`
+	SizeYMM         float64 `gorm:"type:decimal(10,2)"`
+	SizeZMM         float64 `gorm:"type:decimal(10,2)"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (ProductModel3D) TableName() string { return "product_models_3d" }
+
+// MeshStats resume una conversión: triángulos antes/después de simplificar y medidas en mm.
+type MeshStats struct {
+	SourceTriangles int
+	Triangles       int
+	Vertices        int
+	SizeXMM         float64
+	SizeYMM         float64
+	SizeZMM         float64
+}
@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,8 +19,12 @@ const (
 	OrderStatusFinished     OrderStatus = "finished"
 	OrderStatusShipped      OrderStatus = "shipped"
 	OrderStatusCancelled    OrderStatus = "cancelled"
+	OrderStatusRefunded     OrderStatus = "refunded"
 )
 
+// OrderNumberPrefix antecede al número correlativo de la orden ("C3D-000123").
+const OrderNumberPrefix = "C3D"
+
 type Order struct {
 	ID             uuid.UUID   `gorm:"type:uuid;primaryKey"`
 	Status         OrderStatus `gorm:"type:varchar(30);index"`
@@ -40,10 +47,76 @@ type Order struct {
 	CouponID       *uuid.UUID `gorm:"type:uuid;index"`
 	Notified       bool    `gorm:"not null;default:false"`
 
+	// Number: número correlativo sin huecos, asignado al crear la orden (ver OrderSequence y Code).
+	Number int64 `gorm:"not null;default:0"`
+
+	// PaymentAdjustment: descuento (negativo) o recargo por el medio de pago, ya incluido en
+	// Total; PaymentAdjustmentPct es el porcentaje que se aplicó (ver PaymentRule).
+	PaymentAdjustment    float64 `gorm:"type:decimal(12,2);not null;default:0"`
+	PaymentAdjustmentPct float64 `gorm:"type:decimal(6,2);not null;default:0"`
+
+	// PaymentReminderAt: cuándo se avisó que la orden impaga está por vencer (nil = sin aviso).
+	PaymentReminderAt *time.Time
+
+	// Carrier/CarrierService: correo elegido en el checkout (vacío = tarifa por zona o cadete).
+	Carrier        string `gorm:"size:30"`
+	CarrierService string `gorm:"size:40"`
+	TrackingNumber string `gorm:"size:80;index"`
+	// ShippingLabel: ruta del PDF de la etiqueta en el storage (adjunto privado).
+	ShippingLabel string `gorm:"size:255"`
+	TrackingURL   string `gorm:"size:255"`
+	// ShipmentStage: última etapa del envío avisada al cliente (ver ShipmentStage*).
+	ShipmentStage string `gorm:"size:30;index"`
+
+	// MPPaymentID: pago de MercadoPago confirmado por webhook (lo necesitan los reembolsos).
+	MPPaymentID string `gorm:"size:40;index"`
+	// RefundedAmount: suma de los reembolsos hechos (ver Refund); Total no se toca.
+	RefundedAmount float64 `gorm:"type:decimal(12,2);not null;default:0"`
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+// Code es el número de orden que ve el cliente ("C3D-000123"); las órdenes sin número caen
+// en el ID corto.
+func (o Order) Code() string {
+	if o.Number > 0 {
+		return fmt.Sprintf("%s-%06d", OrderNumberPrefix, o.Number)
+	}
+	return o.ID.String()[:8]
+}
+
+// ParseOrderNumber interpreta un número de orden escrito como "C3D-000123", "#123" o "123".
+func ParseOrderNumber(s string) (int64, bool) {
+	s = strings.TrimSpace(strings.ToUpper(s))
+	s = strings.TrimPrefix(s, OrderNumberPrefix)
+	s = strings.TrimLeft(s, "-# ")
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// OrderSequence guarda el último número de orden entregado; se incrementa dentro de la misma
+// transacción que crea la orden, así un alta que falla no deja huecos.
+type OrderSequence struct {
+	Name  string `gorm:"size:30;primaryKey"`
+	Value int64  `gorm:"not null;default:0"`
+}
+
+func (OrderSequence) TableName() string { return "order_sequences" }
+
+// Subtotal es productos + envío, antes del cupón y del ajuste por medio de pago.
+func (o Order) Subtotal() float64 {
+	return o.Total + o.DiscountAmount - o.PaymentAdjustment
+}
+
+// PaymentAdjustmentLabel es el texto del ajuste por medio de pago ("10% OFF").
+func (o Order) PaymentAdjustmentLabel() string {
+	return AdjustmentLabel(o.PaymentAdjustmentPct)
+}
+
 type OrderItem struct {
 	ID        uuid.UUID  `gorm:"type:uuid;primaryKey"`
 	OrderID   uuid.UUID  `gorm:"type:uuid;index"`
@@ -53,4 +126,45 @@ type OrderItem struct {
 	Color     string     `gorm:"size:60"`
 	Qty       int        `gorm:"not null"`
 	UnitPrice float64    `gorm:"type:decimal(12,2)"`
+
+	// Personalization: JSON de []PersonalizationValue; UnitPrice ya incluye los recargos.
+	Personalization string `gorm:"type:text"`
+}
+
+func (it OrderItem) PersonalizationValues() []PersonalizationValue {
+	return DecodePersonalization(it.Personalization)
+}
+
+// itemObservationSep separa el nombre del producto de la observación del cliente en el título
+// del item ("Maceta | Obs: con agujero").
+const itemObservationSep = " | Obs: "
+
+// ItemTitle arma el título de un item con la observación del cliente (vacía = sólo el nombre).
+func ItemTitle(name, observation string) string {
+	if observation == "" {
+		return name
+	}
+	return name + itemObservationSep + observation
+}
+
+// Name es el título del item sin la observación.
+func (it OrderItem) Name() string {
+	name, _, _ := strings.Cut(it.Title, itemObservationSep)
+	return name
+}
+
+// Observation es la observación que dejó el cliente para el item.
+func (it OrderItem) Observation() string {
+	_, obs, _ := strings.Cut(it.Title, itemObservationSep)
+	return obs
+}
+
+// OrderExportFilter filtra las órdenes a exportar; los campos vacíos no filtran. From y To son
+// días completos (inclusive).
+type OrderExportFilter struct {
+	From           time.Time
+	To             time.Time
+	Status         OrderStatus
+	PaymentMethod  string
+	ShippingMethod string
 }
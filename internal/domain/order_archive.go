@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrderArchive registra un archivo de órdenes borradas: antes de eliminar un período se guardan
+// las órdenes con todo lo que se borra con ellas en un JSONL comprimido con gzip (una
+// OrderArchiveEntry por línea) en el storage privado, desde donde se pueden restaurar.
+type OrderArchive struct {
+	ID   uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Path string    `gorm:"size:255"`
+	// PeriodFrom/PeriodTo: días elegidos al borrar (inclusive).
+	PeriodFrom   time.Time
+	PeriodTo     time.Time
+	Orders       int
+	Items        int
+	CouponUsages int
+	// Total: suma de los totales de las órdenes archivadas.
+	Total float64 `gorm:"type:decimal(12,2)"`
+	Size  int64
+	Actor string `gorm:"size:140"`
+	// RestoredAt/RestoredBy: última restauración (nil = nunca se restauró).
+	RestoredAt *time.Time
+	RestoredBy string    `gorm:"size:140"`
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+func (OrderArchive) TableName() string { return "order_archives" }
+
+// OrderArchiveEntry es una línea del archivo: la orden con sus items y las filas que dependen
+// de ella.
+type OrderArchiveEntry struct {
+	Order           Order               `json:"order"`
+	CouponUsages    []CouponUsage       `json:"coupon_usages,omitempty"`
+	StatusHistory   []OrderStatusChange `json:"status_history,omitempty"`
+	Payments        []Payment           `json:"payments,omitempty"`
+	Refunds         []Refund            `json:"refunds,omitempty"`
+	TransferProofs  []TransferProof     `json:"transfer_proofs,omitempty"`
+	TrackingEvents  []TrackingEvent     `json:"tracking_events,omitempty"`
+	ReconcileIssues []ReconcileIssue    `json:"reconcile_issues,omitempty"`
+	Edits           []OrderEdit         `json:"edits,omitempty"`
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrderEdit registra una corrección del admin sobre una orden ya creada (items, envío o
+// dirección): qué cambió, quién lo hizo y cómo quedó el total.
+type OrderEdit struct {
+	ID      uuid.UUID `gorm:"type:uuid;primaryKey"`
+	OrderID uuid.UUID `gorm:"type:uuid;index"`
+	Actor   string    `gorm:"size:140"`
+	// Changes: un cambio por línea, en el texto que recibe el cliente.
+	Changes   string    `gorm:"type:text"`
+	Note      string    `gorm:"size:255"`
+	PrevTotal float64   `gorm:"type:decimal(12,2)"`
+	NewTotal  float64   `gorm:"type:decimal(12,2)"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (OrderEdit) TableName() string { return "order_edits" }
+
+// ChangeLines devuelve los cambios de la edición, uno por elemento.
+func (e OrderEdit) ChangeLines() []string {
+	if e.Changes == "" {
+		return nil
+	}
+	return strings.Split(e.Changes, "\n")
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Origen de un cambio de estado de orden.
+const (
+	StatusSourceCheckout  = "checkout"
+	StatusSourceWebhook   = "webhook"
+	StatusSourceReturn    = "return"
+	StatusSourceAdmin     = "admin"
+	StatusSourceWhatsApp  = "whatsapp"
+	StatusSourceSystem    = "system"
+	StatusSourceTracking  = "tracking"
+	StatusSourceReconcile = "reconcile"
+)
+
+// OrderStatusChange es una fila del historial de estados de una orden.
+// From vacío indica la creación de la orden.
+type OrderStatusChange struct {
+	ID        uuid.UUID   `gorm:"type:uuid;primaryKey"`
+	OrderID   uuid.UUID   `gorm:"type:uuid;index"`
+	From      OrderStatus `gorm:"type:varchar(30)"`
+	To        OrderStatus `gorm:"type:varchar(30)"`
+	Actor     string      `gorm:"size:140"`
+	Source    string      `gorm:"size:30"`
+	Note      string      `gorm:"size:255"`
+	CreatedAt time.Time   `gorm:"index"`
+}
+
+func (OrderStatusChange) TableName() string { return "order_status_history" }
+
+// OrderStatuses son los estados de orden en el orden en que se muestran en los filtros.
+var OrderStatuses = []OrderStatus{
+	OrderStatusAwaitingPay,
+	OrderStatusFinished,
+	OrderStatusInPrint,
+	OrderStatusShipped,
+	OrderStatusCancelled,
+	OrderStatusRefunded,
+	OrderStatusPendingQuote,
+	OrderStatusQuoted,
+}
+
+// OrderStatusLabel devuelve el nombre legible de un estado.
+func OrderStatusLabel(st OrderStatus) string {
+	switch st {
+	case OrderStatusPendingQuote:
+		return "Cotización pendiente"
+	case OrderStatusQuoted:
+		return "Cotizada"
+	case OrderStatusAwaitingPay:
+		return "Esperando pago"
+	case OrderStatusInPrint:
+		return "En impresión"
+	case OrderStatusFinished:
+		return "Pagada"
+	case OrderStatusShipped:
+		return "Enviada"
+	case OrderStatusCancelled:
+		return "Cancelada"
+	case OrderStatusRefunded:
+		return "Reembolsada"
+	case "":
+		return "Creada"
+	}
+	return string(st)
+}
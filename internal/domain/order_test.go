@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseOrderNumber(t *testing.T) {
+	cases := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"C3D-000123", 123, true},
+		{"c3d-000123", 123, true},
+		{" C3D000123 ", 123, true},
+		{"#123", 123, true},
+		{"123", 123, true},
+		{"C3D - 42", 42, true},
+		{"0", 0, false},
+		{"C3D-000000", 0, false},
+		{"C3D-", 0, false},
+		{"", 0, false},
+		{"12a", 0, false},
+		{"juan@example.com", 0, false},
+		{"99999999999999999999", 0, false},
+	}
+	for _, c := range cases {
+		n, ok := ParseOrderNumber(c.in)
+		if n != c.want || ok != c.ok {
+			t.Errorf("ParseOrderNumber(%q) = %d, %v; want %d, %v", c.in, n, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestOrderCode(t *testing.T) {
+	id := uuid.MustParse("3f2b8f4e-1111-4222-8333-444455556666")
+	cases := []struct {
+		number int64
+		want   string
+	}{
+		{1, "C3D-000001"},
+		{123, "C3D-000123"},
+		{1234567, "C3D-1234567"},
+		// órdenes anteriores a la numeración
+		{0, "3f2b8f4e"},
+	}
+	for _, c := range cases {
+		o := Order{ID: id, Number: c.number}
+		got := o.Code()
+		if got != c.want {
+			t.Errorf("Code() con número %d = %q; want %q", c.number, got, c.want)
+		}
+		// lo que se muestra se puede volver a buscar
+		if n, ok := ParseOrderNumber(got); c.number > 0 && (!ok || n != c.number) {
+			t.Errorf("ParseOrderNumber(%q) = %d, %v", got, n, ok)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Medio con el que entró un pago.
+const (
+	PaymentProviderMercadoPago = "mercadopago"
+	PaymentProviderCash        = "efectivo"
+	PaymentProviderTransfer    = "transferencia"
+)
+
+// Estados de un pago; los de MercadoPago se guardan tal como vienen (in_process, in_mediation...).
+const (
+	PaymentStatusPending     = "pending"
+	PaymentStatusApproved    = "approved"
+	PaymentStatusRejected    = "rejected"
+	PaymentStatusRefunded    = "refunded"
+	PaymentStatusChargedBack = "charged_back"
+)
+
+// Payment es un cobro (o intento de cobro) de una orden. Una orden puede tener varios: un pago
+// rechazado y después uno aprobado, una seña en efectivo y el resto por transferencia, etc.
+type Payment struct {
+	ID      uuid.UUID `gorm:"type:uuid;primaryKey"`
+	OrderID uuid.UUID `gorm:"type:uuid;index"`
+	// Provider: PaymentProvider*.
+	Provider string `gorm:"size:30"`
+	// ExternalID: id del pago en la pasarela (vacío para efectivo y transferencias). Único por
+	// proveedor cuando no está vacío (índice parcial creado en la migración).
+	ExternalID   string  `gorm:"size:60"`
+	Amount       float64 `gorm:"type:decimal(12,2);not null"`
+	Status       string  `gorm:"size:30;index"`
+	StatusDetail string  `gorm:"size:80"`
+	// Actor: quién lo registró (email del admin o "mercadopago").
+	Actor string `gorm:"size:140"`
+	Note  string `gorm:"size:255"`
+	// Raw: último payload recibido de la pasarela, para auditoría.
+	Raw    string `gorm:"type:text"`
+	PaidAt *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (Payment) TableName() string { return "order_payments" }
+
+// Collected indica si la plata llegó a cobrarse, aunque después se haya devuelto (los reembolsos
+// se llevan aparte en Refund).
+func (p Payment) Collected() bool {
+	switch p.Status {
+	case PaymentStatusApproved, PaymentStatusRefunded, PaymentStatusChargedBack:
+		return true
+	}
+	return false
+}
+
+// OrderBalance es el estado de cobro de una orden calculado a partir de sus pagos.
+type OrderBalance struct {
+	Total    float64
+	Paid     float64
+	Refunded float64
+	// Due: lo que falta cobrar.
+	Due float64
+}
+
+// NewOrderBalance suma los pagos cobrados de la orden.
+func NewOrderBalance(o *Order, payments []Payment) OrderBalance {
+	b := OrderBalance{Total: o.Total, Refunded: o.RefundedAmount}
+	for _, p := range payments {
+		if p.OrderID == o.ID && p.Collected() {
+			b.Paid += p.Amount
+		}
+	}
+	b.Paid = math.Round(b.Paid*100) / 100
+	b.Due = math.Max(0, math.Round((b.Total-b.Paid)*100)/100)
+	return b
+}
+
+// Settled indica si la orden ya está paga del todo.
+func (b OrderBalance) Settled() bool { return b.Paid > 0 && b.Due < 0.01 }
+
+// Partial indica que se cobró una parte (una seña) pero todavía hay saldo.
+func (b OrderBalance) Partial() bool { return b.Paid > 0 && b.Due >= 0.01 }
+
+// GatewayPayment es un pago tal como lo informa la pasarela.
+type GatewayPayment struct {
+	ID           string
+	Status       string
+	StatusDetail string
+	ExternalRef  string
+	Amount       float64
+	ApprovedAt   *time.Time
+	Raw          []byte
+}
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// PaymentMethods son los medios de pago del checkout, en el orden en que se muestran.
+var PaymentMethods = []string{PaymentProviderCash, PaymentProviderTransfer, PaymentProviderMercadoPago}
+
+// PaymentMethodLabel devuelve el nombre legible de un medio de pago del checkout.
+func PaymentMethodLabel(method string) string {
+	switch method {
+	case PaymentProviderCash:
+		return "Efectivo"
+	case PaymentProviderTransfer:
+		return "Transferencia"
+	case PaymentProviderMercadoPago:
+		return "Mercado Pago"
+	}
+	return method
+}
+
+// PaymentRule ajusta el total de la orden según el medio de pago: Percent negativo es un
+// descuento (ej. -10 pagando por transferencia) y positivo un recargo (ej. tarjeta). Se aplica
+// sobre productos + envío, después del cupón.
+type PaymentRule struct {
+	Method  string  `gorm:"size:30;primaryKey"`
+	Percent float64 `gorm:"type:decimal(6,2);not null;default:0"`
+	// Label es el texto que ve el cliente (vacío = se arma con el porcentaje).
+	Label  string `gorm:"size:80"`
+	Active bool   `gorm:"not null;default:true"`
+	// Installments: cuotas a mostrar en la ficha del producto (0 o 1 = no se muestran).
+	// InstallmentsInterest: recargo total en % de pagar en cuotas (0 = sin interés).
+	Installments         int     `gorm:"not null;default:0"`
+	InstallmentsInterest float64 `gorm:"type:decimal(6,2);not null;default:0"`
+	UpdatedAt            time.Time
+}
+
+func (PaymentRule) TableName() string { return "payment_rules" }
+
+// Applies indica si la regla cambia el total.
+func (r PaymentRule) Applies() bool { return r.Active && r.Percent != 0 }
+
+// Adjustment devuelve el ajuste sobre base, redondeado a centavos (negativo = descuento).
+func (r PaymentRule) Adjustment(base float64) float64 {
+	if !r.Applies() || base <= 0 {
+		return 0
+	}
+	return math.Round(base*r.Percent) / 100
+}
+
+// DisplayLabel es el texto del ajuste para el checkout y los comprobantes.
+func (r PaymentRule) DisplayLabel() string {
+	if r.Label != "" {
+		return r.Label
+	}
+	return AdjustmentLabel(r.Percent)
+}
+
+// AdjustmentLabel arma el texto de un ajuste a partir del porcentaje ("10% OFF", "5% de recargo").
+func AdjustmentLabel(percent float64) string {
+	switch {
+	case percent < 0:
+		return trimPercent(-percent) + "% OFF"
+	case percent > 0:
+		return trimPercent(percent) + "% de recargo"
+	}
+	return ""
+}
+
+func trimPercent(p float64) string {
+	if p == math.Trunc(p) {
+		return fmt.Sprintf("%.0f", p)
+	}
+	return fmt.Sprintf("%.1f", p)
+}
+
+// InstallmentPlan es la información de cuotas que se muestra para un precio.
+type InstallmentPlan struct {
+	Count    int
+	Amount   float64
+	Interest bool
+}
+
+// InstallmentPlan calcula las cuotas de price; ok = false si la regla no las muestra.
+func (r PaymentRule) InstallmentPlan(price float64) (InstallmentPlan, bool) {
+	if !r.Active || r.Installments <= 1 || price <= 0 {
+		return InstallmentPlan{}, false
+	}
+	total := price + r.Adjustment(price)
+	total += total * r.InstallmentsInterest / 100
+	return InstallmentPlan{
+		Count:    r.Installments,
+		Amount:   math.Round(total/float64(r.Installments)*100) / 100,
+		Interest: r.InstallmentsInterest > 0,
+	}, true
+}
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PersonalizationText  = "text"
+	PersonalizationFont  = "font"
+	PersonalizationColor = "color"
+	PersonalizationLogo  = "logo"
+)
+
+// PersonalizationField es un dato que el cliente completa al comprar (nombre a grabar,
+// tipografía, color, logo). Surcharge se suma al precio unitario si el campo se completa.
+type PersonalizationField struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ProductID uuid.UUID `gorm:"type:uuid;index"`
+	Label     string    `gorm:"size:80"`
+	Kind      string    `gorm:"type:varchar(10);not null"`
+	Required  bool      `gorm:"not null;default:false"`
+	MaxLength int       `gorm:"not null;default:0"`
+	// Options: opciones de tipografía/color, una por línea.
+	Options   string  `gorm:"type:text"`
+	Surcharge float64 `gorm:"type:decimal(12,2);not null;default:0"`
+	Position  int     `gorm:"not null;default:0"`
+	CreatedAt time.Time
+}
+
+func (PersonalizationField) TableName() string { return "product_personalization_fields" }
+
+// OptionList devuelve las opciones no vacías, en orden.
+func (f PersonalizationField) OptionList() []string {
+	out := []string{}
+	for _, l := range strings.FieldsFunc(f.Options, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if v := strings.TrimSpace(l); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// PersonalizationValue es lo que eligió el cliente para un campo. En el carrito sólo se
+// guardan ID y Value; Label/Kind/Surcharge se completan desde el producto al armar la orden.
+// Para Kind logo, Value es la ruta del archivo en el storage (no público).
+type PersonalizationValue struct {
+	FieldID   uuid.UUID `json:"id"`
+	Label     string    `json:"label,omitempty"`
+	Kind      string    `json:"kind,omitempty"`
+	Value     string    `json:"value"`
+	Surcharge float64   `json:"surcharge,omitempty"`
+}
+
+// Display es el texto para notificaciones y comprobantes.
+func (v PersonalizationValue) Display() string {
+	if v.Kind == PersonalizationLogo {
+		return "logo adjunto"
+	}
+	return v.Value
+}
+
+func EncodePersonalization(vals []PersonalizationValue) string {
+	if len(vals) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(vals)
+	return string(b)
+}
+
+func DecodePersonalization(s string) []PersonalizationValue {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	var vals []PersonalizationValue
+	if err := json.Unmarshal([]byte(s), &vals); err != nil {
+		return nil
+	}
+	return vals
+}
+
+func PersonalizationSurcharge(vals []PersonalizationValue) float64 {
+	t := 0.0
+	for _, v := range vals {
+		t += v.Surcharge
+	}
+	return t
+}
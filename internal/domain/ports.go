@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,6 +17,16 @@ type ProductRepo interface {
 	DeleteImageByID(ctx context.Context, id uuid.UUID) error
 	DistinctCategories(ctx context.Context) ([]string, error)
 	BulkUpdatePrices(ctx context.Context, updates []PriceUpdate) error
+	ImageURLs(ctx context.Context) ([]string, error)
+	ReplaceImageURL(ctx context.Context, from, to string) (int64, error)
+	// CountImagesByURL cuenta las imágenes (de cualquier producto) que usan el archivo url.
+	CountImagesByURL(ctx context.Context, url string) (int64, error)
+	FindImageByURL(ctx context.Context, url string) (*Image, error)
+	UpdateImage(ctx context.Context, img *Image) error
+	ReorderImages(ctx context.Context, productID uuid.UUID, ids []uuid.UUID) error
+	SetPrimaryImage(ctx context.Context, productID, imageID uuid.UUID) error
+	SavePersonalizationField(ctx context.Context, f *PersonalizationField) error
+	DeletePersonalizationField(ctx context.Context, productID, fieldID uuid.UUID) error
 }
 
 type CustomerRepo interface {
@@ -38,10 +49,111 @@ type OrderRepo interface {
 	FindByID(ctx context.Context, id uuid.UUID) (*Order, error)
 	FindByPreferenceID(ctx context.Context, prefID string) (*Order, error)
 	UpdateStatus(ctx context.Context, id uuid.UUID, st OrderStatus) error
-	List(ctx context.Context, status *OrderStatus, mpStatus *string, page, pageSize int) ([]Order, int64, error)
+	// SaveIfStatus guarda la orden sólo si en la base sigue en el estado from; devuelve false si
+	// otro proceso la cambió antes.
+	SaveIfStatus(ctx context.Context, o *Order, from OrderStatus) (bool, error)
+	// SetNotified cambia el aviso de pago de la orden sólo si en la base tiene el valor contrario;
+	// devuelve false si otro proceso lo cambió antes.
+	SetNotified(ctx context.Context, id uuid.UUID, notified bool) (bool, error)
+	// SetPreference guarda sólo la preferencia de MercadoPago y el total con el que se armó.
+	SetPreference(ctx context.Context, id uuid.UUID, preferenceID string, total float64) error
+	// SetRefunded guarda sólo lo devuelto de la orden y el pago de MercadoPago reembolsado.
+	SetRefunded(ctx context.Context, id uuid.UUID, paymentID string, refunded float64) error
+	// List pagina las órdenes; search filtra por número de orden, ID, email o nombre.
+	List(ctx context.Context, status *OrderStatus, mpStatus *string, search string, page, pageSize int) ([]Order, int64, error)
 	ListInRange(ctx context.Context, from, to time.Time) ([]Order, error)
-	DeleteRange(ctx context.Context, from, to time.Time) (int64, error)
+	// ListArchiveEntries carga las órdenes creadas entre from y to (días completos) con todo lo
+	// que se borra con ellas, para archivarlas.
+	ListArchiveEntries(ctx context.Context, from, to time.Time) ([]OrderArchiveEntry, error)
+	// DeleteByIDs borra las órdenes y sus filas dependientes (items, historial, pagos, etc.) en
+	// una transacción. Los usos de cupón quedan, desvinculados de la orden.
+	DeleteByIDs(ctx context.Context, ids []uuid.UUID) (int64, error)
+	// RestoreArchiveEntries vuelve a insertar órdenes archivadas; lo que ya existe se saltea,
+	// salvo los usos de cupón, que se vuelven a asociar a la orden.
+	// Devuelve cuántas órdenes se insertaron.
+	RestoreArchiveEntries(ctx context.Context, entries []OrderArchiveEntry) (int, error)
 	FindPendingByEmailAndCoupon(ctx context.Context, email, couponCode string) ([]Order, error)
+	// ListByEmail lista las órdenes de un cliente (email sin distinguir mayúsculas), más nuevas primero.
+	ListByEmail(ctx context.Context, email string, page, pageSize int) ([]Order, int64, error)
+	// ListAwaitingPayment devuelve las órdenes esperando pago creadas antes de createdBefore.
+	ListAwaitingPayment(ctx context.Context, createdBefore time.Time) ([]Order, error)
+	// ListUnpaidMP devuelve las órdenes con preferencia de MercadoPago creadas entre from y to que
+	// siguen esperando el pago o se cancelaron sin cobrarse.
+	ListUnpaidMP(ctx context.Context, from, to time.Time) ([]Order, error)
+	MarkPaymentReminder(ctx context.Context, id uuid.UUID, at time.Time) error
+	AddStatusChange(ctx context.Context, c *OrderStatusChange) error
+	// ListStatusChanges devuelve el historial de las órdenes pedidas, del más viejo al más nuevo.
+	ListStatusChanges(ctx context.Context, orderIDs []uuid.UUID) ([]OrderStatusChange, error)
+	// ListTrackable devuelve las órdenes con número de seguimiento que todavía no se entregaron.
+	ListTrackable(ctx context.Context) ([]Order, error)
+	// SaveWithItems actualiza la orden y reemplaza todos sus items en una transacción.
+	SaveWithItems(ctx context.Context, o *Order) error
+	// EachForExport recorre por tandas las órdenes que cumplen el filtro, de la más vieja a la
+	// más nueva y con sus items, sin cargarlas todas en memoria; corta en el primer error de fn.
+	EachForExport(ctx context.Context, f OrderExportFilter, fn func(o *Order) error) error
+}
+
+type OrderEditRepo interface {
+	Add(ctx context.Context, e *OrderEdit) error
+	// ListByOrder devuelve las ediciones de la orden, la más nueva primero.
+	ListByOrder(ctx context.Context, orderID uuid.UUID) ([]OrderEdit, error)
+}
+
+type OrderArchiveRepo interface {
+	Add(ctx context.Context, a *OrderArchive) error
+	Save(ctx context.Context, a *OrderArchive) error
+	FindByID(ctx context.Context, id uuid.UUID) (*OrderArchive, error)
+	// List devuelve los archivos, el más nuevo primero.
+	List(ctx context.Context) ([]OrderArchive, error)
+}
+
+// OrderUpdateNotifier le avisa al cliente que el admin modificó su orden.
+type OrderUpdateNotifier interface {
+	NotifyOrderUpdated(ctx context.Context, o *Order, changes []string) error
+}
+
+type CartRepo interface {
+	FindByEmail(ctx context.Context, email string) (*CustomerCart, error)
+	// Save inserta o reemplaza el carrito del email.
+	Save(ctx context.Context, c *CustomerCart) error
+}
+
+// ShippingCarrier es un correo con cotización y alta de envíos (Andreani, Correo Argentino o el
+// fake de desarrollo).
+type ShippingCarrier interface {
+	Code() string
+	Name() string
+	Quote(ctx context.Context, req CarrierQuoteRequest) ([]CarrierRate, error)
+	CreateShipment(ctx context.Context, req CarrierShipmentRequest) (*CarrierShipment, error)
+}
+
+// ShipmentTracker consulta el seguimiento de un envío en el correo. Los eventos vienen sin
+// OrderID ni ID; el caller los asigna al guardarlos.
+type ShipmentTracker interface {
+	Code() string
+	Track(ctx context.Context, trackingNumber string) ([]TrackingEvent, error)
+	// TrackingURL es la página pública de seguimiento del número.
+	TrackingURL(trackingNumber string) string
+}
+
+// ShipmentNotifier avisa al cliente que su orden cambió de etapa de envío (email, WhatsApp).
+type ShipmentNotifier interface {
+	NotifyShipment(ctx context.Context, o *Order, stage string) error
+}
+
+type ShipmentEventRepo interface {
+	Add(ctx context.Context, e *TrackingEvent) error
+	// ListByOrder devuelve los eventos de la orden, del más viejo al más nuevo.
+	ListByOrder(ctx context.Context, orderID uuid.UUID) ([]TrackingEvent, error)
+}
+
+type ShippingZoneRepo interface {
+	// List devuelve todas las zonas con sus tarifas, por prioridad descendente.
+	List(ctx context.Context) ([]ShippingZone, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*ShippingZone, error)
+	// Save crea o actualiza la zona y reemplaza sus tarifas.
+	Save(ctx context.Context, z *ShippingZone) error
+	Delete(ctx context.Context, id uuid.UUID) error
 }
 
 type QuoteRepo interface {
@@ -54,6 +166,13 @@ type UploadedModelRepo interface {
 	FindByID(ctx context.Context, id uuid.UUID) (*UploadedModel, error)
 }
 
+type ProductModelRepo interface {
+	FindByProductID(ctx context.Context, productID uuid.UUID) (*ProductModel3D, error)
+	// Save inserta o reemplaza el modelo del producto (uno por producto).
+	Save(ctx context.Context, m *ProductModel3D) error
+	DeleteByProductID(ctx context.Context, productID uuid.UUID) error
+}
+
 type PageRepo interface {
 	FindBySlug(ctx context.Context, slug string) (*Page, error)
 	Save(ctx context.Context, p *Page) error
@@ -82,6 +201,9 @@ type CouponRepo interface {
 
 	// Tracking de uso
 	SaveUsage(ctx context.Context, usage *CouponUsage) error
+	// DeleteUsageByOrder borra el uso del cupón de una orden y descuenta el contador;
+	// devuelve false si la orden no lo había usado.
+	DeleteUsageByOrder(ctx context.Context, couponID, orderID uuid.UUID) (bool, error)
 	FindUsagesByEmail(ctx context.Context, email string, couponID uuid.UUID) ([]CouponUsage, error)
 	GetUsageStats(ctx context.Context, couponID uuid.UUID) (totalUses int64, totalDiscount float64, err error)
 }
@@ -97,16 +219,123 @@ type PricingService interface {
 type PaymentGateway interface {
 	CreatePreference(ctx context.Context, o *Order) (initPoint string, err error)
 	VerifyWebhook(signature string, body []byte) (event interface{}, err error)
-	PaymentInfo(ctx context.Context, paymentID string) (status string, externalRef string, err error)
+	PaymentInfo(ctx context.Context, paymentID string) (*GatewayPayment, error)
+	// FindPayment busca el pago aprobado de una orden (para órdenes sin MPPaymentID guardado).
+	FindPayment(ctx context.Context, o *Order) (paymentID string, err error)
+	// SearchPayments devuelve todos los pagos (aprobados, rechazados, pendientes) hechos con la
+	// referencia de la orden, los más nuevos primero.
+	SearchPayments(ctx context.Context, o *Order) ([]GatewayPayment, error)
+	// Refund devuelve amount del pago (0 = lo que queda del total). idempotencyKey evita
+	// reembolsar dos veces si se reintenta el pedido.
+	Refund(ctx context.Context, paymentID string, amount float64, idempotencyKey string) (*GatewayRefund, error)
+	// Refunds lista los reembolsos de un pago, incluidos los hechos desde el panel de la pasarela.
+	Refunds(ctx context.Context, paymentID string) ([]GatewayRefund, error)
 }
 
+type PaymentRepo interface {
+	// Save crea el pago o, si ya hay uno del mismo proveedor con el mismo ExternalID, lo actualiza.
+	Save(ctx context.Context, p *Payment) error
+	ListByOrders(ctx context.Context, orderIDs []uuid.UUID) ([]Payment, error)
+}
+
+type PaymentRuleRepo interface {
+	List(ctx context.Context) ([]PaymentRule, error)
+	// Save inserta o reemplaza la regla del medio de pago.
+	Save(ctx context.Context, r *PaymentRule) error
+}
+
+type TransferProofRepo interface {
+	Save(ctx context.Context, p *TransferProof) error
+	// SaveIfPending guarda el comprobante sólo si en la base sigue pendiente; false = ya lo revisó
+	// otro (ej. doble clic en "Aprobar").
+	SaveIfPending(ctx context.Context, p *TransferProof) (bool, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*TransferProof, error)
+	// ListByStatus devuelve los comprobantes en ese estado, los más viejos primero.
+	ListByStatus(ctx context.Context, status string) ([]TransferProof, error)
+	ListByOrder(ctx context.Context, orderID uuid.UUID) ([]TransferProof, error)
+}
+
+type ReconcileIssueRepo interface {
+	// Save crea la diferencia o, si ya existe una de la misma orden, pago y tipo, actualiza el detalle
+	// (sin volver a mostrar las que el admin ya descartó).
+	Save(ctx context.Context, i *ReconcileIssue) error
+	// ListOpen devuelve las diferencias sin descartar, las más nuevas primero.
+	ListOpen(ctx context.Context, limit int) ([]ReconcileIssue, error)
+	Dismiss(ctx context.Context, id uuid.UUID, actor string, at time.Time) error
+}
+
+// WebhookEventFilter filtra la bandeja de webhooks del admin; vacío = todos.
+type WebhookEventFilter struct {
+	Source string
+	Status string
+}
+
+type WebhookEventRepo interface {
+	// Create guarda el evento. Si ya hay uno con la misma fuente y EventID no crea nada, le suma
+	// un duplicado y devuelve false.
+	Create(ctx context.Context, e *WebhookEvent) (bool, error)
+	Save(ctx context.Context, e *WebhookEvent) error
+	FindByID(ctx context.Context, id uuid.UUID) (*WebhookEvent, error)
+	// ListDue devuelve los eventos pendientes o a reintentar con NextAttemptAt vencido, los más
+	// viejos primero.
+	ListDue(ctx context.Context, now time.Time, limit int) ([]WebhookEvent, error)
+	List(ctx context.Context, f WebhookEventFilter, page, pageSize int) ([]WebhookEvent, int64, error)
+	CountByStatus(ctx context.Context) (map[string]int64, error)
+	// DeleteProcessedBefore borra los eventos procesados o descartados antes de t.
+	DeleteProcessedBefore(ctx context.Context, t time.Time) (int64, error)
+}
+
+// TransferNotifier avisa al cliente que su comprobante de transferencia fue rechazado.
+type TransferNotifier interface {
+	NotifyTransferRejected(ctx context.Context, o *Order, reason string) error
+}
+
+type RefundRepo interface {
+	// Save crea el reembolso o, si ya existe uno con el mismo GatewayID, actualiza motivo y autor.
+	Save(ctx context.Context, r *Refund) error
+	FindByGatewayID(ctx context.Context, gatewayID string) (*Refund, error)
+	ListByOrders(ctx context.Context, orderIDs []uuid.UUID) ([]Refund, error)
+}
+
+// FileStorage guarda y lee archivos subidos. Las rutas devueltas por SaveModel/SaveImage
+// son las que se persisten (Image.URL, UploadedModel.Path) y las que aceptan Open/Delete/Exists.
 type FileStorage interface {
 	SaveModel(ctx context.Context, filename string, data []byte) (string, error)
 	SaveImage(ctx context.Context, filename string, data []byte) (string, error)
+	// SaveAttachment guarda archivos de clientes (ej. logos de personalización); no se sirven en /uploads.
+	SaveAttachment(ctx context.Context, filename string, data []byte) (string, error)
+	// SaveAttachmentFrom es SaveAttachment leyendo r hasta EOF, para archivos que no conviene
+	// tener enteros en memoria (ej. archivos de órdenes).
+	SaveAttachmentFrom(ctx context.Context, filename string, r io.Reader) (string, error)
+	Open(ctx context.Context, path string) (io.ReadCloser, error)
+	Delete(ctx context.Context, path string) error
+	Exists(ctx context.Context, path string) (bool, error)
+	// List devuelve los archivos bajo prefix ("images", "models", ...).
+	List(ctx context.Context, prefix string) ([]StoredFile, error)
+	// Move renombra un archivo; from y to usan el mismo formato de ruta que Save*.
+	Move(ctx context.Context, from, to string) error
+	// Put guarda data en una clave fija ("config/carousel.json"), reemplazando la anterior.
+	// contentType vacío se infiere del contenido.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+}
+
+// ModelConverter convierte un modelo STL/3MF a GLB, simplificando la malla si supera maxTriangles.
+type ModelConverter interface {
+	ToGLB(filename string, data []byte, maxTriangles int) ([]byte, *MeshStats, error)
+}
+
+type StorageGCRepo interface {
+	ReplaceDetected(ctx context.Context, items []StorageGCItem) error
+	List(ctx context.Context, status string) ([]StorageGCItem, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*StorageGCItem, error)
+	Save(ctx context.Context, it *StorageGCItem) error
+	ListQuarantinedBefore(ctx context.Context, before time.Time) ([]StorageGCItem, error)
 }
 
 type EmailService interface {
 	SendOrderConfirmation(ctx context.Context, order *Order) error
+	// SendPaymentReminder avisa que la orden se cancela en expiresAt si no se paga.
+	SendPaymentReminder(ctx context.Context, order *Order, expiresAt time.Time) error
 }
 
 type Clock interface{ Now() time.Time }
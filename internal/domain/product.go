@@ -26,6 +26,9 @@ type Product struct {
 	Variants    []Variant
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+
+	// Personalization: campos que completa el cliente (texto, tipografía, color, logo).
+	Personalization []PersonalizationField `gorm:"foreignKey:ProductID"`
 }
 
 type Variant struct {
@@ -44,9 +47,25 @@ type Image struct {
 	ProductID uuid.UUID `gorm:"type:uuid;index"`
 	URL       string    `gorm:"size:255"`
 	Alt       string    `gorm:"size:140"`
+	Position  int       `gorm:"not null;default:0"`
+	IsPrimary bool      `gorm:"not null;default:false"`
+	// Recorte y punto focal normalizados (0..1) sobre la imagen original.
+	// CropW/CropH en 0 = sin recorte; el foco por defecto es el centro.
+	CropX     float64 `gorm:"type:decimal(6,4);not null;default:0"`
+	CropY     float64 `gorm:"type:decimal(6,4);not null;default:0"`
+	CropW     float64 `gorm:"type:decimal(6,4);not null;default:0"`
+	CropH     float64 `gorm:"type:decimal(6,4);not null;default:0"`
+	FocalX    float64 `gorm:"type:decimal(6,4);not null;default:0.5"`
+	FocalY    float64 `gorm:"type:decimal(6,4);not null;default:0.5"`
 	CreatedAt time.Time
 }
 
+// HasCrop indica si la imagen tiene un recorte válido definido.
+func (im Image) HasCrop() bool {
+	return im.CropW > 0 && im.CropH > 0 && im.CropX >= 0 && im.CropY >= 0 &&
+		im.CropX+im.CropW <= 1.0001 && im.CropY+im.CropH <= 1.0001
+}
+
 type FeaturedProduct struct {
 	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
 	ProductID uuid.UUID `gorm:"type:uuid;uniqueIndex;not null"`
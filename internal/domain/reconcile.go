@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Diferencias que encuentra la conciliación entre las órdenes y MercadoPago.
+const (
+	// ReconcileMissedWebhook: MercadoPago tenía un pago que la orden no reflejaba; la conciliación
+	// ya la corrigió y queda el registro.
+	ReconcileMissedWebhook = "missed_webhook"
+	// ReconcileAmountMismatch: el pago aprobado no coincide con el total de la orden.
+	ReconcileAmountMismatch = "amount_mismatch"
+	// ReconcileBlocked: el estado del pago no se pudo aplicar a la orden.
+	ReconcileBlocked = "blocked"
+	// ReconcileError: no se pudo consultar MercadoPago o guardar la orden.
+	ReconcileError = "error"
+)
+
+// ReconcileIssue es una diferencia entre una orden y sus pagos en MercadoPago. Hay una por orden,
+// pago y tipo: si la conciliación la vuelve a encontrar, actualiza el detalle.
+type ReconcileIssue struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	OrderID   uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_reconcile_issue"`
+	PaymentID string    `gorm:"size:60;uniqueIndex:idx_reconcile_issue"`
+	// Kind: Reconcile*.
+	Kind        string      `gorm:"size:30;uniqueIndex:idx_reconcile_issue"`
+	OrderStatus OrderStatus `gorm:"type:varchar(30)"`
+	MPStatus    string      `gorm:"size:30"`
+	Amount      float64     `gorm:"type:decimal(12,2)"`
+	Detail      string      `gorm:"size:255"`
+	// Fixed: la conciliación ya llevó la orden al estado correcto.
+	Fixed bool
+	// DismissedBy/DismissedAt: el admin la revisó y la sacó de la lista.
+	DismissedBy string `gorm:"size:140"`
+	DismissedAt *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (ReconcileIssue) TableName() string { return "payment_reconcile_issues" }
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Refund es una devolución (total o parcial) del pago de una orden. Las hechas desde el panel de
+// MercadoPago llegan por webhook y se registran con Source webhook.
+type Refund struct {
+	ID      uuid.UUID `gorm:"type:uuid;primaryKey"`
+	OrderID uuid.UUID `gorm:"type:uuid;index"`
+	Amount  float64   `gorm:"type:decimal(12,2);not null"`
+	Reason  string    `gorm:"size:255"`
+	// GatewayID: id del reembolso en MercadoPago.
+	GatewayID string `gorm:"size:40;uniqueIndex"`
+	Status    string `gorm:"size:30"`
+	Actor     string `gorm:"size:140"`
+	Source    string `gorm:"size:30"`
+	CreatedAt time.Time
+}
+
+func (Refund) TableName() string { return "order_refunds" }
+
+// Counts indica si el reembolso descuenta plata de la orden (los rechazados o cancelados no).
+func (r Refund) Counts() bool {
+	return r.Status != "rejected" && r.Status != "cancelled"
+}
+
+// GatewayRefund es un reembolso tal como lo informa la pasarela de pago.
+type GatewayRefund struct {
+	ID        string
+	Amount    float64
+	Status    string
+	CreatedAt time.Time
+}
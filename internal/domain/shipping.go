@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ShippingPickup  = "retiro"
+	ShippingCourier = "envio"
+	ShippingCadete  = "cadete"
+)
+
+// ShippingMethodLabel devuelve el nombre legible de un método de entrega.
+func ShippingMethodLabel(method string) string {
+	switch method {
+	case ShippingCourier:
+		return "Envío a domicilio"
+	case ShippingCadete:
+		return "Cadete (Rosario)"
+	case ShippingPickup, "":
+		return "Retiro en el local"
+	case "whatsapp":
+		return "A coordinar"
+	}
+	return method
+}
+
+// Provinces son las opciones del selector de provincia del checkout.
+var Provinces = []string{
+	"Buenos Aires", "CABA", "Catamarca", "Chaco", "Chubut", "Cordoba", "Corrientes", "Entre Rios",
+	"Formosa", "Jujuy", "La Pampa", "La Rioja", "Mendoza", "Misiones", "Neuquen", "Rio Negro",
+	"Salta", "San Juan", "San Luis", "Santa Cruz", "Santa Fe", "Santiago del Estero",
+	"Tierra del Fuego", "Tucuman",
+}
+
+// ShippingZone agrupa destinos (provincias y/o rangos de código postal) con una tabla de
+// tarifas para un método de entrega. Si un destino matchea varias zonas gana la de mayor
+// prioridad; una zona sin provincias ni rangos cubre todo el país.
+type ShippingZone struct {
+	ID     uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Name   string    `gorm:"size:80;not null"`
+	Method string    `gorm:"size:20;not null;index"`
+	// Provinces: una por línea.
+	Provinces string `gorm:"type:text"`
+	// PostalRanges: rangos "2000-2013" o códigos sueltos, uno por línea.
+	PostalRanges string `gorm:"type:text"`
+	Priority     int    `gorm:"not null;default:0"`
+	// FreeFrom: subtotal de productos desde el cual el envío es gratis (0 = nunca).
+	FreeFrom  float64        `gorm:"type:decimal(12,2);not null;default:0"`
+	Active    bool           `gorm:"not null;default:true"`
+	Rates     []ShippingRate `gorm:"foreignKey:ZoneID;constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (ShippingZone) TableName() string { return "shipping_zones" }
+
+// ShippingRate es un escalón de la tabla de una zona: aplica si el paquete pesa hasta
+// MaxGrams y su lado más largo no supera MaxSideMM (0 = sin límite).
+type ShippingRate struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ZoneID    uuid.UUID `gorm:"type:uuid;index;not null"`
+	MaxGrams  float64   `gorm:"type:decimal(10,2);not null;default:0"`
+	MaxSideMM float64   `gorm:"type:decimal(8,2);not null;default:0"`
+	Price     float64   `gorm:"type:decimal(12,2);not null"`
+}
+
+func (ShippingRate) TableName() string { return "shipping_rates" }
+
+// ProvinceList devuelve las provincias no vacías de la zona.
+func (z ShippingZone) ProvinceList() []string {
+	return splitLines(z.Provinces)
+}
+
+// PostalRangeList devuelve los rangos de código postal no vacíos de la zona.
+func (z ShippingZone) PostalRangeList() []string {
+	return splitLines(z.PostalRanges)
+}
+
+func splitLines(s string) []string {
+	out := []string{}
+	for _, l := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if v := strings.TrimSpace(l); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ShippingPackage es el paquete a enviar: peso total y medidas del bulto (mm).
+type ShippingPackage struct {
+	Grams    float64
+	LongMM   float64
+	VolumeMM float64
+}
+
+// ShippingQuote es el resultado de cotizar un método para un destino.
+type ShippingQuote struct {
+	Method    string
+	Available bool
+	Cost      float64
+	Free      bool
+	Zone      string
+	// Reason explica por qué no está disponible.
+	Reason string
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Etapas del envío que informamos al cliente. El orden importa: una orden nunca vuelve a una
+// etapa anterior aunque el correo repita eventos viejos.
+const (
+	ShipmentStageShipped        = "shipped"
+	ShipmentStageOutForDelivery = "out_for_delivery"
+	ShipmentStageDelivered      = "delivered"
+)
+
+// ShipmentStageRank devuelve la posición de la etapa (0 = sin despachar).
+func ShipmentStageRank(stage string) int {
+	switch stage {
+	case ShipmentStageShipped:
+		return 1
+	case ShipmentStageOutForDelivery:
+		return 2
+	case ShipmentStageDelivered:
+		return 3
+	}
+	return 0
+}
+
+// ShipmentStageLabel devuelve el nombre legible de una etapa.
+func ShipmentStageLabel(stage string) string {
+	switch stage {
+	case ShipmentStageShipped:
+		return "En camino"
+	case ShipmentStageOutForDelivery:
+		return "En distribución"
+	case ShipmentStageDelivered:
+		return "Entregado"
+	case "":
+		return "Sin despachar"
+	}
+	return stage
+}
+
+// ShipmentStageFromText clasifica la descripción de un evento de los correos argentinos.
+// Devuelve "" si el evento no cambia la etapa (ej. "visita sin entrega").
+func ShipmentStageFromText(text string) string {
+	t := strings.ToLower(text)
+	switch {
+	case strings.Contains(t, "no entregado"), strings.Contains(t, "sin entrega"), strings.Contains(t, "devuel"):
+		return ""
+	case strings.Contains(t, "entregad"):
+		return ShipmentStageDelivered
+	case strings.Contains(t, "distribuci"), strings.Contains(t, "reparto"), strings.Contains(t, "en camino al domicilio"):
+		return ShipmentStageOutForDelivery
+	case strings.Contains(t, "ingres"), strings.Contains(t, "admit"), strings.Contains(t, "imposic"), strings.Contains(t, "en viaje"), strings.Contains(t, "tránsito"), strings.Contains(t, "transito"):
+		return ShipmentStageShipped
+	}
+	return ""
+}
+
+// TrackingEvent es un movimiento del envío informado por el correo. Stage vacío indica un
+// evento informativo que no cambia la etapa (ej. "en centro de distribución").
+type TrackingEvent struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
+	OrderID     uuid.UUID `gorm:"type:uuid;index"`
+	Stage       string    `gorm:"size:30"`
+	Description string    `gorm:"size:255"`
+	Location    string    `gorm:"size:140"`
+	OccurredAt  time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
+
+func (TrackingEvent) TableName() string { return "shipment_events" }
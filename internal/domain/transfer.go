@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Estados de un comprobante de transferencia.
+const (
+	TransferProofPending  = "pending"
+	TransferProofApproved = "approved"
+	TransferProofRejected = "rejected"
+)
+
+// TransferProof es el comprobante que sube el cliente después de transferir. Queda pendiente hasta
+// que el admin lo verifica contra el banco.
+type TransferProof struct {
+	ID      uuid.UUID `gorm:"type:uuid;primaryKey"`
+	OrderID uuid.UUID `gorm:"type:uuid;index"`
+	// Path: ruta en FileStorage (attachments/...); se ve sólo desde el admin.
+	Path        string `gorm:"size:255"`
+	Filename    string `gorm:"size:255"`
+	ContentType string `gorm:"size:80"`
+	Size        int64
+	Status      string `gorm:"size:20;index"`
+	// Reason: motivo del rechazo, se le manda al cliente.
+	Reason     string `gorm:"size:255"`
+	ReviewedBy string `gorm:"size:140"`
+	ReviewedAt *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (TransferProof) TableName() string { return "transfer_proofs" }
+
+func (p TransferProof) IsPDF() bool { return p.ContentType == "application/pdf" }
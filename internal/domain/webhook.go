@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Origen de un webhook recibido.
+const (
+	WebhookSourceMercadoPago = "mercadopago"
+	WebhookSourceTelegram    = "telegram"
+	WebhookSourceWhatsApp    = "whatsapp"
+)
+
+// Estados de un evento en la bandeja de webhooks.
+const (
+	WebhookPending   = "pending"
+	WebhookProcessed = "processed"
+	// WebhookRetrying: falló y se vuelve a intentar en NextAttemptAt.
+	WebhookRetrying = "retrying"
+	// WebhookFailed: agotó los intentos; se puede reprocesar desde el admin.
+	WebhookFailed = "failed"
+	// WebhookSkipped: el evento no corresponde a nada que procesar (ej. una orden que no existe).
+	WebhookSkipped = "skipped"
+	// WebhookRejected: la firma no es válida; no se procesa.
+	WebhookRejected = "rejected"
+)
+
+// Resultado de verificar la firma del webhook.
+const (
+	WebhookSignatureValid   = "valid"
+	WebhookSignatureInvalid = "invalid"
+	// WebhookSignatureUnchecked: no hay secreto configurado para esa fuente.
+	WebhookSignatureUnchecked = "unchecked"
+)
+
+// WebhookEvent es un webhook tal como llegó. Se guarda antes de procesarlo y se deduplica por
+// fuente + EventID (el id que manda el proveedor o, si no manda ninguno, un hash del contenido).
+type WebhookEvent struct {
+	ID      uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Source  string    `gorm:"size:20;uniqueIndex:idx_webhook_event;index:idx_webhook_list"`
+	EventID string    `gorm:"size:120;uniqueIndex:idx_webhook_event"`
+	// Headers: JSON con los headers recibidos (sin cookies ni Authorization).
+	Headers   string `gorm:"type:text"`
+	Query     string `gorm:"size:500"`
+	Body      string `gorm:"type:text"`
+	Signature string `gorm:"size:20"`
+	// Status: Webhook*.
+	Status        string `gorm:"size:20;index;index:idx_webhook_list"`
+	Attempts      int
+	NextAttemptAt *time.Time `gorm:"index"`
+	LastError     string     `gorm:"size:500"`
+	// Note: qué hizo el procesamiento (ej. "orden abc: awaiting_payment → finished").
+	Note        string `gorm:"size:255"`
+	ProcessedAt *time.Time
+	// Duplicates: cuántas veces más llegó el mismo evento.
+	Duplicates int
+
+	CreatedAt time.Time `gorm:"index"`
+	UpdatedAt time.Time
+}
+
+func (WebhookEvent) TableName() string { return "webhook_events" }
+
+// Replayable indica si el admin puede volver a procesarlo.
+func (e WebhookEvent) Replayable() bool {
+	return e.Status != WebhookRejected && e.Status != WebhookPending
+}
@@ -93,3 +93,5 @@ type AppSetting struct {
 func (AppSetting) TableName() string { return "app_settings" }
 
 const SettingWorkshopDigestLast = "workshop_digest_last_date"
+
+const SettingStorageGCLast = "storage_gc_last_date"
@@ -0,0 +1,182 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// carrierQuoteTimeout acota cuánto esperamos a cada correo al cotizar en el checkout.
+const carrierQuoteTimeout = 8 * time.Second
+
+// CarrierUC cotiza con los correos configurados y da de alta los envíos de las órdenes.
+type CarrierUC struct {
+	Carriers []domain.ShippingCarrier
+	Orders   domain.OrderRepo
+	Products domain.ProductRepo
+	Storage  domain.FileStorage
+	// OriginPostal: código postal desde donde despachamos.
+	OriginPostal string
+}
+
+func (uc *CarrierUC) Enabled() bool { return uc != nil && len(uc.Carriers) > 0 }
+
+func (uc *CarrierUC) carrier(code string) domain.ShippingCarrier {
+	for _, c := range uc.Carriers {
+		if c.Code() == code {
+			return c
+		}
+	}
+	return nil
+}
+
+// Quote consulta a todos los correos en paralelo. Los que fallan se omiten (se loguean) para
+// no trabar el checkout; el resultado va ordenado por precio.
+func (uc *CarrierUC) Quote(ctx context.Context, destPostal, province string, pkg domain.ShippingPackage, declared float64) []domain.CarrierRate {
+	if !uc.Enabled() || strings.TrimSpace(destPostal) == "" {
+		return nil
+	}
+	req := domain.CarrierQuoteRequest{OriginPostal: uc.OriginPostal, DestPostal: strings.TrimSpace(destPostal), DestProvince: province, Package: pkg, DeclaredValue: declared}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		out []domain.CarrierRate
+	)
+	for _, c := range uc.Carriers {
+		wg.Add(1)
+		go func(c domain.ShippingCarrier) {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, carrierQuoteTimeout)
+			defer cancel()
+			rates, err := c.Quote(cctx, req)
+			if err != nil {
+				log.Warn().Err(err).Str("carrier", c.Code()).Str("cp", req.DestPostal).Msg("cotizar correo")
+				return
+			}
+			mu.Lock()
+			out = append(out, rates...)
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Price < out[j].Price })
+	return out
+}
+
+// QuoteOption vuelve a cotizar y devuelve la tarifa elegida ("correo:servicio"); el precio
+// nunca se toma del formulario.
+func (uc *CarrierUC) QuoteOption(ctx context.Context, option, destPostal, province string, pkg domain.ShippingPackage, declared float64) (domain.CarrierRate, error) {
+	code, service, ok := domain.ParseCarrierOption(option)
+	if !ok || uc.carrier(code) == nil {
+		return domain.CarrierRate{}, errors.New("opción de envío inválida")
+	}
+	c := uc.carrier(code)
+	cctx, cancel := context.WithTimeout(ctx, carrierQuoteTimeout)
+	defer cancel()
+	rates, err := c.Quote(cctx, domain.CarrierQuoteRequest{OriginPostal: uc.OriginPostal, DestPostal: strings.TrimSpace(destPostal), DestProvince: province, Package: pkg, DeclaredValue: declared})
+	if err != nil {
+		return domain.CarrierRate{}, fmt.Errorf("%s no respondió, probá de nuevo o elegí otra opción", c.Name())
+	}
+	for _, r := range rates {
+		if r.Service == service {
+			return r, nil
+		}
+	}
+	return domain.CarrierRate{}, fmt.Errorf("%s ya no ofrece ese servicio para el destino", c.Name())
+}
+
+// Names devuelve código → nombre de los correos configurados (para el admin).
+func (uc *CarrierUC) Names() map[string]string {
+	out := map[string]string{}
+	if uc == nil {
+		return out
+	}
+	for _, c := range uc.Carriers {
+		out[c.Code()] = c.Name()
+	}
+	return out
+}
+
+// OrderPackage arma el paquete de una orden con los datos actuales de sus productos.
+func (uc *CarrierUC) OrderPackage(ctx context.Context, o *domain.Order) (domain.ShippingPackage, error) {
+	ids := make([]uuid.UUID, 0, len(o.Items))
+	for _, it := range o.Items {
+		if it.ProductID != nil {
+			ids = append(ids, *it.ProductID)
+		}
+	}
+	products, err := (&ProductUC{Products: uc.Products}).ListByIDs(ctx, ids)
+	if err != nil {
+		return domain.ShippingPackage{}, err
+	}
+	byID := make(map[uuid.UUID]*domain.Product, len(products))
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+	}
+	items := make([]ShippingItem, 0, len(o.Items))
+	for _, it := range o.Items {
+		if it.ProductID != nil {
+			items = append(items, ShippingItem{Product: byID[*it.ProductID], Qty: it.Qty})
+		}
+	}
+	return BuildPackage(items), nil
+}
+
+// CreateShipment da de alta el envío de la orden en el correo (el elegido en el checkout o
+// carrierCode si no eligió ninguno), guarda el número de seguimiento y la etiqueta.
+func (uc *CarrierUC) CreateShipment(ctx context.Context, orderID uuid.UUID, carrierCode string) (*domain.Order, error) {
+	o, err := uc.Orders.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if o.ShippingMethod != domain.ShippingCourier {
+		return nil, errors.New("la orden no es con envío a domicilio")
+	}
+	if o.TrackingNumber != "" {
+		return nil, fmt.Errorf("la orden ya tiene envío (%s)", o.TrackingNumber)
+	}
+	if o.Carrier != "" {
+		carrierCode = o.Carrier
+	}
+	c := uc.carrier(carrierCode)
+	if c == nil {
+		return nil, errors.New("correo no configurado")
+	}
+	pkg, err := uc.OrderPackage(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	sh, err := c.CreateShipment(ctx, domain.CarrierShipmentRequest{Service: o.CarrierService, OriginPostal: uc.OriginPostal, Order: o, Package: pkg})
+	if err != nil {
+		return nil, err
+	}
+	o.Carrier = c.Code()
+	o.TrackingNumber = sh.TrackingNumber
+	if t, ok := c.(domain.ShipmentTracker); ok {
+		o.TrackingURL = t.TrackingURL(sh.TrackingNumber)
+	}
+	if len(sh.LabelPDF) > 0 && uc.Storage != nil {
+		path, err := uc.Storage.SaveAttachment(ctx, "etiqueta-"+sh.TrackingNumber+".pdf", sh.LabelPDF)
+		if err != nil {
+			log.Error().Err(err).Str("order_id", o.ID.String()).Msg("guardar etiqueta de envío")
+		} else {
+			if !strings.HasPrefix(path, "/") {
+				path = "/" + strings.ReplaceAll(path, "\\", "/")
+			}
+			o.ShippingLabel = path
+		}
+	}
+	if err := uc.Orders.Save(ctx, o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
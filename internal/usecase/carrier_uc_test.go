@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/adapters/carriers/fake"
+	"github.com/phenrril/tienda3d/internal/adapters/storage/localfs"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+func TestCarrierQuoteWithFake(t *testing.T) {
+	uc := &CarrierUC{Carriers: []domain.ShippingCarrier{fake.New()}, OriginPostal: "2000"}
+	pkg := domain.ShippingPackage{Grams: 800, VolumeMM: 150 * 150 * 150, LongMM: 150}
+
+	rates := uc.Quote(context.Background(), "5000", "Córdoba", pkg, 20000)
+	if len(rates) != 2 {
+		t.Fatalf("rates = %+v", rates)
+	}
+	if rates[0].Service != "estandar" || rates[1].Service != "express" || rates[0].Price >= rates[1].Price {
+		t.Fatalf("las tarifas no vienen ordenadas por precio: %+v", rates)
+	}
+	if uc.Quote(context.Background(), "", "Córdoba", pkg, 20000) != nil {
+		t.Fatal("sin código postal no debería cotizar")
+	}
+
+	// la opción elegida se vuelve a cotizar: el precio sale del correo, no del formulario
+	rate, err := uc.QuoteOption(context.Background(), "fake:express", "5000", "Córdoba", pkg, 20000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rate != rates[1] {
+		t.Fatalf("QuoteOption = %+v; want %+v", rate, rates[1])
+	}
+	for _, opt := range []string{"fake:nocturno", "andreani:estandar", "fake", ""} {
+		if _, err := uc.QuoteOption(context.Background(), opt, "5000", "Córdoba", pkg, 20000); err == nil {
+			t.Errorf("QuoteOption(%q) no falló", opt)
+		}
+	}
+}
+
+func TestCarrierCreateShipmentWithFake(t *testing.T) {
+	ctx := context.Background()
+	product := domain.Product{ID: uuid.New(), Name: "Maceta", Grams: 350, WidthMM: 120, HeightMM: 120, DepthMM: 120}
+	o := &domain.Order{
+		ID:             uuid.New(),
+		Status:         domain.OrderStatusFinished,
+		Name:           "Ana",
+		Address:        "San Martín 123",
+		PostalCode:     "5000",
+		Province:       "Córdoba",
+		ShippingMethod: domain.ShippingCourier,
+		Carrier:        "fake",
+		CarrierService: "estandar",
+		Items:          []domain.OrderItem{{ID: uuid.New(), ProductID: &product.ID, Qty: 2, Title: "Maceta"}},
+	}
+	orders := newMemOrderRepo(o)
+	storage := localfs.New(t.TempDir())
+	uc := &CarrierUC{
+		Carriers:     []domain.ShippingCarrier{fake.New()},
+		Orders:       orders,
+		Products:     &memProductRepo{products: []domain.Product{product}},
+		Storage:      storage,
+		OriginPostal: "2000",
+	}
+
+	pkg, err := uc.OrderPackage(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Grams != 700 || pkg.LongMM != 120 {
+		t.Fatalf("paquete = %+v", pkg)
+	}
+
+	got, err := uc.CreateShipment(ctx, o.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got.TrackingNumber, "FAKE") || got.Carrier != "fake" {
+		t.Fatalf("envío = %q / %q", got.Carrier, got.TrackingNumber)
+	}
+	saved := orders.get(o.ID)
+	if saved.TrackingNumber != got.TrackingNumber || saved.ShippingLabel == "" {
+		t.Fatalf("la orden guardada no tiene el envío: %+v", saved)
+	}
+	rc, err := storage.Open(ctx, saved.ShippingLabel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	label, _ := io.ReadAll(rc)
+	rc.Close()
+	if !bytes.HasPrefix(label, []byte("%PDF-")) || !bytes.Contains(label, []byte(got.TrackingNumber)) {
+		t.Fatalf("la etiqueta no es un PDF con el seguimiento (%d bytes)", len(label))
+	}
+
+	if _, err := uc.CreateShipment(ctx, o.ID, ""); err == nil {
+		t.Fatal("una orden con envío no debería darse de alta dos veces")
+	}
+	pickup := &domain.Order{ID: uuid.New(), ShippingMethod: domain.ShippingPickup}
+	_ = orders.Save(ctx, pickup)
+	if _, err := uc.CreateShipment(ctx, pickup.ID, "fake"); err == nil {
+		t.Fatal("una orden con retiro no debería tener envío")
+	}
+}
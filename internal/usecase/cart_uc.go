@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// maxCartLines limita el carrito guardado (las líneas repetidas se agrupan al mostrarlo).
+const maxCartLines = 100
+
+// CartUC guarda el carrito de los clientes logueados y lo revalida contra el catálogo.
+type CartUC struct {
+	Carts    domain.CartRepo
+	Products domain.ProductRepo
+}
+
+func (uc *CartUC) Load(ctx context.Context, email string) ([]domain.CartItem, error) {
+	if strings.TrimSpace(email) == "" {
+		return nil, errors.New("email vacío")
+	}
+	c, err := uc.Carts.FindByEmail(ctx, email)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var items []domain.CartItem
+	if c.Items != "" {
+		if err := json.Unmarshal([]byte(c.Items), &items); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+func (uc *CartUC) Save(ctx context.Context, email string, items []domain.CartItem) error {
+	if strings.TrimSpace(email) == "" {
+		return errors.New("email vacío")
+	}
+	if len(items) > maxCartLines {
+		items = items[len(items)-maxCartLines:]
+	}
+	if items == nil {
+		items = []domain.CartItem{}
+	}
+	b, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return uc.Carts.Save(ctx, &domain.CustomerCart{Email: email, Items: string(b)})
+}
+
+// Merge suma al carrito guardado las líneas del carrito anónimo (cookie) al loguearse.
+func (uc *CartUC) Merge(ctx context.Context, email string, anon []domain.CartItem) ([]domain.CartItem, error) {
+	items, err := uc.Load(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if len(anon) == 0 {
+		return items, nil
+	}
+	items = append(items, anon...)
+	if err := uc.Save(ctx, email, items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Revalidate compara cada línea con el catálogo actual: saca productos que ya no existen o
+// cuya personalización dejó de ser válida, y actualiza el precio guardado avisando si cambió.
+// changed indica que hay que volver a guardar el carrito.
+func (uc *CartUC) Revalidate(ctx context.Context, items []domain.CartItem) (out []domain.CartItem, changes []domain.CartChange, changed bool) {
+	products := map[string]*domain.Product{}
+	for _, it := range items {
+		p, seen := products[it.Slug]
+		if !seen {
+			var err error
+			p, err = uc.Products.FindBySlug(ctx, it.Slug)
+			if err != nil && !errors.Is(err, domain.ErrNotFound) {
+				// error de base: no tocar la línea
+				out = append(out, it)
+				continue
+			}
+			products[it.Slug] = p
+		}
+		if p == nil {
+			changes = append(changes, domain.CartChange{Name: it.Slug, Removed: true, Reason: "ya no está disponible"})
+			changed = true
+			continue
+		}
+		if len(it.PZ) > 0 || len(p.Personalization) > 0 {
+			if !personalizationStillValid(p, it.PZ) {
+				changes = append(changes, domain.CartChange{Name: p.Name, Removed: true, Reason: "cambiaron sus opciones de personalización; volvé a agregarlo desde el producto"})
+				changed = true
+				continue
+			}
+		}
+		if p.BasePrice != 0 {
+			current := p.BasePrice + domain.PersonalizationSurcharge(ApplyPersonalization(p, it.PZ))
+			if math.Abs(current-it.Price) > 0.009 {
+				if it.Price > 0 {
+					changes = append(changes, domain.CartChange{Name: p.Name, OldPrice: it.Price, NewPrice: current})
+				}
+				it.Price = current
+				changed = true
+			}
+		}
+		out = append(out, it)
+	}
+	return out, changes, changed
+}
+
+// personalizationStillValid revisa que los valores guardados sigan pasando la validación
+// de los campos actuales y que no haya valores de campos borrados.
+func personalizationStillValid(p *domain.Product, vals []domain.PersonalizationValue) bool {
+	known := make(map[uuid.UUID]bool, len(p.Personalization))
+	for _, f := range p.Personalization {
+		known[f.ID] = true
+	}
+	input := make(map[uuid.UUID]string, len(vals))
+	for _, v := range vals {
+		if !known[v.FieldID] {
+			return false
+		}
+		input[v.FieldID] = v.Value
+	}
+	_, err := (&ProductUC{}).ResolvePersonalization(p, input)
+	return err == nil
+}
@@ -0,0 +1,187 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type memCartRepo struct {
+	carts map[string]domain.CustomerCart
+}
+
+func (r *memCartRepo) FindByEmail(ctx context.Context, email string) (*domain.CustomerCart, error) {
+	c, ok := r.carts[email]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &c, nil
+}
+
+func (r *memCartRepo) Save(ctx context.Context, c *domain.CustomerCart) error {
+	r.carts[c.Email] = *c
+	return nil
+}
+
+// slugProducts devuelve los productos por slug; "caido" simula un error de base.
+type slugProducts struct {
+	domain.ProductRepo
+	bySlug map[string]*domain.Product
+}
+
+func (p *slugProducts) FindBySlug(ctx context.Context, slug string) (*domain.Product, error) {
+	if slug == "caido" {
+		return nil, errors.New("conexión cerrada")
+	}
+	if pr, ok := p.bySlug[slug]; ok {
+		cp := *pr
+		return &cp, nil
+	}
+	return nil, domain.ErrNotFound
+}
+
+func TestCartMerge(t *testing.T) {
+	ctx := context.Background()
+	llavero := domain.CartItem{Slug: "llavero", Color: "rojo", Qty: 1, Price: 1500}
+	maceta := domain.CartItem{Slug: "maceta", Color: "blanco", Qty: 2, Price: 5000}
+	cases := []struct {
+		name  string
+		saved []domain.CartItem
+		anon  []domain.CartItem
+		want  []domain.CartItem
+	}{
+		{"sin carrito guardado", nil, []domain.CartItem{maceta}, []domain.CartItem{maceta}},
+		{"sin carrito anónimo", []domain.CartItem{llavero}, nil, []domain.CartItem{llavero}},
+		{"suma las líneas", []domain.CartItem{llavero}, []domain.CartItem{maceta, llavero}, []domain.CartItem{llavero, maceta, llavero}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			uc := &CartUC{Carts: &memCartRepo{carts: map[string]domain.CustomerCart{}}}
+			if c.saved != nil {
+				if err := uc.Save(ctx, "c@example.com", c.saved); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := uc.Merge(ctx, "c@example.com", c.anon)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("merge = %+v; want %+v", got, c.want)
+			}
+			loaded, err := uc.Load(ctx, "c@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(loaded) != len(c.want) {
+				t.Fatalf("guardado = %+v; want %+v", loaded, c.want)
+			}
+		})
+	}
+
+	// el carrito guardado se corta en las últimas maxCartLines líneas
+	uc := &CartUC{Carts: &memCartRepo{carts: map[string]domain.CustomerCart{}}}
+	anon := make([]domain.CartItem, maxCartLines+5)
+	for i := range anon {
+		anon[i] = domain.CartItem{Slug: "llavero", Qty: i + 1}
+	}
+	if _, err := uc.Merge(ctx, "c@example.com", anon); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, _ := uc.Load(ctx, "c@example.com"); len(loaded) != maxCartLines || loaded[0].Qty != 6 {
+		t.Fatalf("guardadas %d líneas, la primera con qty %d", len(loaded), loaded[0].Qty)
+	}
+	if _, err := uc.Merge(ctx, " ", anon); err == nil {
+		t.Fatal("se guardó un carrito sin email")
+	}
+}
+
+func TestCartRevalidate(t *testing.T) {
+	ctx := context.Background()
+	nombre := domain.PersonalizationField{ID: uuid.New(), Label: "Nombre", Kind: domain.PersonalizationText, MaxLength: 10, Surcharge: 300}
+	color := domain.PersonalizationField{ID: uuid.New(), Label: "Color", Kind: domain.PersonalizationColor, Options: "Rojo\nAzul", Surcharge: 100}
+	products := &slugProducts{bySlug: map[string]*domain.Product{
+		"maceta":   {Name: "Maceta", Slug: "maceta", BasePrice: 5000},
+		"llavero":  {Name: "Llavero", Slug: "llavero", BasePrice: 1500, Personalization: []domain.PersonalizationField{nombre, color}},
+		"cotizar":  {Name: "Pieza a medida", Slug: "cotizar"},
+		"obligado": {Name: "Taza", Slug: "obligado", BasePrice: 2000, Personalization: []domain.PersonalizationField{{ID: uuid.New(), Label: "Frase", Kind: domain.PersonalizationText, Required: true}}},
+	}}
+	uc := &CartUC{Products: products}
+	pz := func(vals ...domain.PersonalizationValue) []domain.PersonalizationValue { return vals }
+
+	cases := []struct {
+		name    string
+		item    domain.CartItem
+		keep    bool
+		price   float64
+		change  *domain.CartChange
+		changed bool
+	}{
+		{name: "precio igual", item: domain.CartItem{Slug: "maceta", Qty: 1, Price: 5000}, keep: true, price: 5000},
+		{name: "diferencia de redondeo", item: domain.CartItem{Slug: "maceta", Qty: 1, Price: 5000.004}, keep: true, price: 5000.004},
+		{name: "subió el precio", item: domain.CartItem{Slug: "maceta", Qty: 1, Price: 4500}, keep: true, price: 5000,
+			change: &domain.CartChange{Name: "Maceta", OldPrice: 4500, NewPrice: 5000}, changed: true},
+		{name: "línea vieja sin precio no avisa", item: domain.CartItem{Slug: "maceta", Qty: 1}, keep: true, price: 5000, changed: true},
+		{name: "con recargos de personalización", item: domain.CartItem{Slug: "llavero", Qty: 1, Price: 1900,
+			PZ: pz(domain.PersonalizationValue{FieldID: nombre.ID, Value: "Ana"}, domain.PersonalizationValue{FieldID: color.ID, Value: "Azul"})}, keep: true, price: 1900},
+		{name: "opción que ya no existe", item: domain.CartItem{Slug: "llavero", Qty: 1, Price: 1600,
+			PZ: pz(domain.PersonalizationValue{FieldID: color.ID, Value: "Verde"})},
+			change: &domain.CartChange{Name: "Llavero", Removed: true}, changed: true},
+		{name: "campo borrado", item: domain.CartItem{Slug: "llavero", Qty: 1, Price: 1500,
+			PZ: pz(domain.PersonalizationValue{FieldID: uuid.New(), Value: "Ana"})},
+			change: &domain.CartChange{Name: "Llavero", Removed: true}, changed: true},
+		{name: "campo nuevo obligatorio", item: domain.CartItem{Slug: "obligado", Qty: 1, Price: 2000},
+			change: &domain.CartChange{Name: "Taza", Removed: true}, changed: true},
+		{name: "producto inexistente", item: domain.CartItem{Slug: "borrado", Qty: 1, Price: 100},
+			change: &domain.CartChange{Name: "borrado", Removed: true}, changed: true},
+		{name: "producto a cotizar", item: domain.CartItem{Slug: "cotizar", Qty: 1}, keep: true},
+		{name: "error de base no toca la línea", item: domain.CartItem{Slug: "caido", Qty: 1, Price: 100}, keep: true, price: 100},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out, changes, changed := uc.Revalidate(ctx, []domain.CartItem{c.item})
+			if changed != c.changed {
+				t.Fatalf("changed = %v; want %v", changed, c.changed)
+			}
+			if !c.keep {
+				if len(out) != 0 {
+					t.Fatalf("quedó la línea %+v", out)
+				}
+			} else if len(out) != 1 || out[0].Price != c.price {
+				t.Fatalf("líneas = %+v; want precio %v", out, c.price)
+			}
+			if c.change == nil {
+				if len(changes) != 0 {
+					t.Fatalf("avisos = %+v", changes)
+				}
+				return
+			}
+			if len(changes) != 1 {
+				t.Fatalf("avisos = %+v", changes)
+			}
+			got := changes[0]
+			got.Reason = ""
+			if got != *c.change {
+				t.Fatalf("aviso = %+v; want %+v", got, *c.change)
+			}
+			if c.change.Removed && changes[0].Reason == "" {
+				t.Fatal("aviso de línea sacada sin motivo")
+			}
+		})
+	}
+
+	// el mismo producto en varias líneas: cada línea se revisa por separado
+	out, changes, changed := uc.Revalidate(ctx, []domain.CartItem{
+		{Slug: "maceta", Qty: 1, Price: 5000},
+		{Slug: "borrado", Qty: 1},
+		{Slug: "maceta", Qty: 3, Price: 4000},
+	})
+	if !changed || len(out) != 2 || out[1].Price != 5000 || out[1].Qty != 3 || len(changes) != 2 {
+		t.Fatalf("out = %+v, avisos = %+v", out, changes)
+	}
+}
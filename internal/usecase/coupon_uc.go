@@ -11,6 +11,17 @@ import (
 	"github.com/phenrril/tienda3d/internal/domain"
 )
 
+// Errores de ValidateCoupon; el checkout los traduce a mensajes fijos para el carrito.
+var (
+	ErrCouponNotFound     = errors.New("cupón no encontrado")
+	ErrCouponInactive     = errors.New("cupón desactivado")
+	ErrCouponExpired      = errors.New("cupón expirado")
+	ErrCouponExhausted    = errors.New("cupón alcanzó el límite de usos")
+	ErrCouponMinPurchase  = errors.New("monto mínimo de compra no alcanzado")
+	ErrCouponAlreadyUsed  = errors.New("ya has usado este cupón anteriormente")
+	ErrCouponPendingOrder = errors.New("tenés una orden pendiente con este cupón")
+)
+
 type CouponUseCase struct {
 	repo      domain.CouponRepo
 	orderRepo domain.OrderRepo
@@ -41,29 +52,29 @@ func (uc *CouponUseCase) ValidateCoupon(ctx context.Context, code, email string,
 	coupon, err := uc.repo.FindByCode(ctx, code)
 	if err != nil {
 		if errors.Is(err, domain.ErrNotFound) {
-			return nil, errors.New("cupón no encontrado")
+			return nil, ErrCouponNotFound
 		}
 		return nil, fmt.Errorf("error al buscar cupón: %w", err)
 	}
 
 	// 2. Validar que está activo
-	if !coupon.Active {
-		return nil, errors.New("cupón desactivado")
+	if err := checkCouponActive(coupon); err != nil {
+		return nil, err
 	}
 
 	// 3. Validar que no ha expirado
 	if coupon.ExpiresAt != nil && time.Now().After(*coupon.ExpiresAt) {
-		return nil, errors.New("cupón expirado")
+		return nil, ErrCouponExpired
 	}
 
 	// 4. Validar que no se alcanzó el límite de usos
 	if coupon.MaxUses != nil && coupon.CurrentUses >= *coupon.MaxUses {
-		return nil, errors.New("cupón alcanzó el límite de usos")
+		return nil, ErrCouponExhausted
 	}
 
 	// 5. Validar monto mínimo de compra
-	if subtotal < coupon.MinPurchaseAmount {
-		return nil, fmt.Errorf("monto mínimo de compra no alcanzado (requerido: $%.2f)", coupon.MinPurchaseAmount)
+	if err := checkCouponMinPurchase(coupon, subtotal); err != nil {
+		return nil, err
 	}
 
 	// 6. Validar si el usuario ya lo usó (usos confirmados)
@@ -73,7 +84,7 @@ func (uc *CouponUseCase) ValidateCoupon(ctx context.Context, code, email string,
 	}
 
 	if len(usages) > 0 {
-		return nil, errors.New("ya has usado este cupón anteriormente")
+		return nil, ErrCouponAlreadyUsed
 	}
 
 	// 7. Validar si el usuario tiene órdenes pendientes con este cupón
@@ -83,13 +94,37 @@ func (uc *CouponUseCase) ValidateCoupon(ctx context.Context, code, email string,
 	}
 
 	if len(pendingOrders) > 0 {
-		return nil, fmt.Errorf("ya tienes %d orden(es) pendiente(s) con este cupón. Completa o cancela esa orden antes de usar el cupón nuevamente", len(pendingOrders))
+		return nil, fmt.Errorf("%w (%d): completá o cancelá esa orden antes de usar el cupón nuevamente", ErrCouponPendingOrder, len(pendingOrders))
 	}
 
 	return coupon, nil
 }
 
 // CalculateDiscount calcula el descuento a aplicar basado en el cupón y el subtotal
+// CheckOrderCoupon revisa, para una orden que ya tiene el cupón, las condiciones de ValidateCoupon
+// que dependen de la orden: que el cupón siga activo y el monto mínimo de compra. El vencimiento,
+// los usos y el límite por cliente se validaron al aplicarlo en el checkout.
+func (uc *CouponUseCase) CheckOrderCoupon(coupon *domain.Coupon, subtotal float64) error {
+	if err := checkCouponActive(coupon); err != nil {
+		return err
+	}
+	return checkCouponMinPurchase(coupon, subtotal)
+}
+
+func checkCouponActive(coupon *domain.Coupon) error {
+	if !coupon.Active {
+		return ErrCouponInactive
+	}
+	return nil
+}
+
+func checkCouponMinPurchase(coupon *domain.Coupon, subtotal float64) error {
+	if subtotal < coupon.MinPurchaseAmount {
+		return fmt.Errorf("%w (requerido: $%.2f)", ErrCouponMinPurchase, coupon.MinPurchaseAmount)
+	}
+	return nil
+}
+
 func (uc *CouponUseCase) CalculateDiscount(coupon *domain.Coupon, subtotal float64) float64 {
 	if coupon == nil {
 		return 0
@@ -132,7 +167,7 @@ func (uc *CouponUseCase) ApplyCoupon(ctx context.Context, couponID, orderID uuid
 	usage := &domain.CouponUsage{
 		ID:              uuid.New(),
 		CouponID:        couponID,
-		OrderID:         orderID,
+		OrderID:         &orderID,
 		Email:           email,
 		DiscountApplied: discountApplied,
 		OrderTotal:      orderTotal,
@@ -146,6 +181,16 @@ func (uc *CouponUseCase) ApplyCoupon(ctx context.Context, couponID, orderID uuid
 	return nil
 }
 
+// RevertCoupon deshace el uso del cupón de una orden (por ej. al reembolsarla entera), así el
+// cliente puede volver a usarlo y no cuenta para el límite de usos.
+func (uc *CouponUseCase) RevertCoupon(ctx context.Context, couponID, orderID uuid.UUID) (bool, error) {
+	reverted, err := uc.repo.DeleteUsageByOrder(ctx, couponID, orderID)
+	if err != nil {
+		return false, fmt.Errorf("error al revertir uso del cupón: %w", err)
+	}
+	return reverted, nil
+}
+
 // GetCouponStats obtiene estadísticas de uso de un cupón
 func (uc *CouponUseCase) GetCouponStats(ctx context.Context, couponID uuid.UUID) (totalUses int64, totalDiscount float64, err error) {
 	return uc.repo.GetUsageStats(ctx, couponID)
@@ -0,0 +1,35 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// CustomerOrders lista las órdenes del cliente logueado.
+func (uc *OrderUC) CustomerOrders(ctx context.Context, email string, page, pageSize int) ([]domain.Order, int64, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil, 0, errors.New("email vacío")
+	}
+	return uc.Orders.ListByEmail(ctx, email, page, pageSize)
+}
+
+// CustomerOrder devuelve una orden sólo si pertenece al email; si no, domain.ErrNotFound.
+func (uc *OrderUC) CustomerOrder(ctx context.Context, email string, id uuid.UUID) (*domain.Order, error) {
+	email = strings.TrimSpace(email)
+	if email == "" || id == uuid.Nil {
+		return nil, domain.ErrNotFound
+	}
+	o, err := uc.Orders.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(strings.TrimSpace(o.Email), email) {
+		return nil, domain.ErrNotFound
+	}
+	return o, nil
+}
@@ -0,0 +1,256 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// memOrderRepo es un OrderRepo en memoria con lo que usan los casos de uso bajo test; los
+// métodos que no implementa entran por la interfaz embebida (nil) y hacen panic si se llaman.
+type memOrderRepo struct {
+	domain.OrderRepo
+
+	mu      sync.Mutex
+	orders  map[uuid.UUID]domain.Order
+	history []domain.OrderStatusChange
+	number  int64
+}
+
+func newMemOrderRepo(orders ...*domain.Order) *memOrderRepo {
+	r := &memOrderRepo{orders: map[uuid.UUID]domain.Order{}}
+	for _, o := range orders {
+		_ = r.Save(context.Background(), o)
+	}
+	return r
+}
+
+// Save, como el repo de postgres, no pisa status ni notified de una orden existente.
+func (r *memOrderRepo) Save(ctx context.Context, o *domain.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur, ok := r.orders[o.ID]
+	if !ok && o.Number == 0 {
+		r.number++
+		o.Number = r.number
+	}
+	c := cloneOrder(o)
+	if ok {
+		c.Status, c.Notified = cur.Status, cur.Notified
+	}
+	r.orders[o.ID] = c
+	return nil
+}
+
+func (r *memOrderRepo) SaveIfStatus(ctx context.Context, o *domain.Order, from domain.OrderStatus) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur, ok := r.orders[o.ID]
+	if !ok || cur.Status != from {
+		return false, nil
+	}
+	c := cloneOrder(o)
+	c.Notified = cur.Notified
+	r.orders[o.ID] = c
+	return true, nil
+}
+
+func (r *memOrderRepo) FindByID(ctx context.Context, id uuid.UUID) (*domain.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o, ok := r.orders[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	c := cloneOrder(&o)
+	return &c, nil
+}
+
+func (r *memOrderRepo) ListTrackable(ctx context.Context) ([]domain.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.Order
+	for _, o := range r.orders {
+		if o.TrackingNumber != "" && o.ShipmentStage != domain.ShipmentStageDelivered {
+			out = append(out, cloneOrder(&o))
+		}
+	}
+	return out, nil
+}
+
+func (r *memOrderRepo) SetNotified(ctx context.Context, id uuid.UUID, notified bool) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o, ok := r.orders[id]
+	if !ok || o.Notified == notified {
+		return false, nil
+	}
+	o.Notified = notified
+	r.orders[id] = o
+	return true, nil
+}
+
+func (r *memOrderRepo) SetRefunded(ctx context.Context, id uuid.UUID, paymentID string, refunded float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o, ok := r.orders[id]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	o.MPPaymentID, o.RefundedAmount = paymentID, refunded
+	r.orders[id] = o
+	return nil
+}
+
+func (r *memOrderRepo) ListUnpaidMP(ctx context.Context, from, to time.Time) ([]domain.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.Order
+	for _, o := range r.orders {
+		unpaid := o.Status == domain.OrderStatusAwaitingPay || o.Status == domain.OrderStatusCancelled
+		if unpaid && o.MPPreferenceID != "" && !o.CreatedAt.Before(from) && !o.CreatedAt.After(to) {
+			out = append(out, cloneOrder(&o))
+		}
+	}
+	return out, nil
+}
+
+func (r *memOrderRepo) AddStatusChange(ctx context.Context, c *domain.OrderStatusChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.history = append(r.history, *c)
+	return nil
+}
+
+func (r *memOrderRepo) SaveWithItems(ctx context.Context, o *domain.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := cloneOrder(o)
+	if cur, ok := r.orders[o.ID]; ok {
+		c.Status, c.Notified = cur.Status, cur.Notified
+	}
+	r.orders[o.ID] = c
+	return nil
+}
+
+// get devuelve la orden tal como quedó guardada.
+func (r *memOrderRepo) get(id uuid.UUID) domain.Order {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.orders[id]
+}
+
+func cloneOrder(o *domain.Order) domain.Order {
+	c := *o
+	c.Items = append([]domain.OrderItem(nil), o.Items...)
+	return c
+}
+
+type memProductRepo struct {
+	domain.ProductRepo
+	products []domain.Product
+}
+
+func (r *memProductRepo) ListByIDs(ctx context.Context, ids []uuid.UUID) ([]domain.Product, error) {
+	var out []domain.Product
+	for _, p := range r.products {
+		for _, id := range ids {
+			if p.ID == id {
+				out = append(out, p)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+type memPaymentRepo struct {
+	mu   sync.Mutex
+	list []domain.Payment
+}
+
+// Save hace upsert por proveedor + ExternalID, como el índice único de la base.
+func (r *memPaymentRepo) Save(ctx context.Context, p *domain.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p.ExternalID != "" {
+		for i := range r.list {
+			if r.list[i].Provider == p.Provider && r.list[i].ExternalID == p.ExternalID {
+				p.ID = r.list[i].ID
+				r.list[i] = *p
+				return nil
+			}
+		}
+	}
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	r.list = append(r.list, *p)
+	return nil
+}
+
+func (r *memPaymentRepo) ListByOrders(ctx context.Context, orderIDs []uuid.UUID) ([]domain.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.Payment
+	for _, p := range r.list {
+		for _, id := range orderIDs {
+			if p.OrderID == id {
+				out = append(out, p)
+			}
+		}
+	}
+	return out, nil
+}
+
+// memCouponRepo guarda cupones y usos; el resto de CouponRepo no se usa.
+type memCouponRepo struct {
+	domain.CouponRepo
+
+	mu      sync.Mutex
+	coupons []domain.Coupon
+	uses    int
+	usages  []domain.CouponUsage
+}
+
+func (r *memCouponRepo) FindByID(ctx context.Context, id uuid.UUID) (*domain.Coupon, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.coupons {
+		if c.ID == id {
+			return &c, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (r *memCouponRepo) IncrementUses(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.uses++
+	return nil
+}
+
+func (r *memCouponRepo) SaveUsage(ctx context.Context, u *domain.CouponUsage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usages = append(r.usages, *u)
+	return nil
+}
+
+func (r *memCouponRepo) DeleteUsageByOrder(ctx context.Context, couponID, orderID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, u := range r.usages {
+		if u.CouponID == couponID && u.OrderID != nil && *u.OrderID == orderID {
+			r.usages = append(r.usages[:i], r.usages[i+1:]...)
+			r.uses--
+			return true, nil
+		}
+	}
+	return false, nil
+}
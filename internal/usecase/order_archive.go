@@ -0,0 +1,188 @@
+package usecase
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// OrderArchiveUC archiva las órdenes de un período antes de borrarlas y permite verlas y
+// restaurarlas. El archivo es un JSONL comprimido con gzip (una domain.OrderArchiveEntry por
+// línea) guardado como adjunto privado en el storage.
+type OrderArchiveUC struct {
+	Orders   domain.OrderRepo
+	Archives domain.OrderArchiveRepo
+	Storage  domain.FileStorage
+}
+
+// ArchiveAndDelete guarda el archivo de las órdenes creadas entre from y to y después las
+// borra. Si no hay órdenes devuelve nil sin archivo; si no se puede guardar el archivo no se
+// borra nada.
+func (uc *OrderArchiveUC) ArchiveAndDelete(ctx context.Context, from, to time.Time, actor string) (*domain.OrderArchive, int64, error) {
+	if to.Before(from) {
+		from, to = to, from
+	}
+	entries, err := uc.Orders.ListArchiveEntries(ctx, from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(entries) == 0 {
+		return nil, 0, nil
+	}
+
+	a := &domain.OrderArchive{ID: uuid.New(), PeriodFrom: from, PeriodTo: to, Orders: len(entries), Actor: actor}
+	ids := make([]uuid.UUID, len(entries))
+	for i := range entries {
+		ids[i] = entries[i].Order.ID
+		a.Items += len(entries[i].Order.Items)
+		a.CouponUsages += len(entries[i].CouponUsages)
+		a.Total += entries[i].Order.Total
+	}
+	a.Total = round2(a.Total)
+
+	// el archivo se comprime a medida que el storage lo va leyendo, sin armarlo entero en memoria
+	pr, pw := io.Pipe()
+	size := &countingWriter{w: pw}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(writeOrderArchive(size, entries))
+	}()
+	name := fmt.Sprintf("ordenes_%s_%s.jsonl.gz", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	a.Path, err = uc.Storage.SaveAttachmentFrom(ctx, name, pr)
+	// si el storage cortó antes de leer todo, esto destraba la escritura pendiente
+	pr.Close()
+	<-done
+	if err != nil {
+		return nil, 0, fmt.Errorf("guardar archivo de órdenes: %w", err)
+	}
+	a.Size = size.n
+	if err := uc.Archives.Add(ctx, a); err != nil {
+		if derr := uc.Storage.Delete(ctx, a.Path); derr != nil {
+			log.Error().Err(derr).Str("path", a.Path).Msg("borrar archivo de órdenes sin registrar")
+		}
+		return nil, 0, err
+	}
+	// se borran exactamente las órdenes archivadas (no las que se hayan creado en el medio)
+	deleted, err := uc.Orders.DeleteByIDs(ctx, ids)
+	if err != nil {
+		return a, 0, err
+	}
+	return a, deleted, nil
+}
+
+// List devuelve los archivos guardados, el más nuevo primero.
+func (uc *OrderArchiveUC) List(ctx context.Context) ([]domain.OrderArchive, error) {
+	return uc.Archives.List(ctx)
+}
+
+// Get devuelve el registro del archivo.
+func (uc *OrderArchiveUC) Get(ctx context.Context, id uuid.UUID) (*domain.OrderArchive, error) {
+	return uc.Archives.FindByID(ctx, id)
+}
+
+// Open abre el archivo comprimido tal como está en el storage (para descargarlo).
+func (uc *OrderArchiveUC) Open(ctx context.Context, a *domain.OrderArchive) (io.ReadCloser, error) {
+	return uc.Storage.Open(ctx, a.Path)
+}
+
+// Entries lee todas las órdenes del archivo.
+func (uc *OrderArchiveUC) Entries(ctx context.Context, a *domain.OrderArchive) ([]domain.OrderArchiveEntry, error) {
+	rc, err := uc.Storage.Open(ctx, a.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var out []domain.OrderArchiveEntry
+	err = ReadOrderArchive(rc, func(e *domain.OrderArchiveEntry) error {
+		out = append(out, *e)
+		return nil
+	})
+	return out, err
+}
+
+// Restore vuelve a cargar las órdenes del archivo; las que ya existen se saltean, así que se
+// puede repetir sin duplicar nada. Devuelve cuántas órdenes se insertaron.
+func (uc *OrderArchiveUC) Restore(ctx context.Context, id uuid.UUID, actor string) (*domain.OrderArchive, int, error) {
+	a, err := uc.Archives.FindByID(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries, err := uc.Entries(ctx, a)
+	if err != nil {
+		return nil, 0, fmt.Errorf("leer archivo de órdenes: %w", err)
+	}
+	n, err := uc.RestoreEntries(ctx, entries)
+	if err != nil {
+		return nil, 0, err
+	}
+	now := time.Now()
+	a.RestoredAt, a.RestoredBy = &now, actor
+	if err := uc.Archives.Save(ctx, a); err != nil {
+		log.Error().Err(err).Str("archive_id", a.ID.String()).Msg("marcar archivo restaurado")
+	}
+	return a, n, nil
+}
+
+// RestoreEntries inserta órdenes archivadas (por ejemplo, leídas de un archivo descargado).
+func (uc *OrderArchiveUC) RestoreEntries(ctx context.Context, entries []domain.OrderArchiveEntry) (int, error) {
+	if len(entries) == 0 {
+		return 0, errors.New("el archivo no tiene órdenes")
+	}
+	return uc.Orders.RestoreArchiveEntries(ctx, entries)
+}
+
+// writeOrderArchive escribe las órdenes en el formato del archivo (JSONL + gzip).
+func writeOrderArchive(w io.Writer, entries []domain.OrderArchiveEntry) error {
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// countingWriter cuenta los bytes que pasan por w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ReadOrderArchive recorre un archivo de órdenes (JSONL + gzip) línea por línea.
+func ReadOrderArchive(r io.Reader, fn func(e *domain.OrderArchiveEntry) error) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	dec := json.NewDecoder(zr)
+	for {
+		var e domain.OrderArchiveEntry
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := fn(&e); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/adapters/storage/localfs"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// archiveOrderRepo devuelve entries como las órdenes del período y anota lo que se borra.
+type archiveOrderRepo struct {
+	domain.OrderRepo
+	entries []domain.OrderArchiveEntry
+	deleted []uuid.UUID
+}
+
+func (r *archiveOrderRepo) ListArchiveEntries(ctx context.Context, from, to time.Time) ([]domain.OrderArchiveEntry, error) {
+	return r.entries, nil
+}
+
+func (r *archiveOrderRepo) DeleteByIDs(ctx context.Context, ids []uuid.UUID) (int64, error) {
+	r.deleted = append(r.deleted, ids...)
+	return int64(len(ids)), nil
+}
+
+type memArchiveRepo struct {
+	domain.OrderArchiveRepo
+	mu       sync.Mutex
+	archives []domain.OrderArchive
+}
+
+func (r *memArchiveRepo) Add(ctx context.Context, a *domain.OrderArchive) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.archives = append(r.archives, *a)
+	return nil
+}
+
+// failingStorage lee una parte del archivo y corta la subida.
+type failingStorage struct{ domain.FileStorage }
+
+func (failingStorage) SaveAttachmentFrom(ctx context.Context, filename string, r io.Reader) (string, error) {
+	if _, err := r.Read(make([]byte, 10)); err != nil {
+		return "", err
+	}
+	return "", errors.New("storage caído")
+}
+
+func archiveEntries(n int) []domain.OrderArchiveEntry {
+	entries := make([]domain.OrderArchiveEntry, n)
+	for i := range entries {
+		id := uuid.New()
+		entries[i] = domain.OrderArchiveEntry{
+			Order:        domain.Order{ID: id, Email: "cliente@example.com", Total: 1000.10, Items: []domain.OrderItem{{ID: uuid.New(), OrderID: id, Title: strings.Repeat("pieza ", 20), Qty: 1}}},
+			CouponUsages: []domain.CouponUsage{{ID: uuid.New(), CouponID: uuid.New(), OrderID: &id}},
+		}
+	}
+	return entries
+}
+
+func TestArchiveAndDeleteStreamsArchiveToStorage(t *testing.T) {
+	ctx := context.Background()
+	orders := &archiveOrderRepo{entries: archiveEntries(2000)}
+	archives := &memArchiveRepo{}
+	uc := &OrderArchiveUC{Orders: orders, Archives: archives, Storage: localfs.New(t.TempDir())}
+
+	day := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+	a, deleted, err := uc.ArchiveAndDelete(ctx, day, day, "admin@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2000 || len(orders.deleted) != 2000 || len(archives.archives) != 1 {
+		t.Fatalf("borradas = %d (%d ids), archivos = %d", deleted, len(orders.deleted), len(archives.archives))
+	}
+	if a.Orders != 2000 || a.Items != 2000 || a.CouponUsages != 2000 || a.Total != 2000200 {
+		t.Fatalf("archivo = %+v", a)
+	}
+	fi, err := os.Stat(a.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Size != fi.Size() || !strings.HasSuffix(filepath.Base(a.Path), "-ordenes_2025-03-14_2025-03-14.jsonl.gz") {
+		t.Fatalf("Size = %d, archivo de %d bytes en %s", a.Size, fi.Size(), a.Path)
+	}
+
+	entries, err := uc.Entries(ctx, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2000 || entries[1999].Order.ID != orders.entries[1999].Order.ID || *entries[0].CouponUsages[0].OrderID != orders.entries[0].Order.ID {
+		t.Fatalf("el archivo no devuelve las órdenes archivadas (%d)", len(entries))
+	}
+}
+
+func TestArchiveAndDeleteKeepsOrdersWhenStorageFails(t *testing.T) {
+	orders := &archiveOrderRepo{entries: archiveEntries(2000)}
+	archives := &memArchiveRepo{}
+	uc := &OrderArchiveUC{Orders: orders, Archives: archives, Storage: failingStorage{}}
+
+	day := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := uc.ArchiveAndDelete(context.Background(), day, day, "admin@example.com")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("ArchiveAndDelete no devolvió el error del storage")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ArchiveAndDelete quedó trabado escribiendo el archivo")
+	}
+	if len(orders.deleted) != 0 || len(archives.archives) != 0 {
+		t.Fatalf("borradas = %d, archivos = %d", len(orders.deleted), len(archives.archives))
+	}
+}
@@ -0,0 +1,310 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// ErrOrderNotEditable: la orden ya salió, se canceló o tiene reembolsos.
+var ErrOrderNotEditable = errors.New("la orden ya no se puede editar")
+
+// ErrNoOrderChanges: la edición no cambia nada de la orden.
+var ErrNoOrderChanges = errors.New("no hay cambios para guardar")
+
+// OrderEditUC permite al admin corregir una orden después del checkout (items, envío y
+// dirección). Los totales se recalculan igual que en el checkout: productos + envío, cupón y
+// ajuste del medio de pago.
+type OrderEditUC struct {
+	Orders   *OrderUC
+	Edits    domain.OrderEditRepo
+	Products *ProductUC
+	Coupons  *CouponUseCase
+	Shipping *ShippingUC
+	Notifier domain.OrderUpdateNotifier
+}
+
+// OrderEditItem es un item de la orden editada. ID vacío es un item nuevo (del producto
+// ProductSlug, al precio actual); Qty 0 quita el item.
+type OrderEditItem struct {
+	ID          uuid.UUID
+	ProductSlug string
+	Qty         int
+	Color       string
+	Observation string
+}
+
+// OrderEditInput es la orden como la deja el admin.
+type OrderEditInput struct {
+	Items          []OrderEditItem
+	ShippingMethod string
+	Address        string
+	Province       string
+	PostalCode     string
+	// ShippingCost: nil = se cotiza de nuevo con las zonas de envío, como en el checkout.
+	ShippingCost *float64
+	Note         string
+}
+
+// OrderEditable indica si el admin todavía puede editar la orden.
+func OrderEditable(o *domain.Order) bool {
+	if o.RefundedAmount > 0 {
+		return false
+	}
+	switch o.Status {
+	case domain.OrderStatusAwaitingPay, domain.OrderStatusFinished, domain.OrderStatusInPrint:
+		return true
+	}
+	return false
+}
+
+// Edit aplica la edición, recalcula los totales, la registra y le avisa al cliente.
+func (uc *OrderEditUC) Edit(ctx context.Context, orderID uuid.UUID, in OrderEditInput, actor string) (*domain.Order, *domain.OrderEdit, error) {
+	o, err := uc.Orders.Orders.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !OrderEditable(o) {
+		return nil, nil, ErrOrderNotEditable
+	}
+	prevTotal := o.Total
+
+	items, changes, err := uc.editItems(ctx, o.Items, in.Items)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(items) == 0 {
+		return nil, nil, errors.New("la orden tiene que tener al menos un producto")
+	}
+	o.Items = items
+
+	itemsTotal := 0.0
+	for _, it := range items {
+		itemsTotal += it.UnitPrice * float64(it.Qty)
+	}
+	shipChanges, err := uc.editShipping(ctx, o, in, itemsTotal)
+	if err != nil {
+		return nil, nil, err
+	}
+	changes = append(changes, shipChanges...)
+
+	// mismos pasos que el checkout: cupón sobre productos + envío y después el medio de pago
+	subtotal := itemsTotal + o.ShippingCost
+	discount := 0.0
+	var droppedCoupon *uuid.UUID
+	if o.CouponID != nil {
+		coupon, err := uc.Coupons.GetCoupon(ctx, *o.CouponID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cupón de la orden: %w", err)
+		}
+		// si con la edición el cupón ya no aplica (ej. no llega al mínimo) se quita de la orden
+		if err := uc.Coupons.CheckOrderCoupon(coupon, subtotal); err != nil {
+			changes = append(changes, fmt.Sprintf("Se quitó el cupón %s: %s", o.CouponCode, err))
+			droppedCoupon = o.CouponID
+			o.CouponID, o.CouponCode = nil, ""
+		} else {
+			discount = uc.Coupons.CalculateDiscount(coupon, subtotal)
+		}
+	}
+	// se respeta el ajuste acordado en el checkout aunque después haya cambiado la regla
+	rule := domain.PaymentRule{Method: o.PaymentMethod, Percent: o.PaymentAdjustmentPct, Active: true}
+	prevDiscount := o.DiscountAmount
+	ApplyOrderTotals(o, subtotal, discount, rule)
+	if droppedCoupon == nil && round2(prevDiscount) != round2(o.DiscountAmount) {
+		changes = append(changes, fmt.Sprintf("Descuento del cupón %s: $%.2f → $%.2f", o.CouponCode, prevDiscount, o.DiscountAmount))
+	}
+	if len(changes) == 0 {
+		return nil, nil, ErrNoOrderChanges
+	}
+	if round2(prevTotal) != round2(o.Total) {
+		changes = append(changes, fmt.Sprintf("Total: $%.2f → $%.2f", prevTotal, o.Total))
+	}
+
+	if err := uc.Orders.Orders.SaveWithItems(ctx, o); err != nil {
+		return nil, nil, err
+	}
+	if droppedCoupon != nil {
+		// si la orden ya estaba paga el uso del cupón se había registrado: se libera
+		if _, err := uc.Coupons.RevertCoupon(ctx, *droppedCoupon, o.ID); err != nil {
+			log.Error().Err(err).Str("order_id", o.ID.String()).Msg("liberar cupón quitado en la edición")
+		}
+	}
+	e := &domain.OrderEdit{OrderID: o.ID, Actor: actor, Changes: strings.Join(changes, "\n"), Note: strings.TrimSpace(in.Note), PrevTotal: prevTotal, NewTotal: o.Total}
+	if err := uc.Edits.Add(ctx, e); err != nil {
+		log.Error().Err(err).Str("order_id", o.ID.String()).Msg("registrar edición de orden")
+	}
+	if uc.Notifier != nil {
+		if err := uc.Notifier.NotifyOrderUpdated(ctx, o, changes); err != nil {
+			log.Error().Err(err).Str("order_id", o.ID.String()).Msg("aviso de orden modificada")
+		}
+	}
+	return o, e, nil
+}
+
+// History devuelve las ediciones de la orden, la más nueva primero.
+func (uc *OrderEditUC) History(ctx context.Context, orderID uuid.UUID) ([]domain.OrderEdit, error) {
+	return uc.Edits.ListByOrder(ctx, orderID)
+}
+
+func (uc *OrderEditUC) editItems(ctx context.Context, current []domain.OrderItem, edits []OrderEditItem) ([]domain.OrderItem, []string, error) {
+	var out []domain.OrderItem
+	var changes []string
+	seen := map[uuid.UUID]bool{}
+	for _, e := range edits {
+		color := strings.TrimSpace(e.Color)
+		obs := strings.Join(strings.Fields(e.Observation), " ")
+		if len(obs) > 180 {
+			obs = obs[:180]
+		}
+		if e.ID == uuid.Nil {
+			if e.Qty <= 0 || strings.TrimSpace(e.ProductSlug) == "" {
+				continue
+			}
+			p, err := uc.Products.GetBySlug(ctx, strings.TrimSpace(e.ProductSlug))
+			if err != nil {
+				return nil, nil, fmt.Errorf("producto %q no encontrado", e.ProductSlug)
+			}
+			if p.BasePrice <= 0 {
+				return nil, nil, fmt.Errorf("el producto %s no tiene precio", p.Name)
+			}
+			it := domain.OrderItem{ID: uuid.New(), ProductID: &p.ID, Title: domain.ItemTitle(p.Name, obs), Color: color, Qty: e.Qty, UnitPrice: p.BasePrice}
+			out = append(out, it)
+			changes = append(changes, fmt.Sprintf("Se agregó %s x%d ($%.2f c/u)", it.Title, it.Qty, it.UnitPrice))
+			continue
+		}
+		i := slices.IndexFunc(current, func(it domain.OrderItem) bool { return it.ID == e.ID })
+		if i < 0 || seen[e.ID] {
+			return nil, nil, errors.New("item de la orden inválido")
+		}
+		seen[e.ID] = true
+		it := current[i]
+		name := it.Name()
+		if e.Qty <= 0 {
+			changes = append(changes, fmt.Sprintf("Se quitó %s x%d", it.Title, it.Qty))
+			continue
+		}
+		if e.Qty != it.Qty {
+			changes = append(changes, fmt.Sprintf("Cantidad de %s: %d → %d", name, it.Qty, e.Qty))
+			it.Qty = e.Qty
+		}
+		if color != it.Color {
+			changes = append(changes, fmt.Sprintf("Color de %s: %s → %s", name, orDash(it.Color), orDash(color)))
+			it.Color = color
+		}
+		if obs != it.Observation() {
+			changes = append(changes, fmt.Sprintf("Observación de %s: %s → %s", name, orDash(it.Observation()), orDash(obs)))
+			it.Title = domain.ItemTitle(name, obs)
+		}
+		out = append(out, it)
+	}
+	// los items que no vinieron en la edición se mantienen como estaban
+	for _, it := range current {
+		if !seen[it.ID] {
+			out = append(out, it)
+		}
+	}
+	return out, changes, nil
+}
+
+func (uc *OrderEditUC) editShipping(ctx context.Context, o *domain.Order, in OrderEditInput, itemsTotal float64) ([]string, error) {
+	var changes []string
+	method := strings.TrimSpace(in.ShippingMethod)
+	if method == "" {
+		method = o.ShippingMethod
+	}
+	switch method {
+	case domain.ShippingPickup, domain.ShippingCourier, domain.ShippingCadete:
+	default:
+		if method != o.ShippingMethod {
+			return nil, errors.New("método de entrega inválido")
+		}
+	}
+	address, province, postal := strings.TrimSpace(in.Address), strings.TrimSpace(in.Province), strings.TrimSpace(in.PostalCode)
+	if method == domain.ShippingCourier && (address == "" || province == "" || postal == "") {
+		return nil, errors.New("el envío a domicilio necesita dirección, provincia y código postal")
+	}
+	if method == domain.ShippingCadete && address == "" {
+		return nil, errors.New("el envío por cadete necesita la dirección")
+	}
+	if method != o.ShippingMethod {
+		changes = append(changes, fmt.Sprintf("Entrega: %s → %s", domain.ShippingMethodLabel(o.ShippingMethod), domain.ShippingMethodLabel(method)))
+		// el correo elegido en el checkout ya no aplica
+		o.Carrier, o.CarrierService = "", ""
+		o.ShippingMethod = method
+	}
+	if address != o.Address || province != o.Province || postal != o.PostalCode {
+		if method != domain.ShippingPickup {
+			changes = append(changes, fmt.Sprintf("Dirección: %s → %s", orDash(addressLine(o.Address, o.Province, o.PostalCode)), orDash(addressLine(address, province, postal))))
+		}
+		o.Address, o.Province, o.PostalCode = address, province, postal
+	}
+
+	cost := o.ShippingCost
+	if in.ShippingCost != nil {
+		cost = round2(*in.ShippingCost)
+		if cost < 0 {
+			return nil, errors.New("costo de envío inválido")
+		}
+	} else {
+		var ids []uuid.UUID
+		for _, it := range o.Items {
+			if it.ProductID != nil {
+				ids = append(ids, *it.ProductID)
+			}
+		}
+		products, err := uc.Products.ListByIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		var shipItems []ShippingItem
+		for _, it := range o.Items {
+			for i := range products {
+				if it.ProductID != nil && products[i].ID == *it.ProductID {
+					shipItems = append(shipItems, ShippingItem{Product: &products[i], Qty: it.Qty})
+					break
+				}
+			}
+		}
+		quote, err := uc.Shipping.Quote(ctx, ShippingRequest{Method: method, Province: o.Province, PostalCode: o.PostalCode, Package: BuildPackage(shipItems), ItemsTotal: itemsTotal})
+		if err != nil {
+			return nil, err
+		}
+		if !quote.Available {
+			return nil, fmt.Errorf("no se pudo cotizar el envío: %s", quote.Reason)
+		}
+		cost = quote.Cost
+	}
+	if cost != o.ShippingCost {
+		changes = append(changes, fmt.Sprintf("Costo de envío: $%.2f → $%.2f", o.ShippingCost, cost))
+		o.ShippingCost = cost
+	}
+	return changes, nil
+}
+
+// addressLine arma la dirección para el detalle de cambios ("Calle 1, Santa Fe, CP 2000").
+func addressLine(address, province, postal string) string {
+	parts := []string{}
+	for _, p := range []string{address, province} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if postal != "" {
+		parts = append(parts, "CP "+postal)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type memOrderEditRepo struct {
+	mu    sync.Mutex
+	edits []domain.OrderEdit
+}
+
+func (r *memOrderEditRepo) Add(ctx context.Context, e *domain.OrderEdit) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.edits = append(r.edits, *e)
+	return nil
+}
+
+func (r *memOrderEditRepo) ListByOrder(ctx context.Context, orderID uuid.UUID) ([]domain.OrderEdit, error) {
+	return nil, nil
+}
+
+func TestEditDropsCouponBelowMinimum(t *testing.T) {
+	ctx := context.Background()
+	coupon := domain.Coupon{ID: uuid.New(), Code: "PROMO10", DiscountType: domain.DiscountTypePercentage, DiscountValue: 10, MinPurchaseAmount: 15000, Active: true}
+	item := domain.OrderItem{ID: uuid.New(), Title: "Maceta", Qty: 4, UnitPrice: 5000}
+	o := &domain.Order{ID: uuid.New(), Status: domain.OrderStatusFinished, Notified: true, ShippingMethod: domain.ShippingPickup,
+		CouponID: &coupon.ID, CouponCode: coupon.Code, DiscountAmount: 2000, Total: 18000, Items: []domain.OrderItem{item}}
+	orders := newMemOrderRepo(o)
+	coupons := &memCouponRepo{coupons: []domain.Coupon{coupon}, uses: 1, usages: []domain.CouponUsage{{ID: uuid.New(), CouponID: coupon.ID, OrderID: &o.ID}}}
+	edits := &memOrderEditRepo{}
+	uc := &OrderEditUC{Orders: &OrderUC{Orders: orders}, Edits: edits, Coupons: NewCouponUseCase(coupons, orders)}
+	noShipping := 0.0
+
+	// 3 x $5000 sigue llegando al mínimo: se recalcula el descuento
+	got, _, err := uc.Edit(ctx, o.ID, OrderEditInput{Items: []OrderEditItem{{ID: item.ID, Qty: 3}}, ShippingCost: &noShipping}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CouponID == nil || got.DiscountAmount != 1500 || got.Total != 13500 {
+		t.Fatalf("con el mínimo: cupón %v, descuento %.2f, total %.2f", got.CouponID, got.DiscountAmount, got.Total)
+	}
+
+	// 2 x $5000 ya no llega: se quita el cupón y se libera su uso
+	got, e, err := uc.Edit(ctx, o.ID, OrderEditInput{Items: []OrderEditItem{{ID: item.ID, Qty: 2}}, ShippingCost: &noShipping}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CouponID != nil || got.CouponCode != "" || got.DiscountAmount != 0 || got.Total != 10000 {
+		t.Fatalf("bajo el mínimo: cupón %v %q, descuento %.2f, total %.2f", got.CouponID, got.CouponCode, got.DiscountAmount, got.Total)
+	}
+	if saved := orders.get(o.ID); saved.CouponID != nil || saved.Total != 10000 {
+		t.Fatalf("orden guardada: cupón %v, total %.2f", saved.CouponID, saved.Total)
+	}
+	if !strings.Contains(e.Changes, "Se quitó el cupón PROMO10") {
+		t.Fatalf("cambios = %q", e.Changes)
+	}
+	if len(coupons.usages) != 0 || coupons.uses != 0 {
+		t.Fatalf("usos del cupón = %d (%d registros)", coupons.uses, len(coupons.usages))
+	}
+}
+
+func TestEditDropsInactiveCoupon(t *testing.T) {
+	ctx := context.Background()
+	coupon := domain.Coupon{ID: uuid.New(), Code: "VIEJO", DiscountType: domain.DiscountTypeFixedAmount, DiscountValue: 1000, Active: false}
+	item := domain.OrderItem{ID: uuid.New(), Title: "Llavero", Qty: 1, UnitPrice: 3000}
+	o := &domain.Order{ID: uuid.New(), Status: domain.OrderStatusAwaitingPay, ShippingMethod: domain.ShippingPickup,
+		CouponID: &coupon.ID, CouponCode: coupon.Code, DiscountAmount: 1000, Total: 2000, Items: []domain.OrderItem{item}}
+	orders := newMemOrderRepo(o)
+	uc := &OrderEditUC{Orders: &OrderUC{Orders: orders}, Edits: &memOrderEditRepo{}, Coupons: NewCouponUseCase(&memCouponRepo{coupons: []domain.Coupon{coupon}}, orders)}
+	noShipping := 0.0
+
+	got, _, err := uc.Edit(ctx, o.ID, OrderEditInput{Items: []OrderEditItem{{ID: item.ID, Qty: 2}}, ShippingCost: &noShipping}, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CouponID != nil || got.Total != 6000 {
+		t.Fatalf("cupón inactivo: cupón %v, total %.2f", got.CouponID, got.Total)
+	}
+}
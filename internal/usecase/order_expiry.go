@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// ExpiryPolicy define cuánto puede esperar pago una orden según el método.
+// Un TTL 0 (o un método vacío, ej. órdenes de WhatsApp) no vence nunca.
+type ExpiryPolicy struct {
+	TTL            map[string]time.Duration
+	ReminderBefore time.Duration
+}
+
+// ttlFor devuelve el TTL del método; métodos desconocidos siguen el flujo de MercadoPago como en el checkout.
+func (p ExpiryPolicy) ttlFor(method string) time.Duration {
+	m := strings.ToLower(strings.TrimSpace(method))
+	if m == "" {
+		return 0
+	}
+	if d, ok := p.TTL[m]; ok {
+		return d
+	}
+	return p.TTL["mercadopago"]
+}
+
+func (p ExpiryPolicy) minTTL() time.Duration {
+	var shortest time.Duration
+	for _, d := range p.TTL {
+		if d > 0 && (shortest == 0 || d < shortest) {
+			shortest = d
+		}
+	}
+	return shortest
+}
+
+// OrderExpiryUC cancela las órdenes impagas vencidas y avisa al cliente antes de cancelarlas.
+type OrderExpiryUC struct {
+	Orders *OrderUC
+	Email  domain.EmailService
+	Policy ExpiryPolicy
+}
+
+type OrderExpiryResult struct {
+	Reminded  int
+	Cancelled int
+}
+
+// Run procesa las órdenes esperando pago. Al cancelar, la orden deja de figurar como pendiente
+// y el cupón vuelve a estar disponible para el cliente (el uso recién se registra al aprobarse el
+// pago, así que no hay usos que revertir). Los productos se fabrican a pedido: no hay stock reservado.
+func (uc *OrderExpiryUC) Run(ctx context.Context, now time.Time) (OrderExpiryResult, error) {
+	var res OrderExpiryResult
+	shortest := uc.Policy.minTTL()
+	if shortest == 0 {
+		return res, nil
+	}
+	before := now.Add(-shortest)
+	if uc.Policy.ReminderBefore > 0 {
+		before = before.Add(uc.Policy.ReminderBefore)
+	}
+	list, err := uc.Orders.Orders.ListAwaitingPayment(ctx, before)
+	if err != nil {
+		return res, err
+	}
+	for i := range list {
+		o := &list[i]
+		ttl := uc.Policy.ttlFor(o.PaymentMethod)
+		if ttl <= 0 {
+			continue
+		}
+		deadline := o.CreatedAt.Add(ttl)
+		if !now.Before(deadline) {
+			note := fmt.Sprintf("vencida sin pago (%s, %gh)", o.PaymentMethod, ttl.Hours())
+			if o.CouponCode != "" {
+				note += "; cupón " + o.CouponCode + " liberado"
+			}
+			if err := uc.Orders.ChangeStatus(ctx, o, domain.OrderStatusCancelled, StatusChange{Actor: "sistema", Source: domain.StatusSourceSystem, Note: note}); err != nil {
+				log.Warn().Err(err).Str("order_id", o.ID.String()).Msg("vencer orden impaga")
+				continue
+			}
+			res.Cancelled++
+			continue
+		}
+		rb := uc.Policy.ReminderBefore
+		if uc.Email == nil || rb <= 0 || rb >= ttl || o.PaymentReminderAt != nil || o.Email == "" {
+			continue
+		}
+		if now.Before(deadline.Add(-rb)) {
+			continue
+		}
+		if err := uc.Email.SendPaymentReminder(ctx, o, deadline); err != nil {
+			log.Warn().Err(err).Str("order_id", o.ID.String()).Msg("recordatorio de pago")
+			continue
+		}
+		if err := uc.Orders.Orders.MarkPaymentReminder(ctx, o.ID, now); err != nil {
+			log.Warn().Err(err).Str("order_id", o.ID.String()).Msg("marcar recordatorio de pago")
+			continue
+		}
+		res.Reminded++
+	}
+	return res, nil
+}
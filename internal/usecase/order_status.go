@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// StatusChange describe quién y desde dónde cambia el estado de una orden.
+type StatusChange struct {
+	Actor  string
+	Source string
+	Note   string
+}
+
+// orderTransitions define los estados a los que puede pasar cada estado.
+// finished = pago confirmado; shipped sólo puede reembolsarse y refunded es terminal.
+var orderTransitions = map[domain.OrderStatus][]domain.OrderStatus{
+	domain.OrderStatusPendingQuote: {domain.OrderStatusQuoted, domain.OrderStatusCancelled},
+	domain.OrderStatusQuoted:       {domain.OrderStatusAwaitingPay, domain.OrderStatusFinished, domain.OrderStatusCancelled},
+	domain.OrderStatusAwaitingPay:  {domain.OrderStatusFinished, domain.OrderStatusInPrint, domain.OrderStatusCancelled},
+	domain.OrderStatusInPrint:      {domain.OrderStatusFinished, domain.OrderStatusShipped, domain.OrderStatusCancelled, domain.OrderStatusRefunded},
+	domain.OrderStatusFinished:     {domain.OrderStatusInPrint, domain.OrderStatusShipped, domain.OrderStatusCancelled, domain.OrderStatusRefunded},
+	// un pago aprobado después de cancelar (reintento con la misma preferencia) igual la cobra;
+	// volver a esperar pago sólo se hace a mano con Reopen, así un aviso atrasado no la revive
+	domain.OrderStatusCancelled: {domain.OrderStatusFinished, domain.OrderStatusRefunded},
+	domain.OrderStatusShipped:   {domain.OrderStatusRefunded},
+	domain.OrderStatusRefunded:  {},
+}
+
+// CanTransition indica si una orden puede pasar de from a to.
+func CanTransition(from, to domain.OrderStatus) bool {
+	for _, st := range orderTransitions[from] {
+		if st == to {
+			return true
+		}
+	}
+	return false
+}
+
+// NextStatuses devuelve los estados válidos a partir de from.
+func NextStatuses(from domain.OrderStatus) []domain.OrderStatus {
+	return orderTransitions[from]
+}
+
+// Place guarda una orden nueva y registra su estado inicial en el historial.
+func (uc *OrderUC) Place(ctx context.Context, o *domain.Order, ch StatusChange) error {
+	if o == nil {
+		return errors.New("order nil")
+	}
+	if err := uc.Orders.Save(ctx, o); err != nil {
+		return err
+	}
+	recordStatusChange(ctx, uc.Orders, o.ID, "", o.Status, ch)
+	return nil
+}
+
+// ChangeStatus valida la transición, guarda la orden completa (incluidos otros campos ya
+// modificados por el caller) y registra el cambio. Si el estado no cambia sólo guarda la orden.
+// Con una transición inválida no guarda nada y devuelve domain.ErrInvalidTransition. El guardado
+// exige que la orden siga en el estado leído: si otro proceso la movió devuelve
+// domain.ErrStatusConflict sin pisar nada.
+func (uc *OrderUC) ChangeStatus(ctx context.Context, o *domain.Order, to domain.OrderStatus, ch StatusChange) error {
+	if o == nil {
+		return errors.New("order nil")
+	}
+	if o.Status != to && !CanTransition(o.Status, to) {
+		return fmt.Errorf("%w: %s → %s", domain.ErrInvalidTransition, o.Status, to)
+	}
+	return uc.swapStatus(ctx, o, to, ch)
+}
+
+// Reopen vuelve una orden cancelada a esperar pago. No es parte de las transiciones normales
+// (los avisos de pago no pueden reabrir una orden) y sólo se usa desde el panel.
+func (uc *OrderUC) Reopen(ctx context.Context, o *domain.Order, ch StatusChange) error {
+	if o == nil {
+		return errors.New("order nil")
+	}
+	if o.Status != domain.OrderStatusCancelled {
+		return fmt.Errorf("%w: %s → %s", domain.ErrInvalidTransition, o.Status, domain.OrderStatusAwaitingPay)
+	}
+	return uc.swapStatus(ctx, o, domain.OrderStatusAwaitingPay, ch)
+}
+
+// swapStatus guarda la orden con el estado to sólo si en la base sigue en el estado actual de o.
+func (uc *OrderUC) swapStatus(ctx context.Context, o *domain.Order, to domain.OrderStatus, ch StatusChange) error {
+	from := o.Status
+	o.Status = to
+	ok, err := uc.Orders.SaveIfStatus(ctx, o, from)
+	if err != nil || !ok {
+		o.Status = from
+		if err == nil {
+			err = fmt.Errorf("%w: %s ya no está en %s", domain.ErrStatusConflict, o.Code(), from)
+		}
+		return err
+	}
+	if from != to {
+		recordStatusChange(ctx, uc.Orders, o.ID, from, to, ch)
+	}
+	return nil
+}
+
+func (uc *OrderUC) UpdateStatus(ctx context.Context, id uuid.UUID, st domain.OrderStatus, ch StatusChange) error {
+	o, err := uc.Orders.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if o.Status == st {
+		return nil
+	}
+	return uc.ChangeStatus(ctx, o, st, ch)
+}
+
+// History agrupa por orden el historial de estados de las órdenes pedidas.
+func (uc *OrderUC) History(ctx context.Context, orderIDs []uuid.UUID) (map[uuid.UUID][]domain.OrderStatusChange, error) {
+	list, err := uc.Orders.ListStatusChanges(ctx, orderIDs)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[uuid.UUID][]domain.OrderStatusChange, len(orderIDs))
+	for _, c := range list {
+		out[c.OrderID] = append(out[c.OrderID], c)
+	}
+	return out, nil
+}
+
+// recordStatusChange agrega la fila de historial. La orden ya quedó guardada, así que un error
+// acá sólo se loguea para no cortar el checkout o el webhook.
+func recordStatusChange(ctx context.Context, repo domain.OrderRepo, orderID uuid.UUID, from, to domain.OrderStatus, ch StatusChange) {
+	source := ch.Source
+	if source == "" {
+		source = domain.StatusSourceSystem
+	}
+	note := ch.Note
+	if r := []rune(note); len(r) > 255 {
+		note = string(r[:255])
+	}
+	err := repo.AddStatusChange(ctx, &domain.OrderStatusChange{
+		ID:      uuid.New(),
+		OrderID: orderID,
+		From:    from,
+		To:      to,
+		Actor:   ch.Actor,
+		Source:  source,
+		Note:    note,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("order_id", orderID.String()).Str("to", string(to)).Msg("historial de estado de orden")
+	}
+}
@@ -0,0 +1,134 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to domain.OrderStatus
+		want     bool
+	}{
+		{domain.OrderStatusPendingQuote, domain.OrderStatusQuoted, true},
+		{domain.OrderStatusPendingQuote, domain.OrderStatusFinished, false},
+		{domain.OrderStatusQuoted, domain.OrderStatusAwaitingPay, true},
+		{domain.OrderStatusAwaitingPay, domain.OrderStatusFinished, true},
+		{domain.OrderStatusAwaitingPay, domain.OrderStatusShipped, false},
+		{domain.OrderStatusAwaitingPay, domain.OrderStatusRefunded, false},
+		{domain.OrderStatusInPrint, domain.OrderStatusShipped, true},
+		// un aviso "pending" atrasado no vuelve atrás una orden cobrada
+		{domain.OrderStatusFinished, domain.OrderStatusAwaitingPay, false},
+		{domain.OrderStatusFinished, domain.OrderStatusRefunded, true},
+		// un pago aprobado tarde cobra la orden cancelada, pero nada la vuelve a esperar pago
+		{domain.OrderStatusCancelled, domain.OrderStatusFinished, true},
+		{domain.OrderStatusCancelled, domain.OrderStatusAwaitingPay, false},
+		{domain.OrderStatusShipped, domain.OrderStatusRefunded, true},
+		{domain.OrderStatusShipped, domain.OrderStatusCancelled, false},
+		{domain.OrderStatusRefunded, domain.OrderStatusFinished, false},
+		{domain.OrderStatusFinished, domain.OrderStatusFinished, false},
+		{"desconocido", domain.OrderStatusFinished, false},
+	}
+	for _, c := range cases {
+		if got := CanTransition(c.from, c.to); got != c.want {
+			t.Errorf("CanTransition(%s, %s) = %v; want %v", c.from, c.to, got, c.want)
+		}
+	}
+
+	// todos los destinos son estados conocidos
+	for from, tos := range orderTransitions {
+		for _, to := range tos {
+			if _, ok := orderTransitions[to]; !ok {
+				t.Errorf("%s → %s: estado sin transiciones definidas", from, to)
+			}
+		}
+	}
+}
+
+func TestChangeStatus(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name string
+		// stored es el estado en la base; la orden se leyó en awaiting_payment
+		stored  domain.OrderStatus
+		to      domain.OrderStatus
+		wantErr error
+		want    domain.OrderStatus
+		history int
+	}{
+		{"pago aprobado", domain.OrderStatusAwaitingPay, domain.OrderStatusFinished, nil, domain.OrderStatusFinished, 1},
+		{"mismo estado sólo guarda", domain.OrderStatusAwaitingPay, domain.OrderStatusAwaitingPay, nil, domain.OrderStatusAwaitingPay, 0},
+		{"transición inválida", domain.OrderStatusAwaitingPay, domain.OrderStatusShipped, domain.ErrInvalidTransition, domain.OrderStatusAwaitingPay, 0},
+		{"otro proceso la canceló", domain.OrderStatusCancelled, domain.OrderStatusFinished, domain.ErrStatusConflict, domain.OrderStatusCancelled, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			id := uuid.New()
+			orders := newMemOrderRepo(&domain.Order{ID: id, Status: c.stored, Email: "c@example.com"})
+			uc := &OrderUC{Orders: orders}
+			o := &domain.Order{ID: id, Status: domain.OrderStatusAwaitingPay, Email: "c@example.com", MPStatus: "approved"}
+
+			err := uc.ChangeStatus(ctx, o, c.to, StatusChange{Actor: "mercadopago", Source: domain.StatusSourceWebhook})
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("err = %v; want %v", err, c.wantErr)
+			}
+			got := orders.get(id)
+			if got.Status != c.want || len(orders.history) != c.history {
+				t.Fatalf("status %s con %d cambios; want %s con %d", got.Status, len(orders.history), c.want, c.history)
+			}
+			if c.wantErr != nil {
+				if o.Status != domain.OrderStatusAwaitingPay || got.MPStatus != "" {
+					t.Fatalf("con error la orden quedó en %s (guardada mp=%q)", o.Status, got.MPStatus)
+				}
+				return
+			}
+			if got.MPStatus != "approved" {
+				t.Fatal("no se guardaron los demás campos de la orden")
+			}
+			if c.history > 0 {
+				h := orders.history[0]
+				if h.From != domain.OrderStatusAwaitingPay || h.To != c.to || h.Source != domain.StatusSourceWebhook || h.Actor != "mercadopago" {
+					t.Fatalf("historial = %+v", h)
+				}
+			}
+		})
+	}
+}
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		from domain.OrderStatus
+		ok   bool
+	}{
+		{domain.OrderStatusCancelled, true},
+		{domain.OrderStatusAwaitingPay, false},
+		{domain.OrderStatusFinished, false},
+		{domain.OrderStatusRefunded, false},
+	}
+	for _, c := range cases {
+		t.Run(string(c.from), func(t *testing.T) {
+			o := &domain.Order{ID: uuid.New(), Status: c.from}
+			orders := newMemOrderRepo(o)
+			err := (&OrderUC{Orders: orders}).Reopen(ctx, o, StatusChange{Actor: "admin@example.com", Source: domain.StatusSourceAdmin})
+			got := orders.get(o.ID)
+			if !c.ok {
+				if !errors.Is(err, domain.ErrInvalidTransition) || got.Status != c.from || len(orders.history) != 0 {
+					t.Fatalf("err = %v, status %s, %d cambios", err, got.Status, len(orders.history))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != domain.OrderStatusAwaitingPay || len(orders.history) != 1 || orders.history[0].From != domain.OrderStatusCancelled {
+				t.Fatalf("status %s, historial %+v", got.Status, orders.history)
+			}
+		})
+	}
+}
@@ -26,12 +26,8 @@ func (uc *OrderUC) CreateFromQuote(ctx context.Context, quote *domain.Quote, ema
 		Items:  []domain.OrderItem{{ID: uuid.New(), QuoteID: &quote.ID, Qty: 1, UnitPrice: quote.Price}},
 		Total:  quote.Price,
 	}
-	if err := uc.Orders.Save(ctx, o); err != nil {
+	if err := uc.Place(ctx, o, StatusChange{Actor: email, Source: domain.StatusSourceCheckout}); err != nil {
 		return nil, err
 	}
 	return o, nil
 }
-
-func (uc *OrderUC) UpdateStatus(ctx context.Context, id uuid.UUID, st domain.OrderStatus) error {
-	return uc.Orders.UpdateStatus(ctx, id, st)
-}
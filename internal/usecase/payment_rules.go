@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// PaymentRulesUC administra los descuentos y recargos por medio de pago y las cuotas que se
+// muestran de MercadoPago.
+type PaymentRulesUC struct {
+	Rules domain.PaymentRuleRepo
+}
+
+// List devuelve una regla por cada medio de pago del checkout, en orden; los que no tienen
+// regla guardada vuelven inactivos y sin ajuste.
+func (uc *PaymentRulesUC) List(ctx context.Context) ([]domain.PaymentRule, error) {
+	saved, err := uc.Rules.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]domain.PaymentRule, 0, len(domain.PaymentMethods))
+	for _, m := range domain.PaymentMethods {
+		rule := domain.PaymentRule{Method: m}
+		for _, r := range saved {
+			if r.Method == m {
+				rule = r
+				break
+			}
+		}
+		out = append(out, rule)
+	}
+	return out, nil
+}
+
+// ByMethod devuelve las reglas indexadas por medio de pago.
+func (uc *PaymentRulesUC) ByMethod(ctx context.Context) (map[string]domain.PaymentRule, error) {
+	list, err := uc.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]domain.PaymentRule, len(list))
+	for _, r := range list {
+		out[r.Method] = r
+	}
+	return out, nil
+}
+
+// Rule devuelve la regla del medio de pago (inactiva si no hay).
+func (uc *PaymentRulesUC) Rule(ctx context.Context, method string) (domain.PaymentRule, error) {
+	rules, err := uc.ByMethod(ctx)
+	if err != nil {
+		return domain.PaymentRule{}, err
+	}
+	if r, ok := rules[method]; ok {
+		return r, nil
+	}
+	return domain.PaymentRule{Method: method}, nil
+}
+
+// Save valida la regla y la guarda.
+func (uc *PaymentRulesUC) Save(ctx context.Context, r *domain.PaymentRule) error {
+	if !slices.Contains(domain.PaymentMethods, r.Method) {
+		return errors.New("medio de pago inválido")
+	}
+	if r.Percent <= -100 || r.Percent > 100 {
+		return errors.New("el porcentaje tiene que estar entre -99 y 100")
+	}
+	if r.Installments < 0 || r.Installments > 24 {
+		return errors.New("las cuotas tienen que estar entre 0 y 24")
+	}
+	if r.InstallmentsInterest < 0 {
+		return errors.New("el interés de las cuotas no puede ser negativo")
+	}
+	r.Label = strings.TrimSpace(r.Label)
+	return uc.Rules.Save(ctx, r)
+}
+
+// SeedDefaults crea el 10% OFF pagando por transferencia o en efectivo si todavía no hay
+// reglas guardadas.
+func (uc *PaymentRulesUC) SeedDefaults(ctx context.Context) error {
+	saved, err := uc.Rules.List(ctx)
+	if err != nil || len(saved) > 0 {
+		return err
+	}
+	defaults := []domain.PaymentRule{
+		{Method: domain.PaymentProviderCash, Percent: -10, Active: true},
+		{Method: domain.PaymentProviderTransfer, Percent: -10, Active: true},
+		{Method: domain.PaymentProviderMercadoPago, Active: true},
+	}
+	for i := range defaults {
+		if err := uc.Rules.Save(ctx, &defaults[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProductPaymentInfo resume para la ficha de un producto el mejor precio con descuento por
+// medio de pago y las cuotas de MercadoPago.
+type ProductPaymentInfo struct {
+	// DiscountPrice: precio con el mayor descuento (0 = ningún medio tiene descuento).
+	DiscountPrice   float64
+	DiscountLabel   string
+	DiscountMethods string
+	Installments    domain.InstallmentPlan
+	HasInstallments bool
+}
+
+// ProductInfo calcula ProductPaymentInfo para price.
+func (uc *PaymentRulesUC) ProductInfo(ctx context.Context, price float64) (ProductPaymentInfo, error) {
+	rules, err := uc.List(ctx)
+	if err != nil {
+		return ProductPaymentInfo{}, err
+	}
+	var info ProductPaymentInfo
+	best := 0.0
+	var methods []string
+	for _, r := range rules {
+		if r.Method == domain.PaymentProviderMercadoPago {
+			info.Installments, info.HasInstallments = r.InstallmentPlan(price)
+		}
+		if !r.Applies() || r.Percent > best {
+			continue
+		}
+		if r.Percent < best {
+			best, methods = r.Percent, nil
+			info.DiscountLabel = r.DisplayLabel()
+		}
+		methods = append(methods, strings.ToLower(domain.PaymentMethodLabel(r.Method)))
+	}
+	if best < 0 {
+		// mismo redondeo que ApplyOrderTotals: la ficha muestra lo que después cobra el checkout
+		info.DiscountPrice = math.Round((price+math.Round(price*best)/100)*100) / 100
+		info.DiscountMethods = strings.Join(methods, " o ")
+	}
+	return info, nil
+}
+
+// ApplyOrderTotals calcula el total de la orden a partir de subtotal (productos + envío): resta
+// el descuento del cupón y aplica el ajuste del medio de pago sobre lo que queda.
+func ApplyOrderTotals(o *domain.Order, subtotal, discount float64, rule domain.PaymentRule) {
+	o.DiscountAmount = discount
+	net := subtotal - discount
+	o.PaymentAdjustment = rule.Adjustment(net)
+	o.PaymentAdjustmentPct = 0
+	if o.PaymentAdjustment != 0 {
+		o.PaymentAdjustmentPct = rule.Percent
+	}
+	o.Total = math.Round((net+o.PaymentAdjustment)*100) / 100
+}
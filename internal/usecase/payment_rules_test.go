@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type memPaymentRuleRepo struct {
+	rules []domain.PaymentRule
+}
+
+func (r *memPaymentRuleRepo) List(ctx context.Context) ([]domain.PaymentRule, error) {
+	return append([]domain.PaymentRule(nil), r.rules...), nil
+}
+
+func (r *memPaymentRuleRepo) Save(ctx context.Context, rule *domain.PaymentRule) error {
+	for i := range r.rules {
+		if r.rules[i].Method == rule.Method {
+			r.rules[i] = *rule
+			return nil
+		}
+	}
+	r.rules = append(r.rules, *rule)
+	return nil
+}
+
+func TestApplyOrderTotals(t *testing.T) {
+	off10 := domain.PaymentRule{Method: domain.PaymentProviderTransfer, Percent: -10, Active: true}
+	cases := []struct {
+		name               string
+		subtotal, discount float64
+		rule               domain.PaymentRule
+		adjustment, pct    float64
+		total              float64
+	}{
+		{"sin regla", 12000, 0, domain.PaymentRule{Method: domain.PaymentProviderMercadoPago}, 0, 0, 12000},
+		{"10% OFF", 12000, 0, off10, -1200, -10, 10800},
+		{"después del cupón", 12000, 1000, off10, -1100, -10, 9900},
+		{"centavos redondeados", 1234.55, 0, off10, -123.46, -10, 1111.09},
+		{"recargo", 999.99, 0, domain.PaymentRule{Method: domain.PaymentProviderMercadoPago, Percent: 7.5, Active: true}, 75, 7.5, 1074.99},
+		{"regla inactiva", 12000, 0, domain.PaymentRule{Method: domain.PaymentProviderCash, Percent: -10}, 0, 0, 12000},
+		{"cupón mayor que el subtotal", 500, 800, off10, 0, 0, -300},
+		{"suma de flotantes", 0.1 + 0.2, 0, domain.PaymentRule{}, 0, 0, 0.3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := &domain.Order{PaymentAdjustment: 99, PaymentAdjustmentPct: 99}
+			ApplyOrderTotals(o, c.subtotal, c.discount, c.rule)
+			if o.DiscountAmount != c.discount || o.PaymentAdjustment != c.adjustment || o.PaymentAdjustmentPct != c.pct || o.Total != c.total {
+				t.Fatalf("descuento %v, ajuste %v (%v%%), total %v; want %v, %v (%v%%), %v",
+					o.DiscountAmount, o.PaymentAdjustment, o.PaymentAdjustmentPct, o.Total, c.discount, c.adjustment, c.pct, c.total)
+			}
+		})
+	}
+}
+
+func TestProductInfo(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name     string
+		rules    []domain.PaymentRule
+		price    float64
+		discount float64
+		label    string
+		methods  string
+		cuotas   int
+		cuota    float64
+		interest bool
+	}{
+		{name: "sin reglas", price: 1000},
+		{
+			name:  "mismo descuento en dos medios",
+			rules: []domain.PaymentRule{{Method: domain.PaymentProviderCash, Percent: -10, Active: true}, {Method: domain.PaymentProviderTransfer, Percent: -10, Active: true}},
+			price: 1234.55, discount: 1111.09, label: "10% OFF", methods: "efectivo o transferencia",
+		},
+		{
+			name:  "gana el mayor descuento",
+			rules: []domain.PaymentRule{{Method: domain.PaymentProviderCash, Percent: -15, Label: "Precio contado", Active: true}, {Method: domain.PaymentProviderTransfer, Percent: -10, Active: true}},
+			price: 19999.99, discount: 16999.99, label: "Precio contado", methods: "efectivo",
+		},
+		{
+			name:  "centavos que no cierran en binario",
+			rules: []domain.PaymentRule{{Method: domain.PaymentProviderTransfer, Percent: -12.5, Active: true}},
+			price: 4321.1, discount: 3780.96, label: "12.5% OFF", methods: "transferencia",
+		},
+		{
+			name:  "un recargo no es descuento",
+			rules: []domain.PaymentRule{{Method: domain.PaymentProviderMercadoPago, Percent: 5, Active: true}},
+			price: 1000,
+		},
+		{
+			name:  "cuotas sin interés",
+			rules: []domain.PaymentRule{{Method: domain.PaymentProviderMercadoPago, Active: true, Installments: 3}},
+			price: 1000, cuotas: 3, cuota: 333.33,
+		},
+		{
+			name:  "cuotas con recargo e interés",
+			rules: []domain.PaymentRule{{Method: domain.PaymentProviderMercadoPago, Percent: 5, Active: true, Installments: 6, InstallmentsInterest: 20}},
+			price: 1000, cuotas: 6, cuota: 210, interest: true,
+		},
+		{
+			name:  "regla de cuotas inactiva",
+			rules: []domain.PaymentRule{{Method: domain.PaymentProviderMercadoPago, Installments: 6}},
+			price: 1000,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			uc := &PaymentRulesUC{Rules: &memPaymentRuleRepo{rules: c.rules}}
+			info, err := uc.ProductInfo(ctx, c.price)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.DiscountPrice != c.discount || info.DiscountLabel != c.label || info.DiscountMethods != c.methods {
+				t.Fatalf("descuento %v %q (%q); want %v %q (%q)", info.DiscountPrice, info.DiscountLabel, info.DiscountMethods, c.discount, c.label, c.methods)
+			}
+			if info.HasInstallments != (c.cuotas > 0) || info.Installments.Count != c.cuotas || info.Installments.Amount != c.cuota || info.Installments.Interest != c.interest {
+				t.Fatalf("cuotas = %+v (%v)", info.Installments, info.HasInstallments)
+			}
+			if c.discount == 0 {
+				return
+			}
+			// el precio de la ficha es el mismo total que cobra el checkout con ese medio
+			o := &domain.Order{}
+			ApplyOrderTotals(o, c.price, 0, c.rules[0])
+			if o.Total != info.DiscountPrice {
+				t.Fatalf("ficha %v, checkout %v", info.DiscountPrice, o.Total)
+			}
+		})
+	}
+}
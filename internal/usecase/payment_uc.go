@@ -2,13 +2,45 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 
 	"github.com/phenrril/tienda3d/internal/domain"
 )
 
 type PaymentUC struct {
-	Orders  domain.OrderRepo
-	Gateway domain.PaymentGateway
+	Orders   domain.OrderRepo
+	Gateway  domain.PaymentGateway
+	Payments domain.PaymentRepo
+
+	// Lo que usa Apply para mover la orden: historial de estados, reembolsos y cupón.
+	Lifecycle *OrderUC
+	Refunds   *RefundUC
+	Coupons   *CouponUseCase
+	// Issues guarda los pagos aprobados que no cubren el saldo de la orden.
+	Issues domain.ReconcileIssueRepo
+	// OnPaid avisa (en otra goroutine) la primera vez que una orden queda paga.
+	OnPaid func(o *domain.Order)
+}
+
+// PaymentApplyResult cuenta qué hizo Apply con la orden.
+type PaymentApplyResult struct {
+	From, To domain.OrderStatus
+	// Ignored explica por qué el aviso no cambió la orden (transición inválida, rechazo de una
+	// orden ya cobrada); vacío si se aplicó.
+	Ignored string
+	// Paid: la orden quedó paga con este aviso.
+	Paid bool
+	// AppliedElsewhere: otro proceso (webhook, conciliación, retorno del checkout) ya aplicó la
+	// aprobación de esta orden.
+	AppliedElsewhere bool
+	// Underpaid: el pago está aprobado pero no cubre el saldo; la orden sigue esperando el pago y
+	// la diferencia quedó guardada para el admin.
+	Underpaid bool
 }
 
 func (uc *PaymentUC) CreatePreference(ctx context.Context, order *domain.Order) (string, error) {
@@ -18,3 +50,201 @@ func (uc *PaymentUC) CreatePreference(ctx context.Context, order *domain.Order)
 	}
 	return url, nil
 }
+
+// RecordGateway guarda (o actualiza) el pago de MercadoPago que informó el webhook.
+func (uc *PaymentUC) RecordGateway(ctx context.Context, o *domain.Order, gp *domain.GatewayPayment) (*domain.Payment, error) {
+	p := &domain.Payment{
+		OrderID:      o.ID,
+		Provider:     domain.PaymentProviderMercadoPago,
+		ExternalID:   gp.ID,
+		Amount:       round2(gp.Amount),
+		Status:       gp.Status,
+		StatusDetail: gp.StatusDetail,
+		Actor:        "mercadopago",
+		Raw:          string(gp.Raw),
+		PaidAt:       gp.ApprovedAt,
+	}
+	if p.Amount == 0 && p.Collected() {
+		p.Amount = round2(o.Total)
+	}
+	if p.PaidAt == nil && p.Status == domain.PaymentStatusApproved {
+		now := time.Now()
+		p.PaidAt = &now
+	}
+	if err := uc.Payments.Save(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ClaimPaid marca la orden como avisada si todavía no lo estaba; true = la marcó esta llamada. El
+// webhook, la conciliación y el retorno del checkout pueden ver la misma orden impaga a la vez:
+// sólo el que la marca registra el cupón y manda el aviso de pago.
+func (uc *PaymentUC) ClaimPaid(ctx context.Context, o *domain.Order) (bool, error) {
+	if o.Notified {
+		return false, nil
+	}
+	ok, err := uc.Orders.SetNotified(ctx, o.ID, true)
+	if err != nil {
+		return false, err
+	}
+	o.Notified = true
+	return ok, nil
+}
+
+// ReleasePaid deshace ClaimPaid cuando no se pudo guardar el pago, para que el próximo aviso lo
+// vuelva a intentar.
+func (uc *PaymentUC) ReleasePaid(ctx context.Context, o *domain.Order) {
+	if _, err := uc.Orders.SetNotified(ctx, o.ID, false); err != nil {
+		log.Error().Err(err).Str("order_id", o.ID.String()).Msg("liberar aviso de pago")
+		return
+	}
+	o.Notified = false
+}
+
+// RecordManual registra un cobro confirmado por el admin (efectivo o transferencia).
+func (uc *PaymentUC) RecordManual(ctx context.Context, o *domain.Order, provider string, amount float64, actor, note string) (*domain.Payment, error) {
+	amount = round2(amount)
+	if amount <= 0 {
+		return nil, errors.New("monto inválido")
+	}
+	now := time.Now()
+	p := &domain.Payment{
+		OrderID:  o.ID,
+		Provider: provider,
+		Amount:   amount,
+		Status:   domain.PaymentStatusApproved,
+		Actor:    actor,
+		Note:     note,
+		PaidAt:   &now,
+	}
+	if err := uc.Payments.Save(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Balance devuelve lo cobrado y el saldo de la orden junto con sus pagos.
+func (uc *PaymentUC) Balance(ctx context.Context, o *domain.Order) (domain.OrderBalance, []domain.Payment, error) {
+	list, err := uc.Payments.ListByOrders(ctx, []uuid.UUID{o.ID})
+	if err != nil {
+		return domain.OrderBalance{}, nil, err
+	}
+	return domain.NewOrderBalance(o, list), list, nil
+}
+
+// Apply lleva la orden al estado que corresponde al pago de MercadoPago. Es la misma lógica para el
+// webhook y para la conciliación: un aviso atrasado no hace retroceder la orden, un rechazo no
+// cancela una orden que ya tiene plata cobrada y la primera aprobación registra el cupón y avisa.
+func (uc *PaymentUC) Apply(ctx context.Context, o *domain.Order, gp *domain.GatewayPayment, ch StatusChange) (PaymentApplyResult, error) {
+	res := PaymentApplyResult{From: o.Status, To: o.Status}
+	// cada intento queda registrado, aunque sea rechazado o no cambie la orden
+	_, recErr := uc.RecordGateway(ctx, o, gp)
+	if recErr != nil {
+		log.Error().Err(recErr).Str("order_id", o.ID.String()).Str("payment_id", gp.ID).Msg("registrar pago")
+	}
+	status := gp.Status
+	approved := false
+	target := o.Status
+	switch status {
+	case "approved":
+		approved = true
+		target = domain.OrderStatusFinished
+	case "pending", "in_process", "in_mediation":
+		target = domain.OrderStatusAwaitingPay
+	case "rejected":
+		target = domain.OrderStatusCancelled
+		// un intento rechazado no cancela (ni pisa el MPStatus de) una orden que ya tiene plata cobrada
+		if b, _, err := uc.Balance(ctx, o); err == nil && b.Paid > 0 {
+			res.Ignored = "pago rechazado en una orden ya cobrada"
+			return res, nil
+		}
+	case "refunded", "charged_back":
+		target = domain.OrderStatusRefunded
+	}
+	// la primera aprobación sólo cierra la orden si, con este pago, no queda saldo (un pago por
+	// menos del total, ej. una preferencia vieja, deja la orden esperando el resto)
+	if approved && !o.Notified {
+		if recErr != nil {
+			return res, recErr
+		}
+		b, _, err := uc.Balance(ctx, o)
+		if err != nil {
+			return res, err
+		}
+		if b.Due > 0.009 {
+			approved = false
+			target = domain.OrderStatusAwaitingPay
+			res.Underpaid = true
+			res.Ignored = fmt.Sprintf("pago aprobado por $%.2f; falta cobrar $%.2f", round2(gp.Amount), b.Due)
+			saveReconcileIssue(ctx, uc.Issues, o, gp, domain.ReconcileAmountMismatch,
+				fmt.Sprintf("cobrado $%.2f de un total de $%.2f", b.Paid, b.Total))
+		}
+	}
+	// ya pagada: un nuevo aviso "approved" (ej. por un reembolso parcial) no mueve el estado
+	if approved && o.Notified {
+		target = o.Status
+	}
+	// un aviso atrasado (ej. "pending" después de "approved") no puede retroceder la orden
+	if target != o.Status && !CanTransition(o.Status, target) {
+		res.Ignored = fmt.Sprintf("transición inválida %s → %s", o.Status, target)
+		return res, nil
+	}
+	o.MPStatus = status
+	if approved || target == domain.OrderStatusRefunded {
+		o.MPPaymentID = gp.ID
+	}
+	// un pago ya aprobado que vuelve a notificarse suele ser un reembolso (parcial o hecho desde
+	// el panel de MercadoPago)
+	if uc.Refunds != nil && ((approved && o.Notified) || target == domain.OrderStatusRefunded) {
+		if err := uc.Refunds.Sync(ctx, o, gp.ID); err != nil {
+			log.Error().Err(err).Str("order_id", o.ID.String()).Str("payment_id", gp.ID).Msg("sincronizar reembolsos")
+		}
+		if target == domain.OrderStatusRefunded {
+			uc.Refunds.RevertCoupon(ctx, o)
+		}
+	}
+	notify := false
+	if approved && !o.Notified {
+		claimed, err := uc.ClaimPaid(ctx, o)
+		if err != nil {
+			return res, err
+		}
+		if !claimed {
+			res.Ignored, res.AppliedElsewhere = "el pago ya lo aplicó otro proceso", true
+			return res, nil
+		}
+		notify = true
+	}
+	if ch.Note == "" {
+		ch.Note = "pago " + gp.ID + ": " + status
+	}
+	if err := uc.Lifecycle.ChangeStatus(ctx, o, target, ch); err != nil {
+		if notify {
+			uc.ReleasePaid(ctx, o)
+		}
+		return res, err
+	}
+	res.To, res.Paid = o.Status, notify
+	if notify {
+		// Registrar uso del cupón cuando el pago es aprobado
+		if o.CouponID != nil && o.CouponCode != "" && uc.Coupons != nil {
+			if err := uc.Coupons.ApplyCoupon(ctx, *o.CouponID, o.ID, o.Email, o.DiscountAmount, o.Subtotal()); err != nil {
+				log.Error().Err(err).
+					Str("coupon_code", o.CouponCode).
+					Str("order_id", o.ID.String()).
+					Msg("error al registrar uso de cupón al aprobarse el pago")
+			} else {
+				log.Info().
+					Str("coupon_code", o.CouponCode).
+					Str("order_id", o.ID.String()).
+					Float64("discount", o.DiscountAmount).
+					Msg("cupón registrado exitosamente tras confirmación de pago")
+			}
+		}
+		if uc.OnPaid != nil {
+			go uc.OnPaid(o)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+const (
+	defaultPersonalizationMaxLength = 30
+	maxPersonalizationLength        = 200
+	maxPersonalizationFields        = 10
+)
+
+// SavePersonalizationField valida y guarda un campo de personalización del producto.
+func (uc *ProductUC) SavePersonalizationField(ctx context.Context, p *domain.Product, f *domain.PersonalizationField) error {
+	if p == nil || p.ID == uuid.Nil {
+		return errors.New("producto vacío")
+	}
+	if f.ID == uuid.Nil && len(p.Personalization) >= maxPersonalizationFields {
+		return fmt.Errorf("máximo %d campos de personalización por producto", maxPersonalizationFields)
+	}
+	f.ProductID = p.ID
+	f.Label = strings.TrimSpace(f.Label)
+	if f.Label == "" {
+		return errors.New("el campo necesita un nombre")
+	}
+	if utf8.RuneCountInString(f.Label) > 80 {
+		return errors.New("nombre demasiado largo")
+	}
+	if f.Surcharge < 0 {
+		return errors.New("el recargo no puede ser negativo")
+	}
+	switch f.Kind {
+	case domain.PersonalizationText:
+		if f.MaxLength <= 0 {
+			f.MaxLength = defaultPersonalizationMaxLength
+		}
+		if f.MaxLength > maxPersonalizationLength {
+			f.MaxLength = maxPersonalizationLength
+		}
+		f.Options = ""
+	case domain.PersonalizationFont, domain.PersonalizationColor:
+		opts := f.OptionList()
+		if len(opts) == 0 {
+			return errors.New("cargá al menos una opción")
+		}
+		f.Options = strings.Join(opts, "\n")
+		f.MaxLength = 0
+	case domain.PersonalizationLogo:
+		f.Options = ""
+		f.MaxLength = 0
+	default:
+		return errors.New("tipo de campo inválido")
+	}
+	return uc.Products.SavePersonalizationField(ctx, f)
+}
+
+func (uc *ProductUC) DeletePersonalizationField(ctx context.Context, productID, fieldID uuid.UUID) error {
+	if productID == uuid.Nil || fieldID == uuid.Nil {
+		return errors.New("id vacío")
+	}
+	return uc.Products.DeletePersonalizationField(ctx, productID, fieldID)
+}
+
+// ResolvePersonalization valida lo que cargó el cliente (id de campo -> valor) contra los
+// campos del producto y devuelve los valores completos en el orden de los campos.
+// Para logos, el valor es la ruta ya guardada (o el nombre del archivo al prevalidar).
+func (uc *ProductUC) ResolvePersonalization(p *domain.Product, input map[uuid.UUID]string) ([]domain.PersonalizationValue, error) {
+	if p == nil {
+		return nil, errors.New("producto nil")
+	}
+	var out []domain.PersonalizationValue
+	for _, f := range p.Personalization {
+		v := strings.TrimSpace(input[f.ID])
+		if f.Kind == domain.PersonalizationText {
+			v = strings.Join(strings.Fields(v), " ")
+		}
+		if v == "" {
+			if f.Required {
+				return nil, fmt.Errorf("completá \"%s\"", f.Label)
+			}
+			continue
+		}
+		switch f.Kind {
+		case domain.PersonalizationText:
+			max := f.MaxLength
+			if max <= 0 {
+				max = defaultPersonalizationMaxLength
+			}
+			if utf8.RuneCountInString(v) > max {
+				return nil, fmt.Errorf("\"%s\" admite hasta %d caracteres", f.Label, max)
+			}
+		case domain.PersonalizationFont, domain.PersonalizationColor:
+			ok := false
+			for _, o := range f.OptionList() {
+				if strings.EqualFold(o, v) {
+					v, ok = o, true
+					break
+				}
+			}
+			if !ok {
+				return nil, fmt.Errorf("opción inválida para \"%s\"", f.Label)
+			}
+		}
+		out = append(out, domain.PersonalizationValue{FieldID: f.ID, Label: f.Label, Kind: f.Kind, Value: v, Surcharge: f.Surcharge})
+	}
+	return out, nil
+}
+
+// ApplyPersonalization completa nombre, tipo y recargo de valores guardados (carrito) con
+// la definición actual del producto. Los valores de campos que ya no existen se conservan
+// sin recargo para no perder lo que pidió el cliente.
+func ApplyPersonalization(p *domain.Product, vals []domain.PersonalizationValue) []domain.PersonalizationValue {
+	if len(vals) == 0 {
+		return nil
+	}
+	out := make([]domain.PersonalizationValue, 0, len(vals))
+	for _, v := range vals {
+		v.Label, v.Kind, v.Surcharge = "Personalización", domain.PersonalizationText, 0
+		if strings.Contains(v.Value, "attachments/") {
+			v.Kind = domain.PersonalizationLogo
+		}
+		if p != nil {
+			for _, f := range p.Personalization {
+				if f.ID == v.FieldID {
+					v.Label, v.Kind, v.Surcharge = f.Label, f.Kind, f.Surcharge
+					break
+				}
+			}
+		}
+		out = append(out, v)
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// ProductModelUC adjunta un modelo STL/3MF a un producto: guarda el original (privado),
+// lo convierte a GLB y publica sólo el GLB para el visor 3D.
+type ProductModelUC struct {
+	Storage   domain.FileStorage
+	Uploads   domain.UploadedModelRepo
+	Models    domain.ProductModelRepo
+	Converter domain.ModelConverter
+
+	MaxTriangles int
+}
+
+const MaxProductModelBytes = 100 << 20
+
+// Attach reemplaza el modelo 3D del producto. Si la conversión falla no se guarda nada.
+func (uc *ProductModelUC) Attach(ctx context.Context, productID uuid.UUID, filename string, data []byte) (*domain.ProductModel3D, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext != ".stl" && ext != ".3mf" {
+		return nil, errors.New("formato no soportado (usar STL o 3MF)")
+	}
+	if len(data) == 0 {
+		return nil, errors.New("archivo vacío")
+	}
+	if len(data) > MaxProductModelBytes {
+		return nil, errors.New("archivo demasiado grande")
+	}
+	glb, stats, err := uc.Converter.ToGLB(filename, data, uc.MaxTriangles)
+	if err != nil {
+		return nil, err
+	}
+
+	srcPath, err := uc.Storage.SaveModel(ctx, filepath.Base(filename), data)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	src := &domain.UploadedModel{
+		ID:        uuid.New(),
+		Filename:  filepath.Base(filename),
+		Path:      srcPath,
+		Hash:      hex.EncodeToString(sum[:]),
+		CreatedAt: time.Now(),
+	}
+	if err := uc.Uploads.Save(ctx, src); err != nil {
+		_ = uc.Storage.Delete(ctx, srcPath)
+		return nil, err
+	}
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	assetPath, err := uc.Storage.SaveModel(ctx, base+".glb", glb)
+	if err != nil {
+		return nil, err
+	}
+
+	prev, err := uc.Models.FindByProductID(ctx, productID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	pm := &domain.ProductModel3D{
+		ProductID:       productID,
+		SourceModelID:   src.ID,
+		SourceFilename:  src.Filename,
+		AssetPath:       assetPath,
+		AssetSize:       int64(len(glb)),
+		Triangles:       stats.Triangles,
+		SourceTriangles: stats.SourceTriangles,
+		SizeXMM:         stats.SizeXMM,
+		SizeYMM:         stats.SizeYMM,
+		SizeZMM:         stats.SizeZMM,
+	}
+	if err := uc.Models.Save(ctx, pm); err != nil {
+		_ = uc.Storage.Delete(ctx, assetPath)
+		return nil, err
+	}
+	if prev != nil && prev.AssetPath != "" && prev.AssetPath != assetPath {
+		if err := uc.Storage.Delete(ctx, prev.AssetPath); err != nil && !errors.Is(err, domain.ErrNotFound) {
+			log.Warn().Err(err).Str("path", prev.AssetPath).Msg("modelo 3D: borrar glb anterior")
+		}
+	}
+	return pm, nil
+}
+
+// Remove quita el modelo 3D del producto y borra el GLB; el original queda como UploadedModel.
+func (uc *ProductModelUC) Remove(ctx context.Context, productID uuid.UUID) error {
+	pm, err := uc.Models.FindByProductID(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if err := uc.Models.DeleteByProductID(ctx, productID); err != nil {
+		return err
+	}
+	if err := uc.Storage.Delete(ctx, pm.AssetPath); err != nil && !errors.Is(err, domain.ErrNotFound) {
+		log.Warn().Err(err).Str("path", pm.AssetPath).Msg("modelo 3D: borrar glb")
+	}
+	return nil
+}
+
+func (uc *ProductModelUC) Get(ctx context.Context, productID uuid.UUID) (*domain.ProductModel3D, error) {
+	return uc.Models.FindByProductID(ctx, productID)
+}
+
+// OpenAsset abre el GLB del producto.
+func (uc *ProductModelUC) OpenAsset(ctx context.Context, productID uuid.UUID) (*domain.ProductModel3D, io.ReadCloser, error) {
+	pm, err := uc.Models.FindByProductID(ctx, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+	rc, err := uc.Storage.Open(ctx, pm.AssetPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pm, rc, nil
+}
@@ -135,3 +135,98 @@ func (uc *ProductUC) Categories(ctx context.Context) ([]string, error) {
 	}
 	return []string{}, nil
 }
+
+func (uc *ProductUC) ReorderImages(ctx context.Context, productID uuid.UUID, ids []uuid.UUID) error {
+	if productID == uuid.Nil {
+		return errors.New("producto vacío")
+	}
+	return uc.Products.ReorderImages(ctx, productID, ids)
+}
+
+func (uc *ProductUC) SetPrimaryImage(ctx context.Context, productID, imageID uuid.UUID) error {
+	if productID == uuid.Nil || imageID == uuid.Nil {
+		return errors.New("id vacío")
+	}
+	return uc.Products.SetPrimaryImage(ctx, productID, imageID)
+}
+
+// MoveImage corre una imagen una posición (delta -1 sube, +1 baja) dentro de la galería.
+func (uc *ProductUC) MoveImage(ctx context.Context, p *domain.Product, imageID uuid.UUID, delta int) error {
+	if p == nil {
+		return errors.New("producto nil")
+	}
+	ids := make([]uuid.UUID, 0, len(p.Images))
+	idx := -1
+	for i, im := range p.Images {
+		ids = append(ids, im.ID)
+		if im.ID == imageID {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return domain.ErrNotFound
+	}
+	j := idx + delta
+	if j < 0 || j >= len(ids) {
+		return nil
+	}
+	ids[idx], ids[j] = ids[j], ids[idx]
+	if err := uc.Products.ReorderImages(ctx, p.ID, ids); err != nil {
+		return err
+	}
+	// con portada marcada, la que queda primera pasa a ser la portada
+	if p.Images[0].IsPrimary && ids[0] != p.Images[0].ID {
+		return uc.Products.SetPrimaryImage(ctx, p.ID, ids[0])
+	}
+	return nil
+}
+
+// UpdateImageMeta valida y guarda alt, recorte y punto focal.
+func (uc *ProductUC) UpdateImageMeta(ctx context.Context, img *domain.Image) error {
+	if img == nil || img.ID == uuid.Nil {
+		return errors.New("imagen vacía")
+	}
+	img.Alt = strings.TrimSpace(img.Alt)
+	if len([]rune(img.Alt)) > 140 {
+		img.Alt = string([]rune(img.Alt)[:140])
+	}
+	if img.FocalX < 0 || img.FocalX > 1 || img.FocalY < 0 || img.FocalY > 1 {
+		return errors.New("punto focal fuera de rango (0..1)")
+	}
+	if img.CropW != 0 || img.CropH != 0 {
+		if !img.HasCrop() {
+			return errors.New("recorte inválido")
+		}
+	} else {
+		img.CropX, img.CropY = 0, 0
+	}
+	return uc.Products.UpdateImage(ctx, img)
+}
+
+// ReplaceImage cambia el archivo de una imagen manteniendo id, orden, portada y alt.
+// Devuelve la URL anterior para que el caller borre el archivo viejo.
+func (uc *ProductUC) ReplaceImage(ctx context.Context, id uuid.UUID, newURL string) (string, error) {
+	img, err := uc.Products.FindImageByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	old := img.URL
+	img.URL = newURL
+	img.CropX, img.CropY, img.CropW, img.CropH = 0, 0, 0, 0
+	img.FocalX, img.FocalY = 0.5, 0.5
+	if err := uc.Products.UpdateImage(ctx, img); err != nil {
+		return "", err
+	}
+	return old, nil
+}
+
+// ImageFileInUse indica si alguna imagen sigue usando el archivo url: el GC de duplicados puede
+// dejar imágenes de varios productos apuntando al mismo archivo.
+func (uc *ProductUC) ImageFileInUse(ctx context.Context, url string) (bool, error) {
+	n, err := uc.Products.CountImagesByURL(ctx, url)
+	return n > 0, err
+}
+
+func (uc *ProductUC) FindImageByURL(ctx context.Context, url string) (*domain.Image, error) {
+	return uc.Products.FindImageByURL(ctx, url)
+}
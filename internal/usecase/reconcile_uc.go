@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// ReconcileUC compara las órdenes sin cobrar con los pagos que tiene MercadoPago para su
+// external_reference. Si se perdió un webhook, aplica el pago con la misma lógica que el webhook
+// (PaymentUC.Apply); lo que no puede resolver solo queda como diferencia para el admin.
+type ReconcileUC struct {
+	Orders   domain.OrderRepo
+	Payments *PaymentUC
+	Issues   domain.ReconcileIssueRepo
+	// MinAge: cuánto se le da al webhook antes de ir a preguntarle a MercadoPago.
+	MinAge time.Duration
+	// Lookback: las órdenes más viejas que esto no se revisan más.
+	Lookback time.Duration
+}
+
+// ReconcileResult resume una pasada de la conciliación.
+type ReconcileResult struct {
+	Checked int
+	// Fixed: órdenes que cambiaron de estado por un pago que no había llegado por webhook.
+	Fixed int
+	// Issues: diferencias que quedaron para revisar.
+	Issues int
+}
+
+// Run revisa las órdenes con preferencia de MercadoPago creadas entre now-Lookback y now-MinAge.
+func (uc *ReconcileUC) Run(ctx context.Context, now time.Time) (ReconcileResult, error) {
+	var res ReconcileResult
+	list, err := uc.Orders.ListUnpaidMP(ctx, now.Add(-uc.Lookback), now.Add(-uc.MinAge))
+	if err != nil {
+		return res, err
+	}
+	for i := range list {
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		o := &list[i]
+		res.Checked++
+		if err := uc.reconcile(ctx, o, &res); err != nil {
+			log.Warn().Err(err).Str("order_id", o.ID.String()).Msg("conciliar orden con mercadopago")
+			uc.report(ctx, &res, o, &domain.GatewayPayment{}, domain.ReconcileError, err.Error())
+		}
+	}
+	return res, nil
+}
+
+func (uc *ReconcileUC) reconcile(ctx context.Context, o *domain.Order, res *ReconcileResult) error {
+	payments, err := uc.Payments.Gateway.SearchPayments(ctx, o)
+	if err != nil {
+		return fmt.Errorf("buscar pagos: %w", err)
+	}
+	if len(payments) == 0 {
+		return nil
+	}
+	// manda el último pago aprobado; si no hay, el último intento (vienen del más nuevo al más viejo)
+	pick := 0
+	for i := range payments {
+		if payments[i].Status == domain.PaymentStatusApproved {
+			pick = i
+			break
+		}
+	}
+	for i := range payments {
+		if i == pick {
+			continue
+		}
+		if _, err := uc.Payments.RecordGateway(ctx, o, &payments[i]); err != nil {
+			return fmt.Errorf("registrar pago %s: %w", payments[i].ID, err)
+		}
+	}
+	gp := &payments[pick]
+	// una orden vencida sólo se revive si MercadoPago la cobró; un pago pendiente (ej. un cupón de
+	// pago en efectivo sin pagar) no la vuelve a abrir
+	if o.Status == domain.OrderStatusCancelled && gp.Status != domain.PaymentStatusApproved {
+		_, err := uc.Payments.RecordGateway(ctx, o, gp)
+		return err
+	}
+	if gp.Status == o.MPStatus && gp.Status != domain.PaymentStatusApproved {
+		_, err := uc.Payments.RecordGateway(ctx, o, gp)
+		return err
+	}
+	ar, err := uc.Payments.Apply(ctx, o, gp, StatusChange{
+		Actor:  "conciliación",
+		Source: domain.StatusSourceReconcile,
+		Note:   "conciliación: pago " + gp.ID + " " + gp.Status + " sin webhook",
+	})
+	if err != nil {
+		return err
+	}
+	if ar.AppliedElsewhere {
+		// el webhook llegó mientras tanto
+		return nil
+	}
+	if ar.Underpaid {
+		// Apply ya guardó la diferencia de monto
+		res.Issues++
+		return nil
+	}
+	if ar.Ignored != "" {
+		uc.report(ctx, res, o, gp, domain.ReconcileBlocked, "MercadoPago informa "+gp.Status+" pero no se aplicó: "+ar.Ignored)
+		return nil
+	}
+	if ar.To != ar.From {
+		res.Fixed++
+		log.Info().Str("order_id", o.ID.String()).Str("payment_id", gp.ID).Str("from", string(ar.From)).Str("to", string(ar.To)).Msg("orden conciliada con mercadopago")
+		uc.report(ctx, nil, o, gp, domain.ReconcileMissedWebhook,
+			fmt.Sprintf("MercadoPago informa %s; la orden pasó de %s a %s", gp.Status, ar.From, ar.To))
+	}
+	if gp.Status == domain.PaymentStatusApproved {
+		b, _, err := uc.Payments.Balance(ctx, o)
+		if err != nil {
+			return err
+		}
+		if math.Abs(b.Paid-b.Total) >= 0.01 {
+			uc.report(ctx, res, o, gp, domain.ReconcileAmountMismatch,
+				fmt.Sprintf("cobrado $%.2f de un total de $%.2f", b.Paid, b.Total))
+		}
+	}
+	return nil
+}
+
+// report guarda la diferencia; con res != nil cuenta como pendiente de revisar.
+func (uc *ReconcileUC) report(ctx context.Context, res *ReconcileResult, o *domain.Order, gp *domain.GatewayPayment, kind, detail string) {
+	if res != nil {
+		res.Issues++
+	}
+	saveReconcileIssue(ctx, uc.Issues, o, gp, kind, detail)
+}
+
+// saveReconcileIssue guarda una diferencia para el admin; sin repo (tests) no hace nada.
+func saveReconcileIssue(ctx context.Context, issues domain.ReconcileIssueRepo, o *domain.Order, gp *domain.GatewayPayment, kind, detail string) {
+	if issues == nil {
+		return
+	}
+	detail = strings.TrimSpace(detail)
+	if r := []rune(detail); len(r) > 255 {
+		detail = string(r[:255])
+	}
+	i := &domain.ReconcileIssue{
+		OrderID:     o.ID,
+		PaymentID:   gp.ID,
+		Kind:        kind,
+		OrderStatus: o.Status,
+		MPStatus:    gp.Status,
+		Amount:      round2(gp.Amount),
+		Detail:      detail,
+		Fixed:       kind == domain.ReconcileMissedWebhook,
+	}
+	if err := issues.Save(ctx, i); err != nil {
+		log.Error().Err(err).Str("order_id", o.ID.String()).Str("kind", kind).Msg("guardar diferencia de conciliación")
+	}
+}
+
+// Open devuelve las diferencias que el admin todavía no descartó.
+func (uc *ReconcileUC) Open(ctx context.Context) ([]domain.ReconcileIssue, error) {
+	return uc.Issues.ListOpen(ctx, 200)
+}
+
+// Dismiss saca la diferencia de la lista.
+func (uc *ReconcileUC) Dismiss(ctx context.Context, id uuid.UUID, actor string) error {
+	return uc.Issues.Dismiss(ctx, id, actor, time.Now())
+}
@@ -0,0 +1,191 @@
+package usecase
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/adapters/payments/mercadopago/mpemu"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// frozenOrders devuelve a la conciliación la lista de órdenes que leyó antes de que llegara el webhook.
+type frozenOrders struct {
+	*memOrderRepo
+	list []domain.Order
+}
+
+func (r frozenOrders) ListUnpaidMP(ctx context.Context, from, to time.Time) ([]domain.Order, error) {
+	return r.list, nil
+}
+
+type reconcileFixture struct {
+	orders  *memOrderRepo
+	coupons *memCouponRepo
+	pay     *PaymentUC
+	paid    chan uuid.UUID
+	order   *domain.Order
+	payment *mpemu.Payment
+}
+
+// newReconcileFixture arma una orden con cupón, pagada en el emulador sin que llegue el webhook.
+func newReconcileFixture(t *testing.T, now time.Time) *reconcileFixture {
+	t.Helper()
+	g, emu := newEmulatedGateway(t)
+	o, p := paidOrder(t, g, emu, mpemu.StatusApproved)
+	couponID := uuid.New()
+	o.CreatedAt, o.CouponID, o.CouponCode, o.DiscountAmount = now.Add(-time.Hour), &couponID, "HOLA10", 1000
+	f := &reconcileFixture{orders: newMemOrderRepo(o), coupons: &memCouponRepo{}, paid: make(chan uuid.UUID, 4), order: o, payment: p}
+	f.pay = &PaymentUC{
+		Orders:    f.orders,
+		Gateway:   g,
+		Payments:  &memPaymentRepo{},
+		Lifecycle: &OrderUC{Orders: f.orders},
+		Coupons:   NewCouponUseCase(f.coupons, f.orders),
+		OnPaid:    func(o *domain.Order) { f.paid <- o.ID },
+	}
+	return f
+}
+
+func (f *reconcileFixture) reconciler(orders domain.OrderRepo) *ReconcileUC {
+	return &ReconcileUC{Orders: orders, Payments: f.pay, MinAge: 10 * time.Minute, Lookback: 48 * time.Hour}
+}
+
+func (f *reconcileFixture) waitPaid(t *testing.T, want int) {
+	t.Helper()
+	for i := 0; i < want; i++ {
+		select {
+		case <-f.paid:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("avisos de pago = %d; want %d", i, want)
+		}
+	}
+	select {
+	case <-f.paid:
+		t.Fatal("la orden se avisó como paga más de una vez")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestReconcileAppliesMissedWebhook(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	f := newReconcileFixture(t, now)
+
+	res, err := f.reconciler(f.orders).Run(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Checked != 1 || res.Fixed != 1 || res.Issues != 0 {
+		t.Fatalf("conciliación = %+v", res)
+	}
+	got := f.orders.get(f.order.ID)
+	if got.Status != domain.OrderStatusFinished || !got.Notified || got.MPStatus != "approved" {
+		t.Fatalf("orden: status %s, notified %v, mp %s", got.Status, got.Notified, got.MPStatus)
+	}
+	f.waitPaid(t, 1)
+
+	// la segunda pasada ya no la ve impaga
+	if res, err := f.reconciler(f.orders).Run(ctx, now); err != nil || res.Checked != 0 {
+		t.Fatalf("segunda pasada = %+v, %v", res, err)
+	}
+	if f.coupons.uses != 1 || len(f.coupons.usages) != 1 {
+		t.Fatalf("usos del cupón = %d (%d registros)", f.coupons.uses, len(f.coupons.usages))
+	}
+}
+
+func TestReconcileAndWebhookApplyPaymentOnce(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	f := newReconcileFixture(t, now)
+
+	// la conciliación lista la orden impaga y justo llega el webhook del pago
+	stale, _ := f.orders.ListUnpaidMP(ctx, now.Add(-48*time.Hour), now)
+	o, _ := f.orders.FindByID(ctx, f.order.ID)
+	gp, err := f.pay.Gateway.PaymentInfo(ctx, mpPaymentID(f.payment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wr, err := f.pay.Apply(ctx, o, gp, StatusChange{Actor: "mercadopago", Source: domain.StatusSourceWebhook})
+	if err != nil || !wr.Paid {
+		t.Fatalf("webhook = %+v, %v", wr, err)
+	}
+
+	res, err := f.reconciler(frozenOrders{memOrderRepo: f.orders, list: stale}).Run(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Checked != 1 || res.Fixed != 0 || res.Issues != 0 {
+		t.Fatalf("conciliación = %+v", res)
+	}
+	if f.coupons.uses != 1 || len(f.coupons.usages) != 1 {
+		t.Fatalf("usos del cupón = %d (%d registros)", f.coupons.uses, len(f.coupons.usages))
+	}
+	f.waitPaid(t, 1)
+	if got := f.orders.get(f.order.ID); got.Status != domain.OrderStatusFinished {
+		t.Fatalf("status = %s", got.Status)
+	}
+}
+
+type memIssueRepo struct {
+	domain.ReconcileIssueRepo
+	issues []domain.ReconcileIssue
+}
+
+func (r *memIssueRepo) Save(ctx context.Context, i *domain.ReconcileIssue) error {
+	r.issues = append(r.issues, *i)
+	return nil
+}
+
+func TestApplyUnderpaidApprovalKeepsOrderAwaitingPayment(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name   string
+		amount float64
+		paid   bool
+	}{
+		{"pago completo", 0, true},
+		{"pago de menos", 7000, false},
+		{"falta un centavo", 11999.99, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g, emu := newEmulatedGateway(t)
+			o, _ := paidOrder(t, g, emu, mpemu.StatusPending)
+			p, err := emu.Pay(o.MPPreferenceID, mpemu.StatusApproved, c.amount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			orders := newMemOrderRepo(o)
+			issues := &memIssueRepo{}
+			uc := &PaymentUC{Orders: orders, Gateway: g, Payments: &memPaymentRepo{}, Lifecycle: &OrderUC{Orders: orders}, Issues: issues}
+			gp, err := g.PaymentInfo(ctx, mpPaymentID(p))
+			if err != nil {
+				t.Fatal(err)
+			}
+			o, _ = orders.FindByID(ctx, o.ID)
+			res, err := uc.Apply(ctx, o, gp, StatusChange{Actor: "mercadopago", Source: domain.StatusSourceWebhook})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := orders.get(o.ID)
+			if c.paid {
+				if !res.Paid || res.Underpaid || got.Status != domain.OrderStatusFinished || !got.Notified || len(issues.issues) != 0 {
+					t.Fatalf("apply = %+v; orden %s notified=%v; %d diferencias", res, got.Status, got.Notified, len(issues.issues))
+				}
+				return
+			}
+			if res.Paid || !res.Underpaid || got.Status != domain.OrderStatusAwaitingPay || got.Notified {
+				t.Fatalf("apply = %+v; orden %s notified=%v", res, got.Status, got.Notified)
+			}
+			if len(issues.issues) != 1 || issues.issues[0].Kind != domain.ReconcileAmountMismatch || issues.issues[0].Amount != c.amount {
+				t.Fatalf("diferencias = %+v", issues.issues)
+			}
+		})
+	}
+}
+
+func mpPaymentID(p *mpemu.Payment) string { return strconv.FormatInt(p.ID, 10) }
@@ -0,0 +1,206 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// ErrNotRefundable: la orden no tiene un pago de MercadoPago que se pueda devolver.
+var ErrNotRefundable = errors.New("la orden no tiene un pago de MercadoPago para reembolsar")
+
+// RefundUC hace los reembolsos (totales o parciales) por la pasarela y los registra en la orden.
+type RefundUC struct {
+	Orders  *OrderUC
+	Refunds domain.RefundRepo
+	Gateway domain.PaymentGateway
+	Coupons *CouponUseCase
+}
+
+// Refundable es lo que todavía se puede devolver de la orden.
+func Refundable(o *domain.Order) float64 {
+	if o.MPStatus != "approved" {
+		return 0
+	}
+	return math.Max(0, round2(o.Total-o.RefundedAmount))
+}
+
+func round2(v float64) float64 { return math.Round(v*100) / 100 }
+
+// Refund devuelve amount del pago de la orden (0 = todo lo que queda). Si con esto se devuelve el
+// total, la orden pasa a reembolsada y se libera el cupón que haya usado.
+func (uc *RefundUC) Refund(ctx context.Context, orderID uuid.UUID, amount float64, reason string, ch StatusChange) (*domain.Refund, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("falta el motivo del reembolso")
+	}
+	o, err := uc.Orders.Orders.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	left := Refundable(o)
+	if left <= 0 {
+		return nil, ErrNotRefundable
+	}
+	amount = round2(amount)
+	if amount < 0 {
+		return nil, errors.New("monto inválido")
+	}
+	if amount == 0 {
+		amount = left
+	}
+	if amount > left {
+		return nil, fmt.Errorf("el monto supera lo que queda por devolver (%.2f)", left)
+	}
+	if o.MPPaymentID == "" {
+		id, err := uc.Gateway.FindPayment(ctx, o)
+		if err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				return nil, ErrNotRefundable
+			}
+			return nil, err
+		}
+		o.MPPaymentID = id
+	}
+
+	rf := &domain.Refund{ID: uuid.New(), OrderID: o.ID, Amount: amount, Reason: reason, Actor: ch.Actor, Source: ch.Source}
+	// MP toma "sin monto" como reembolso total; lo usamos sólo si es todo lo cobrado
+	gwAmount := amount
+	if o.RefundedAmount == 0 && amount == left {
+		gwAmount = 0
+	}
+	gr, err := uc.Gateway.Refund(ctx, o.MPPaymentID, gwAmount, refundKey(o, amount))
+	if err != nil {
+		return nil, err
+	}
+	if prev, err := uc.Refunds.FindByGatewayID(ctx, gr.ID); err == nil {
+		// doble envío: MP devolvió el reembolso que ya hizo el primero, que es el que actualiza la orden
+		log.Warn().Str("order_id", o.ID.String()).Str("refund_id", gr.ID).Msg("reembolso repetido ignorado")
+		return prev, nil
+	}
+	rf.GatewayID, rf.Status = gr.ID, gr.Status
+	if gr.Amount > 0 {
+		rf.Amount = gr.Amount
+	}
+	if err := uc.Refunds.Save(ctx, rf); err != nil {
+		// la plata ya salió: el webhook lo vuelve a registrar
+		log.Error().Err(err).Str("order_id", o.ID.String()).Str("refund_id", rf.GatewayID).Msg("guardar reembolso")
+	}
+	o.RefundedAmount = uc.refunded(ctx, o, rf)
+	ch.Note = refundNote(rf)
+	if err := uc.settle(ctx, o, ch); err != nil {
+		return rf, err
+	}
+	return rf, nil
+}
+
+// refundKey es la clave de idempotencia del reembolso: sale de la orden, lo ya devuelto y el monto
+// pedido, así un doble envío con la misma orden manda la misma clave y MP devuelve el reembolso
+// ya hecho en vez de hacer otro.
+func refundKey(o *domain.Order, amount float64) string {
+	return uuid.NewSHA1(o.ID, fmt.Appendf(nil, "refund:%.2f:%.2f", o.RefundedAmount, amount)).String()
+}
+
+// refunded suma los reembolsos guardados de la orden (más rf, por si no se pudo guardar). La
+// orden que se leyó al empezar puede estar vieja si hubo otro reembolso en el medio.
+func (uc *RefundUC) refunded(ctx context.Context, o *domain.Order, rf *domain.Refund) float64 {
+	list, err := uc.Refunds.ListByOrders(ctx, []uuid.UUID{o.ID})
+	if err != nil {
+		log.Error().Err(err).Str("order_id", o.ID.String()).Msg("listar reembolsos")
+		if rf.Counts() {
+			return round2(o.RefundedAmount + rf.Amount)
+		}
+		return o.RefundedAmount
+	}
+	total, seen := 0.0, false
+	for _, r := range list {
+		if r.OrderID != o.ID {
+			continue
+		}
+		seen = seen || r.GatewayID == rf.GatewayID
+		if r.Counts() {
+			total += r.Amount
+		}
+	}
+	if !seen && rf.Counts() {
+		total += rf.Amount
+	}
+	return round2(total)
+}
+
+// Sync registra los reembolsos del pago que todavía no conocemos (los hechos desde el panel de
+// MercadoPago) y recalcula lo devuelto. Lo usa el webhook; no guarda la orden.
+func (uc *RefundUC) Sync(ctx context.Context, o *domain.Order, paymentID string) error {
+	list, err := uc.Gateway.Refunds(ctx, paymentID)
+	if err != nil {
+		return err
+	}
+	total := 0.0
+	for _, gr := range list {
+		rf, err := uc.Refunds.FindByGatewayID(ctx, gr.ID)
+		if err != nil && !errors.Is(err, domain.ErrNotFound) {
+			return err
+		}
+		if rf == nil {
+			rf = &domain.Refund{OrderID: o.ID, Amount: gr.Amount, GatewayID: gr.ID, Status: gr.Status, Reason: "Reembolso desde MercadoPago", Actor: "mercadopago", Source: domain.StatusSourceWebhook}
+			if !gr.CreatedAt.IsZero() {
+				rf.CreatedAt = gr.CreatedAt
+			}
+			if err := uc.Refunds.Save(ctx, rf); err != nil {
+				return err
+			}
+			log.Info().Str("order_id", o.ID.String()).Str("refund_id", gr.ID).Float64("amount", gr.Amount).Msg("reembolso registrado desde MercadoPago")
+		}
+		rf.Status = gr.Status
+		if rf.Counts() {
+			total += gr.Amount
+		}
+	}
+	o.RefundedAmount = round2(total)
+	return nil
+}
+
+// RevertCoupon libera el cupón de una orden reembolsada entera.
+func (uc *RefundUC) RevertCoupon(ctx context.Context, o *domain.Order) {
+	if o.CouponID == nil || uc.Coupons == nil {
+		return
+	}
+	reverted, err := uc.Coupons.RevertCoupon(ctx, *o.CouponID, o.ID)
+	if err != nil {
+		log.Error().Err(err).Str("order_id", o.ID.String()).Str("coupon_code", o.CouponCode).Msg("revertir cupón por reembolso")
+		return
+	}
+	if reverted {
+		log.Info().Str("order_id", o.ID.String()).Str("coupon_code", o.CouponCode).Msg("uso de cupón revertido por reembolso")
+	}
+}
+
+// settle guarda lo devuelto y, si ya se devolvió todo, pasa la orden a reembolsada. Un reembolso
+// parcial guarda sólo el monto: la orden se leyó antes de llamar a la pasarela y pudo cambiar.
+func (uc *RefundUC) settle(ctx context.Context, o *domain.Order, ch StatusChange) error {
+	if o.RefundedAmount < round2(o.Total)-0.009 {
+		return uc.Orders.Orders.SetRefunded(ctx, o.ID, o.MPPaymentID, o.RefundedAmount)
+	}
+	o.MPStatus = "refunded"
+	uc.RevertCoupon(ctx, o)
+	to := o.Status
+	if CanTransition(o.Status, domain.OrderStatusRefunded) {
+		to = domain.OrderStatusRefunded
+	}
+	return uc.Orders.ChangeStatus(ctx, o, to, ch)
+}
+
+func refundNote(rf *domain.Refund) string {
+	note := fmt.Sprintf("reembolso $%.2f: %s", rf.Amount, rf.Reason)
+	if r := []rune(note); len(r) > 255 {
+		note = string(r[:252]) + "..."
+	}
+	return note
+}
@@ -0,0 +1,175 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/adapters/payments/mercadopago"
+	"github.com/phenrril/tienda3d/internal/adapters/payments/mercadopago/mpemu"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type memRefundRepo struct {
+	mu   sync.Mutex
+	list []domain.Refund
+}
+
+func (r *memRefundRepo) Save(ctx context.Context, rf *domain.Refund) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rf.ID == uuid.Nil {
+		rf.ID = uuid.New()
+	}
+	for i := range r.list {
+		if r.list[i].GatewayID == rf.GatewayID {
+			r.list[i] = *rf
+			return nil
+		}
+	}
+	r.list = append(r.list, *rf)
+	return nil
+}
+
+func (r *memRefundRepo) FindByGatewayID(ctx context.Context, gatewayID string) (*domain.Refund, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rf := range r.list {
+		if rf.GatewayID == gatewayID {
+			return &rf, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (r *memRefundRepo) ListByOrders(ctx context.Context, orderIDs []uuid.UUID) ([]domain.Refund, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.Refund
+	for _, rf := range r.list {
+		for _, id := range orderIDs {
+			if rf.OrderID == id {
+				out = append(out, rf)
+			}
+		}
+	}
+	return out, nil
+}
+
+// newEmulatedGateway levanta el emulador de MercadoPago y devuelve el gateway apuntado a él. Los
+// webhooks del emulador van a un servidor que sólo responde 200.
+func newEmulatedGateway(t *testing.T) (*mercadopago.Gateway, *mpemu.Emulator) {
+	t.Helper()
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(sink.Close)
+	emu := mpemu.New("")
+	emu.NotifyURL = sink.URL
+	mp := httptest.NewServer(emu)
+	t.Cleanup(mp.Close)
+	g := mercadopago.NewGateway("TEST-local")
+	g.BaseURL = mp.URL
+	return g, emu
+}
+
+// paidOrder crea la preferencia de la orden en el emulador y la paga con status.
+func paidOrder(t *testing.T, g *mercadopago.Gateway, emu *mpemu.Emulator, status string) (*domain.Order, *mpemu.Payment) {
+	t.Helper()
+	o := &domain.Order{ID: uuid.New(), Status: domain.OrderStatusAwaitingPay, Email: "c@example.com", Total: 12000, ShippingCost: 2000,
+		Items: []domain.OrderItem{{Title: "Maceta", Qty: 2, UnitPrice: 5000}}}
+	if _, err := g.CreatePreference(context.Background(), o); err != nil {
+		t.Fatal(err)
+	}
+	p, err := emu.Pay(o.MPPreferenceID, status, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o, p
+}
+
+func TestRefundDoubleSubmitRefundsOnce(t *testing.T) {
+	ctx := context.Background()
+	g, emu := newEmulatedGateway(t)
+	o, p := paidOrder(t, g, emu, mpemu.StatusApproved)
+	o.Status, o.MPStatus, o.MPPaymentID = domain.OrderStatusFinished, "approved", mpPaymentID(p)
+	stale := cloneOrder(o)
+	orders := newMemOrderRepo(o)
+	refunds := &memRefundRepo{}
+	uc := &RefundUC{Orders: &OrderUC{Orders: orders}, Refunds: refunds, Gateway: g}
+	ch := StatusChange{Actor: "admin", Source: domain.StatusSourceAdmin}
+
+	first, err := uc.Refund(ctx, o.ID, 2000, "falla de impresión", ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// el segundo clic leyó la orden antes de que el primero la guardara
+	saved := orders.get(o.ID)
+	_ = orders.Save(ctx, &stale)
+	second, err := uc.Refund(ctx, o.ID, 2000, "falla de impresión", ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.GatewayID != first.GatewayID {
+		t.Fatalf("el doble envío hizo dos reembolsos: %s y %s", first.GatewayID, second.GatewayID)
+	}
+	if list, err := g.Refunds(ctx, o.MPPaymentID); err != nil || len(list) != 1 {
+		t.Fatalf("reembolsos en MP = %+v, %v", list, err)
+	}
+
+	// un segundo reembolso real (con la orden ya actualizada) sí sale
+	_ = orders.Save(ctx, &saved)
+	if saved.RefundedAmount != 2000 {
+		t.Fatalf("devuelto después del primero = %.2f", saved.RefundedAmount)
+	}
+	if _, err := uc.Refund(ctx, o.ID, 2000, "otra pieza", ch); err != nil {
+		t.Fatal(err)
+	}
+	if got := orders.get(o.ID); got.RefundedAmount != 4000 || got.Status != domain.OrderStatusFinished {
+		t.Fatalf("orden: devuelto %.2f, status %s", got.RefundedAmount, got.Status)
+	}
+	if list, _ := g.Refunds(ctx, o.MPPaymentID); len(list) != 2 || len(refunds.list) != 2 {
+		t.Fatalf("reembolsos: MP %d, guardados %d", len(list), len(refunds.list))
+	}
+}
+
+// slowRefundGateway corre during antes de hacer el reembolso, como un cambio que llega mientras
+// se espera a la pasarela.
+type slowRefundGateway struct {
+	*mercadopago.Gateway
+	during func()
+}
+
+func (g *slowRefundGateway) Refund(ctx context.Context, paymentID string, amount float64, key string) (*domain.GatewayRefund, error) {
+	g.during()
+	return g.Gateway.Refund(ctx, paymentID, amount, key)
+}
+
+func TestPartialRefundKeepsConcurrentStatusChange(t *testing.T) {
+	ctx := context.Background()
+	g, emu := newEmulatedGateway(t)
+	o, p := paidOrder(t, g, emu, mpemu.StatusApproved)
+	o.Status, o.MPStatus, o.MPPaymentID = domain.OrderStatusFinished, "approved", mpPaymentID(p)
+	orders := newMemOrderRepo(o)
+	slow := &slowRefundGateway{Gateway: g, during: func() {
+		shipped := orders.get(o.ID)
+		shipped.Status, shipped.TrackingNumber = domain.OrderStatusShipped, "AR123"
+		if ok, _ := orders.SaveIfStatus(ctx, &shipped, domain.OrderStatusFinished); !ok {
+			t.Fatal("no se pudo despachar la orden")
+		}
+	}}
+	uc := &RefundUC{Orders: &OrderUC{Orders: orders}, Refunds: &memRefundRepo{}, Gateway: slow}
+
+	if _, err := uc.Refund(ctx, o.ID, 2000, "falla de impresión", StatusChange{Actor: "admin", Source: domain.StatusSourceAdmin}); err != nil {
+		t.Fatal(err)
+	}
+	got := orders.get(o.ID)
+	if got.Status != domain.OrderStatusShipped || got.TrackingNumber != "AR123" {
+		t.Fatalf("el reembolso pisó el despacho: status %s, seguimiento %q", got.Status, got.TrackingNumber)
+	}
+	if got.RefundedAmount != 2000 {
+		t.Fatalf("devuelto = %.2f", got.RefundedAmount)
+	}
+}
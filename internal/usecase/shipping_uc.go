@@ -0,0 +1,323 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// volumetricDivisor convierte volumen en peso facturable: gramos = mm³ / 5000 (5000 cm³ por kg,
+// el divisor que usan los correos).
+const volumetricDivisor = 5000.0
+
+// ShippingUC administra las zonas de envío y cotiza el costo para el checkout.
+type ShippingUC struct {
+	Zones domain.ShippingZoneRepo
+}
+
+// ShippingItem es una línea del carrito para armar el paquete.
+type ShippingItem struct {
+	Product *domain.Product
+	Qty     int
+}
+
+// ShippingRequest son los datos de una cotización.
+type ShippingRequest struct {
+	Method     string
+	Province   string
+	PostalCode string
+	Package    domain.ShippingPackage
+	// ItemsTotal es el subtotal de productos (para envío gratis).
+	ItemsTotal float64
+}
+
+// BuildPackage suma peso y volumen de los productos (Grams y medidas en mm). Los productos
+// sin datos cargados no suman.
+func BuildPackage(items []ShippingItem) domain.ShippingPackage {
+	var pkg domain.ShippingPackage
+	for _, it := range items {
+		if it.Product == nil || it.Qty <= 0 {
+			continue
+		}
+		p := it.Product
+		q := float64(it.Qty)
+		pkg.Grams += p.Grams * q
+		pkg.VolumeMM += p.WidthMM * p.HeightMM * p.DepthMM * q
+		pkg.LongMM = math.Max(pkg.LongMM, math.Max(p.WidthMM, math.Max(p.HeightMM, p.DepthMM)))
+	}
+	return pkg
+}
+
+// BillableGrams es el mayor entre el peso real y el volumétrico.
+func BillableGrams(pkg domain.ShippingPackage) float64 {
+	return math.Max(pkg.Grams, pkg.VolumeMM/volumetricDivisor)
+}
+
+// QuoteShipping cotiza un método con las zonas dadas. Toma la zona activa de mayor prioridad
+// que cubra el destino y, dentro de ella, el primer escalón en el que entra el paquete.
+func QuoteShipping(zones []domain.ShippingZone, req ShippingRequest) domain.ShippingQuote {
+	q := domain.ShippingQuote{Method: req.Method}
+	if req.Method == domain.ShippingPickup || req.Method == "" {
+		q.Method = domain.ShippingPickup
+		q.Available = true
+		return q
+	}
+	sorted := make([]domain.ShippingZone, len(zones))
+	copy(sorted, zones)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority > sorted[j].Priority })
+	var zone *domain.ShippingZone
+	for i := range sorted {
+		z := &sorted[i]
+		if z.Active && z.Method == req.Method && zoneCovers(*z, req.Province, req.PostalCode) {
+			zone = z
+			break
+		}
+	}
+	if zone == nil {
+		if req.Method == domain.ShippingCadete {
+			q.Reason = "El cadete no llega a esa dirección"
+		} else {
+			q.Reason = "No hacemos envíos a ese destino"
+		}
+		if strings.TrimSpace(req.PostalCode) == "" && strings.TrimSpace(req.Province) == "" {
+			q.Reason = "Completá provincia y código postal para cotizar"
+		}
+		return q
+	}
+	q.Zone = zone.Name
+	rate, ok := pickRate(zone.Rates, req.Package)
+	if !ok {
+		q.Reason = fmt.Sprintf("El paquete supera el peso o las medidas máximas de \"%s\"", zone.Name)
+		return q
+	}
+	q.Available = true
+	q.Cost = rate.Price
+	if zone.FreeFrom > 0 && req.ItemsTotal >= zone.FreeFrom {
+		q.Cost = 0
+		q.Free = true
+	}
+	return q
+}
+
+func pickRate(rates []domain.ShippingRate, pkg domain.ShippingPackage) (domain.ShippingRate, bool) {
+	sorted := make([]domain.ShippingRate, len(rates))
+	copy(sorted, rates)
+	// 0 = sin límite: va al final
+	limit := func(v float64) float64 {
+		if v <= 0 {
+			return math.Inf(1)
+		}
+		return v
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if limit(sorted[i].MaxGrams) != limit(sorted[j].MaxGrams) {
+			return limit(sorted[i].MaxGrams) < limit(sorted[j].MaxGrams)
+		}
+		return limit(sorted[i].MaxSideMM) < limit(sorted[j].MaxSideMM)
+	})
+	grams := BillableGrams(pkg)
+	for _, r := range sorted {
+		if grams <= limit(r.MaxGrams) && pkg.LongMM <= limit(r.MaxSideMM) {
+			return r, true
+		}
+	}
+	return domain.ShippingRate{}, false
+}
+
+// zoneCovers: una zona sin provincias ni rangos cubre todo; si no, alcanza con que coincida
+// la provincia o que el código postal caiga en un rango.
+func zoneCovers(z domain.ShippingZone, province, postal string) bool {
+	provs, ranges := z.ProvinceList(), z.PostalRangeList()
+	if len(provs) == 0 && len(ranges) == 0 {
+		return true
+	}
+	province = strings.TrimSpace(province)
+	for _, p := range provs {
+		if province != "" && strings.EqualFold(p, province) {
+			return true
+		}
+	}
+	n, ok := postalNumber(postal)
+	if !ok {
+		return false
+	}
+	for _, r := range ranges {
+		from, to, err := parsePostalRange(r)
+		if err == nil && n >= from && n <= to {
+			return true
+		}
+	}
+	return false
+}
+
+// postalNumber toma la parte numérica del código postal (acepta "2000" y CPA "S2000ABC").
+func postalNumber(pc string) (int, bool) {
+	digits := strings.Builder{}
+	for _, r := range strings.TrimSpace(pc) {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		} else if digits.Len() > 0 {
+			break
+		}
+	}
+	if digits.Len() == 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(digits.String())
+	return n, err == nil
+}
+
+func parsePostalRange(s string) (int, int, error) {
+	a, b, found := strings.Cut(strings.TrimSpace(s), "-")
+	from, err := strconv.Atoi(strings.TrimSpace(a))
+	if err != nil {
+		return 0, 0, fmt.Errorf("rango de código postal inválido: %q", s)
+	}
+	if !found {
+		return from, from, nil
+	}
+	to, err := strconv.Atoi(strings.TrimSpace(b))
+	if err != nil || to < from {
+		return 0, 0, fmt.Errorf("rango de código postal inválido: %q", s)
+	}
+	return from, to, nil
+}
+
+// ParseShippingRates lee la tabla de tarifas del formulario del admin: una línea por escalón
+// con "gramos máx; lado máx mm; precio" (0 = sin límite). También acepta "gramos máx; precio".
+func ParseShippingRates(text string) ([]domain.ShippingRate, error) {
+	var out []domain.ShippingRate
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		parts := strings.Split(line, ";")
+		nums := make([]float64, 0, len(parts))
+		for _, p := range parts {
+			v, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(p), ",", "."), 64)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("tarifa %d: número inválido %q", i+1, strings.TrimSpace(p))
+			}
+			nums = append(nums, v)
+		}
+		switch len(nums) {
+		case 2:
+			out = append(out, domain.ShippingRate{MaxGrams: nums[0], Price: nums[1]})
+		case 3:
+			out = append(out, domain.ShippingRate{MaxGrams: nums[0], MaxSideMM: nums[1], Price: nums[2]})
+		default:
+			return nil, fmt.Errorf("tarifa %d: usá \"gramos; lado mm; precio\"", i+1)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("cargá al menos una tarifa")
+	}
+	return out, nil
+}
+
+// FormatShippingRates es la inversa de ParseShippingRates, para editar la zona.
+func FormatShippingRates(rates []domain.ShippingRate) string {
+	lines := make([]string, 0, len(rates))
+	for _, r := range rates {
+		lines = append(lines, fmt.Sprintf("%g; %g; %g", r.MaxGrams, r.MaxSideMM, r.Price))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (uc *ShippingUC) List(ctx context.Context) ([]domain.ShippingZone, error) {
+	return uc.Zones.List(ctx)
+}
+
+func (uc *ShippingUC) Get(ctx context.Context, id uuid.UUID) (*domain.ShippingZone, error) {
+	return uc.Zones.FindByID(ctx, id)
+}
+
+// SaveZone valida la zona y su tabla de tarifas (texto del admin) y la guarda.
+func (uc *ShippingUC) SaveZone(ctx context.Context, z *domain.ShippingZone, ratesText string) error {
+	z.Name = strings.TrimSpace(z.Name)
+	if z.Name == "" {
+		return errors.New("el nombre es obligatorio")
+	}
+	if z.Method != domain.ShippingCourier && z.Method != domain.ShippingCadete {
+		return errors.New("método de entrega inválido")
+	}
+	if z.FreeFrom < 0 {
+		return errors.New("el mínimo para envío gratis no puede ser negativo")
+	}
+	for _, r := range z.PostalRangeList() {
+		if _, _, err := parsePostalRange(r); err != nil {
+			return err
+		}
+	}
+	rates, err := ParseShippingRates(ratesText)
+	if err != nil {
+		return err
+	}
+	z.Rates = rates
+	return uc.Zones.Save(ctx, z)
+}
+
+func (uc *ShippingUC) DeleteZone(ctx context.Context, id uuid.UUID) error {
+	return uc.Zones.Delete(ctx, id)
+}
+
+// Quote cotiza con las zonas guardadas.
+func (uc *ShippingUC) Quote(ctx context.Context, req ShippingRequest) (domain.ShippingQuote, error) {
+	if req.Method == domain.ShippingPickup || req.Method == "" {
+		return QuoteShipping(nil, req), nil
+	}
+	zones, err := uc.Zones.List(ctx)
+	if err != nil {
+		return domain.ShippingQuote{}, err
+	}
+	return QuoteShipping(zones, req), nil
+}
+
+// StartingPrices devuelve la tarifa más baja de cada método con zonas activas (para mostrar
+// "desde $X" en el checkout). Un método sin zonas activas no aparece: no se ofrece.
+func (uc *ShippingUC) StartingPrices(ctx context.Context) (map[string]float64, error) {
+	zones, err := uc.Zones.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]float64{}
+	for _, z := range zones {
+		if !z.Active {
+			continue
+		}
+		for _, r := range z.Rates {
+			if v, ok := out[z.Method]; !ok || r.Price < v {
+				out[z.Method] = r.Price
+			}
+		}
+	}
+	return out, nil
+}
+
+// SeedDefaults crea las zonas equivalentes a los costos fijos anteriores (envío a todo el
+// país $9000, cadete $5000) si todavía no hay ninguna.
+func (uc *ShippingUC) SeedDefaults(ctx context.Context) error {
+	zones, err := uc.Zones.List(ctx)
+	if err != nil || len(zones) > 0 {
+		return err
+	}
+	defaults := []domain.ShippingZone{
+		{Name: "Todo el país", Method: domain.ShippingCourier, Active: true, Rates: []domain.ShippingRate{{Price: 9000}}},
+		{Name: "Cadete Rosario", Method: domain.ShippingCadete, Active: true, Rates: []domain.ShippingRate{{Price: 5000}}},
+	}
+	for i := range defaults {
+		if err := uc.Zones.Save(ctx, &defaults[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
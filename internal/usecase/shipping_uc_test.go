@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+func testShippingZones() []domain.ShippingZone {
+	rates := []domain.ShippingRate{
+		{MaxGrams: 0, MaxSideMM: 0, Price: 15000},
+		{MaxGrams: 500, MaxSideMM: 300, Price: 6000},
+		{MaxGrams: 2000, MaxSideMM: 500, Price: 9000},
+	}
+	return []domain.ShippingZone{
+		{Name: "Nacional", Method: domain.ShippingCourier, Priority: 0, Active: true, Rates: rates},
+		{Name: "Rosario", Method: domain.ShippingCourier, Priority: 10, Active: true, PostalRanges: "2000-2013\n2121", FreeFrom: 50000, Rates: []domain.ShippingRate{{MaxGrams: 2000, Price: 3000}}},
+		{Name: "Córdoba (pausada)", Method: domain.ShippingCourier, Priority: 20, Active: false, Provinces: "Córdoba", Rates: []domain.ShippingRate{{Price: 1}}},
+		{Name: "Cadete Rosario", Method: domain.ShippingCadete, Active: true, PostalRanges: "2000", Rates: []domain.ShippingRate{{Price: 2500}}},
+	}
+}
+
+func TestQuoteShipping(t *testing.T) {
+	small := domain.ShippingPackage{Grams: 300, VolumeMM: 100 * 100 * 100, LongMM: 100}
+	big := domain.ShippingPackage{Grams: 1800, VolumeMM: 200 * 200 * 200, LongMM: 600}
+	huge := domain.ShippingPackage{Grams: 3000, LongMM: 900}
+
+	cases := []struct {
+		name      string
+		req       ShippingRequest
+		available bool
+		cost      float64
+		zone      string
+		free      bool
+	}{
+		{"retiro siempre disponible", ShippingRequest{Method: domain.ShippingPickup}, true, 0, "", false},
+		{"método vacío es retiro", ShippingRequest{}, true, 0, "", false},
+		{"nacional escalón chico", ShippingRequest{Method: domain.ShippingCourier, Province: "Mendoza", PostalCode: "5500", Package: small}, true, 6000, "Nacional", false},
+		{"nacional escalón por medidas", ShippingRequest{Method: domain.ShippingCourier, Province: "Mendoza", PostalCode: "5500", Package: big}, true, 15000, "Nacional", false},
+		{"zona inactiva no aplica", ShippingRequest{Method: domain.ShippingCourier, Province: "Córdoba", PostalCode: "5000", Package: small}, true, 6000, "Nacional", false},
+		{"rango postal con prioridad", ShippingRequest{Method: domain.ShippingCourier, Province: "Santa Fe", PostalCode: "S2005ABC", Package: small}, true, 3000, "Rosario", false},
+		{"código suelto", ShippingRequest{Method: domain.ShippingCourier, PostalCode: "2121", Package: small}, true, 3000, "Rosario", false},
+		{"envío gratis desde el umbral", ShippingRequest{Method: domain.ShippingCourier, PostalCode: "2000", Package: small, ItemsTotal: 50000}, true, 0, "Rosario", true},
+		{"supera la zona elegida", ShippingRequest{Method: domain.ShippingCourier, PostalCode: "2000", Package: huge}, false, 0, "Rosario", false},
+		{"cadete fuera de zona", ShippingRequest{Method: domain.ShippingCadete, PostalCode: "5000", Package: small}, false, 0, "", false},
+		{"cadete en zona", ShippingRequest{Method: domain.ShippingCadete, PostalCode: "2000", Package: small}, true, 2500, "Cadete Rosario", false},
+	}
+	for _, c := range cases {
+		q := QuoteShipping(testShippingZones(), c.req)
+		if q.Available != c.available || q.Cost != c.cost || q.Zone != c.zone || q.Free != c.free {
+			t.Errorf("%s: got available=%v cost=%v zone=%q free=%v (%s)", c.name, q.Available, q.Cost, q.Zone, q.Free, q.Reason)
+		}
+		if !q.Available && q.Reason == "" {
+			t.Errorf("%s: sin motivo de no disponible", c.name)
+		}
+	}
+}
+
+func TestBillableGramsUsesVolumetricWeight(t *testing.T) {
+	// 30x30x30 cm = 27.000 cm³ → 5.400 g volumétricos
+	pkg := BuildPackage([]ShippingItem{{Product: &domain.Product{Grams: 200, WidthMM: 300, HeightMM: 300, DepthMM: 300}, Qty: 1}})
+	if got := BillableGrams(pkg); got != 5400 {
+		t.Fatalf("BillableGrams = %v; want 5400", got)
+	}
+	if pkg.LongMM != 300 {
+		t.Fatalf("LongMM = %v", pkg.LongMM)
+	}
+}
+
+func TestParseShippingRates(t *testing.T) {
+	rates, err := ParseShippingRates("500; 300; 6000\n\n2000; 9000,5\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 || rates[0].MaxSideMM != 300 || rates[0].Price != 6000 || rates[1].MaxGrams != 2000 {
+		t.Fatalf("rates = %+v", rates)
+	}
+	for _, bad := range []string{"", "abc; 100", "1; 2; 3; 4", "-1; 100"} {
+		if _, err := ParseShippingRates(bad); err == nil {
+			t.Errorf("ParseShippingRates(%q) no falló", bad)
+		}
+	}
+}
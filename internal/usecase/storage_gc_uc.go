@@ -0,0 +1,338 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// StorageGCUC detecta imágenes huérfanas (sin fila en images) y duplicadas (mismo hash),
+// las mueve a cuarentena y las purga pasado QuarantineTTL.
+type StorageGCUC struct {
+	Storage  domain.FileStorage
+	Products domain.ProductRepo
+	Items    domain.StorageGCRepo
+
+	// MinAge evita marcar archivos recién subidos cuyo producto todavía se está guardando.
+	MinAge        time.Duration
+	QuarantineTTL time.Duration
+}
+
+type StorageGCScanResult struct {
+	ScannedFiles int
+	ScannedBytes int64
+	Orphans      int
+	Duplicates   int
+	Duration     time.Duration
+}
+
+type StorageGCReport struct {
+	Detected        []domain.StorageGCItem
+	Quarantined     []domain.StorageGCItem
+	OrphanCount     int
+	OrphanBytes     int64
+	DuplicateCount  int
+	DuplicateBytes  int64
+	QuarantineBytes int64
+	LastScan        time.Time
+}
+
+const storageGCQuarantinePrefix = "quarantine/"
+
+// gcKey normaliza una ruta/URL guardada a la clave relativa al storage ("images/x.jpg").
+func gcKey(p string) string {
+	v := strings.ReplaceAll(strings.TrimSpace(p), "\\", "/")
+	if i := strings.IndexAny(v, "?#"); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.Index(v, "uploads/"); i >= 0 {
+		return v[i+len("uploads/"):]
+	}
+	// STORAGE_DIR con otro nombre: cortar en la subcarpeta conocida (la cuarentena primero, que
+	// tiene images/ adentro)
+	v = "/" + strings.TrimPrefix(v, "/")
+	for _, sub := range []string{"/" + storageGCQuarantinePrefix, "/images/", "/models/", "/attachments/"} {
+		if i := strings.Index(v, sub); i >= 0 {
+			return v[i+1:]
+		}
+	}
+	return strings.TrimPrefix(v, "/")
+}
+
+// imageRefs devuelve clave -> URLs crudas de images que apuntan a ese archivo.
+func (uc *StorageGCUC) imageRefs(ctx context.Context) (map[string][]string, error) {
+	urls, err := uc.Products.ImageURLs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string][]string, len(urls))
+	for _, u := range urls {
+		low := strings.ToLower(strings.TrimSpace(u))
+		if low == "" || strings.HasPrefix(low, "http://") || strings.HasPrefix(low, "https://") {
+			continue
+		}
+		k := gcKey(u)
+		refs[k] = append(refs[k], u)
+	}
+	return refs, nil
+}
+
+func (uc *StorageGCUC) hashFile(ctx context.Context, p string) (string, error) {
+	rc, err := uc.Storage.Open(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Scan recorre images/, calcula hashes y reemplaza el reporte de ítems detectados.
+func (uc *StorageGCUC) Scan(ctx context.Context) (*StorageGCScanResult, error) {
+	start := time.Now()
+	files, err := uc.Storage.List(ctx, "images")
+	if err != nil {
+		return nil, err
+	}
+	refs, err := uc.imageRefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := &StorageGCScanResult{}
+	type entry struct {
+		f    domain.StoredFile
+		key  string
+		hash string
+		ref  bool
+	}
+	groups := map[string][]entry{}
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		res.ScannedFiles++
+		res.ScannedBytes += f.Size
+		h, err := uc.hashFile(ctx, f.Path)
+		if err != nil {
+			log.Warn().Err(err).Str("path", f.Path).Msg("storage gc: hash")
+			continue
+		}
+		k := gcKey(f.Path)
+		_, ref := refs[k]
+		groups[h] = append(groups[h], entry{f: f, key: k, hash: h, ref: ref})
+	}
+
+	now := time.Now()
+	items := []domain.StorageGCItem{}
+	for _, g := range groups {
+		// canónico: referenciado primero, después el más viejo
+		sort.SliceStable(g, func(i, j int) bool {
+			if g[i].ref != g[j].ref {
+				return g[i].ref
+			}
+			if !g[i].f.ModTime.Equal(g[j].f.ModTime) {
+				return g[i].f.ModTime.Before(g[j].f.ModTime)
+			}
+			return g[i].key < g[j].key
+		})
+		canon := g[0]
+		for i, e := range g {
+			it := domain.StorageGCItem{
+				Path:       e.f.Path,
+				Hash:       e.hash,
+				Size:       e.f.Size,
+				Status:     domain.StorageGCDetected,
+				DetectedAt: now,
+			}
+			if i > 0 {
+				it.DuplicateOf = canon.f.Path
+			}
+			switch {
+			case !e.ref:
+				if uc.MinAge > 0 && now.Sub(e.f.ModTime) < uc.MinAge {
+					continue
+				}
+				it.Reason = domain.StorageGCReasonOrphan
+				res.Orphans++
+			case i > 0 && canon.ref:
+				it.Reason = domain.StorageGCReasonDuplicate
+				res.Duplicates++
+			default:
+				continue
+			}
+			items = append(items, it)
+		}
+	}
+	if err := uc.Items.ReplaceDetected(ctx, items); err != nil {
+		return nil, err
+	}
+	res.Duration = time.Since(start)
+	return res, nil
+}
+
+func (uc *StorageGCUC) Report(ctx context.Context) (*StorageGCReport, error) {
+	det, err := uc.Items.List(ctx, domain.StorageGCDetected)
+	if err != nil {
+		return nil, err
+	}
+	q, err := uc.Items.List(ctx, domain.StorageGCQuarantined)
+	if err != nil {
+		return nil, err
+	}
+	rep := &StorageGCReport{Detected: det, Quarantined: q}
+	for _, it := range det {
+		if it.DetectedAt.After(rep.LastScan) {
+			rep.LastScan = it.DetectedAt
+		}
+		if it.Reason == domain.StorageGCReasonDuplicate {
+			rep.DuplicateCount++
+			rep.DuplicateBytes += it.Size
+		} else {
+			rep.OrphanCount++
+			rep.OrphanBytes += it.Size
+		}
+	}
+	for _, it := range q {
+		rep.QuarantineBytes += it.Size
+	}
+	return rep, nil
+}
+
+// Quarantine mueve a cuarentena los ítems indicados (o todos los detectados si ids está vacío).
+// Antes de mover se vuelve a verificar que el archivo siga sin uso; en duplicados, las
+// imágenes que lo usaban pasan a apuntar al archivo canónico.
+func (uc *StorageGCUC) Quarantine(ctx context.Context, ids []uuid.UUID) (int, error) {
+	var list []domain.StorageGCItem
+	if len(ids) == 0 {
+		all, err := uc.Items.List(ctx, domain.StorageGCDetected)
+		if err != nil {
+			return 0, err
+		}
+		list = all
+	} else {
+		for _, id := range ids {
+			it, err := uc.Items.FindByID(ctx, id)
+			if err != nil {
+				return 0, err
+			}
+			list = append(list, *it)
+		}
+	}
+	refs, err := uc.imageRefs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for i := range list {
+		it := &list[i]
+		if it.Status != domain.StorageGCDetected {
+			continue
+		}
+		key := gcKey(it.Path)
+		raws := refs[key]
+		switch it.Reason {
+		case domain.StorageGCReasonOrphan:
+			if len(raws) > 0 {
+				log.Info().Str("path", it.Path).Msg("storage gc: archivo volvió a tener uso, se omite")
+				continue
+			}
+		case domain.StorageGCReasonDuplicate:
+			if it.DuplicateOf == "" {
+				continue
+			}
+			if ok, err := uc.Storage.Exists(ctx, it.DuplicateOf); err != nil || !ok {
+				log.Warn().Err(err).Str("path", it.Path).Str("canonical", it.DuplicateOf).Msg("storage gc: canónico inexistente, se omite")
+				continue
+			}
+			for _, raw := range raws {
+				if _, err := uc.Products.ReplaceImageURL(ctx, raw, it.DuplicateOf); err != nil {
+					return moved, err
+				}
+			}
+		}
+		qp := "/uploads/" + storageGCQuarantinePrefix + key
+		if err := uc.Storage.Move(ctx, it.Path, qp); err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				it.Status = domain.StorageGCPurged
+				now := time.Now()
+				it.PurgedAt = &now
+				_ = uc.Items.Save(ctx, it)
+				continue
+			}
+			return moved, err
+		}
+		now := time.Now()
+		it.Status = domain.StorageGCQuarantined
+		it.QuarantinePath = qp
+		it.QuarantinedAt = &now
+		if err := uc.Items.Save(ctx, it); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// Restore devuelve un archivo en cuarentena a su ruta original.
+func (uc *StorageGCUC) Restore(ctx context.Context, id uuid.UUID) error {
+	it, err := uc.Items.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if it.Status != domain.StorageGCQuarantined {
+		return errors.New("el archivo no está en cuarentena")
+	}
+	if err := uc.Storage.Move(ctx, it.QuarantinePath, it.Path); err != nil {
+		return err
+	}
+	it.Status = domain.StorageGCRestored
+	return uc.Items.Save(ctx, it)
+}
+
+// Purge borra definitivamente lo que está en cuarentena desde antes de before.
+func (uc *StorageGCUC) Purge(ctx context.Context, before time.Time) (int, int64, error) {
+	list, err := uc.Items.ListQuarantinedBefore(ctx, before)
+	if err != nil {
+		return 0, 0, err
+	}
+	n := 0
+	var freed int64
+	for i := range list {
+		it := &list[i]
+		if err := uc.Storage.Delete(ctx, it.QuarantinePath); err != nil && !errors.Is(err, domain.ErrNotFound) {
+			log.Warn().Err(err).Str("path", it.QuarantinePath).Msg("storage gc: purge")
+			continue
+		}
+		now := time.Now()
+		it.Status = domain.StorageGCPurged
+		it.PurgedAt = &now
+		if err := uc.Items.Save(ctx, it); err != nil {
+			return n, freed, err
+		}
+		n++
+		freed += it.Size
+	}
+	return n, freed, nil
+}
+
+// PurgeExpired purga lo que cumplió QuarantineTTL.
+func (uc *StorageGCUC) PurgeExpired(ctx context.Context) (int, int64, error) {
+	ttl := uc.QuarantineTTL
+	if ttl <= 0 {
+		ttl = 7 * 24 * time.Hour
+	}
+	return uc.Purge(ctx, time.Now().Add(-ttl))
+}
@@ -0,0 +1,267 @@
+package usecase
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/adapters/storage/localfs"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// gcProducts son las URLs de la tabla images.
+type gcProducts struct {
+	domain.ProductRepo
+	urls []string
+}
+
+func (p *gcProducts) ImageURLs(ctx context.Context) ([]string, error) {
+	return append([]string(nil), p.urls...), nil
+}
+
+func (p *gcProducts) ReplaceImageURL(ctx context.Context, from, to string) (int64, error) {
+	var n int64
+	for i, u := range p.urls {
+		if u == from {
+			p.urls[i] = to
+			n++
+		}
+	}
+	return n, nil
+}
+
+type memGCRepo struct {
+	items map[uuid.UUID]*domain.StorageGCItem
+}
+
+func (r *memGCRepo) ReplaceDetected(ctx context.Context, items []domain.StorageGCItem) error {
+	for id, it := range r.items {
+		if it.Status == domain.StorageGCDetected {
+			delete(r.items, id)
+		}
+	}
+	for _, it := range items {
+		it := it
+		it.ID = uuid.New()
+		r.items[it.ID] = &it
+	}
+	return nil
+}
+
+func (r *memGCRepo) List(ctx context.Context, status string) ([]domain.StorageGCItem, error) {
+	var out []domain.StorageGCItem
+	for _, it := range r.items {
+		if it.Status == status {
+			out = append(out, *it)
+		}
+	}
+	return out, nil
+}
+
+func (r *memGCRepo) FindByID(ctx context.Context, id uuid.UUID) (*domain.StorageGCItem, error) {
+	it, ok := r.items[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	cp := *it
+	return &cp, nil
+}
+
+func (r *memGCRepo) Save(ctx context.Context, it *domain.StorageGCItem) error {
+	cp := *it
+	r.items[it.ID] = &cp
+	return nil
+}
+
+func (r *memGCRepo) ListQuarantinedBefore(ctx context.Context, before time.Time) ([]domain.StorageGCItem, error) {
+	var out []domain.StorageGCItem
+	for _, it := range r.items {
+		if it.Status == domain.StorageGCQuarantined && it.QuarantinedAt.Before(before) {
+			out = append(out, *it)
+		}
+	}
+	return out, nil
+}
+
+func (r *memGCRepo) byPath(path string) *domain.StorageGCItem {
+	for _, it := range r.items {
+		if it.Path == path {
+			return it
+		}
+	}
+	return nil
+}
+
+func TestGCKey(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"/uploads/images/a.jpg", "images/a.jpg"},
+		{"uploads/images/a.jpg", "images/a.jpg"},
+		{" /uploads/images/a.jpg?v=3 ", "images/a.jpg"},
+		{`C:\data\uploads\images\a.jpg`, "images/a.jpg"},
+		{"/srv/storage/images/a.jpg", "images/a.jpg"},
+		{"/srv/storage/quarantine/images/a.jpg", "quarantine/images/a.jpg"},
+		{"images/a.jpg#frente", "images/a.jpg"},
+	}
+	for _, c := range cases {
+		if got := gcKey(c.in); got != c.want {
+			t.Errorf("gcKey(%q) = %q; want %q", c.in, got, c.want)
+		}
+	}
+}
+
+type gcFixture struct {
+	dir      string
+	products *gcProducts
+	items    *memGCRepo
+	uc       *StorageGCUC
+}
+
+// newGCFixture escribe las imágenes (nombre -> contenido) con la antigüedad indicada.
+func newGCFixture(t *testing.T, files map[string]string, age map[string]time.Duration, urls ...string) *gcFixture {
+	t.Helper()
+	dir := t.TempDir()
+	now := time.Now()
+	for name, body := range files {
+		full := filepath.Join(dir, "images", name)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mt := now.Add(-age[name])
+		if err := os.Chtimes(full, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f := &gcFixture{dir: dir, products: &gcProducts{urls: urls}, items: &memGCRepo{items: map[uuid.UUID]*domain.StorageGCItem{}}}
+	f.uc = &StorageGCUC{Storage: localfs.New(dir), Products: f.products, Items: f.items, MinAge: time.Hour}
+	return f
+}
+
+func TestStorageGCScanDetectsOrphansAndDuplicates(t *testing.T) {
+	day := 24 * time.Hour
+	f := newGCFixture(t,
+		map[string]string{
+			"original.jpg": "foto",
+			"copia.jpg":    "foto",
+			"suelta.jpg":   "foto",
+			"huerfana.jpg": "otra",
+			"recien.jpg":   "nueva",
+			"unica.jpg":    "unica",
+		},
+		map[string]time.Duration{
+			"original.jpg": 3 * day,
+			"copia.jpg":    2 * day,
+			"suelta.jpg":   day,
+			"huerfana.jpg": day,
+			"recien.jpg":   time.Minute,
+			"unica.jpg":    day,
+		},
+		"/uploads/images/original.jpg", "uploads/images/copia.jpg", "/uploads/images/unica.jpg", "https://cdn.example.com/images/huerfana.jpg")
+
+	res, err := f.uc.Scan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ScannedFiles != 6 || res.Orphans != 2 || res.Duplicates != 1 {
+		t.Fatalf("scan = %+v", res)
+	}
+	cases := []struct {
+		path        string
+		reason      string
+		duplicateOf string
+	}{
+		{"/uploads/images/original.jpg", "", ""},
+		{"/uploads/images/unica.jpg", "", ""},
+		// referenciada, con el mismo contenido que una más vieja
+		{"/uploads/images/copia.jpg", domain.StorageGCReasonDuplicate, "/uploads/images/original.jpg"},
+		// sin referencia gana huérfana aunque repita el contenido
+		{"/uploads/images/suelta.jpg", domain.StorageGCReasonOrphan, "/uploads/images/original.jpg"},
+		// las URLs externas no cuentan como referencia
+		{"/uploads/images/huerfana.jpg", domain.StorageGCReasonOrphan, ""},
+		// más nueva que MinAge: todavía se puede estar guardando el producto
+		{"/uploads/images/recien.jpg", "", ""},
+	}
+	for _, c := range cases {
+		it := f.items.byPath(c.path)
+		if c.reason == "" {
+			if it != nil {
+				t.Errorf("%s detectado como %s", c.path, it.Reason)
+			}
+			continue
+		}
+		if it == nil || it.Reason != c.reason || it.DuplicateOf != c.duplicateOf || it.Status != domain.StorageGCDetected {
+			t.Errorf("%s = %+v; want %s de %q", c.path, it, c.reason, c.duplicateOf)
+		}
+	}
+}
+
+func TestStorageGCQuarantine(t *testing.T) {
+	ctx := context.Background()
+	day := 24 * time.Hour
+	f := newGCFixture(t,
+		map[string]string{"original.jpg": "foto", "copia.jpg": "foto", "huerfana.jpg": "otra", "vuelve.jpg": "otra mas"},
+		map[string]time.Duration{"original.jpg": 3 * day, "copia.jpg": 2 * day, "huerfana.jpg": day, "vuelve.jpg": day},
+		"/uploads/images/original.jpg", "/uploads/images/copia.jpg", "/uploads/images/copia.jpg")
+	if _, err := f.uc.Scan(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// entre el scan y la cuarentena un producto empieza a usar la imagen
+	f.products.urls = append(f.products.urls, "uploads/images/vuelve.jpg")
+
+	moved, err := f.uc.Quarantine(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 2 {
+		t.Fatalf("movidos = %d; want 2", moved)
+	}
+	cases := []struct {
+		name      string
+		status    string
+		inImages  bool
+		inQuarant bool
+	}{
+		{"original.jpg", "", true, false},
+		{"copia.jpg", domain.StorageGCQuarantined, false, true},
+		{"huerfana.jpg", domain.StorageGCQuarantined, false, true},
+		{"vuelve.jpg", domain.StorageGCDetected, true, false},
+	}
+	for _, c := range cases {
+		p := "/uploads/images/" + c.name
+		if it := f.items.byPath(p); c.status != "" && (it == nil || it.Status != c.status) {
+			t.Errorf("%s = %+v; want %s", c.name, it, c.status)
+		}
+		_, err := os.Stat(filepath.Join(f.dir, "images", c.name))
+		if (err == nil) != c.inImages {
+			t.Errorf("%s en images/: %v", c.name, err)
+		}
+		_, err = os.Stat(filepath.Join(f.dir, "quarantine", "images", c.name))
+		if (err == nil) != c.inQuarant {
+			t.Errorf("%s en quarantine/: %v", c.name, err)
+		}
+	}
+	// las imágenes que usaban la copia apuntan al original
+	for _, u := range f.products.urls[:3] {
+		if u != "/uploads/images/original.jpg" {
+			t.Fatalf("urls = %v", f.products.urls)
+		}
+	}
+
+	// restaurar devuelve el archivo a su lugar
+	it := f.items.byPath("/uploads/images/huerfana.jpg")
+	if err := f.uc.Restore(ctx, it.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(f.dir, "images", "huerfana.jpg")); err != nil {
+		t.Fatal(err)
+	}
+	if it := f.items.byPath("/uploads/images/huerfana.jpg"); it.Status != domain.StorageGCRestored {
+		t.Fatalf("status = %s", it.Status)
+	}
+}
@@ -0,0 +1,199 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// trackingTimeout acota cuánto esperamos a un correo por cada envío al hacer el polling.
+const trackingTimeout = 20 * time.Second
+
+// TrackingUC guarda el seguimiento de los envíos, consulta los eventos en los correos y avisa al
+// cliente cuando la orden sale, está en distribución y se entrega.
+type TrackingUC struct {
+	Orders    *OrderUC
+	Events    domain.ShipmentEventRepo
+	Trackers  []domain.ShipmentTracker
+	Notifiers []domain.ShipmentNotifier
+}
+
+type TrackingPollResult struct {
+	Checked int
+	Updated int
+}
+
+func (uc *TrackingUC) tracker(code string) domain.ShipmentTracker {
+	if uc == nil {
+		return nil
+	}
+	for _, t := range uc.Trackers {
+		if t.Code() == code {
+			return t
+		}
+	}
+	return nil
+}
+
+// TrackingURL devuelve la página de seguimiento del correo ("" si no lo conocemos).
+func (uc *TrackingUC) TrackingURL(carrier, number string) string {
+	if t := uc.tracker(carrier); t != nil {
+		return t.TrackingURL(number)
+	}
+	return ""
+}
+
+// SetTracking carga a mano correo, número y URL de seguimiento (vacía = la del correo) y marca
+// la orden como despachada.
+func (uc *TrackingUC) SetTracking(ctx context.Context, id uuid.UUID, carrier, number, trackingURL string, ch StatusChange) (*domain.Order, error) {
+	number = strings.TrimSpace(number)
+	if number == "" {
+		return nil, errors.New("falta el número de seguimiento")
+	}
+	trackingURL = strings.TrimSpace(trackingURL)
+	if trackingURL != "" && !strings.HasPrefix(trackingURL, "http://") && !strings.HasPrefix(trackingURL, "https://") {
+		return nil, errors.New("la URL de seguimiento tiene que empezar con http:// o https://")
+	}
+	o, err := uc.Orders.Orders.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c := strings.ToLower(strings.TrimSpace(carrier)); c != "" {
+		o.Carrier = c
+	}
+	o.TrackingNumber = number
+	if trackingURL == "" {
+		trackingURL = uc.TrackingURL(o.Carrier, number)
+	}
+	o.TrackingURL = trackingURL
+	if err := uc.Advance(ctx, o, domain.ShipmentStageShipped, ch); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Advance lleva la orden a la etapa de envío stage si es posterior a la actual: la pasa a
+// "Enviada" cuando la transición es válida y avisa al cliente. Siempre guarda la orden, pero
+// sólo avisa si quedó enviada (una orden cancelada o sin pagar con seguimiento no se avisa).
+func (uc *TrackingUC) Advance(ctx context.Context, o *domain.Order, stage string, ch StatusChange) error {
+	if domain.ShipmentStageRank(stage) <= domain.ShipmentStageRank(o.ShipmentStage) {
+		return uc.Orders.Orders.Save(ctx, o)
+	}
+	prev := o.ShipmentStage
+	o.ShipmentStage = stage
+	to := o.Status
+	if CanTransition(o.Status, domain.OrderStatusShipped) {
+		to = domain.OrderStatusShipped
+	}
+	if err := uc.Orders.ChangeStatus(ctx, o, to, ch); err != nil {
+		o.ShipmentStage = prev
+		return err
+	}
+	if o.Status == domain.OrderStatusShipped {
+		uc.notify(ctx, o, stage)
+	}
+	return nil
+}
+
+// notify avisa por todos los canales configurados; un canal caído no frena a los demás.
+func (uc *TrackingUC) notify(ctx context.Context, o *domain.Order, stage string) {
+	for _, n := range uc.Notifiers {
+		if err := n.NotifyShipment(ctx, o, stage); err != nil {
+			log.Warn().Err(err).Str("order_id", o.ID.String()).Str("stage", stage).Msg("aviso de envío")
+		}
+	}
+}
+
+// OrderEvents devuelve los movimientos del envío de la orden.
+func (uc *TrackingUC) OrderEvents(ctx context.Context, orderID uuid.UUID) ([]domain.TrackingEvent, error) {
+	if uc == nil || uc.Events == nil {
+		return nil, nil
+	}
+	return uc.Events.ListByOrder(ctx, orderID)
+}
+
+// Poll consulta a los correos el seguimiento de las órdenes despachadas y no entregadas,
+// guarda los eventos nuevos y avanza la etapa cuando corresponde.
+func (uc *TrackingUC) Poll(ctx context.Context) (TrackingPollResult, error) {
+	var res TrackingPollResult
+	if len(uc.Trackers) == 0 {
+		return res, nil
+	}
+	list, err := uc.Orders.Orders.ListTrackable(ctx)
+	if err != nil {
+		return res, err
+	}
+	for i := range list {
+		o := &list[i]
+		t := uc.tracker(o.Carrier)
+		if t == nil {
+			continue
+		}
+		tctx, cancel := context.WithTimeout(ctx, trackingTimeout)
+		events, err := t.Track(tctx, o.TrackingNumber)
+		cancel()
+		if err != nil {
+			log.Warn().Err(err).Str("order_id", o.ID.String()).Str("carrier", o.Carrier).Msg("consultar seguimiento")
+			continue
+		}
+		res.Checked++
+		stage, err := uc.recordEvents(ctx, o.ID, events)
+		if err != nil {
+			log.Warn().Err(err).Str("order_id", o.ID.String()).Msg("guardar eventos de seguimiento")
+			continue
+		}
+		if domain.ShipmentStageRank(stage) <= domain.ShipmentStageRank(o.ShipmentStage) {
+			continue
+		}
+		ch := StatusChange{Actor: "sistema", Source: domain.StatusSourceTracking, Note: "seguimiento " + o.Carrier + ": " + domain.ShipmentStageLabel(stage)}
+		if err := uc.Advance(ctx, o, stage, ch); err != nil {
+			log.Warn().Err(err).Str("order_id", o.ID.String()).Msg("avanzar etapa de envío")
+			continue
+		}
+		res.Updated++
+	}
+	return res, nil
+}
+
+// recordEvents guarda los eventos que todavía no teníamos y devuelve la etapa más avanzada.
+func (uc *TrackingUC) recordEvents(ctx context.Context, orderID uuid.UUID, events []domain.TrackingEvent) (string, error) {
+	existing, err := uc.Events.ListByOrder(ctx, orderID)
+	if err != nil {
+		return "", err
+	}
+	key := func(e domain.TrackingEvent) string {
+		return e.OccurredAt.UTC().Format(time.RFC3339) + "|" + e.Description
+	}
+	seen := make(map[string]bool, len(existing))
+	stage := ""
+	for _, e := range existing {
+		seen[key(e)] = true
+		if domain.ShipmentStageRank(e.Stage) > domain.ShipmentStageRank(stage) {
+			stage = e.Stage
+		}
+	}
+	for _, e := range events {
+		if seen[key(e)] {
+			continue
+		}
+		seen[key(e)] = true
+		e.ID = uuid.New()
+		e.OrderID = orderID
+		if r := []rune(e.Description); len(r) > 255 {
+			e.Description = string(r[:255])
+		}
+		if err := uc.Events.Add(ctx, &e); err != nil {
+			return "", err
+		}
+		if domain.ShipmentStageRank(e.Stage) > domain.ShipmentStageRank(stage) {
+			stage = e.Stage
+		}
+	}
+	return stage, nil
+}
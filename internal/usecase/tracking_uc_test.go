@@ -0,0 +1,149 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/adapters/carriers/fake"
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type memEventRepo struct {
+	mu     sync.Mutex
+	events []domain.TrackingEvent
+}
+
+func (r *memEventRepo) Add(ctx context.Context, e *domain.TrackingEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, *e)
+	return nil
+}
+
+func (r *memEventRepo) ListByOrder(ctx context.Context, orderID uuid.UUID) ([]domain.TrackingEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.TrackingEvent
+	for _, e := range r.events {
+		if e.OrderID == orderID {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// recNotifier guarda los avisos de envío en vez de mandarlos.
+type recNotifier struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (n *recNotifier) NotifyShipment(ctx context.Context, o *domain.Order, stage string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, o.Code()+" "+stage)
+	return nil
+}
+
+func (n *recNotifier) list() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.sent...)
+}
+
+func newTrackingUC(orders *memOrderRepo, carrier *fake.Carrier) (*TrackingUC, *recNotifier) {
+	n := &recNotifier{}
+	return &TrackingUC{
+		Orders:    &OrderUC{Orders: orders},
+		Events:    &memEventRepo{},
+		Trackers:  []domain.ShipmentTracker{carrier},
+		Notifiers: []domain.ShipmentNotifier{n},
+	}, n
+}
+
+func TestAdvanceNotifiesOnlyShippedOrders(t *testing.T) {
+	ctx := context.Background()
+	paid := &domain.Order{ID: uuid.New(), Status: domain.OrderStatusFinished, Carrier: "fake", TrackingNumber: "FAKE1"}
+	cancelled := &domain.Order{ID: uuid.New(), Status: domain.OrderStatusCancelled, Carrier: "fake", TrackingNumber: "FAKE2"}
+	orders := newMemOrderRepo(paid, cancelled)
+	uc, n := newTrackingUC(orders, fake.New())
+	ch := StatusChange{Actor: "test", Source: domain.StatusSourceTracking}
+
+	if err := uc.Advance(ctx, paid, domain.ShipmentStageShipped, ch); err != nil {
+		t.Fatal(err)
+	}
+	if got := orders.get(paid.ID); got.Status != domain.OrderStatusShipped || got.ShipmentStage != domain.ShipmentStageShipped {
+		t.Fatalf("orden paga: status %s, etapa %s", got.Status, got.ShipmentStage)
+	}
+
+	// cancelada: no puede pasar a enviada, se guarda la etapa pero el cliente no recibe "salió"
+	if err := uc.Advance(ctx, cancelled, domain.ShipmentStageShipped, ch); err != nil {
+		t.Fatal(err)
+	}
+	if got := orders.get(cancelled.ID); got.Status != domain.OrderStatusCancelled || got.ShipmentStage != domain.ShipmentStageShipped {
+		t.Fatalf("orden cancelada: status %s, etapa %s", got.Status, got.ShipmentStage)
+	}
+
+	// una etapa que no avanza no vuelve a avisar
+	if err := uc.Advance(ctx, paid, domain.ShipmentStageShipped, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := n.list()
+	if len(sent) != 1 || sent[0] != paid.Code()+" "+domain.ShipmentStageShipped {
+		t.Fatalf("avisos = %v", sent)
+	}
+}
+
+func TestPollAdvancesThroughFakeCarrier(t *testing.T) {
+	ctx := context.Background()
+	carrier := fake.New()
+	carrier.Step = time.Hour
+	o := &domain.Order{ID: uuid.New(), Status: domain.OrderStatusInPrint, ShippingMethod: domain.ShippingCourier, Carrier: "fake", TrackingNumber: "FAKEABC123"}
+	orders := newMemOrderRepo(o)
+	uc, n := newTrackingUC(orders, carrier)
+
+	// primera consulta: el correo de prueba recién lo recibe
+	res, err := uc.Poll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Checked != 1 || res.Updated != 1 {
+		t.Fatalf("primer poll = %+v", res)
+	}
+	if got := orders.get(o.ID); got.Status != domain.OrderStatusShipped || got.ShipmentStage != domain.ShipmentStageShipped {
+		t.Fatalf("después del primer poll: status %s, etapa %s", got.Status, got.ShipmentStage)
+	}
+
+	// sin novedades no hay cambios ni avisos
+	if res, err := uc.Poll(ctx); err != nil || res.Updated != 0 {
+		t.Fatalf("poll sin novedades = %+v, %v", res, err)
+	}
+
+	// pasa el tiempo: el correo informa distribución y entrega juntas
+	carrier.Step = 0
+	if res, err := uc.Poll(ctx); err != nil || res.Updated != 1 {
+		t.Fatalf("poll de entrega = %+v, %v", res, err)
+	}
+	if got := orders.get(o.ID); got.Status != domain.OrderStatusShipped || got.ShipmentStage != domain.ShipmentStageDelivered {
+		t.Fatalf("después de la entrega: status %s, etapa %s", got.Status, got.ShipmentStage)
+	}
+	events, _ := uc.OrderEvents(ctx, o.ID)
+	if len(events) != 3 {
+		t.Fatalf("eventos guardados = %d", len(events))
+	}
+
+	// entregada: deja de consultarse
+	if res, err := uc.Poll(ctx); err != nil || res.Checked != 0 {
+		t.Fatalf("poll después de entregada = %+v, %v", res, err)
+	}
+
+	want := []string{o.Code() + " " + domain.ShipmentStageShipped, o.Code() + " " + domain.ShipmentStageDelivered}
+	if sent := n.list(); len(sent) != 2 || sent[0] != want[0] || sent[1] != want[1] {
+		t.Fatalf("avisos = %v; want %v", sent, want)
+	}
+}
@@ -0,0 +1,196 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// ErrTransferNotPending: la orden no está esperando una transferencia.
+var ErrTransferNotPending = errors.New("la orden no tiene una transferencia pendiente")
+
+// ErrProofReviewed: el comprobante ya fue aprobado o rechazado.
+var ErrProofReviewed = errors.New("el comprobante ya fue revisado")
+
+// TransferUC maneja los comprobantes de transferencia: el cliente los sube desde /pay/{id} y el
+// admin los aprueba (registra el pago) o los rechaza con un motivo.
+type TransferUC struct {
+	Orders   *OrderUC
+	Proofs   domain.TransferProofRepo
+	Payments *PaymentUC
+	Storage  domain.FileStorage
+	Notifier domain.TransferNotifier
+}
+
+// AwaitingTransfer indica si la orden espera una transferencia (y por lo tanto acepta comprobantes).
+func AwaitingTransfer(o *domain.Order) bool {
+	return o.MPStatus == "transferencia_pending" && CanTransition(o.Status, domain.OrderStatusFinished)
+}
+
+// Submit guarda el comprobante. Si ya había uno pendiente de revisar, lo reemplaza.
+func (uc *TransferUC) Submit(ctx context.Context, orderID uuid.UUID, filename, contentType string, data []byte) (*domain.TransferProof, error) {
+	o, err := uc.Orders.Orders.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !AwaitingTransfer(o) {
+		return nil, ErrTransferNotPending
+	}
+	list, err := uc.Proofs.ListByOrder(ctx, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	p := &domain.TransferProof{OrderID: o.ID, Status: domain.TransferProofPending}
+	for i := range list {
+		if list[i].Status == domain.TransferProofPending {
+			p = &list[i]
+			break
+		}
+	}
+	path, err := uc.Storage.SaveAttachment(ctx, filename, data)
+	if err != nil {
+		return nil, fmt.Errorf("guardar comprobante: %w", err)
+	}
+	old := p.Path
+	p.Path, p.Filename, p.ContentType, p.Size = path, filename, contentType, int64(len(data))
+	replaced, err := uc.saveSubmitted(ctx, p)
+	if err != nil {
+		_ = uc.Storage.Delete(ctx, path)
+		return nil, err
+	}
+	if old != "" && replaced {
+		if err := uc.Storage.Delete(ctx, old); err != nil {
+			log.Warn().Err(err).Str("path", old).Msg("borrar comprobante reemplazado")
+		}
+	}
+	return p, nil
+}
+
+// saveSubmitted guarda el comprobante subido; true = reemplazó a uno pendiente. Si el admin revisó
+// ese comprobante mientras tanto, no lo pisa: guarda el nuevo aparte.
+func (uc *TransferUC) saveSubmitted(ctx context.Context, p *domain.TransferProof) (bool, error) {
+	if p.ID == uuid.Nil {
+		return false, uc.Proofs.Save(ctx, p)
+	}
+	ok, err := uc.Proofs.SaveIfPending(ctx, p)
+	if err != nil || ok {
+		return ok, err
+	}
+	*p = domain.TransferProof{OrderID: p.OrderID, Status: domain.TransferProofPending, Path: p.Path, Filename: p.Filename, ContentType: p.ContentType, Size: p.Size}
+	return false, uc.Proofs.Save(ctx, p)
+}
+
+// Latest devuelve el último comprobante de la orden, o nil si no subió ninguno.
+func (uc *TransferUC) Latest(ctx context.Context, orderID uuid.UUID) (*domain.TransferProof, error) {
+	list, err := uc.Proofs.ListByOrder(ctx, orderID)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// Pending es la cola de comprobantes a verificar.
+func (uc *TransferUC) Pending(ctx context.Context) ([]domain.TransferProof, error) {
+	return uc.Proofs.ListByStatus(ctx, domain.TransferProofPending)
+}
+
+func (uc *TransferUC) pendingProof(ctx context.Context, proofID uuid.UUID) (*domain.TransferProof, *domain.Order, error) {
+	p, err := uc.Proofs.FindByID(ctx, proofID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.Status != domain.TransferProofPending {
+		return nil, nil, ErrProofReviewed
+	}
+	o, err := uc.Orders.Orders.FindByID(ctx, p.OrderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, o, nil
+}
+
+// Approve registra la transferencia como pago de la orden (amount 0 = todo el saldo) y marca el
+// comprobante como aprobado. Devuelve la orden y su saldo: si quedó paga, quien llama aplica los
+// efectos de un pago confirmado (cupón, aviso, estado).
+func (uc *TransferUC) Approve(ctx context.Context, proofID uuid.UUID, amount float64, actor string) (*domain.Order, domain.OrderBalance, error) {
+	p, o, err := uc.pendingProof(ctx, proofID)
+	if err != nil {
+		return nil, domain.OrderBalance{}, err
+	}
+	if !AwaitingTransfer(o) {
+		return nil, domain.OrderBalance{}, ErrTransferNotPending
+	}
+	b, _, err := uc.Payments.Balance(ctx, o)
+	if err != nil {
+		return nil, b, err
+	}
+	amount = round2(amount)
+	if amount < 0 {
+		return nil, b, errors.New("monto inválido")
+	}
+	if amount == 0 {
+		amount = b.Due
+	}
+	if amount > b.Due+0.009 {
+		return nil, b, fmt.Errorf("el monto supera el saldo (%.2f)", b.Due)
+	}
+	// el comprobante se marca antes de registrar el pago: si otro lo aprobó en el medio no se cobra dos veces
+	now := time.Now()
+	p.Status, p.ReviewedBy, p.ReviewedAt = domain.TransferProofApproved, actor, &now
+	ok, err := uc.Proofs.SaveIfPending(ctx, p)
+	if err != nil {
+		return nil, b, err
+	}
+	if !ok {
+		return nil, b, ErrProofReviewed
+	}
+	if amount > 0 {
+		if _, err := uc.Payments.RecordManual(ctx, o, domain.PaymentProviderTransfer, amount, actor, "comprobante de la orden "+o.Code()); err != nil {
+			p.Status, p.ReviewedBy, p.ReviewedAt = domain.TransferProofPending, "", nil
+			if err := uc.Proofs.Save(ctx, p); err != nil {
+				log.Error().Err(err).Str("proof_id", p.ID.String()).Msg("volver a pendiente el comprobante")
+			}
+			return nil, b, err
+		}
+	}
+	b, _, err = uc.Payments.Balance(ctx, o)
+	return o, b, err
+}
+
+// Reject marca el comprobante como rechazado y le avisa al cliente el motivo. La orden sigue
+// esperando la transferencia, así que puede subir otro.
+func (uc *TransferUC) Reject(ctx context.Context, proofID uuid.UUID, reason, actor string) (*domain.TransferProof, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("falta el motivo del rechazo")
+	}
+	if r := []rune(reason); len(r) > 255 {
+		reason = string(r[:255])
+	}
+	p, o, err := uc.pendingProof(ctx, proofID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	p.Status, p.Reason, p.ReviewedBy, p.ReviewedAt = domain.TransferProofRejected, reason, actor, &now
+	ok, err := uc.Proofs.SaveIfPending(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrProofReviewed
+	}
+	if uc.Notifier != nil {
+		if err := uc.Notifier.NotifyTransferRejected(ctx, o, reason); err != nil {
+			log.Error().Err(err).Str("order_id", o.ID.String()).Msg("avisar comprobante rechazado")
+		}
+	}
+	return p, nil
+}
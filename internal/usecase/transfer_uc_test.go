@@ -0,0 +1,117 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+type memProofRepo struct {
+	mu     sync.Mutex
+	proofs map[uuid.UUID]domain.TransferProof
+}
+
+func (r *memProofRepo) Save(ctx context.Context, p *domain.TransferProof) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	r.proofs[p.ID] = *p
+	return nil
+}
+
+func (r *memProofRepo) SaveIfPending(ctx context.Context, p *domain.TransferProof) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cur, ok := r.proofs[p.ID]; !ok || cur.Status != domain.TransferProofPending {
+		return false, nil
+	}
+	r.proofs[p.ID] = *p
+	return true, nil
+}
+
+func (r *memProofRepo) FindByID(ctx context.Context, id uuid.UUID) (*domain.TransferProof, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.proofs[id]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return &p, nil
+}
+
+func (r *memProofRepo) ListByStatus(ctx context.Context, status string) ([]domain.TransferProof, error) {
+	return nil, nil
+}
+
+func (r *memProofRepo) ListByOrder(ctx context.Context, orderID uuid.UUID) ([]domain.TransferProof, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.TransferProof
+	for _, p := range r.proofs {
+		if p.OrderID == orderID {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// staleProofRepo devuelve siempre el comprobante como estaba al leerlo: es el segundo clic que
+// leyó antes de que el primero lo guardara.
+type staleProofRepo struct {
+	*memProofRepo
+	snap domain.TransferProof
+}
+
+func (r staleProofRepo) FindByID(ctx context.Context, id uuid.UUID) (*domain.TransferProof, error) {
+	p := r.snap
+	return &p, nil
+}
+
+// blindPaymentRepo no ve los pagos ya registrados: el saldo que calculó el segundo clic.
+type blindPaymentRepo struct{ *memPaymentRepo }
+
+func (blindPaymentRepo) ListByOrders(ctx context.Context, orderIDs []uuid.UUID) ([]domain.Payment, error) {
+	return nil, nil
+}
+
+func TestApproveTransferTwiceRecordsOnePayment(t *testing.T) {
+	ctx := context.Background()
+	o := &domain.Order{ID: uuid.New(), Number: 7, Status: domain.OrderStatusAwaitingPay, MPStatus: "transferencia_pending", Total: 15000}
+	orders := newMemOrderRepo(o)
+	proof := domain.TransferProof{ID: uuid.New(), OrderID: o.ID, Status: domain.TransferProofPending}
+	proofs := &memProofRepo{proofs: map[uuid.UUID]domain.TransferProof{proof.ID: proof}}
+	payments := &memPaymentRepo{}
+	uc := &TransferUC{Orders: &OrderUC{Orders: orders}, Proofs: proofs, Payments: &PaymentUC{Payments: payments}}
+
+	_, b, err := uc.Approve(ctx, proof.ID, 0, "admin@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Settled() {
+		t.Fatalf("saldo después de aprobar = %+v", b)
+	}
+	uc.Proofs = staleProofRepo{memProofRepo: proofs, snap: proof}
+	uc.Payments = &PaymentUC{Payments: blindPaymentRepo{payments}}
+	if _, _, err := uc.Approve(ctx, proof.ID, 0, "admin@example.com"); !errors.Is(err, ErrProofReviewed) {
+		t.Fatalf("segunda aprobación: err = %v", err)
+	}
+	if len(payments.list) != 1 {
+		t.Fatalf("pagos registrados = %d", len(payments.list))
+	}
+	if got := payments.list[0]; got.Amount != 15000 || got.Note != "comprobante de la orden "+o.Code() {
+		t.Fatalf("pago = %+v", got)
+	}
+	if _, err := uc.Reject(ctx, proof.ID, "no llegó", "admin@example.com"); !errors.Is(err, ErrProofReviewed) {
+		t.Fatalf("rechazar un comprobante aprobado: err = %v", err)
+	}
+	if got, _ := proofs.FindByID(ctx, proof.ID); got.Status != domain.TransferProofApproved {
+		t.Fatalf("comprobante = %s", got.Status)
+	}
+}
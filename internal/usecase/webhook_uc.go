@@ -0,0 +1,186 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/phenrril/tienda3d/internal/domain"
+)
+
+// ErrWebhookSkip: el evento no tiene nada que procesar y no vale la pena reintentarlo. Los
+// handlers lo envuelven con el motivo (fmt.Errorf("%w: ...", ErrWebhookSkip)).
+var ErrWebhookSkip = errors.New("evento descartado")
+
+// ErrWebhookNotReplayable: el evento está pendiente o fue rechazado por firma inválida.
+var ErrWebhookNotReplayable = errors.New("el evento no se puede reprocesar")
+
+// WebhookHandler procesa un evento de una fuente. Puede dejar en e.Note qué hizo.
+type WebhookHandler func(ctx context.Context, e *domain.WebhookEvent) error
+
+// WebhookUC es la bandeja de webhooks: Receive guarda el evento y responde enseguida; ProcessDue
+// (el job de app) lo procesa después con el handler de su fuente y reintenta con espera creciente
+// hasta MaxAttempts.
+type WebhookUC struct {
+	Events      domain.WebhookEventRepo
+	MaxAttempts int
+
+	mu       sync.RWMutex
+	handlers map[string]WebhookHandler
+	wake     chan struct{}
+}
+
+func NewWebhookUC(events domain.WebhookEventRepo, maxAttempts int) *WebhookUC {
+	if maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+	return &WebhookUC{Events: events, MaxAttempts: maxAttempts, handlers: map[string]WebhookHandler{}, wake: make(chan struct{}, 1)}
+}
+
+// Handle registra el handler de una fuente (domain.WebhookSource*).
+func (uc *WebhookUC) Handle(source string, h WebhookHandler) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.handlers[source] = h
+}
+
+func (uc *WebhookUC) handler(source string) WebhookHandler {
+	uc.mu.RLock()
+	defer uc.mu.RUnlock()
+	return uc.handlers[source]
+}
+
+// Wake avisa cuando entró algo para procesar, para no esperar al próximo tick.
+func (uc *WebhookUC) Wake() <-chan struct{} { return uc.wake }
+
+func (uc *WebhookUC) kick() {
+	select {
+	case uc.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Receive guarda el evento. Devuelve false si ya había llegado (el proveedor lo reenvió).
+func (uc *WebhookUC) Receive(ctx context.Context, e *domain.WebhookEvent) (bool, error) {
+	now := time.Now()
+	e.Status, e.NextAttemptAt = domain.WebhookPending, &now
+	if e.Signature == domain.WebhookSignatureInvalid {
+		e.Status, e.NextAttemptAt = domain.WebhookRejected, nil
+	}
+	created, err := uc.Events.Create(ctx, e)
+	if err != nil {
+		return false, err
+	}
+	if created && e.Status == domain.WebhookPending {
+		uc.kick()
+	}
+	return created, nil
+}
+
+// WebhookRunResult resume una pasada de ProcessDue.
+type WebhookRunResult struct {
+	Processed int
+	Retrying  int
+	Failed    int
+}
+
+// ProcessDue procesa los eventos pendientes y los reintentos vencidos.
+func (uc *WebhookUC) ProcessDue(ctx context.Context, now time.Time) (WebhookRunResult, error) {
+	var res WebhookRunResult
+	list, err := uc.Events.ListDue(ctx, now, 50)
+	if err != nil {
+		return res, err
+	}
+	for i := range list {
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+		e := &list[i]
+		uc.process(ctx, e, now)
+		switch e.Status {
+		case domain.WebhookRetrying:
+			res.Retrying++
+		case domain.WebhookFailed:
+			res.Failed++
+		default:
+			res.Processed++
+		}
+	}
+	return res, nil
+}
+
+func (uc *WebhookUC) process(ctx context.Context, e *domain.WebhookEvent, now time.Time) {
+	e.Attempts++
+	err := uc.run(ctx, e)
+	e.NextAttemptAt = nil
+	switch {
+	case err == nil:
+		e.Status, e.LastError, e.ProcessedAt = domain.WebhookProcessed, "", &now
+	case errors.Is(err, ErrWebhookSkip):
+		e.Status, e.LastError, e.ProcessedAt = domain.WebhookSkipped, truncateRunes(err.Error(), 500), &now
+	case e.Attempts >= uc.MaxAttempts:
+		e.Status, e.LastError = domain.WebhookFailed, truncateRunes(err.Error(), 500)
+		log.Error().Err(err).Str("source", e.Source).Str("event_id", e.EventID).Int("intentos", e.Attempts).Msg("webhook agotó los reintentos")
+	default:
+		next := now.Add(webhookBackoff(e.Attempts))
+		e.Status, e.LastError, e.NextAttemptAt = domain.WebhookRetrying, truncateRunes(err.Error(), 500), &next
+		log.Warn().Err(err).Str("source", e.Source).Str("event_id", e.EventID).Int("intento", e.Attempts).Time("reintento", next).Msg("webhook falló")
+	}
+	e.Note = truncateRunes(e.Note, 255)
+	if err := uc.Events.Save(ctx, e); err != nil {
+		log.Error().Err(err).Str("event", e.ID.String()).Msg("guardar evento de webhook")
+	}
+}
+
+// run llama al handler; un panic cuenta como un intento fallido.
+func (uc *WebhookUC) run(ctx context.Context, e *domain.WebhookEvent) (err error) {
+	h := uc.handler(e.Source)
+	if h == nil {
+		return fmt.Errorf("sin handler para %q", e.Source)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(ctx, e)
+}
+
+// webhookBackoff: 30s, 1m, 2m, 4m... hasta 1h.
+func webhookBackoff(attempt int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempt && d < time.Hour; i++ {
+		d *= 2
+	}
+	return min(d, time.Hour)
+}
+
+// Replay vuelve a encolar un evento procesado, descartado o fallido, con los intentos en cero.
+func (uc *WebhookUC) Replay(ctx context.Context, id uuid.UUID) (*domain.WebhookEvent, error) {
+	e, err := uc.Events.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !e.Replayable() {
+		return nil, ErrWebhookNotReplayable
+	}
+	now := time.Now()
+	e.Status, e.Attempts, e.NextAttemptAt, e.LastError = domain.WebhookPending, 0, &now, ""
+	if err := uc.Events.Save(ctx, e); err != nil {
+		return nil, err
+	}
+	uc.kick()
+	return e, nil
+}
+
+func truncateRunes(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
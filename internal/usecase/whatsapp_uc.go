@@ -75,6 +75,7 @@ func (uc *WhatsAppUC) ProcessWhatsAppOrder(ctx context.Context, whatsappOrder *d
 	if err := uc.Orders.Save(ctx, order); err != nil {
 		return fmt.Errorf("error guardando orden: %w", err)
 	}
+	recordStatusChange(ctx, uc.Orders, order.ID, "", order.Status, StatusChange{Actor: whatsappOrder.CustomerInfo.Phone, Source: domain.StatusSourceWhatsApp})
 
 	// Actualizar el estado de la orden de WhatsApp
 	now := time.Now()
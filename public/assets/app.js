@@ -631,18 +631,88 @@ function formatPrice(num) {
   const provinceSelect=document.getElementById('provinceSelect');
   const shipCostEl=document.getElementById('shipCost');
   const subtotalEl=document.getElementById('subtotalVal');
-  const discountEl=document.getElementById('discount');
-  const discountRow=document.getElementById('discountRow');
   const finalTotalEl=document.getElementById('finalTotal');
   if(!subtotalEl || !finalTotalEl || !shipCostEl) return;
   const base=parseFloat((subtotalEl.textContent || '').replace(/[^0-9.,]/g,'').replace(',','.'))||0;
-  const CADETE_COST=5000;
-  const COSTS=(()=>{ const m={}; document.querySelectorAll('#pcData [data-prov]').forEach(n=>{ const k=n.getAttribute('data-prov'); const v=parseFloat(n.getAttribute('data-cost')||'0'); if(k){ m[k]=v; } }); return m; })();
   const phone = form.querySelector('input[name="phone"]');
   const addrCadete = form.querySelector('input[name="address_cadete"]');
   const addrEnvio = form.querySelector('input[name="address_envio"]');
   const postal = form.querySelector('input[name="postal_code"]');
+  const postalCadete = form.querySelector('input[name="postal_code_cadete"]');
   const dni = form.querySelector('input[name="dni"]');
+  const quoteMsg = document.getElementById('shipQuoteMsg');
+  // Costo de envío cotizado por el servidor (zonas y tarifas por peso); lo leen también los totales del resumen
+  window.shippingQuoteCost = 0;
+  let quoteKey = '', quoteTimer = null;
+  // Opciones de los correos (Andreani, Correo Argentino...): si elige una, reemplaza la tarifa por zona
+  const carrierBox = document.getElementById('carrierOptions');
+  let zoneCost = 0, carrierKey = '';
+  function applyCarrier(){
+    const sel = carrierBox ? carrierBox.querySelector('input[name="carrier_option"]:checked') : null;
+    window.shippingQuoteCost = (sel && sel.value) ? (parseFloat(sel.dataset.cost)||0) : zoneCost;
+  }
+  function requestCarriers(method){
+    if(!carrierBox) return;
+    const pc = postal ? postal.value.trim() : '';
+    const prov = provinceSelect ? provinceSelect.value : '';
+    const key = method==='envio' && pc.length>=4 ? pc+'|'+prov : '';
+    if(key===carrierKey) return;
+    carrierKey = key;
+    carrierBox.innerHTML = '';
+    applyCarrier();
+    if(!key) return;
+    carrierBox.textContent = 'Consultando correos...';
+    fetch('/api/shipping/carriers?province='+encodeURIComponent(prov)+'&postal_code='+encodeURIComponent(pc))
+      .then(res=>res.json())
+      .then(data=>{
+        if(key!==carrierKey) return;
+        carrierBox.innerHTML = '';
+        const opts = data.options || [];
+        if(!opts.length) return;
+        const addOpt = (value, text, cost, checked)=>{
+          const label = document.createElement('label');
+          label.className = 'checkout-carrier-option';
+          const input = document.createElement('input');
+          input.type = 'radio'; input.name = 'carrier_option'; input.value = value; input.checked = checked;
+          input.dataset.cost = cost;
+          input.addEventListener('change', ()=>{ applyCarrier(); calcCost(); document.dispatchEvent(new CustomEvent('shippingquote')); });
+          label.appendChild(input);
+          label.appendChild(document.createTextNode(' '+text));
+          carrierBox.appendChild(label);
+        };
+        addOpt('', 'Tarifa por zona', zoneCost, true);
+        opts.forEach(o=>{
+          const days = o.days_max ? ' · '+(o.days_min && o.days_min!==o.days_max ? o.days_min+'-' : '')+o.days_max+' días hábiles' : '';
+          addOpt(o.option, o.carrier+' '+o.service+' — $'+formatPrice(o.cost)+days, o.cost, false);
+        });
+      })
+      .catch(()=>{ carrierKey=''; carrierBox.innerHTML=''; });
+  }
+  function requestQuote(method){
+    const prov = provinceSelect ? provinceSelect.value : '';
+    const pc = method==='cadete' ? (postalCadete ? postalCadete.value.trim() : '') : (postal ? postal.value.trim() : '');
+    const key = method+'|'+prov+'|'+pc;
+    if(key===quoteKey) return;
+    quoteKey = key;
+    if(method!=='envio' && method!=='cadete'){ zoneCost=0; applyCarrier(); if(quoteMsg) quoteMsg.style.display='none'; return; }
+    if(method==='envio' && !prov){ zoneCost=0; applyCarrier(); if(quoteMsg) quoteMsg.style.display='none'; return; }
+    fetch('/api/shipping/quote?method='+encodeURIComponent(method)+'&province='+encodeURIComponent(prov)+'&postal_code='+encodeURIComponent(pc))
+      .then(res=>res.json())
+      .then(data=>{
+        if(key!==quoteKey) return;
+        zoneCost = data.available ? (data.cost||0) : 0;
+        window.shippingQuoteCost = zoneCost;
+        applyCarrier();
+        if(quoteMsg){
+          const msg = !data.available ? (data.message||'Envío no disponible') : (data.free ? '¡Tu pedido tiene envío gratis!' : '');
+          quoteMsg.textContent = msg;
+          quoteMsg.style.display = msg ? 'block' : 'none';
+        }
+        calcCost();
+        document.dispatchEvent(new CustomEvent('shippingquote'));
+      })
+      .catch(()=>{ quoteKey=''; });
+  }
   function setRequired(el,flag){ if(!el) return; if(flag){el.setAttribute('required','required')} else {el.removeAttribute('required')} }
   function updateRadioBorder(elements){
     elements.forEach(el=>{
@@ -652,7 +722,6 @@ function formatPrice(num) {
   }
   function calcCost(){
     let method='retiro'; shipRadios.forEach(r=>{ if(r.checked) method=r.value });
-    let paymentMethod='efectivo'; paymentRadios.forEach(r=>{ if(r.checked) paymentMethod=r.value });
     updateRadioBorder(shipRadios);
     updateRadioBorder(paymentRadios);
     if(envioGroup) envioGroup.style.display='none'; if(cadeteGroup) cadeteGroup.style.display='none';
@@ -661,30 +730,23 @@ function formatPrice(num) {
     if(method==='envio'){
       if(envioGroup) envioGroup.style.display='flex';
       setRequired(phone,true); setRequired(addrEnvio,true); setRequired(provinceSelect,true); setRequired(postal,true); setRequired(dni,true);
-      const prov=provinceSelect?provinceSelect.value:''; if(prov && COSTS[prov]!=null){cost=COSTS[prov];}
+      cost=window.shippingQuoteCost||0;
     } else if(method==='cadete') {
       if(cadeteGroup) cadeteGroup.style.display='flex';
       setRequired(phone,true); setRequired(addrCadete,true);
-      cost=CADETE_COST;
+      cost=window.shippingQuoteCost||0;
     }
+    requestQuote(method);
+    requestCarriers(method);
     if(shipCostEl) shipCostEl.textContent='$'+formatPrice(cost);
-    const withShip=(base+cost);
-    const discount=(paymentMethod==='transferencia'? withShip*0.1 : 0);
-    if(discountRow){
-      if(discount>0){
-        discountRow.style.display='flex';
-        if(discountEl) discountEl.textContent='-$'+formatPrice(discount);
-      } else {
-        discountRow.style.display='none';
-        if(discountEl) discountEl.textContent='$0';
-      }
-    }
-    const final=(withShip-discount);
-    if(finalTotalEl) finalTotalEl.textContent='$'+formatPrice(final);
+    if(finalTotalEl) finalTotalEl.textContent='$'+formatPrice(base+cost);
+    // Cupón y ajuste del medio de pago los suma el resumen (updateTotals)
+    document.dispatchEvent(new CustomEvent('checkouttotals'));
   }
   shipRadios.forEach(r=>r.addEventListener('change',calcCost));
   paymentRadios.forEach(r=>r.addEventListener('change',calcCost));
   provinceSelect && provinceSelect.addEventListener('change',calcCost);
+  [postal, postalCadete].forEach(el=>{ if(el) el.addEventListener('input',()=>{ clearTimeout(quoteTimer); quoteTimer=setTimeout(calcCost,400); }); });
   calcCost();
   
   // Popup de confirmación de compra
@@ -2425,8 +2487,6 @@ if ('serviceWorker' in navigator) {
   
   // ============ CÁLCULO DE TOTALES ============
   function updateTotals() {
-    const pcData = document.getElementById('pcData');
-    const provinceSelect = document.getElementById('provinceSelect');
     const shippingMethod = document.querySelector('input[name="shipping"]:checked');
     const paymentMethod = document.querySelector('input[name="payment_method"]:checked');
     
@@ -2440,16 +2500,8 @@ if ('serviceWorker' in navigator) {
     
     // Calcular costo de envío
     let shipCost = 0;
-    if(shippingMethod) {
-      if(shippingMethod.value === 'cadete') {
-        shipCost = 5000;
-      } else if(shippingMethod.value === 'envio' && provinceSelect && pcData) {
-        const province = provinceSelect.value;
-        const provinceCostEl = pcData.querySelector(`[data-prov="${province}"]`);
-        if(provinceCostEl) {
-          shipCost = parseFloat(provinceCostEl.getAttribute('data-cost')) || 0;
-        }
-      }
+    if(shippingMethod && (shippingMethod.value === 'cadete' || shippingMethod.value === 'envio')) {
+      shipCost = window.shippingQuoteCost || 0;
     }
     
     // Obtener descuento del cupón (si está validado)
@@ -2465,8 +2517,25 @@ if ('serviceWorker' in navigator) {
       if(stickyDiscountRow) stickyDiscountRow.style.display = 'none';
     }
     
+    // Descuento o recargo del medio de pago (reglas de /admin/pagos), sobre el total con cupón
+    const payPct = paymentMethod ? (parseFloat(paymentMethod.dataset.adjustPct) || 0) : 0;
+    const payAdjust = Math.round((subtotal + shipCost - discount) * payPct) / 100;
+    const payLabel = payAdjust !== 0 ? paymentMethod.dataset.adjustLabel || 'Medio de pago' : 'Medio de pago';
+    ['payAdjustRow', 'stickyPayAdjustRow'].forEach(id => {
+      const row = document.getElementById(id);
+      if(row) row.style.display = payAdjust !== 0 ? 'flex' : 'none';
+    });
+    ['payAdjustLabel', 'stickyPayAdjustLabel'].forEach(id => {
+      const el = document.getElementById(id);
+      if(el) el.textContent = payLabel;
+    });
+    ['payAdjust', 'stickyPayAdjust'].forEach(id => {
+      const el = document.getElementById(id);
+      if(el) el.textContent = (payAdjust < 0 ? '-$' : '$') + formatPrice(Math.abs(payAdjust));
+    });
+    
     // Calcular total final
-    const total = subtotal + shipCost - discount;
+    const total = subtotal + shipCost - discount + payAdjust;
     
     // Actualizar todos los displays
     const updateDisplay = (id, value) => {
@@ -2490,6 +2559,9 @@ if ('serviceWorker' in navigator) {
   if(provinceSelect) {
     provinceSelect.addEventListener('change', updateTotals);
   }
+  // Recalcular cuando llega la cotización del envío
+  document.addEventListener('shippingquote', updateTotals);
+  document.addEventListener('checkouttotals', updateTotals);
   
   // Calcular totales al cargar
   updateTotals();
@@ -2538,20 +2610,8 @@ if ('serviceWorker' in navigator) {
       // Calcular costo de envío
       const shippingMethod = document.querySelector('input[name="shipping"]:checked');
       let shipCost = 0;
-      if(shippingMethod) {
-        if(shippingMethod.value === 'cadete') {
-          shipCost = 5000;
-        } else if(shippingMethod.value === 'envio') {
-          const provinceSelect = document.getElementById('provinceSelect');
-          const pcData = document.getElementById('pcData');
-          if(provinceSelect && pcData) {
-            const province = provinceSelect.value;
-            const provinceCostEl = pcData.querySelector(`[data-prov="${province}"]`);
-            if(provinceCostEl) {
-              shipCost = parseFloat(provinceCostEl.getAttribute('data-cost')) || 0;
-            }
-          }
-        }
+      if(shippingMethod && (shippingMethod.value === 'cadete' || shippingMethod.value === 'envio')) {
+        shipCost = window.shippingQuoteCost || 0;
       }
       
       const totalWithShip = subtotal + shipCost;
@@ -0,0 +1,119 @@
+//go:build ignore
+// +build ignore
+
+// Impresora térmica de mentira para probar los tickets sin gastar papel: escucha en el puerto raw,
+// decodifica lo que llega, lo muestra y verifica que el ticket esté bien formado.
+//
+//	go run tools/escpos_listener.go -addr :9100 -width 48
+//	TICKET_PRINTER_ADDR=localhost:9100 go run ./cmd/tienda3d
+//
+// Con -once termina después del primer ticket (exit 1 si falla alguna verificación), útil en scripts:
+//
+//	go run tools/escpos_listener.go -once -expect "Pedido taller" -expect SALDO
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/phenrril/tienda3d/internal/adapters/escpos"
+)
+
+type expectations []string
+
+func (e *expectations) String() string     { return strings.Join(*e, ", ") }
+func (e *expectations) Set(v string) error { *e = append(*e, v); return nil }
+
+func main() {
+	addr := flag.String("addr", ":9100", "dirección donde escuchar")
+	width := flag.Int("width", escpos.Width80mm, "caracteres por línea del papel")
+	once := flag.Bool("once", false, "terminar después del primer ticket")
+	raw := flag.Bool("raw", false, "mostrar también los bytes recibidos en hexa")
+	var expect expectations
+	flag.Var(&expect, "expect", "texto que el ticket tiene que contener (se puede repetir)")
+	flag.Parse()
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("esperando tickets en %s (ancho %d)", ln.Addr(), *width)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+		data, err := io.ReadAll(conn)
+		conn.Close()
+		if err != nil {
+			log.Printf("leer ticket: %v", err)
+			continue
+		}
+		ok := check(data, *width, expect, *raw)
+		if *once {
+			if !ok {
+				os.Exit(1)
+			}
+			return
+		}
+	}
+}
+
+func check(data []byte, width int, expect []string, raw bool) bool {
+	fmt.Printf("\n===== ticket recibido: %d bytes =====\n", len(data))
+	if raw {
+		fmt.Printf("% x\n", data)
+	}
+	d, err := escpos.Decode(data)
+	if err != nil {
+		fmt.Println("FALLA:", err)
+		return false
+	}
+	for _, l := range d.Lines {
+		text := l.Text
+		if l.Align == escpos.AlignCenter && text != "" {
+			max := width
+			if l.Double {
+				max /= 2
+			}
+			if pad := (max - len([]rune(text))) / 2; pad > 0 {
+				text = strings.Repeat(" ", pad) + text
+			}
+		}
+		mark := "  "
+		if l.Bold {
+			mark = "* "
+		}
+		if l.Double {
+			mark = "##"
+		}
+		fmt.Println(mark + "|" + text)
+	}
+	ok := true
+	if err := d.Check(width); err != nil {
+		fmt.Println("FALLA:", err)
+		ok = false
+	}
+	if d.CodePage != 19 {
+		fmt.Printf("FALLA: página de códigos %d (se espera 19, PC858)\n", d.CodePage)
+		ok = false
+	}
+	text := d.Text()
+	for _, e := range expect {
+		if !strings.Contains(text, e) {
+			fmt.Printf("FALLA: no aparece %q\n", e)
+			ok = false
+		}
+	}
+	if ok {
+		fmt.Println("OK")
+	}
+	return ok
+}